@@ -26,13 +26,22 @@ import (
 	"github.com/interline-io/transitland-server/server/auth/authn"
 	"github.com/interline-io/transitland-server/server/auth/mw/usercheck"
 	"github.com/interline-io/transitland-server/server/dbutil"
+	"github.com/interline-io/transitland-server/server/directions"
 	"github.com/interline-io/transitland-server/server/meters"
 	localmeter "github.com/interline-io/transitland-server/server/meters/local"
 
+	"github.com/interline-io/transitland-server/server/finders/actions"
 	"github.com/interline-io/transitland-server/server/finders/dbfinder"
 	"github.com/interline-io/transitland-server/server/finders/gbfsfinder"
 	"github.com/interline-io/transitland-server/server/finders/rtfinder"
 	"github.com/interline-io/transitland-server/server/gql"
+	"github.com/interline-io/transitland-server/server/jobs"
+	"github.com/interline-io/transitland-server/server/jobs/gbfssnapshot"
+	localjobs "github.com/interline-io/transitland-server/server/jobs/local"
+	"github.com/interline-io/transitland-server/server/jobs/poller"
+	"github.com/interline-io/transitland-server/server/jobs/stopobs"
+	"github.com/interline-io/transitland-server/server/metrics"
+	localmetrics "github.com/interline-io/transitland-server/server/metrics/local"
 	"github.com/interline-io/transitland-server/server/model"
 	"github.com/interline-io/transitland-server/server/playground"
 	"github.com/interline-io/transitland-server/server/rest"
@@ -45,7 +54,9 @@ import (
 
 	// Import routers
 	_ "github.com/interline-io/transitland-server/server/directions/awsrouter"
+	_ "github.com/interline-io/transitland-server/server/directions/bikeshare"
 	_ "github.com/interline-io/transitland-server/server/directions/linerouter"
+	_ "github.com/interline-io/transitland-server/server/directions/raptor"
 	_ "github.com/interline-io/transitland-server/server/directions/tlrouter"
 	_ "github.com/interline-io/transitland-server/server/directions/valhalla"
 )
@@ -92,10 +103,23 @@ type ServerCommand struct {
 	SecretsFile             string
 	Storage                 string
 	RTStorage               string
+	RTArchive               string
+	RTArchiveRetention      int
+	RTBlockDelays           bool
+	StopObservations        bool
+	PollRealtime            bool
+	PollInterval            int
+	PollGbfsInterval        int
+	PollMaxBackoff          int
+	PollRefreshInterval     int
+	PollWorkers             int
+	GbfsSnapshotInterval    int
 	DBURL                   string
 	RedisURL                string
 	MaxRadius               float64
+	AllowedOrigins          []string
 	secrets                 []dmfr.Secret
+	directionsConfig        directions.Config
 }
 
 func (cmd *ServerCommand) HelpDesc() (string, string) {
@@ -111,6 +135,17 @@ func (cmd *ServerCommand) AddFlags(fl *pflag.FlagSet) {
 	fl.StringVar(&cmd.RedisURL, "redisurl", "", "Redis URL (default: $TL_REDIS_URL)")
 	fl.StringVar(&cmd.Storage, "storage", "", "Static storage backend")
 	fl.StringVar(&cmd.RTStorage, "rt-storage", "", "RT storage backend")
+	fl.StringVar(&cmd.RTArchive, "rt-archive", "", "Local directory for archiving RT messages; enables as_of queries")
+	fl.IntVar(&cmd.RTArchiveRetention, "rt-archive-retention", 168, "Hours of archived RT messages to keep for each feed; 0 keeps all messages")
+	fl.BoolVar(&cmd.RTBlockDelays, "rt-block-delays", false, "Estimate delays for trips without RT data from earlier trips in the same block")
+	fl.BoolVar(&cmd.StopObservations, "stop-observations", false, "Record stop observations from received RT TripUpdates")
+	fl.BoolVar(&cmd.PollRealtime, "poll-realtime", false, "Periodically fetch GTFS-RT and GBFS feed URLs")
+	fl.IntVar(&cmd.PollInterval, "poll-interval", 30, "Default GTFS-RT polling interval (seconds); set per feed with the poll_interval tag")
+	fl.IntVar(&cmd.PollGbfsInterval, "poll-gbfs-interval", 60, "Default GBFS polling interval (seconds)")
+	fl.IntVar(&cmd.PollMaxBackoff, "poll-max-backoff", 1800, "Maximum polling interval after repeated fetch errors (seconds)")
+	fl.IntVar(&cmd.PollRefreshInterval, "poll-refresh-interval", 300, "How often to reload the list of feeds to poll (seconds)")
+	fl.IntVar(&cmd.PollWorkers, "poll-workers", 4, "Number of concurrent feed polling jobs")
+	fl.IntVar(&cmd.GbfsSnapshotInterval, "gbfs-snapshot-interval", 0, "Record GBFS station availability snapshots at most this often (seconds); 0 disables")
 	fl.BoolVar(&cmd.ValidateLargeFiles, "validate-large-files", false, "Allow validation of large files")
 	fl.StringVar(&cmd.RestPrefix, "rest-prefix", "", "REST prefix for generating pagination links")
 	fl.StringVar(&cmd.Port, "port", "8080", "")
@@ -121,6 +156,7 @@ func (cmd *ServerCommand) AddFlags(fl *pflag.FlagSet) {
 	fl.IntVar(&cmd.LoaderBatchSize, "loader-batch-size", 100, "GraphQL Loader batch size")
 	fl.IntVar(&cmd.LoaderStopTimeBatchSize, "loader-stop-time-batch-size", 1, "GraphQL Loader batch size for StopTimes")
 	fl.Float64Var(&cmd.MaxRadius, "max-radius", 100_000, "Maximum radius for nearby stops")
+	fl.StringSliceVar(&cmd.AllowedOrigins, "allowed-origins", []string{"https://*", "http://*"}, "Origins allowed for CORS requests and websocket subscriptions")
 }
 
 func (cmd *ServerCommand) Parse(args []string) error {
@@ -142,6 +178,9 @@ func (cmd *ServerCommand) Parse(args []string) error {
 		secrets = rr.Secrets
 	}
 	cmd.secrets = secrets
+
+	// Routing handlers
+	cmd.directionsConfig = directions.ConfigFromEnv()
 	return nil
 }
 
@@ -173,17 +212,48 @@ func (cmd *ServerCommand) Run(ctx context.Context) error {
 	}
 
 	// Create RTFinder, GbfsFinder
-	var rtFinder model.RTFinder
-	var gbfsFinder model.GbfsFinder
+	var rtCache rtfinder.Cache
+	var gbfsFinder *gbfsfinder.Finder
 	if redisClient != nil {
 		// Use redis backed finders
-		rtFinder = rtfinder.NewFinder(rtfinder.NewRedisCache(redisClient), db)
+		rtCache = rtfinder.NewRedisCache(redisClient)
 		gbfsFinder = gbfsfinder.NewFinder(redisClient)
 	} else {
 		// Default to in-memory cache
-		rtFinder = rtfinder.NewFinder(rtfinder.NewLocalCache(), db)
+		rtCache = rtfinder.NewLocalCache()
 		gbfsFinder = gbfsfinder.NewFinder(nil)
 	}
+	if cmd.GbfsSnapshotInterval > 0 {
+		gbfsFinder.OnUpdate = gbfssnapshot.NewScheduler(time.Duration(cmd.GbfsSnapshotInterval) * time.Second).AddJob
+	}
+	if cmd.RTArchive != "" {
+		// Archive RT messages for replay
+		store, err := rtfinder.NewLocalArchiveStore(cmd.RTArchive)
+		if err != nil {
+			return err
+		}
+		store.Retention = time.Duration(cmd.RTArchiveRetention) * time.Hour
+		rtCache = rtfinder.NewArchiveCache(rtCache, store)
+	}
+	rtFinder := rtfinder.NewFinder(rtCache, db)
+	if cmd.StopObservations {
+		rtFinder.OnTripUpdates = stopobs.AddJob
+	}
+	rtFinder.BlockDelays = cmd.RTBlockDelays
+
+	// Metrics
+	var metricProvider metrics.MetricProvider = localmetrics.NewLocalMetric()
+
+	// Routing handlers
+	directionsRegistry, err := directions.NewRegistry(cmd.directionsConfig)
+	if err != nil {
+		return err
+	}
+	if cacheCfg := cmd.directionsConfig.Cache; cacheCfg.Enabled {
+		cacheMetric := metrics.NewCacheMetric(metricProvider, "directions")
+		directionsRegistry.SetCache(directions.NewResponseCache(redisClient, cacheCfg, cacheMetric))
+	}
+	directions.SetDefaultRegistry(directionsRegistry)
 
 	// Setup config
 	cfg := model.Config{
@@ -198,12 +268,53 @@ func (cmd *ServerCommand) Run(ctx context.Context) error {
 		LoaderBatchSize:         cmd.LoaderBatchSize,
 		LoaderStopTimeBatchSize: cmd.LoaderStopTimeBatchSize,
 		MaxRadius:               cmd.MaxRadius,
+		AllowedOrigins:          cmd.AllowedOrigins,
+	}
+
+	// Job queue for stop observations, GBFS snapshots, and feed polling
+	if cmd.StopObservations || cmd.GbfsSnapshotInterval > 0 || cmd.PollRealtime {
+		jobQueue := jobs.NewJobLogger(localjobs.NewLocalJobs())
+		jobQueue.AddQueue("default", 1)
+		if err := jobQueue.AddJobType(func() jobs.JobWorker { return &stopobs.StopObservationWorker{} }); err != nil {
+			return err
+		}
+		if err := jobQueue.AddJobType(func() jobs.JobWorker { return &gbfssnapshot.SnapshotWorker{} }); err != nil {
+			return err
+		}
+		if cmd.PollRealtime {
+			cfg.Actions = &actions.Actions{}
+		}
+		cfg.JobQueue = jobQueue
+		jobCtx := model.WithConfig(ctx, cfg)
+		go jobQueue.Run(jobCtx)
+
+		// Feed polling uses a separate queue so fetches do not delay other jobs
+		if cmd.PollRealtime {
+			feedPoller := poller.NewPoller(poller.Options{
+				RealtimeInterval: time.Duration(cmd.PollInterval) * time.Second,
+				GbfsInterval:     time.Duration(cmd.PollGbfsInterval) * time.Second,
+				MaxBackoff:       time.Duration(cmd.PollMaxBackoff) * time.Second,
+				RefreshInterval:  time.Duration(cmd.PollRefreshInterval) * time.Second,
+				Fetch:            actions.PollFetch,
+			})
+			pollQueue := jobs.NewJobLogger(localjobs.NewLocalJobs())
+			pollQueue.AddQueue(poller.QueueName, cmd.PollWorkers)
+			if err := pollQueue.AddJobType(feedPoller.NewWorker); err != nil {
+				return err
+			}
+			go pollQueue.Run(jobCtx)
+			go func() {
+				if err := feedPoller.Start(jobCtx, dbFinder, pollQueue); err != nil {
+					log.For(ctx).Error().Err(err).Msg("poller: failed to schedule feeds")
+				}
+			}()
+		}
 	}
 
 	// Setup router
 	root := chi.NewRouter()
 	root.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
+		AllowedOrigins:   cmd.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"content-type", "apikey", "authorization"},
 		AllowCredentials: true,
@@ -18,6 +18,7 @@ type Options struct {
 }
 
 type Result struct {
+	TTL int // Seconds the system file may be cached, from the GBFS ttl field
 	fetch.Result
 }
 
@@ -41,16 +42,20 @@ func Fetch(ctx context.Context, atx tldb.Adapter, opts Options) ([]GbfsFeed, Res
 	result.ResponseSHA1 = fr.ResponseSHA1
 	result.ResponseSize = fr.ResponseSize
 	if err != nil {
-		return nil, result, err
+		// Fetch errors are recorded and returned in the result
+		result.FetchError = err
 	}
+	result.TTL = int(systemFile.TTL.Val)
 
 	// Fetch additional data
+	// Version 3 files have a single set of feeds with localized text, selected using opts.Language
+	version := majorVersion(systemFile.Version.Val)
 	var feeds []GbfsFeed
 	for _, sflang := range systemFile.Data {
 		if sflang == nil {
 			continue
 		}
-		if feed, err := fetchAll(ctx, *sflang); err == nil {
+		if feed, err := fetchAll(ctx, *sflang, version, opts.Language, reqOpts...); err == nil {
 			feeds = append(feeds, feed)
 		}
 	}
@@ -83,74 +88,81 @@ func Fetch(ctx context.Context, atx tldb.Adapter, opts Options) ([]GbfsFeed, Res
 	return feeds, result, nil
 }
 
-func fetchAll(ctx context.Context, sf SystemFeeds, reqOpts ...request.RequestOption) (GbfsFeed, error) {
+func fetchAll(ctx context.Context, sf SystemFeeds, version int, lang string, reqOpts ...request.RequestOption) (GbfsFeed, error) {
 	ret := GbfsFeed{}
 	var err error
 	for _, v := range sf.Feeds {
+		unmarshal := fetchUnmarshal
+		if version >= 3 {
+			unmarshal = func(url string, ent any, reqOpts ...request.RequestOption) (request.FetchResponse, error) {
+				return fetchUnmarshalV3(url, v.Name.Val, lang, ent, reqOpts...)
+			}
+		}
 		switch v.Name.Val {
 		case "system_information":
 			e := SystemInformationFile{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			ret.SystemInformation = e.Data
 		case "station_information":
 			e := StationInformationFile{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			ret.StationInformation = e.Data.Stations
 		case "station_status":
 			e := StationStatusFile{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			ret.StationStatus = e.Data.Stations
-		case "free_bike_status":
+		case "free_bike_status", "vehicle_status":
 			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			if e.Data != nil {
 				ret.Bikes = e.Data.Bikes
 			}
 		case "system_hours":
 			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			if e.Data != nil {
 				ret.RentalHours = e.Data.RentalHours
 			}
 		case "system_calendar":
 			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			if e.Data != nil {
 				ret.Calendars = e.Data.Calendars
 			}
 		case "system_regions":
 			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			if e.Data != nil {
 				ret.Regions = e.Data.Regions
 			}
 		case "system_alerts":
 			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			if e.Data != nil {
 				ret.Alerts = e.Data.Alerts
 			}
 		case "vehicle_types":
 			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			if e.Data != nil {
 				ret.VehicleTypes = e.Data.VehicleTypes
 			}
 		case "system_pricing_plans":
 			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			if e.Data != nil {
 				ret.Plans = e.Data.Plans
 			}
 		case "geofencing_zones":
-			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
-			if e.Data != nil {
-				ret.GeofencingZones = e.Data.GeofencingZones
+			e := GeofencingZonesFile{}
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
+			if e.Data.GeofencingZones != nil {
+				ret.GeofencingZones = []*GeofenceZone{e.Data.GeofencingZones}
 			}
+			ret.GeofencingRules = e.Data.GlobalRules
 		case "gbfs_versions":
 			e := GbfsFeedData{}
-			_, err = fetchUnmarshal(v.URL.Val, &e, reqOpts...)
+			_, err = unmarshal(v.URL.Val, &e, reqOpts...)
 			if e.Data != nil {
 				ret.Versions = e.Data.Versions
 			}
@@ -174,3 +186,20 @@ func fetchUnmarshal(url string, ent any, reqOpts ...request.RequestOption) (requ
 	}
 	return fr, nil
 }
+
+func fetchUnmarshalV3(url string, feedName string, lang string, ent any, reqOpts ...request.RequestOption) (request.FetchResponse, error) {
+	ctx := context.TODO()
+	var out bytes.Buffer
+	fr, err := request.AuthenticatedRequest(ctx, &out, url, reqOpts...)
+	if err != nil {
+		return fr, err
+	}
+	data, err := normalizeV3(out.Bytes(), feedName, lang)
+	if err != nil {
+		return fr, err
+	}
+	if err := json.Unmarshal(data, ent); err != nil {
+		return fr, err
+	}
+	return fr, nil
+}
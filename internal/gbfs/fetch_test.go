@@ -25,3 +25,141 @@ func TestGbfsFetch(t *testing.T) {
 	}
 	assert.ElementsMatch(t, []string{"Bay Wheels"}, fids)
 }
+
+func TestGbfsFetch_Result(t *testing.T) {
+	t.Run("ttl", func(t *testing.T) {
+		ts := httptest.NewServer(&TestGbfsServer{Language: "en", TTL: 60, Path: testdata.Path("server/gbfs")})
+		defer ts.Close()
+		opts := Options{}
+		opts.FeedURL = fmt.Sprintf("%s/%s", ts.URL, "gbfs.json")
+		_, result, err := Fetch(context.Background(), nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, result.FetchError)
+		assert.Equal(t, 60, result.TTL)
+	})
+	t.Run("fetch error", func(t *testing.T) {
+		ts := httptest.NewServer(&TestGbfsServer{Language: "en", Path: testdata.Path("server/gbfs")})
+		defer ts.Close()
+		opts := Options{}
+		opts.FeedURL = fmt.Sprintf("%s/%s", ts.URL, "not-found.json")
+		feeds, result, err := Fetch(context.Background(), nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Error(t, result.FetchError)
+		assert.Equal(t, 404, result.ResponseCode)
+		assert.Empty(t, feeds)
+	})
+}
+
+func TestGbfsFetch_V3(t *testing.T) {
+	ts := httptest.NewServer(&TestGbfsServer{Version: "3.0", Path: testdata.Path("server/gbfs-v3")})
+	defer ts.Close()
+	fetch := func(t *testing.T, lang string) GbfsFeed {
+		opts := Options{Language: lang}
+		opts.FeedURL = fmt.Sprintf("%s/%s", ts.URL, "gbfs.json")
+		feeds, _, err := Fetch(context.Background(), nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(feeds) != 1 {
+			t.Fatalf("got %d feeds, expected 1", len(feeds))
+		}
+		return feeds[0]
+	}
+	t.Run("system_information", func(t *testing.T) {
+		feed := fetch(t, "")
+		si := feed.SystemInformation
+		if si == nil {
+			t.Fatal("no system information")
+		}
+		assert.Equal(t, "example_v3", si.SystemID.Val)
+		assert.Equal(t, "Example Bikes", si.Name.Val)
+		assert.Equal(t, "en", si.Language.Val)
+		assert.Equal(t, []string{"en", "fr"}, si.Languages.Val)
+		assert.Equal(t, "https://bikes.example.com/terms", si.TermsURL.Val)
+		assert.Equal(t, "24/7", si.OpeningHours.Val)
+	})
+	t.Run("language", func(t *testing.T) {
+		feed := fetch(t, "fr")
+		assert.Equal(t, "fr", feed.SystemInformation.Language.Val)
+		assert.Equal(t, "Vélos Exemple", feed.SystemInformation.Name.Val)
+		// Falls back to the first entry
+		assert.Equal(t, "EB", feed.SystemInformation.ShortName.Val)
+		if assert.Len(t, feed.VehicleTypes, 1) {
+			assert.Equal(t, "Vélo électrique", feed.VehicleTypes[0].Name.Val)
+		}
+	})
+	t.Run("stations", func(t *testing.T) {
+		feed := fetch(t, "en")
+		if assert.Len(t, feed.StationInformation, 2) {
+			assert.Equal(t, "Market & 4th", feed.StationInformation[0].Name.Val)
+			assert.Equal(t, int64(20), feed.StationInformation[0].Capacity.Val)
+		}
+		if assert.Len(t, feed.StationStatus, 2) {
+			ss := feed.StationStatus[0]
+			assert.Equal(t, int64(7), ss.NumBikesAvailable.Val)
+			assert.Equal(t, int64(1), ss.NumBikesDisabled.Val)
+			assert.Equal(t, int64(12), ss.NumDocksAvailable.Val)
+			assert.Equal(t, int64(1714589940), ss.LastReported.Val)
+		}
+	})
+	t.Run("vehicles", func(t *testing.T) {
+		feed := fetch(t, "en")
+		if assert.Len(t, feed.Bikes, 2) {
+			b := feed.Bikes[0]
+			assert.Equal(t, "v1", b.BikeID.Val)
+			assert.Equal(t, "ebike", b.VehicleTypeID.Val)
+			assert.Equal(t, false, b.IsReserved.Val)
+			assert.Equal(t, int64(1714589970), b.LastReported.Val)
+			assert.Equal(t, "https://bikes.example.com/v1", b.RentalURIs.Web.Val)
+			assert.Equal(t, true, feed.Bikes[1].IsDisabled.Val)
+		}
+	})
+	t.Run("pricing_plans", func(t *testing.T) {
+		feed := fetch(t, "en")
+		if assert.Len(t, feed.Plans, 1) {
+			p := feed.Plans[0]
+			assert.Equal(t, "Single Ride", p.Name.Val)
+			assert.Equal(t, "$1 to unlock, then $0.25 per minute", p.Description.Val)
+			assert.Equal(t, 0.1, p.ReservationPricePerMin.Val)
+			assert.Equal(t, 0.5, p.ReservationPriceFlatRate.Val)
+			assert.Len(t, p.PerMinPricing, 1)
+		}
+	})
+	t.Run("geofencing_zones", func(t *testing.T) {
+		feed := fetch(t, "en")
+		if !assert.Len(t, feed.GeofencingZones, 1) || !assert.Len(t, feed.GeofencingZones[0].Features, 1) {
+			return
+		}
+		prop := feed.GeofencingZones[0].Features[0].Properties
+		assert.Equal(t, "Union Square", prop.Name.Val)
+		if assert.Len(t, prop.Rules, 1) {
+			rule := prop.Rules[0]
+			assert.Equal(t, []string{"ebike"}, rule.VehicleTypeID.Val)
+			assert.Equal(t, false, rule.RideStartAllowed.Val)
+			assert.Equal(t, true, rule.RideStartAllowed.Valid)
+			assert.Equal(t, true, rule.RideThroughAllowed.Val)
+			assert.Equal(t, int64(10), rule.MaximumSpeedKph.Val)
+		}
+	})
+	t.Run("versions", func(t *testing.T) {
+		feed := fetch(t, "en")
+		var versions []string
+		for _, v := range feed.Versions {
+			versions = append(versions, v.Version.Val)
+		}
+		assert.Equal(t, []string{"2.3", "3.0"}, versions)
+	})
+}
+
+func TestNormalizeV3(t *testing.T) {
+	data := `{"data":{"vehicles":[{"vehicle_id":"a","name":[{"text":"A","language":"en"},{"text":"B","language":"de"}],"last_reported":"1970-01-01T00:01:00Z","tags":["x","y"]}]}}`
+	out, err := normalizeV3([]byte(data), "vehicle_status", "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `{"data":{"bikes":[{"bike_id":"a","name":"B","last_reported":60,"tags":["x","y"]}]}}`, string(out))
+}
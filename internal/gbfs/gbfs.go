@@ -1,6 +1,10 @@
 package gbfs
 
-import "github.com/interline-io/transitland-lib/tt"
+import (
+	"encoding/json"
+
+	"github.com/interline-io/transitland-lib/tt"
+)
 
 // Loaders
 
@@ -17,6 +21,7 @@ type GbfsFeed struct {
 	Plans              []*SystemPricingPlan  `json:"plans,omitempty"`
 	Alerts             []*SystemAlert        `json:"alerts,omitempty"`
 	GeofencingZones    []*GeofenceZone       // `json:"geofencing_zones,omitempty"`
+	GeofencingRules    []*GeofenceRule       `json:"geofencing_global_rules,omitempty"`
 }
 
 type GbfsFeedData struct {
@@ -33,7 +38,37 @@ type SystemFeed struct {
 }
 
 type SystemFile struct {
-	Data map[string]*SystemFeeds `json:"data,omitempty"`
+	Version tt.String               `json:"version,omitempty"`
+	TTL     tt.Int                  `json:"ttl,omitempty"`
+	Data    map[string]*SystemFeeds `json:"data,omitempty"`
+}
+
+// UnmarshalJSON accepts both the version 1/2 layout, with feeds keyed by language,
+// and the version 3 layout, with a single list of feeds stored under an empty key.
+func (sf *SystemFile) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Version tt.String       `json:"version"`
+		TTL     tt.Int          `json:"ttl"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	sf.Version = raw.Version
+	sf.TTL = raw.TTL
+	sf.Data = nil
+	if len(raw.Data) == 0 {
+		return nil
+	}
+	if majorVersion(raw.Version.Val) >= 3 {
+		var feeds SystemFeeds
+		if err := json.Unmarshal(raw.Data, &feeds); err != nil {
+			return err
+		}
+		sf.Data = map[string]*SystemFeeds{"": &feeds}
+		return nil
+	}
+	return json.Unmarshal(raw.Data, &sf.Data)
 }
 
 type SystemInformationFile struct {
@@ -52,6 +87,13 @@ type StationStatusFile struct {
 	}
 }
 
+type GeofencingZonesFile struct {
+	Data struct {
+		GeofencingZones *GeofenceZone   `json:"geofencing_zones,omitempty"`
+		GlobalRules     []*GeofenceRule `json:"global_rules,omitempty"`
+	}
+}
+
 ///////////////
 
 // Main types
@@ -59,6 +101,7 @@ type StationStatusFile struct {
 type SystemInformation struct {
 	SystemID           tt.String   `json:"system_id,omitempty"`
 	Language           tt.String   `json:"language,omitempty"`
+	Languages          tt.Strings  `json:"languages,omitempty"`
 	Name               tt.String   `json:"name,omitempty"`
 	ShortName          tt.String   `json:"short_name,omitempty"`
 	Operator           tt.String   `json:"operator,omitempty"`
@@ -67,6 +110,7 @@ type SystemInformation struct {
 	StartDate          tt.Date     `json:"start_date,omitempty"`
 	PhoneNumber        tt.String   `json:"phone_number,omitempty"`
 	Email              tt.String   `json:"email,omitempty"`
+	OpeningHours       tt.String   `json:"opening_hours,omitempty"`
 	FeedContactEmail   tt.String   `json:"feed_contact_email,omitempty"`
 	Timezone           tt.String   `json:"timezone,omitempty"`
 	LicenseURL         tt.String   `json:"license_url,omitempty"`
@@ -118,6 +162,7 @@ type StationInformation struct {
 	VehicleCapacity   map[string]tt.Int `json:"vehicle_capacity,omitempty"`
 	IsValetStation    tt.Bool           `json:"is_valet_station,omitempty"`
 	IsChargingStation tt.Bool           `json:"is_charging_station,omitempty"`
+	RentalURIs        *RentalURIs       `json:"rental_uris,omitempty"`
 }
 
 ///////////////
@@ -238,16 +283,18 @@ type SystemRegion struct {
 }
 
 type SystemPricingPlan struct {
-	PlanID        tt.String    `json:"plan_id,omitempty"`
-	URL           tt.String    `json:"url,omitempty"`
-	Name          tt.String    `json:"name,omitempty"`
-	Currency      tt.String    `json:"currency,omitempty"`
-	Price         tt.Float     `json:"price,omitempty"`
-	IsTaxable     tt.Bool      `json:"is_taxable,omitempty"`
-	Description   tt.String    `json:"description,omitempty"`
-	SurgePricing  tt.Bool      `json:"surge_pricing,omitempty"`
-	PerKmPricing  []*PlanPrice `json:"per_km_pricing,omitempty"`
-	PerMinPricing []*PlanPrice `json:"per_min_pricing,omitempty"`
+	PlanID                   tt.String    `json:"plan_id,omitempty"`
+	URL                      tt.String    `json:"url,omitempty"`
+	Name                     tt.String    `json:"name,omitempty"`
+	Currency                 tt.String    `json:"currency,omitempty"`
+	Price                    tt.Float     `json:"price,omitempty"`
+	IsTaxable                tt.Bool      `json:"is_taxable,omitempty"`
+	Description              tt.String    `json:"description,omitempty"`
+	SurgePricing             tt.Bool      `json:"surge_pricing,omitempty"`
+	ReservationPricePerMin   tt.Float     `json:"reservation_price_per_min,omitempty"`
+	ReservationPriceFlatRate tt.Float     `json:"reservation_price_flat_rate,omitempty"`
+	PerKmPricing             []*PlanPrice `json:"per_km_pricing,omitempty"`
+	PerMinPricing            []*PlanPrice `json:"per_min_pricing,omitempty"`
 }
 
 type PlanPrice struct {
@@ -295,6 +342,8 @@ type GeofenceProperty struct {
 type GeofenceRule struct {
 	VehicleTypeID      tt.Strings `json:"vehicle_type_id,omitempty"`
 	RideAllowed        tt.Bool    `json:"ride_allowed,omitempty"`
+	RideStartAllowed   tt.Bool    `json:"ride_start_allowed,omitempty"`
+	RideEndAllowed     tt.Bool    `json:"ride_end_allowed,omitempty"`
 	RideThroughAllowed tt.Bool    `json:"ride_through_allowed,omitempty"`
 	MaximumSpeedKph    tt.Int     `json:"maximum_speed_kph,omitempty"`
 	StationParking     tt.Bool    `json:"station_parking,omitempty"`
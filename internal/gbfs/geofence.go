@@ -0,0 +1,180 @@
+package gbfs
+
+import (
+	"math"
+	"time"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/xy"
+)
+
+const (
+	// Paths are checked at points no more than this distance apart
+	geofenceSampleDistance = 10.0 // meters
+	// Longer paths are checked at points spread evenly to stay within this limit
+	maxGeofenceSamples = 10_000
+)
+
+// GeofenceCheck is the result of evaluating geofencing rules along a path
+type GeofenceCheck struct {
+	RideStartAllowed   bool
+	RideEndAllowed     bool
+	RideThroughAllowed bool
+	MaximumSpeedKph    tt.Int   // Lowest maximum speed along the path
+	StationParking     tt.Bool  // Station parking requirement at the end of the path
+	Zones              []string // Names of the zones whose rules applied, in path order
+	Features           []*GeofenceFeature
+}
+
+// CheckGeofence evaluates the geofencing rules of a feed for a vehicle type along a path.
+// Ride start is checked at the first point, ride end and station parking at the last point,
+// and ride through and maximum speed at points sampled along the path.
+// Where zones overlap, the zone listed first takes precedence, and within a zone the first rule
+// that applies to the vehicle type is used; if no zone applies, the global rules are used.
+// Without a vehicle type, the first rule in a zone applies. Everything is allowed by default.
+func CheckGeofence(feed GbfsFeed, path []tlxy.Point, vehicleTypeID string, at time.Time) GeofenceCheck {
+	ret := GeofenceCheck{RideStartAllowed: true, RideEndAllowed: true, RideThroughAllowed: true}
+	if len(path) == 0 {
+		return ret
+	}
+	var features []*GeofenceFeature
+	for _, zone := range feed.GeofencingZones {
+		if zone == nil {
+			continue
+		}
+		for _, f := range zone.Features {
+			if f != nil && f.Properties != nil && featureActive(f.Properties, at) {
+				features = append(features, f)
+			}
+		}
+	}
+	seen := map[*GeofenceFeature]bool{}
+	samples := samplePath(path, geofenceSampleDistance, maxGeofenceSamples)
+	for i, pt := range samples {
+		f, rule := findRule(features, feed.GeofencingRules, pt, vehicleTypeID)
+		if f != nil && !seen[f] {
+			seen[f] = true
+			ret.Features = append(ret.Features, f)
+			ret.Zones = append(ret.Zones, f.Properties.Name.Val)
+		}
+		if rule == nil {
+			continue
+		}
+		if i == 0 {
+			ret.RideStartAllowed = ruleAllowed(rule.RideStartAllowed, rule.RideAllowed)
+		}
+		if i == len(samples)-1 {
+			ret.RideEndAllowed = ruleAllowed(rule.RideEndAllowed, rule.RideAllowed)
+			ret.StationParking = rule.StationParking
+		}
+		if rule.RideThroughAllowed.Valid && !rule.RideThroughAllowed.Val {
+			ret.RideThroughAllowed = false
+		}
+		if rule.MaximumSpeedKph.Valid && (!ret.MaximumSpeedKph.Valid || rule.MaximumSpeedKph.Val < ret.MaximumSpeedKph.Val) {
+			ret.MaximumSpeedKph = rule.MaximumSpeedKph
+		}
+	}
+	return ret
+}
+
+// findRule returns the zone and rule that apply at a point; the zone is nil if a global rule applies
+func findRule(features []*GeofenceFeature, globalRules []*GeofenceRule, pt tlxy.Point, vehicleTypeID string) (*GeofenceFeature, *GeofenceRule) {
+	for _, f := range features {
+		if !geometryContains(f.Geometry, pt) {
+			continue
+		}
+		if rule := matchRule(f.Properties.Rules, vehicleTypeID); rule != nil {
+			return f, rule
+		}
+	}
+	return nil, matchRule(globalRules, vehicleTypeID)
+}
+
+func matchRule(rules []*GeofenceRule, vehicleTypeID string) *GeofenceRule {
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		if vehicleTypeID == "" || len(rule.VehicleTypeID.Val) == 0 {
+			return rule
+		}
+		for _, v := range rule.VehicleTypeID.Val {
+			if v == vehicleTypeID {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// ruleAllowed uses the version 3 start/end value, or the version 2 ride_allowed value
+func ruleAllowed(v tt.Bool, v2 tt.Bool) bool {
+	if v.Valid {
+		return v.Val
+	}
+	if v2.Valid {
+		return v2.Val
+	}
+	return true
+}
+
+func featureActive(prop *GeofenceProperty, at time.Time) bool {
+	if prop.Start.Valid && at.Unix() < prop.Start.Val {
+		return false
+	}
+	if prop.End.Valid && at.Unix() >= prop.End.Val {
+		return false
+	}
+	return true
+}
+
+func geometryContains(g tt.Geometry, pt tlxy.Point) bool {
+	if !g.Valid {
+		return false
+	}
+	c := geom.Coord{pt.Lon, pt.Lat}
+	switch v := g.Val.(type) {
+	case *geom.Polygon:
+		return polygonContains(v, c)
+	case *geom.MultiPolygon:
+		for i := 0; i < v.NumPolygons(); i++ {
+			if polygonContains(v.Polygon(i), c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func polygonContains(pg *geom.Polygon, c geom.Coord) bool {
+	if pg.NumLinearRings() == 0 || !xy.IsPointInRing(geom.XY, c, pg.LinearRing(0).FlatCoords()) {
+		return false
+	}
+	for i := 1; i < pg.NumLinearRings(); i++ {
+		if xy.IsPointInRing(geom.XY, c, pg.LinearRing(i).FlatCoords()) {
+			return false
+		}
+	}
+	return true
+}
+
+// samplePath returns the path with additional points so that no two points are more than dist meters apart.
+// The distance is increased if needed to keep the number of added points within maxSamples.
+func samplePath(path []tlxy.Point, dist float64, maxSamples int) []tlxy.Point {
+	if length := tlxy.LengthHaversine(path); length/dist > float64(maxSamples) {
+		dist = length / float64(maxSamples)
+	}
+	ret := []tlxy.Point{path[0]}
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		n := int(math.Ceil(tlxy.DistanceHaversine(a, b) / dist))
+		for j := 1; j < n; j++ {
+			f := float64(j) / float64(n)
+			ret = append(ret, tlxy.Point{Lon: a.Lon + (b.Lon-a.Lon)*f, Lat: a.Lat + (b.Lat-a.Lat)*f})
+		}
+		ret = append(ret, b)
+	}
+	return ret
+}
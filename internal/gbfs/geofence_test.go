@@ -0,0 +1,126 @@
+package gbfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/stretchr/testify/assert"
+	"github.com/twpayne/go-geom"
+)
+
+func testZone(name string, minLon, minLat, maxLon, maxLat float64, rules ...*GeofenceRule) *GeofenceFeature {
+	pg := geom.NewPolygon(geom.XY).MustSetCoords([][]geom.Coord{{
+		{minLon, minLat}, {maxLon, minLat}, {maxLon, maxLat}, {minLon, maxLat}, {minLon, minLat},
+	}})
+	return &GeofenceFeature{
+		Type:       tt.NewString("Feature"),
+		Geometry:   tt.NewGeometry(pg),
+		Properties: &GeofenceProperty{Name: tt.NewString(name), Rules: rules},
+	}
+}
+
+func TestCheckGeofence(t *testing.T) {
+	noParking := &GeofenceRule{
+		VehicleTypeID:      tt.NewStrings([]string{"scooter"}),
+		RideStartAllowed:   tt.NewBool(false),
+		RideEndAllowed:     tt.NewBool(false),
+		RideThroughAllowed: tt.NewBool(true),
+		MaximumSpeedKph:    tt.NewInt(10),
+	}
+	slow := &GeofenceRule{
+		RideStartAllowed:   tt.NewBool(true),
+		RideEndAllowed:     tt.NewBool(true),
+		RideThroughAllowed: tt.NewBool(true),
+		MaximumSpeedKph:    tt.NewInt(15),
+	}
+	noRiding := &GeofenceRule{
+		RideAllowed:        tt.NewBool(false),
+		RideThroughAllowed: tt.NewBool(false),
+		StationParking:     tt.NewBool(true),
+	}
+	// Zone "park" overlaps "downtown" and is listed first, so it takes precedence.
+	// Zone "closed" is only active in 2020.
+	closed := testZone("closed", 0.03, 0, 0.04, 0.01, noRiding)
+	closed.Properties.Start = tt.NewInt(1577836800)
+	closed.Properties.End = tt.NewInt(1609459200)
+	feed := GbfsFeed{
+		GeofencingZones: []*GeofenceZone{{
+			Type: tt.NewString("FeatureCollection"),
+			Features: []*GeofenceFeature{
+				testZone("park", 0.004, 0.004, 0.006, 0.006, noParking),
+				testZone("downtown", 0, 0, 0.01, 0.01, slow),
+				testZone("pier", 0.02, 0, 0.03, 0.01, noRiding),
+				closed,
+			},
+		}},
+		GeofencingRules: []*GeofenceRule{{RideStartAllowed: tt.NewBool(true), RideEndAllowed: tt.NewBool(false), RideThroughAllowed: tt.NewBool(true)}},
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pt := func(lon, lat float64) tlxy.Point { return tlxy.Point{Lon: lon, Lat: lat} }
+	tcs := []struct {
+		name          string
+		path          []tlxy.Point
+		vehicleTypeID string
+		at            time.Time
+		start         bool
+		end           bool
+		through       bool
+		speed         tt.Int
+		parking       tt.Bool
+		zones         []string
+	}{
+		{name: "global rules", path: []tlxy.Point{pt(0.5, 0.5)}, start: true, end: false, through: true},
+		{name: "zone", path: []tlxy.Point{pt(0.001, 0.001)}, start: true, end: true, through: true, speed: tt.NewInt(15), zones: []string{"downtown"}},
+		{name: "overlapping zone for vehicle type", path: []tlxy.Point{pt(0.005, 0.005)}, vehicleTypeID: "scooter", start: false, end: false, through: true, speed: tt.NewInt(10), zones: []string{"park"}},
+		{name: "overlapping zone without matching rule", path: []tlxy.Point{pt(0.005, 0.005)}, vehicleTypeID: "bicycle", start: true, end: true, through: true, speed: tt.NewInt(15), zones: []string{"downtown"}},
+		{name: "version 2 ride_allowed", path: []tlxy.Point{pt(0.025, 0.005)}, start: false, end: false, through: false, parking: tt.NewBool(true), zones: []string{"pier"}},
+		{name: "inactive zone", path: []tlxy.Point{pt(0.035, 0.005)}, start: true, end: false, through: true},
+		{name: "active zone", path: []tlxy.Point{pt(0.035, 0.005)}, at: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), start: false, end: false, through: false, parking: tt.NewBool(true), zones: []string{"closed"}},
+		{
+			name:          "line",
+			path:          []tlxy.Point{pt(0.001, 0.005), pt(0.009, 0.005), pt(0.5, 0.5)},
+			vehicleTypeID: "scooter",
+			start:         true,
+			end:           false,
+			through:       true,
+			speed:         tt.NewInt(10),
+			zones:         []string{"downtown", "park"},
+		},
+		{
+			name:    "line through zone",
+			path:    []tlxy.Point{pt(0.015, 0.005), pt(0.035, 0.005), pt(0.035, 0.02)},
+			start:   true,
+			end:     false,
+			through: false,
+			zones:   []string{"pier"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			checkAt := at
+			if !tc.at.IsZero() {
+				checkAt = tc.at
+			}
+			ret := CheckGeofence(feed, tc.path, tc.vehicleTypeID, checkAt)
+			assert.Equal(t, tc.start, ret.RideStartAllowed, "ride_start_allowed")
+			assert.Equal(t, tc.end, ret.RideEndAllowed, "ride_end_allowed")
+			assert.Equal(t, tc.through, ret.RideThroughAllowed, "ride_through_allowed")
+			assert.Equal(t, tc.speed, ret.MaximumSpeedKph, "maximum_speed_kph")
+			assert.Equal(t, tc.parking, ret.StationParking, "station_parking")
+			assert.Equal(t, tc.zones, ret.Zones, "zones")
+		})
+	}
+}
+
+func TestSamplePath(t *testing.T) {
+	path := []tlxy.Point{{Lon: -122.0, Lat: 37.0}, {Lon: -122.0, Lat: 37.001}}
+	ret := samplePath(path, 10, 100)
+	assert.Equal(t, 13, len(ret))
+	assert.Equal(t, path[0], ret[0])
+	assert.Equal(t, path[1], ret[len(ret)-1])
+	// Samples are capped for long paths
+	long := []tlxy.Point{{Lon: -122.0, Lat: 37.0}, {Lon: -121.0, Lat: 38.0}}
+	assert.LessOrEqual(t, len(samplePath(long, 10, 100)), 102)
+}
@@ -13,8 +13,11 @@ import (
 )
 
 // Serve a directory of GBFS files. Used for testing.
+// If Version is 3.0 or later, gbfs.json uses the version 3 layout.
 type TestGbfsServer struct {
 	Language string
+	Version  string
+	TTL      int
 	Path     string
 }
 
@@ -39,6 +42,11 @@ func (g *TestGbfsServer) open(host string, path string) ([]byte, error) {
 				sfs.Feeds = append(sfs.Feeds, &SystemFeed{Name: tt.NewString(fn), URL: tt.NewString(url)})
 			}
 		}
+		if majorVersion(g.Version) >= 3 {
+			return json.Marshal(map[string]any{"version": g.Version, "ttl": g.TTL, "data": sfs})
+		}
+		sf.Version = tt.NewString(g.Version)
+		sf.TTL = tt.NewInt(g.TTL)
 		sf.Data = map[string]*SystemFeeds{}
 		sf.Data[g.Language] = &sfs
 		data, err := json.Marshal(sf)
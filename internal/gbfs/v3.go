@@ -0,0 +1,131 @@
+package gbfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GBFS 3.0 support.
+// Version 3 files are normalized to the version 1/2 layout before decoding into the existing types:
+// localized text arrays are reduced to a single string, RFC 3339 timestamps are converted to
+// POSIX times, and renamed fields are mapped to their previous names.
+
+// majorVersion returns the major version of a GBFS version string; files without a version are version 1.
+func majorVersion(v string) int {
+	major, _, _ := strings.Cut(v, ".")
+	if n, err := strconv.Atoi(major); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// Renamed fields, by feed name
+var v3FieldNames = map[string]map[string]string{
+	"vehicle_status": {
+		"vehicles":   "bikes",
+		"vehicle_id": "bike_id",
+	},
+	"station_status": {
+		"num_vehicles_available": "num_bikes_available",
+		"num_vehicles_disabled":  "num_bikes_disabled",
+	},
+	"geofencing_zones": {
+		"vehicle_type_ids": "vehicle_type_id",
+	},
+}
+
+// Fields that are RFC 3339 timestamps in version 3 and POSIX times in earlier versions
+var v3TimestampFields = map[string]bool{
+	"last_updated":    true,
+	"last_reported":   true,
+	"available_until": true,
+	"start":           true,
+	"end":             true,
+}
+
+// normalizeV3 converts a version 3 file to the version 1/2 layout, using lang to select localized text
+func normalizeV3(data []byte, feedName string, lang string) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	v = normalizeV3Value(v, v3FieldNames[feedName], lang)
+	// Avoid escaping HTML characters; tt.String does not decode escape sequences
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func normalizeV3Value(v any, names map[string]string, lang string) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		ret := make(map[string]any, len(vv))
+		for k, item := range vv {
+			item = normalizeV3Value(item, names, lang)
+			if s, ok := item.(string); ok && v3TimestampFields[k] {
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					item = t.Unix()
+				}
+			}
+			if newKey, ok := names[k]; ok {
+				k = newKey
+			}
+			ret[k] = item
+		}
+		// system_information lists languages instead of a single language
+		if langs, ok := ret["languages"].([]any); ok && len(langs) > 0 && ret["language"] == nil {
+			ret["language"] = langs[0]
+			for _, l := range langs {
+				if s, ok := l.(string); ok && lang != "" && strings.EqualFold(s, lang) {
+					ret["language"] = s
+				}
+			}
+		}
+		return ret
+	case []any:
+		if text, ok := localizedText(vv, lang); ok {
+			return text
+		}
+		for i, item := range vv {
+			vv[i] = normalizeV3Value(item, names, lang)
+		}
+		return vv
+	}
+	return v
+}
+
+// localizedText returns the text for lang from a localized string array,
+// falling back to the first entry if there is no match.
+func localizedText(v []any, lang string) (string, bool) {
+	if len(v) == 0 {
+		return "", false
+	}
+	var texts []string
+	match := -1
+	for i, item := range v {
+		m, ok := item.(map[string]any)
+		if !ok || len(m) != 2 {
+			return "", false
+		}
+		text, ok1 := m["text"].(string)
+		language, ok2 := m["language"].(string)
+		if !ok1 || !ok2 {
+			return "", false
+		}
+		if match < 0 && lang != "" && strings.EqualFold(language, lang) {
+			match = i
+		}
+		texts = append(texts, text)
+	}
+	if match < 0 {
+		match = 0
+	}
+	return texts[match], true
+}
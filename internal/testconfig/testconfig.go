@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/interline-io/transitland-lib/rt"
+	"github.com/interline-io/transitland-lib/rt/pb"
 	"github.com/interline-io/transitland-lib/tldb"
 	"github.com/interline-io/transitland-lib/tldb/querylogger"
 	"github.com/interline-io/transitland-server/internal/clock"
@@ -32,6 +33,8 @@ type Options struct {
 	Storage        string
 	RTStorage      string
 	RTJsons        []RTJsonFile
+	RTArchive      bool
+	RTBlockDelays  bool
 	FGAEndpoint    string
 	FGAModelFile   string
 	FGAModelTuples []authz.TupleKey
@@ -72,13 +75,15 @@ type RTJsonFile struct {
 	Feed  string
 	Ftype string
 	Fname string
+	// Transform optionally modifies the message before it is added
+	Transform func(*pb.FeedMessage)
 }
 
 func DefaultRTJson() []RTJsonFile {
 	return []RTJsonFile{
-		{"BA", "realtime_trip_updates", "BA.json"},
-		{"BA", "realtime_alerts", "BA-alerts.json"},
-		{"CT", "realtime_trip_updates", "CT.json"},
+		{Feed: "BA", Ftype: "realtime_trip_updates", Fname: "BA.json"},
+		{Feed: "BA", Ftype: "realtime_alerts", Fname: "BA-alerts.json"},
+		{Feed: "CT", Ftype: "realtime_trip_updates", Fname: "CT.json"},
 	}
 }
 
@@ -113,14 +118,28 @@ func newTestConfig(t testing.TB, ctx context.Context, db tldb.Ext, opts Options)
 	dbf.Clock = cl
 
 	// Setup RT
-	rtf := rtfinder.NewFinder(rtfinder.NewLocalCache(), db)
+	var rtCache rtfinder.Cache = rtfinder.NewLocalCache()
+	if opts.RTArchive {
+		store, err := rtfinder.NewLocalArchiveStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		archiveCache := rtfinder.NewArchiveCache(rtCache, store)
+		archiveCache.Clock = cl
+		rtCache = archiveCache
+	}
+	rtf := rtfinder.NewFinder(rtCache, db)
 	rtf.Clock = cl
+	rtf.BlockDelays = opts.RTBlockDelays
 	for _, rtj := range opts.RTJsons {
 		fn := testdata.Path("server", "rt", rtj.Fname)
 		msg, err := rt.ReadFile(fn)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if rtj.Transform != nil {
+			rtj.Transform(msg)
+		}
 		key := fmt.Sprintf("rtdata:%s:%s", rtj.Feed, rtj.Ftype)
 		rtdata, err := proto.Marshal(msg)
 		if err != nil {
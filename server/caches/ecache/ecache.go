@@ -63,10 +63,24 @@ func (e *Cache[T]) Get(ctx context.Context, key string) (T, bool) {
 		return a.Value, true
 	}
 	v, ok := e.getRedis(ctx, key)
-	e.setLocal(key, v, 0)
+	if ok {
+		e.setLocal(key, v, 0)
+	}
 	return v.Value, ok
 }
 
+// Expire removes expired items from the local cache
+func (e *Cache[T]) Expire() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t := time.Now().In(time.UTC)
+	for k, v := range e.m {
+		if expired(v, t) {
+			delete(e.m, k)
+		}
+	}
+}
+
 func (e *Cache[T]) LocalKeys() []string {
 	e.lock.Lock()
 	defer e.lock.Unlock()
@@ -97,9 +111,17 @@ func (e *Cache[T]) redisKey(key string) string {
 
 func (e *Cache[T]) getLocal(key string) (Item[T], bool) {
 	a, ok := e.m[key]
+	if ok && expired(a, time.Now().In(time.UTC)) {
+		delete(e.m, key)
+		return a, false
+	}
 	return a, ok
 }
 
+func expired[T any](item Item[T], t time.Time) bool {
+	return !item.ExpiresAt.IsZero() && item.ExpiresAt.Before(t)
+}
+
 func (e *Cache[T]) getRedis(ctx context.Context, key string) (Item[T], bool) {
 	t := time.Now().In(time.UTC)
 	ld := Item[T]{
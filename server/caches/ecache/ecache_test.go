@@ -1 +1,31 @@
 package ecache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_Expire(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache[string](nil, "test")
+	if _, ok := c.Get(ctx, "a"); ok {
+		t.Fatal("expected miss")
+	}
+	// Misses are not cached
+	if _, ok := c.Get(ctx, "a"); ok {
+		t.Fatal("expected miss")
+	}
+	assert.NoError(t, c.SetTTL(ctx, "a", "ok", time.Hour, time.Hour))
+	assert.NoError(t, c.SetTTL(ctx, "b", "expired", -time.Second, -time.Second))
+	v, ok := c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "ok", v)
+	_, ok = c.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.NoError(t, c.SetTTL(ctx, "c", "expired", -time.Second, -time.Second))
+	c.Expire()
+	assert.ElementsMatch(t, []string{"a"}, c.LocalKeys())
+}
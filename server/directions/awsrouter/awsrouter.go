@@ -2,8 +2,8 @@ package awsrouter
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -23,30 +23,26 @@ type LocationClient interface {
 }
 
 func init() {
-	ctx := context.Background()
-	// Get AWS config and register handler factory
-	cn := os.Getenv("TL_AWS_LOCATION_CALCULATOR")
-	if cn == "" {
-		return
-	}
-	cfg, err := awsconfig.LoadDefaultConfig(ctx)
-	if err != nil {
-		return
-	}
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	if os.Getenv("TL_DIRECTIONS_ENABLE_CACHE") != "" {
-		// By default use a 1 minute TTL cache
-		cache := httpcache.NewTTLCache(16*1024, 1*time.Minute)
-		cache.SkipExtension(true) // don't refresh values on get
-		client.Transport = httpcache.NewCache(nil, httpcache.NoHeadersKey, cache)
-
-	}
-	cfg.HTTPClient = client
-	lc := location.NewFromConfig(cfg)
-	if err := directions.RegisterRouter("aws", func() directions.Handler {
-		return NewRouter(lc, cn)
+	if err := directions.RegisterRouter("aws", func(cfg directions.HandlerConfig) (directions.Handler, error) {
+		cn := cfg.Options["calculator"]
+		if cn == "" {
+			return nil, errors.New("calculator option required")
+		}
+		awscfg, err := awsconfig.LoadDefaultConfig(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		client := &http.Client{
+			Timeout: cfg.Timeout,
+		}
+		if cfg.Cache {
+			// By default use a 1 minute TTL cache
+			cache := httpcache.NewTTLCache(16*1024, 1*time.Minute)
+			cache.SkipExtension(true) // don't refresh values on get
+			client.Transport = httpcache.NewCache(nil, httpcache.NoHeadersKey, cache)
+		}
+		awscfg.HTTPClient = client
+		return NewRouter(location.NewFromConfig(awscfg), cn), nil
 	}); err != nil {
 		panic(err)
 	}
@@ -107,6 +103,12 @@ func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*mode
 		input.DepartNow = nil
 		input.DepartureTime = nil
 	}
+	// Arrive by is approximated by shifting the route calculated without traffic
+	arriveBy := req.ArriveBy != nil && *req.ArriveBy
+	if arriveBy {
+		input.DepartNow = nil
+		input.DepartureTime = nil
+	}
 	// Ensure we are in UTC
 	departAt = departAt.In(time.UTC)
 
@@ -119,10 +121,13 @@ func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*mode
 
 	// Prepare response
 	ret := makeDirections(res, departAt)
+	if arriveBy {
+		directions.ArriveBy(ret, departAt)
+	}
 	ret.Origin = wpiWaypoint(req.From)
 	ret.Destination = wpiWaypoint(req.To)
 	ret.Success = true
-	ret.Exception = nil
+	ret.Exception = directions.IgnoredOptions(req, directions.OptionArriveBy)
 	return ret, nil
 }
 
@@ -0,0 +1,255 @@
+package directions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// DefaultBikeshareRadius is the default distance searched for vehicles and docks, in meters
+const DefaultBikeshareRadius = 500.0
+
+// BikeshareRental is a vehicle pickup and dropoff location for a bikeshare trip
+type BikeshareRental struct {
+	Pickup  *model.WaypointInput
+	Dropoff *model.WaypointInput
+	Rental  *model.LegRental
+}
+
+// FindBikeshareRental finds the nearest available vehicle to the origin, either at a station or free floating.
+// Docked vehicles are returned to the nearest station in the same system with a free dock;
+// free floating vehicles are ridden to the destination.
+// Returns nil if no vehicle or dock is available within radius meters.
+func FindBikeshareRental(ctx context.Context, finder model.GbfsFinder, from *model.WaypointInput, to *model.WaypointInput, radius float64) (*BikeshareRental, error) {
+	if finder == nil {
+		return nil, errors.New("no bikeshare data available")
+	}
+	if radius <= 0 {
+		radius = DefaultBikeshareRadius
+	}
+	fromPt := tlxy.Point{Lon: from.Lon, Lat: from.Lat}
+	toPt := tlxy.Point{Lon: to.Lon, Lat: to.Lat}
+
+	// Nearest station with an available vehicle
+	docks, err := finder.FindDocks(ctx, nil, &model.GbfsDockRequest{Near: &model.PointRadius{Lon: from.Lon, Lat: from.Lat, Radius: radius}})
+	if err != nil {
+		return nil, err
+	}
+	var pickupStation *model.GbfsStationInformation
+	bestDist := radius
+	for _, station := range docks {
+		st := station.Status()
+		if st == nil || st.NumBikesAvailable.Val <= 0 || !boolDefault(st.IsRenting.Valid, st.IsRenting.Val) {
+			continue
+		}
+		if d := tlxy.DistanceHaversine(fromPt, tlxy.Point{Lon: station.Lon.Val, Lat: station.Lat.Val}); d <= bestDist {
+			pickupStation, bestDist = station, d
+		}
+	}
+
+	// Closer free floating vehicle
+	bikes, err := finder.FindBikes(ctx, nil, &model.GbfsBikeRequest{Near: &model.PointRadius{Lon: from.Lon, Lat: from.Lat, Radius: radius}})
+	if err != nil {
+		return nil, err
+	}
+	var pickupBike *model.GbfsFreeBikeStatus
+	for _, bike := range bikes {
+		if bike.IsReserved.Val || bike.IsDisabled.Val || bike.StationID.Val != "" {
+			continue
+		}
+		if d := tlxy.DistanceHaversine(fromPt, tlxy.Point{Lon: bike.Lon.Val, Lat: bike.Lat.Val}); d < bestDist {
+			pickupBike, pickupStation, bestDist = bike, nil, d
+		}
+	}
+
+	// Free floating vehicles are ridden to the destination
+	if pickupBike != nil {
+		rental := newLegRental(pickupBike.Feed)
+		rental.VehicleID = ptr(pickupBike.BikeID.Val)
+		if pickupBike.VehicleTypeID.Val != "" {
+			rental.VehicleTypeID = ptr(pickupBike.VehicleTypeID.Val)
+		}
+		rental.RentalUris = pickupBike.RentalUris()
+		return &BikeshareRental{
+			Pickup:  &model.WaypointInput{Lon: pickupBike.Lon.Val, Lat: pickupBike.Lat.Val},
+			Dropoff: to,
+			Rental:  rental,
+		}, nil
+	}
+	if pickupStation == nil {
+		return nil, nil
+	}
+
+	// Nearest station in the same system with a free dock
+	docks, err = finder.FindDocks(ctx, nil, &model.GbfsDockRequest{Near: &model.PointRadius{Lon: to.Lon, Lat: to.Lat, Radius: radius}})
+	if err != nil {
+		return nil, err
+	}
+	var dropoffStation *model.GbfsStationInformation
+	bestDist = radius
+	systemID := gbfsSystemID(pickupStation.Feed)
+	for _, station := range docks {
+		st := station.Status()
+		if st == nil || st.NumDocksAvailable.Val <= 0 || !boolDefault(st.IsReturning.Valid, st.IsReturning.Val) || gbfsSystemID(station.Feed) != systemID {
+			continue
+		}
+		if d := tlxy.DistanceHaversine(toPt, tlxy.Point{Lon: station.Lon.Val, Lat: station.Lat.Val}); d <= bestDist {
+			dropoffStation, bestDist = station, d
+		}
+	}
+	if dropoffStation == nil {
+		return nil, nil
+	}
+	rental := newLegRental(pickupStation.Feed)
+	rental.FromStation = legRentalStation(pickupStation)
+	rental.ToStation = legRentalStation(dropoffStation)
+	rental.RentalUris = pickupStation.RentalUris()
+	return &BikeshareRental{
+		Pickup:  &model.WaypointInput{Lon: pickupStation.Lon.Val, Lat: pickupStation.Lat.Val, Name: rental.FromStation.StationName},
+		Dropoff: &model.WaypointInput{Lon: dropoffStation.Lon.Val, Lat: dropoffStation.Lat.Val, Name: rental.ToStation.StationName},
+		Rental:  rental,
+	}, nil
+}
+
+// PlanFunc plans a single mode segment of a multimodal trip
+type PlanFunc func(context.Context, model.DirectionRequest) (*model.Directions, error)
+
+// PlanBikeshare plans a walk to the vehicle pickup, a ride to the dropoff, and a walk to the destination.
+// Each segment is planned in turn, departing when the previous segment arrives.
+// The ride legs have mode BIKESHARE and carry the rental details.
+func PlanBikeshare(ctx context.Context, req model.DirectionRequest, rental *BikeshareRental, plan PlanFunc) (*model.Directions, error) {
+	departAt := time.Now().In(time.UTC)
+	if req.DepartAt != nil {
+		departAt = *req.DepartAt
+	}
+	departAt = departAt.In(time.UTC)
+	segments := []struct {
+		from *model.WaypointInput
+		to   *model.WaypointInput
+		mode model.StepMode
+	}{
+		{req.From, rental.Pickup, model.StepModeWalk},
+		{rental.Pickup, rental.Dropoff, model.StepModeBicycle},
+		{rental.Dropoff, req.To, model.StepModeWalk},
+	}
+	itin := model.Itinerary{
+		StartTime: departAt,
+		From:      wpiWaypoint(req.From),
+		To:        wpiWaypoint(req.To),
+	}
+	t := departAt
+	duration, distance := 0.0, 0.0
+	var dataSource *string
+	for _, seg := range segments {
+		// Skip segments with no distance, e.g. riding a free floating vehicle to the destination
+		if tlxy.DistanceHaversine(tlxy.Point{Lon: seg.from.Lon, Lat: seg.from.Lat}, tlxy.Point{Lon: seg.to.Lon, Lat: seg.to.Lat}) < 1 {
+			continue
+		}
+		segDepartAt := t
+		d, err := plan(ctx, model.DirectionRequest{
+			From:      seg.from,
+			To:        seg.to,
+			Mode:      seg.mode,
+			DepartAt:  &segDepartAt,
+			WalkSpeed: req.WalkSpeed,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if d == nil || !d.Success || len(d.Itineraries) == 0 {
+			return &model.Directions{Success: false, Exception: ptr("could not calculate route")}, nil
+		}
+		sub := d.Itineraries[0]
+		for _, leg := range sub.Legs {
+			if seg.mode == model.StepModeBicycle {
+				leg.Mode = ptr(model.StepModeBikeshare)
+				leg.Rental = rental.Rental
+			} else if leg.Mode == nil {
+				leg.Mode = ptr(seg.mode)
+			}
+			itin.Legs = append(itin.Legs, leg)
+		}
+		duration += sub.Duration.Duration
+		distance += kilometers(sub.Distance)
+		t = sub.EndTime
+		if seg.mode == model.StepModeBicycle && d.DataSource != nil {
+			dataSource = d.DataSource
+		}
+	}
+	if len(itin.Legs) == 0 {
+		return &model.Directions{Success: false, Exception: ptr("could not calculate route")}, nil
+	}
+	itin.EndTime = t
+	itin.Duration = &model.Duration{Duration: duration, Units: model.DurationUnitSeconds}
+	itin.Distance = &model.Distance{Distance: distance, Units: model.DistanceUnitKilometers}
+	ret := model.Directions{
+		Success:     true,
+		Origin:      wpiWaypoint(req.From),
+		Destination: wpiWaypoint(req.To),
+		Duration:    itin.Duration,
+		Distance:    itin.Distance,
+		StartTime:   &itin.StartTime,
+		EndTime:     &itin.EndTime,
+		Itineraries: []*model.Itinerary{&itin},
+		DataSource:  ptr("GBFS"),
+	}
+	if dataSource != nil {
+		ret.DataSource = ptr(*dataSource + ", GBFS")
+	}
+	if req.ArriveBy != nil && *req.ArriveBy {
+		ArriveBy(&ret, departAt)
+	}
+	return &ret, nil
+}
+
+func newLegRental(feed *model.GbfsFeed) *model.LegRental {
+	ret := model.LegRental{SystemID: gbfsSystemID(feed)}
+	if feed != nil && feed.GbfsFeed != nil && feed.GbfsFeed.SystemInformation != nil && feed.GbfsFeed.SystemInformation.Name.Val != "" {
+		ret.SystemName = ptr(feed.GbfsFeed.SystemInformation.Name.Val)
+	}
+	return &ret
+}
+
+func legRentalStation(station *model.GbfsStationInformation) *model.LegRentalStation {
+	ret := model.LegRentalStation{
+		StationID: station.StationID.Val,
+		Lon:       station.Lon.Val,
+		Lat:       station.Lat.Val,
+	}
+	if station.Name.Val != "" {
+		ret.StationName = ptr(station.Name.Val)
+	}
+	return &ret
+}
+
+func gbfsSystemID(feed *model.GbfsFeed) string {
+	if feed == nil || feed.GbfsFeed == nil || feed.GbfsFeed.SystemInformation == nil {
+		return ""
+	}
+	return feed.GbfsFeed.SystemInformation.SystemID.Val
+}
+
+// boolDefault returns true for unset values
+func boolDefault(valid bool, v bool) bool {
+	return !valid || v
+}
+
+func kilometers(d *model.Distance) float64 {
+	if d == nil {
+		return 0
+	}
+	switch d.Units {
+	case model.DistanceUnitMeters:
+		return d.Distance / 1000
+	case model.DistanceUnitMiles:
+		return d.Distance * 1.609344
+	}
+	return d.Distance
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,81 @@
+package bikeshare
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-server/internal/clock"
+	"github.com/interline-io/transitland-server/server/directions"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+func init() {
+	if err := directions.RegisterRouter("bikeshare", func(cfg directions.HandlerConfig) (directions.Handler, error) {
+		return NewRouter(cfg.Options["walk"], cfg.Options["bicycle"]), nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// Router plans BIKESHARE trips using live GBFS data, composing walking and cycling directions from other handlers
+type Router struct {
+	Clock   clock.Clock
+	Walk    string // handler for walking segments; empty uses the WALK mode handlers
+	Bicycle string // handler for cycling segments; empty uses the BICYCLE mode handlers
+	Plan    func(context.Context, string, model.DirectionRequest) (*model.Directions, error)
+}
+
+func NewRouter(walk string, bicycle string) *Router {
+	return &Router{
+		Walk:    walk,
+		Bicycle: bicycle,
+		Plan:    directions.HandleRequest,
+	}
+}
+
+func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*model.Directions, error) {
+	if err := directions.ValidateDirectionRequest(req); err != nil {
+		return &model.Directions{Success: false, Exception: aws.String("invalid input")}, nil
+	}
+	if req.Mode != model.StepModeBikeshare {
+		return &model.Directions{Success: false, Exception: aws.String("unsupported travel mode")}, nil
+	}
+
+	// Prepare time
+	departAt := time.Now().In(time.UTC)
+	if h.Clock != nil {
+		departAt = h.Clock.Now()
+	}
+	if req.DepartAt == nil {
+		req.DepartAt = &departAt
+	}
+
+	// Find vehicle and dock
+	radius := directions.DefaultBikeshareRadius
+	if req.MaxWalkDistance != nil {
+		radius = *req.MaxWalkDistance
+	}
+	rental, err := directions.FindBikeshareRental(ctx, model.ForContext(ctx).GbfsFinder, req.From, req.To, radius)
+	if err != nil {
+		log.For(ctx).Error().Err(err).Msg("bikeshare router failed to find vehicle")
+		return &model.Directions{Success: false, Exception: aws.String("could not calculate route")}, nil
+	}
+	if rental == nil {
+		return &model.Directions{Success: false, Exception: aws.String("no bikeshare vehicle available")}, nil
+	}
+
+	// Plan each segment with the configured handlers
+	ret, err := directions.PlanBikeshare(ctx, req, rental, func(ctx context.Context, sub model.DirectionRequest) (*model.Directions, error) {
+		pref := h.Walk
+		if sub.Mode == model.StepModeBicycle {
+			pref = h.Bicycle
+		}
+		return h.Plan(ctx, pref, sub)
+	})
+	if ret != nil && ret.Success {
+		ret.Exception = directions.IgnoredOptions(req, directions.OptionArriveBy, directions.OptionMaxWalkDistance, directions.OptionWalkSpeed)
+	}
+	return ret, err
+}
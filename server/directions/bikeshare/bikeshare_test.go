@@ -0,0 +1,76 @@
+package bikeshare
+
+import (
+	"context"
+	"testing"
+
+	dt "github.com/interline-io/transitland-server/server/directions/directionstest"
+	"github.com/interline-io/transitland-server/server/directions/linerouter"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	req := dt.MakeBasicTests()["ped"]
+	req.Mode = model.StepModeBikeshare
+	ctx := model.WithConfig(context.Background(), model.Config{GbfsFinder: dt.NewBikeshareFinder(t, dt.BikeshareDockedFeed())})
+	var prefs []string
+	h := NewRouter("walker", "cyclist")
+	h.Plan = func(ctx context.Context, pref string, req model.DirectionRequest) (*model.Directions, error) {
+		prefs = append(prefs, pref)
+		return (&linerouter.Router{}).Request(ctx, req)
+	}
+
+	t.Run("docked", func(t *testing.T) {
+		prefs = nil
+		ret, err := h.Request(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, ret.Success)
+		assert.Nil(t, ret.Exception)
+		assert.Equal(t, []string{"walker", "cyclist", "walker"}, prefs)
+		if assert.Equal(t, 1, len(ret.Itineraries)) && assert.Equal(t, 3, len(ret.Itineraries[0].Legs)) {
+			leg := ret.Itineraries[0].Legs[1]
+			assert.Equal(t, model.StepModeBikeshare, *leg.Mode)
+			assert.Equal(t, "pickup", leg.Rental.FromStation.StationID)
+			assert.Equal(t, "dropoff", leg.Rental.ToStation.StationID)
+		}
+	})
+	t.Run("max_walk_distance", func(t *testing.T) {
+		r := req
+		d := 100.0
+		r.MaxWalkDistance = &d
+		ret, err := h.Request(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, ret.Success)
+		assert.Equal(t, "no bikeshare vehicle available", *ret.Exception)
+	})
+	t.Run("unsupported mode", func(t *testing.T) {
+		r := req
+		r.Mode = model.StepModeWalk
+		ret, err := h.Request(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, ret.Success)
+		assert.Equal(t, "unsupported travel mode", *ret.Exception)
+	})
+	t.Run("segment failed", func(t *testing.T) {
+		h2 := NewRouter("", "")
+		h2.Plan = func(ctx context.Context, pref string, req model.DirectionRequest) (*model.Directions, error) {
+			if req.Mode == model.StepModeBicycle {
+				return &model.Directions{Success: false}, nil
+			}
+			return (&linerouter.Router{}).Request(ctx, req)
+		}
+		ret, err := h2.Request(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, ret.Success)
+		assert.Equal(t, "could not calculate route", *ret.Exception)
+	})
+}
@@ -0,0 +1,53 @@
+package directions
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a circuit breaker that opens after a number of consecutive failures.
+// While open, requests are not allowed until the cooldown has passed;
+// the next request is then allowed through and either closes the breaker or opens it again.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	lock      sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.failures < b.threshold || !b.now().Before(b.openUntil)
+}
+
+func (b *breaker) record(ok bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+func (b *breaker) status() (int, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.failures, b.threshold > 0 && b.failures >= b.threshold && b.now().Before(b.openUntil)
+}
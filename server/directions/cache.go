@@ -0,0 +1,173 @@
+package directions
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-server/server/caches/ecache"
+	"github.com/interline-io/transitland-server/server/metrics"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+const (
+	defaultCachePrecision  = 4 // decimal places, about 10 meters
+	defaultCacheTimeBucket = 5 * time.Minute
+	defaultCacheTTL        = 1 * time.Hour
+)
+
+// CacheConfig configures caching of successful directions responses
+type CacheConfig struct {
+	Enabled    bool
+	Precision  int           // Decimal places kept in waypoint coordinates
+	TimeBucket time.Duration // Departure times are truncated to this interval
+	TTL        time.Duration // Time a response is cached
+}
+
+// ResponseCache caches successful directions responses keyed by a normalized request.
+// Responses are stored in redis when a client is provided, and in a local cache.
+type ResponseCache struct {
+	precision  int
+	timeBucket time.Duration
+	ttl        time.Duration
+	metric     metrics.CacheMetric
+	cache      *ecache.Cache[cacheEntry]
+	lock       sync.Mutex
+	lastExpire time.Time
+}
+
+type cacheEntry struct {
+	Handler  string          // handler that served the response
+	Response json.RawMessage // stored encoded so each hit returns a new copy
+}
+
+// NewResponseCache returns a cache using the given redis client, which may be nil, and metric, which may be nil
+func NewResponseCache(client *redis.Client, cfg CacheConfig, metric metrics.CacheMetric) *ResponseCache {
+	c := &ResponseCache{
+		precision:  cfg.Precision,
+		timeBucket: cfg.TimeBucket,
+		ttl:        cfg.TTL,
+		metric:     metric,
+		cache:      ecache.NewCache[cacheEntry](client, "directions"),
+		lastExpire: time.Now(),
+	}
+	if c.precision <= 0 {
+		c.precision = defaultCachePrecision
+	}
+	if c.timeBucket <= 0 {
+		c.timeBucket = defaultCacheTimeBucket
+	}
+	if c.ttl <= 0 {
+		c.ttl = defaultCacheTTL
+	}
+	return c
+}
+
+// Key returns the cache key for a request sent to a preferred handler, or the mode chain if empty.
+// Coordinates are rounded and the departure time is truncated so nearby requests share a key;
+// requests without a departure time use the current time.
+// Handlers may only use the feeds visible to the caller, so the key includes the caller's permission filter.
+func (c *ResponseCache) Key(ctx context.Context, pref string, req model.DirectionRequest) string {
+	departAt := time.Now()
+	if req.DepartAt != nil {
+		departAt = *req.DepartAt
+	}
+	departAt = departAt.In(time.UTC).Truncate(c.timeBucket)
+	pf := model.PermsForContext(ctx)
+	key := struct {
+		Pref                string
+		Now                 bool
+		AllowedFeeds        []int
+		AllowedFeedVersions []int
+		Request             model.DirectionRequest
+	}{
+		Pref:                pref,
+		Now:                 req.DepartAt == nil,
+		AllowedFeeds:        sortedInts(pf.GetAllowedFeeds()),
+		AllowedFeedVersions: sortedInts(pf.GetAllowedFeedVersions()),
+		Request:             req,
+	}
+	key.Request.DepartAt = &departAt
+	key.Request.From = c.roundWaypoint(req.From)
+	key.Request.To = c.roundWaypoint(req.To)
+	data, err := json.Marshal(key)
+	if err != nil {
+		return ""
+	}
+	h := sha1.Sum(data)
+	return hex.EncodeToString(h[:])
+}
+
+// Get returns a cached response and the name of the handler that served it
+func (c *ResponseCache) Get(ctx context.Context, key string) (string, *model.Directions, bool) {
+	entry, ok := c.cache.Get(ctx, key)
+	var ret *model.Directions
+	if ok {
+		if err := json.Unmarshal(entry.Response, &ret); err != nil {
+			log.For(ctx).Error().Err(err).Msg("directions: could not decode cached response")
+			ok = false
+		}
+	}
+	if c.metric != nil {
+		if ok {
+			c.metric.AddCacheHit()
+		} else {
+			c.metric.AddCacheMiss()
+		}
+	}
+	if !ok {
+		log.For(ctx).Debug().Str("key", key).Msg("directions: cache miss")
+		return "", nil, false
+	}
+	log.For(ctx).Debug().Str("key", key).Str("handler", entry.Handler).Msg("directions: cache hit")
+	return entry.Handler, ret, true
+}
+
+// Set caches a response served by the named handler
+func (c *ResponseCache) Set(ctx context.Context, key string, handler string, d *model.Directions) {
+	if key == "" || d == nil {
+		return
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		log.For(ctx).Error().Err(err).Msg("directions: could not encode response for cache")
+		return
+	}
+	if err := c.cache.SetTTL(ctx, key, cacheEntry{Handler: handler, Response: data}, c.ttl, c.ttl); err != nil {
+		log.For(ctx).Error().Err(err).Msg("directions: could not cache response")
+		return
+	}
+
+	// Periodically remove expired responses from the local cache
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if time.Since(c.lastExpire) > c.ttl {
+		c.lastExpire = time.Now()
+		c.cache.Expire()
+	}
+}
+
+func sortedInts(v []int) []int {
+	ret := slices.Clone(v)
+	slices.Sort(ret)
+	return slices.Compact(ret)
+}
+
+func (c *ResponseCache) roundWaypoint(w *model.WaypointInput) *model.WaypointInput {
+	if w == nil {
+		return nil
+	}
+	p := math.Pow10(c.precision)
+	return &model.WaypointInput{
+		Lon:  math.Round(w.Lon*p) / p,
+		Lat:  math.Round(w.Lat*p) / p,
+		Name: w.Name,
+	}
+}
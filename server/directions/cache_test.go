@@ -0,0 +1,106 @@
+package directions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCacheMetric struct {
+	hits   int
+	misses int
+}
+
+func (m *testCacheMetric) AddCacheHit()  { m.hits++ }
+func (m *testCacheMetric) AddCacheMiss() { m.misses++ }
+
+func TestResponseCache_Key(t *testing.T) {
+	c := NewResponseCache(nil, CacheConfig{Precision: 3, TimeBucket: 10 * time.Minute}, nil)
+	ctx := context.Background()
+	departAt := time.Date(2022, 1, 1, 8, 2, 0, 0, time.UTC)
+	req := func(lon float64, departAt time.Time) model.DirectionRequest {
+		r := testDirectionRequest(model.StepModeWalk)
+		r.From.Lon = lon
+		r.DepartAt = &departAt
+		return r
+	}
+	base := c.Key(ctx, "", req(-122.4001, departAt))
+	assert.NotEmpty(t, base)
+	assert.Equal(t, base, c.Key(ctx, "", req(-122.4004, departAt.Add(5*time.Minute))), "rounded coordinates and same time bucket")
+	assert.NotEqual(t, base, c.Key(ctx, "", req(-122.4006, departAt)), "different coordinates")
+	assert.NotEqual(t, base, c.Key(ctx, "", req(-122.4001, departAt.Add(10*time.Minute))), "different time bucket")
+	assert.NotEqual(t, base, c.Key(ctx, "valhalla", req(-122.4001, departAt)), "different handler")
+	r := req(-122.4001, departAt)
+	r.Mode = model.StepModeBicycle
+	assert.NotEqual(t, base, c.Key(ctx, "", r), "different mode")
+	r = req(-122.4001, departAt)
+	r.DepartAt = nil
+	assert.NotEqual(t, base, c.Key(ctx, "", r), "no departure time")
+	permCtx := model.WithPermFilter(ctx, &model.PermFilter{AllowedFeeds: []int{2, 1}})
+	permKey := c.Key(permCtx, "", req(-122.4001, departAt))
+	assert.NotEqual(t, base, permKey, "different permissions")
+	assert.Equal(t, permKey, c.Key(model.WithPermFilter(ctx, &model.PermFilter{AllowedFeeds: []int{1, 2}}), "", req(-122.4001, departAt)), "same permissions")
+}
+
+func TestRegistry_Cache(t *testing.T) {
+	a, b := &testHandler{}, &testHandler{fail: true}
+	r := newTestRegistry(t, Config{
+		Handlers: []HandlerConfig{{Name: "a"}, {Name: "b"}},
+		Modes:    map[string][]string{"WALK": {"a"}, "BICYCLE": {"b"}},
+	}, map[string]Handler{"a": a, "b": b})
+	metric := &testCacheMetric{}
+	r.SetCache(NewResponseCache(nil, CacheConfig{}, metric))
+	ctx := context.Background()
+
+	// Second request is served from the cache
+	for i := 0; i < 2; i++ {
+		req := testDirectionRequest(model.StepModeWalk)
+		req.From.Lon += float64(i) * 0.00001
+		ret, err := r.HandleRequest(ctx, "", req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, ret.Success)
+		assert.Equal(t, "TEST (a)", *ret.DataSource)
+	}
+	assert.Equal(t, int32(1), a.calls)
+	assert.Equal(t, 1, metric.hits)
+	assert.Equal(t, 1, metric.misses)
+
+	// Failed responses are not cached
+	for i := 0; i < 2; i++ {
+		ret, err := r.HandleRequest(ctx, "", testDirectionRequest(model.StepModeBicycle))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, ret.Success)
+	}
+	assert.Equal(t, int32(2), b.calls)
+	assert.Equal(t, 1, metric.hits)
+	assert.Equal(t, 3, metric.misses)
+}
+
+func TestResponseCache_Copy(t *testing.T) {
+	c := NewResponseCache(nil, CacheConfig{}, nil)
+	ctx := context.Background()
+	key := c.Key(ctx, "", testDirectionRequest(model.StepModeWalk))
+	startTime := time.Date(2022, 1, 1, 8, 0, 0, 0, time.UTC)
+	c.Set(ctx, key, "a", &model.Directions{
+		Success:     true,
+		DataSource:  ptr("TEST"),
+		StartTime:   &startTime,
+		Itineraries: []*model.Itinerary{{StartTime: startTime, Legs: []*model.Leg{{Mode: ptr(model.StepModeWalk)}}}},
+	})
+	name, d1, ok := c.Get(ctx, key)
+	assert.True(t, ok)
+	assert.Equal(t, "a", name)
+	if assert.Len(t, d1.Itineraries, 1) {
+		assert.True(t, startTime.Equal(d1.Itineraries[0].StartTime))
+	}
+	d1.DataSource = ptr("changed")
+	_, d2, _ := c.Get(ctx, key)
+	assert.Equal(t, "TEST", *d2.DataSource)
+}
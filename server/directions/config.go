@@ -0,0 +1,150 @@
+package directions
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Mode chain keys in addition to StepMode values
+	ModeDefault = "DEFAULT"
+	ModeTraffic = "TRAFFIC" // AUTO requests without a departure time
+
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// Config configures the routing handlers and the order they are tried for each mode
+type Config struct {
+	// Handlers available to mode chains
+	Handlers []HandlerConfig
+	// Handler names to try in order for each mode, keyed by StepMode, ModeTraffic or ModeDefault
+	Modes map[string][]string
+	// Consecutive failures before a handler is skipped; zero disables the circuit breaker
+	BreakerThreshold int
+	// Time a tripped handler is skipped before it is tried again
+	BreakerCooldown time.Duration
+	// Check itineraries against realtime data and replan when supported
+	EnableRealtime bool
+	// Maximum concurrent requests when building a matrix from individual requests
+	MatrixConcurrency int
+	// Cache successful responses keyed by normalized request
+	Cache CacheConfig
+}
+
+// HandlerConfig configures a single routing handler
+type HandlerConfig struct {
+	Name     string            // Name used in mode chains and data_source
+	Type     string            // Registered router type; defaults to Name
+	Endpoint string            // Service endpoint, for routers backed by a remote service
+	APIKey   string            // Service API key
+	Timeout  time.Duration     // Per request timeout; zero for no timeout
+	Cache    bool              // Cache HTTP responses from the remote service
+	Options  map[string]string // Router specific options
+}
+
+// ConfigFromEnv returns a Config using the TL_ROUTER_*, TL_DIRECTIONS_* and router specific environment variables.
+// TL_DIRECTIONS_ENABLE_CACHE caches responses by normalized request; TL_DIRECTIONS_ENABLE_HTTP_CACHE caches remote router HTTP responses.
+// Mode chains are comma separated handler names, e.g. TL_ROUTER_TRANSIT=raptor,tlrouter
+func ConfigFromEnv() Config {
+	cache := os.Getenv("TL_DIRECTIONS_ENABLE_HTTP_CACHE") != ""
+	cfg := Config{
+		Modes:             map[string][]string{},
+		BreakerThreshold:  defaultBreakerThreshold,
+		BreakerCooldown:   defaultBreakerCooldown,
+		EnableRealtime:    os.Getenv("TL_DIRECTIONS_ENABLE_REALTIME") != "",
+		MatrixConcurrency: envInt("TL_DIRECTIONS_MATRIX_CONCURRENCY", defaultMatrixConcurrency),
+		Cache: CacheConfig{
+			Enabled:    os.Getenv("TL_DIRECTIONS_ENABLE_CACHE") != "",
+			Precision:  envInt("TL_DIRECTIONS_CACHE_PRECISION", defaultCachePrecision),
+			TimeBucket: envDuration("TL_DIRECTIONS_CACHE_TIME_BUCKET", defaultCacheTimeBucket),
+			TTL:        envDuration("TL_DIRECTIONS_CACHE_TTL", defaultCacheTTL),
+		},
+	}
+	if v, ok := os.LookupEnv("TL_ROUTER_BREAKER_THRESHOLD"); ok {
+		cfg.BreakerThreshold, _ = strconv.Atoi(v)
+	}
+	cfg.BreakerCooldown = envDuration("TL_ROUTER_BREAKER_COOLDOWN", cfg.BreakerCooldown)
+
+	// Handlers
+	cfg.Handlers = append(cfg.Handlers,
+		HandlerConfig{Name: "line"},
+		HandlerConfig{Name: "raptor", Timeout: envDuration("TL_RAPTOR_TIMEOUT", 0)},
+		HandlerConfig{Name: "bikeshare", Options: map[string]string{
+			"walk":    os.Getenv("TL_BIKESHARE_WALK_ROUTER"),
+			"bicycle": os.Getenv("TL_BIKESHARE_BICYCLE_ROUTER"),
+		}},
+	)
+	if endpoint := os.Getenv("TL_VALHALLA_ENDPOINT"); endpoint != "" {
+		cfg.Handlers = append(cfg.Handlers, HandlerConfig{
+			Name:     "valhalla",
+			Endpoint: endpoint,
+			APIKey:   os.Getenv("TL_VALHALLA_API_KEY"),
+			Timeout:  envDuration("TL_VALHALLA_TIMEOUT", 10*time.Second),
+			Cache:    cache,
+		})
+	}
+	if endpoint := os.Getenv("TL_TLROUTER_ENDPOINT"); endpoint != "" {
+		cfg.Handlers = append(cfg.Handlers, HandlerConfig{
+			Name:     "tlrouter",
+			Endpoint: endpoint,
+			APIKey:   os.Getenv("TL_TLROUTER_APIKEY"),
+			Timeout:  envDuration("TL_TLROUTER_TIMEOUT", 60*time.Second),
+			Cache:    cache,
+		})
+	}
+	if cn := os.Getenv("TL_AWS_LOCATION_CALCULATOR"); cn != "" {
+		cfg.Handlers = append(cfg.Handlers, HandlerConfig{
+			Name:    "aws",
+			Timeout: envDuration("TL_AWS_LOCATION_TIMEOUT", 10*time.Second),
+			Cache:   cache,
+			Options: map[string]string{"calculator": cn},
+		})
+	}
+
+	// Mode chains
+	for key, env := range map[string]string{
+		"LINE":      "TL_ROUTER_LINE",
+		"TRANSIT":   "TL_ROUTER_TRANSIT",
+		"WALK":      "TL_ROUTER_WALK",
+		"BICYCLE":   "TL_ROUTER_BICYCLE",
+		"AUTO":      "TL_ROUTER_AUTO",
+		"BIKESHARE": "TL_ROUTER_BIKESHARE",
+		ModeTraffic: "TL_ROUTER_TRAFFIC",
+		ModeDefault: "TL_ROUTER_DEFAULT",
+	} {
+		if chain := splitChain(os.Getenv(env)); len(chain) > 0 {
+			cfg.Modes[key] = chain
+		}
+	}
+	if _, ok := cfg.Modes["BIKESHARE"]; !ok {
+		cfg.Modes["BIKESHARE"] = []string{"bikeshare"}
+	}
+	return cfg
+}
+
+func splitChain(v string) []string {
+	var ret []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
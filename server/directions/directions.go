@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
 	"sync"
 
 	"github.com/interline-io/log"
@@ -15,12 +14,14 @@ type Handler interface {
 	Request(context.Context, model.DirectionRequest) (*model.Directions, error)
 }
 
-type handlerFunc func() Handler
+// HandlerFactory creates a handler from its configuration
+type HandlerFactory func(HandlerConfig) (Handler, error)
 
 var handlersLock sync.Mutex
-var handlers = map[string]handlerFunc{}
+var handlers = map[string]HandlerFactory{}
 
-func RegisterRouter(name string, f handlerFunc) error {
+// RegisterRouter registers a router type that can be used in a Config
+func RegisterRouter(name string, f HandlerFactory) error {
 	handlersLock.Lock()
 	defer handlersLock.Unlock()
 	if _, ok := handlers[name]; ok {
@@ -31,65 +32,103 @@ func RegisterRouter(name string, f handlerFunc) error {
 	return nil
 }
 
-func getHandler(name string) (handlerFunc, bool) {
+func getHandler(name string) (HandlerFactory, bool) {
 	handlersLock.Lock()
 	defer handlersLock.Unlock()
 	a, ok := handlers[name]
 	return a, ok
 }
 
+// HandleRequest sends a request to the default registry
 func HandleRequest(ctx context.Context, pref string, req model.DirectionRequest) (*model.Directions, error) {
+	return DefaultRegistry().HandleRequest(ctx, pref, req)
+}
+
+func (r *Registry) HandleRequest(ctx context.Context, pref string, req model.DirectionRequest) (*model.Directions, error) {
 	// Default to walking
 	if !req.Mode.IsValid() {
 		req.Mode = model.StepModeWalk
 	}
 
-	switch req.Mode {
-	case model.StepModeLine:
-		pref = os.Getenv("TL_ROUTER_LINE")
-	case model.StepModeTransit:
-		pref = os.Getenv("TL_ROUTER_TRANSIT")
-	case model.StepModeWalk:
-		pref = os.Getenv("TL_ROUTER_WALK")
-	case model.StepModeAuto:
-		// Realtime auto requires aws
-		pref = os.Getenv("TL_ROUTER_AUTO")
-		if req.DepartAt == nil {
-			pref = os.Getenv("TL_ROUTER_TRAFFIC")
-		}
+	// Get the handlers
+	chain := r.chain(pref, req.Mode, req.DepartAt)
+
+	// If no handler found, return an error
+	if len(chain) == 0 {
+		a := "no routing handler found for mode"
+		return &model.Directions{Success: false, Exception: &a}, nil
+	}
+	if err := ValidateDirectionRequest(req); err != nil {
+		a := "invalid input"
+		return &model.Directions{Success: false, Exception: &a}, nil
 	}
 
-	// Get the handler
-	// Fallhack to TL_DEFAULT_ROUTER if no handler found
-	var handler Handler
-	if hf, ok := getHandler(pref); ok {
-		handler = hf()
-	} else if hf, ok := getHandler(os.Getenv("TL_ROUTER_DEFAULT")); ok {
-		handler = hf()
+	// Use a cached response, if available
+	var h *model.Directions
+	var served *routerHandler
+	var err error
+	cacheKey := ""
+	if r.cache != nil {
+		cacheKey = r.cache.Key(ctx, pref, req)
+		if name, cached, ok := r.cache.Get(ctx, cacheKey); ok {
+			h, served = cached, r.handlers[name]
+		}
 	}
 
-	// If no handler found, return an error
-	if handler == nil {
-		a := "no routing handler found for mode"
+	// Otherwise call each handler until one succeeds
+	if served == nil {
+		h, served, err = tryChain(ctx, chain, func(rh *routerHandler) (*model.Directions, bool, error) {
+			h, err := rh.Request(ctx, req)
+			return h, err == nil && h != nil && h.Success, err
+		})
+		if r.cache != nil && served != nil && err == nil && h != nil && h.Success {
+			r.cache.Set(ctx, cacheKey, served.name, h)
+		}
+	}
+	if served == nil {
+		a := "no routing handler available for mode"
 		return &model.Directions{Success: false, Exception: &a}, nil
 	}
+	if errors.Is(err, errHandlerTimeout) {
+		log.For(ctx).Error().Err(err).Msg("directions: handler timed out")
+		a := errHandlerTimeout.Error()
+		h, err = &model.Directions{Success: false, Exception: &a}, nil
+	}
+
+	// Optionally check itineraries against realtime data, and replan if supported
+	if err == nil && h != nil && h.Success && r.enableRealtime {
+		if infeasible := ApplyRealtime(ctx, h); infeasible {
+			if rp, ok := served.handler.(Replanner); ok {
+				h2, err2 := call(ctx, served, func(ctx context.Context) (*model.Directions, error) {
+					return rp.Replan(ctx, req, h)
+				})
+				if err2 != nil {
+					log.For(ctx).Error().Err(err2).Msg("directions: failed to replan with realtime data")
+				} else if h2 != nil && h2.Success {
+					ApplyRealtime(ctx, h2)
+					h = h2
+				}
+			}
+		}
+	}
+	if h != nil {
+		h.DataSource = handlerDataSource(served.name, h.DataSource)
+	}
 
-	// Call the handler
-	h, err := handler.Request(ctx, req)
 	a := log.For(ctx).Trace()
 	if err != nil {
 		a = log.For(ctx).Error().Err(err)
 	}
 	a = a.Str("mode", req.Mode.String()).
-		Str("handler", pref).
+		Str("handler", served.name).
 		Float64("from_lat", req.From.Lat).
 		Float64("from_lon", req.From.Lon).
 		Float64("to_lat", req.To.Lat).
 		Float64("to_lon", req.To.Lon)
-	if h.Duration != nil {
+	if h != nil && h.Duration != nil {
 		a = a.Float64("duration", h.Duration.Duration).Str("duration_units", h.Duration.Units.String())
 	}
-	if h.Distance != nil {
+	if h != nil && h.Distance != nil {
 		a = a.Float64("distance", h.Distance.Distance).Str("distance_units", h.Distance.Units.String())
 	}
 	a.Msg("directions request")
@@ -100,5 +139,5 @@ func ValidateDirectionRequest(req model.DirectionRequest) error {
 	if req.From == nil || req.To == nil {
 		return errors.New("from and to waypoints required")
 	}
-	return nil
+	return validateOptions(req)
 }
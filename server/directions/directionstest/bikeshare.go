@@ -0,0 +1,78 @@
+package directionstest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/internal/gbfs"
+	"github.com/interline-io/transitland-server/server/finders/gbfsfinder"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// BikeshareDockedFeed is a docked system with stations near BaseFrom and BaseTo.
+// The nearest stations have no bikes or no free docks.
+func BikeshareDockedFeed() gbfs.GbfsFeed {
+	station := func(id string, lon, lat float64, bikes, docks int) (*gbfs.StationInformation, *gbfs.StationStatus) {
+		return &gbfs.StationInformation{
+			StationID:  tt.NewString(id),
+			Name:       tt.NewString(id + " station"),
+			Lon:        tt.NewFloat(lon),
+			Lat:        tt.NewFloat(lat),
+			RentalURIs: &gbfs.RentalURIs{Web: tt.NewString("https://bikes.example.com/" + id)},
+		}, &gbfs.StationStatus{
+			StationID:         tt.NewString(id),
+			NumBikesAvailable: tt.NewInt(bikes),
+			NumDocksAvailable: tt.NewInt(docks),
+			IsRenting:         tt.NewBool(true),
+			IsReturning:       tt.NewBool(true),
+		}
+	}
+	feed := gbfs.GbfsFeed{
+		SystemInformation: &gbfs.SystemInformation{SystemID: tt.NewString("docked"), Name: tt.NewString("Docked Bikes")},
+	}
+	for _, s := range [][]any{
+		{"empty", BaseFrom.Lon, BaseFrom.Lat + 0.0004, 0, 10},
+		{"pickup", BaseFrom.Lon, BaseFrom.Lat + 0.0013, 3, 7},
+		{"full", BaseTo.Lon, BaseTo.Lat - 0.0004, 10, 0},
+		{"dropoff", BaseTo.Lon, BaseTo.Lat - 0.0018, 5, 5},
+	} {
+		si, ss := station(s[0].(string), s[1].(float64), s[2].(float64), s[3].(int), s[4].(int))
+		feed.StationInformation = append(feed.StationInformation, si)
+		feed.StationStatus = append(feed.StationStatus, ss)
+	}
+	return feed
+}
+
+// BikeshareFreeFloatingFeed is a free floating system with a vehicle near BaseFrom, closer than any docked station
+func BikeshareFreeFloatingFeed() gbfs.GbfsFeed {
+	return gbfs.GbfsFeed{
+		SystemInformation: &gbfs.SystemInformation{SystemID: tt.NewString("scooters"), Name: tt.NewString("Scooters")},
+		Bikes: []*gbfs.FreeBikeStatus{
+			{
+				BikeID:     tt.NewString("reserved"),
+				Lon:        tt.NewFloat(BaseFrom.Lon),
+				Lat:        tt.NewFloat(BaseFrom.Lat + 0.0001),
+				IsReserved: tt.NewBool(true),
+			},
+			{
+				BikeID:        tt.NewString("scooter"),
+				Lon:           tt.NewFloat(BaseFrom.Lon),
+				Lat:           tt.NewFloat(BaseFrom.Lat + 0.0005),
+				VehicleTypeID: tt.NewString("scooter"),
+				RentalURIs:    &gbfs.RentalURIs{Web: tt.NewString("https://scooters.example.com/scooter")},
+			},
+		},
+	}
+}
+
+// NewBikeshareFinder returns an in-memory GbfsFinder with the given feeds
+func NewBikeshareFinder(t testing.TB, feeds ...gbfs.GbfsFeed) model.GbfsFinder {
+	f := gbfsfinder.NewFinder(nil)
+	for _, feed := range feeds {
+		if err := f.AddData(context.Background(), feed.SystemInformation.SystemID.Val, feed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
@@ -0,0 +1,108 @@
+package directions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+const (
+	maxIsochroneCutoffs = 4
+	maxIsochroneCutoff  = 120 // minutes
+)
+
+var defaultIsochroneCutoffs = []int{15, 30, 45, 60}
+
+// IsochroneRequest is a request for the areas reachable from an origin within each cutoff
+type IsochroneRequest struct {
+	Origin   *model.WaypointInput
+	Mode     model.StepMode
+	DepartAt *time.Time
+	Cutoffs  []int // minutes
+}
+
+// IsochroneHandler is implemented by handlers that can calculate isochrones
+type IsochroneHandler interface {
+	Isochrones(context.Context, IsochroneRequest) (*model.Isochrones, error)
+}
+
+// HandleIsochroneRequest sends a request to the default registry
+func HandleIsochroneRequest(ctx context.Context, pref string, req IsochroneRequest) (*model.Isochrones, error) {
+	return DefaultRegistry().HandleIsochroneRequest(ctx, pref, req)
+}
+
+func (r *Registry) HandleIsochroneRequest(ctx context.Context, pref string, req IsochroneRequest) (*model.Isochrones, error) {
+	// Default to walking
+	if !req.Mode.IsValid() {
+		req.Mode = model.StepModeWalk
+	}
+	if len(req.Cutoffs) == 0 {
+		req.Cutoffs = defaultIsochroneCutoffs
+	}
+	if err := ValidateIsochroneRequest(req); err != nil {
+		a := err.Error()
+		return &model.Isochrones{Success: false, Exception: &a}, nil
+	}
+
+	// Get the handlers that support isochrones
+	var chain []*routerHandler
+	for _, rh := range r.chain(pref, req.Mode, req.DepartAt) {
+		if _, ok := rh.handler.(IsochroneHandler); ok {
+			chain = append(chain, rh)
+		}
+	}
+	if len(chain) == 0 {
+		a := "no isochrone handler found for mode"
+		return &model.Isochrones{Success: false, Exception: &a}, nil
+	}
+
+	// Call each handler until one succeeds
+	ret, served, err := tryChain(ctx, chain, func(rh *routerHandler) (*model.Isochrones, bool, error) {
+		ret, err := call(ctx, rh, func(ctx context.Context) (*model.Isochrones, error) {
+			return rh.handler.(IsochroneHandler).Isochrones(ctx, req)
+		})
+		return ret, err == nil && ret != nil && ret.Success, err
+	})
+	if served == nil {
+		a := "no isochrone handler available for mode"
+		return &model.Isochrones{Success: false, Exception: &a}, nil
+	}
+	if errors.Is(err, errHandlerTimeout) {
+		log.For(ctx).Error().Err(err).Msg("isochrone: handler timed out")
+		a := errHandlerTimeout.Error()
+		ret, err = &model.Isochrones{Success: false, Exception: &a}, nil
+	}
+	if ret != nil {
+		ret.DataSource = handlerDataSource(served.name, ret.DataSource)
+	}
+
+	a := log.For(ctx).Trace()
+	if err != nil {
+		a = log.For(ctx).Error().Err(err)
+	}
+	a.Str("mode", req.Mode.String()).
+		Str("handler", served.name).
+		Float64("lat", req.Origin.Lat).
+		Float64("lon", req.Origin.Lon).
+		Ints("cutoffs", req.Cutoffs).
+		Msg("isochrone request")
+	return ret, err
+}
+
+func ValidateIsochroneRequest(req IsochroneRequest) error {
+	if req.Origin == nil {
+		return errors.New("origin required")
+	}
+	if len(req.Cutoffs) == 0 || len(req.Cutoffs) > maxIsochroneCutoffs {
+		return errors.New("between 1 and 4 cutoffs required")
+	}
+	for _, c := range req.Cutoffs {
+		if c <= 0 || c > maxIsochroneCutoff {
+			return errors.New("cutoffs must be between 1 and 120 minutes")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package directions
+
+import (
+	"testing"
+
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateIsochroneRequest(t *testing.T) {
+	wp := &model.WaypointInput{Lon: -122.4, Lat: 37.7}
+	tcs := []struct {
+		name string
+		req  IsochroneRequest
+		ok   bool
+	}{
+		{name: "ok", req: IsochroneRequest{Origin: wp, Cutoffs: []int{15, 30}}, ok: true},
+		{name: "no origin", req: IsochroneRequest{Cutoffs: []int{15}}, ok: false},
+		{name: "no cutoffs", req: IsochroneRequest{Origin: wp}, ok: false},
+		{name: "too many cutoffs", req: IsochroneRequest{Origin: wp, Cutoffs: []int{5, 10, 15, 20, 25}}, ok: false},
+		{name: "zero cutoff", req: IsochroneRequest{Origin: wp, Cutoffs: []int{0}}, ok: false},
+		{name: "cutoff too large", req: IsochroneRequest{Origin: wp, Cutoffs: []int{121}}, ok: false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateIsochroneRequest(tc.req)
+			assert.Equal(t, tc.ok, err == nil)
+		})
+	}
+}
@@ -13,8 +13,8 @@ import (
 )
 
 func init() {
-	if err := directions.RegisterRouter("line", func() directions.Handler {
-		return &Router{}
+	if err := directions.RegisterRouter("line", func(cfg directions.HandlerConfig) (directions.Handler, error) {
+		return &Router{}, nil
 	}); err != nil {
 		panic(err)
 	}
@@ -51,6 +51,11 @@ func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*mode
 	// Ensure we are in UTC
 	departAt = departAt.In(time.UTC)
 
+	// Bikeshare trips are composed from walking and cycling lines
+	if req.Mode == model.StepModeBikeshare {
+		return h.bikeshare(ctx, req)
+	}
+
 	distance := tlxy.DistanceHaversine(tlxy.Point{Lon: req.From.Lon, Lat: req.From.Lat}, tlxy.Point{Lon: req.To.Lon, Lat: req.To.Lat}) / 1000.0
 	speed := 1.0 // m/s
 	switch req.Mode {
@@ -101,9 +106,31 @@ func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*mode
 	if len(itin.Legs) > 0 {
 		ret.Itineraries = append(ret.Itineraries, &itin)
 	}
+
+	// Arrive at the requested time
+	if req.ArriveBy != nil && *req.ArriveBy {
+		directions.ArriveBy(&ret, departAt)
+	}
+	ret.Exception = directions.IgnoredOptions(req, directions.OptionArriveBy)
 	return &ret, nil
 }
 
+func (h *Router) bikeshare(ctx context.Context, req model.DirectionRequest) (*model.Directions, error) {
+	radius := directions.DefaultBikeshareRadius
+	if req.MaxWalkDistance != nil {
+		radius = *req.MaxWalkDistance
+	}
+	rental, err := directions.FindBikeshareRental(ctx, model.ForContext(ctx).GbfsFinder, req.From, req.To, radius)
+	if err != nil || rental == nil {
+		return &model.Directions{Success: false, Exception: aws.String("no bikeshare vehicle available")}, nil
+	}
+	ret, err := directions.PlanBikeshare(ctx, req, rental, h.Request)
+	if ret != nil && ret.Success {
+		ret.Exception = directions.IgnoredOptions(req, directions.OptionArriveBy, directions.OptionMaxWalkDistance)
+	}
+	return ret, err
+}
+
 func wpiWaypoint(w *model.WaypointInput) *model.Waypoint {
 	if w == nil {
 		return nil
@@ -1,10 +1,14 @@
 package linerouter
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	dt "github.com/interline-io/transitland-server/server/directions/directionstest"
+	"github.com/interline-io/transitland-server/server/model"
 	"github.com/interline-io/transitland-server/testdata"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRouter(t *testing.T) {
@@ -58,3 +62,86 @@ func TestRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_ArriveBy(t *testing.T) {
+	req := dt.MakeBasicTests()["ped"]
+	arriveBy := true
+	maxTransfers := 2
+	req.ArriveBy = &arriveBy
+	req.MaxTransfers = &maxTransfers
+	h := &Router{}
+	ret := dt.HandlerTest(t, h, dt.TestCase{Name: "arrive_by", Req: req, Success: true, Duration: 4116, Distance: 4.116})
+	assert.True(t, ret.EndTime.Equal(dt.BaseTime), "arrives at requested time")
+	assert.True(t, ret.StartTime.Equal(dt.BaseTime.Add(-4116*time.Second)), "departs before requested time")
+	for _, itin := range ret.Itineraries {
+		assert.True(t, itin.EndTime.Equal(dt.BaseTime))
+		for _, leg := range itin.Legs {
+			assert.True(t, leg.EndTime.Equal(dt.BaseTime))
+		}
+	}
+	if assert.NotNil(t, ret.Exception) {
+		assert.Equal(t, "ignored unsupported options: max_transfers", *ret.Exception)
+	}
+}
+
+func TestRouter_Bikeshare(t *testing.T) {
+	req := dt.MakeBasicTests()["ped"]
+	req.Mode = model.StepModeBikeshare
+	h := &Router{}
+	t.Run("docked", func(t *testing.T) {
+		ctx := model.WithConfig(context.Background(), model.Config{GbfsFinder: dt.NewBikeshareFinder(t, dt.BikeshareDockedFeed())})
+		ret, err := h.Request(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, ret.Success)
+		assert.Equal(t, "LINE, GBFS", *ret.DataSource)
+		if !assert.Equal(t, 1, len(ret.Itineraries)) || !assert.Equal(t, 3, len(ret.Itineraries[0].Legs)) {
+			return
+		}
+		legs := ret.Itineraries[0].Legs
+		var modes []model.StepMode
+		for i, leg := range legs {
+			modes = append(modes, *leg.Mode)
+			if i > 0 {
+				assert.True(t, leg.StartTime.Equal(legs[i-1].EndTime), "leg departs when previous leg arrives")
+			}
+		}
+		assert.Equal(t, []model.StepMode{model.StepModeWalk, model.StepModeBikeshare, model.StepModeWalk}, modes)
+		assert.Nil(t, legs[0].Rental)
+		if rental := legs[1].Rental; assert.NotNil(t, rental) {
+			assert.Equal(t, "docked", rental.SystemID)
+			assert.Equal(t, "Docked Bikes", *rental.SystemName)
+			assert.Equal(t, "pickup", rental.FromStation.StationID)
+			assert.Equal(t, "dropoff", rental.ToStation.StationID)
+			assert.Equal(t, "https://bikes.example.com/pickup", rental.RentalUris.Web.Val)
+		}
+		assert.True(t, ret.StartTime.Equal(dt.BaseTime))
+		assert.True(t, ret.EndTime.Equal(legs[2].EndTime))
+	})
+	t.Run("free floating", func(t *testing.T) {
+		ctx := model.WithConfig(context.Background(), model.Config{GbfsFinder: dt.NewBikeshareFinder(t, dt.BikeshareDockedFeed(), dt.BikeshareFreeFloatingFeed())})
+		ret, err := h.Request(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, ret.Success)
+		if !assert.Equal(t, 1, len(ret.Itineraries)) || !assert.Equal(t, 2, len(ret.Itineraries[0].Legs)) {
+			return
+		}
+		if rental := ret.Itineraries[0].Legs[1].Rental; assert.NotNil(t, rental) {
+			assert.Equal(t, "scooters", rental.SystemID)
+			assert.Equal(t, "scooter", *rental.VehicleID)
+			assert.Nil(t, rental.FromStation)
+			assert.Equal(t, "https://scooters.example.com/scooter", rental.RentalUris.Web.Val)
+		}
+	})
+	t.Run("no vehicles", func(t *testing.T) {
+		ret, err := h.Request(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, ret.Success)
+		assert.Equal(t, "no bikeshare vehicle available", *ret.Exception)
+	})
+}
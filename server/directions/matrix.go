@@ -0,0 +1,212 @@
+package directions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+const (
+	defaultMatrixConcurrency = 8
+	maxMatrixCells           = 2500
+)
+
+// MatrixRequest is a request for travel times between each origin and each destination
+type MatrixRequest struct {
+	Origins      []*model.WaypointInput
+	Destinations []*model.WaypointInput
+	Mode         model.StepMode
+	DepartAt     *time.Time
+}
+
+// MatrixHandler is implemented by handlers that can calculate travel time matrices directly.
+// Other handlers fall back to a Request for each origin and destination pair.
+type MatrixHandler interface {
+	Matrix(context.Context, MatrixRequest) (*model.TravelTimeMatrix, error)
+}
+
+// HandleMatrixRequest sends a request to the default registry
+func HandleMatrixRequest(ctx context.Context, pref string, req MatrixRequest) (*model.TravelTimeMatrix, error) {
+	return DefaultRegistry().HandleMatrixRequest(ctx, pref, req)
+}
+
+func (r *Registry) HandleMatrixRequest(ctx context.Context, pref string, req MatrixRequest) (*model.TravelTimeMatrix, error) {
+	// Default to walking
+	if !req.Mode.IsValid() {
+		req.Mode = model.StepModeWalk
+	}
+	if err := ValidateMatrixRequest(req); err != nil {
+		a := err.Error()
+		return &model.TravelTimeMatrix{Success: false, Exception: &a}, nil
+	}
+
+	// Get the handlers
+	chain := r.chain(pref, req.Mode, req.DepartAt)
+	if len(chain) == 0 {
+		a := "no routing handler found for mode"
+		return &model.TravelTimeMatrix{Success: false, Exception: &a}, nil
+	}
+
+	// Call each handler until one succeeds
+	ret, served, err := tryChain(ctx, chain, func(rh *routerHandler) (*model.TravelTimeMatrix, bool, error) {
+		var ret *model.TravelTimeMatrix
+		var err error
+		if mh, ok := rh.handler.(MatrixHandler); ok {
+			ret, err = call(ctx, rh, func(ctx context.Context) (*model.TravelTimeMatrix, error) {
+				return mh.Matrix(ctx, req)
+			})
+		} else {
+			ret, err = RequestMatrix(ctx, rh, req, r.matrixConcurrency)
+		}
+		return ret, err == nil && ret != nil && ret.Success, err
+	})
+	if served == nil {
+		a := "no routing handler available for mode"
+		return &model.TravelTimeMatrix{Success: false, Exception: &a}, nil
+	}
+	if errors.Is(err, errHandlerTimeout) {
+		log.For(ctx).Error().Err(err).Msg("travel time matrix: handler timed out")
+		a := errHandlerTimeout.Error()
+		ret, err = &model.TravelTimeMatrix{Success: false, Exception: &a}, nil
+	}
+	if ret != nil {
+		ret.DataSource = handlerDataSource(served.name, ret.DataSource)
+	}
+
+	a := log.For(ctx).Trace()
+	if err != nil {
+		a = log.For(ctx).Error().Err(err)
+	}
+	a.Str("mode", req.Mode.String()).
+		Str("handler", served.name).
+		Int("origins", len(req.Origins)).
+		Int("destinations", len(req.Destinations)).
+		Msg("travel time matrix request")
+	if err != nil && ret != nil && ret.Exception != nil {
+		// Failed requests are reported through the matrix exception
+		return ret, nil
+	}
+	return ret, err
+}
+
+func ValidateMatrixRequest(req MatrixRequest) error {
+	if len(req.Origins) == 0 || len(req.Destinations) == 0 {
+		return errors.New("origins and destinations required")
+	}
+	if len(req.Origins)*len(req.Destinations) > maxMatrixCells {
+		return errors.New("too many origins and destinations")
+	}
+	return nil
+}
+
+// RequestMatrix builds a matrix from a Request for each origin and destination pair,
+// with at most concurrency requests in flight.
+// The matrix is only successful if at least one cell was found; otherwise the first request error is returned.
+func RequestMatrix(ctx context.Context, handler Handler, req MatrixRequest, concurrency int) (*model.TravelTimeMatrix, error) {
+	ret := NewMatrix(req)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var dataSource *string
+	var firstErr error
+	errCount := 0
+	found := 0
+schedule:
+	for i, origin := range req.Origins {
+		for j, destination := range req.Destinations {
+			// Stop scheduling requests once the context is done
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				break schedule
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				d, err := handler.Request(ctx, model.DirectionRequest{
+					From:     origin,
+					To:       destination,
+					Mode:     req.Mode,
+					DepartAt: req.DepartAt,
+				})
+				if err != nil {
+					log.For(ctx).Debug().Err(err).Int("origin", i).Int("destination", j).Msg("travel time matrix: request failed")
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errCount++
+					mu.Unlock()
+					return
+				}
+				if d == nil || !d.Success {
+					return
+				}
+				// Each cell is written by a single goroutine
+				ret.Durations[i][j] = d.Duration
+				ret.Distances[i][j] = d.Distance
+				mu.Lock()
+				if dataSource == nil {
+					dataSource = d.DataSource
+				}
+				found++
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if errCount > 0 {
+		log.For(ctx).Error().Err(firstErr).Int("errors", errCount).Int("cells", len(req.Origins)*len(req.Destinations)).Msg("travel time matrix: requests failed")
+	}
+	ret.DataSource = dataSource
+	if found == 0 {
+		a := "no travel times found"
+		if firstErr != nil {
+			a = firstErr.Error()
+		}
+		ret.Exception = &a
+		return ret, firstErr
+	}
+	ret.Success = true
+	return ret, nil
+}
+
+// NewMatrix returns an empty matrix for a request
+func NewMatrix(req MatrixRequest) *model.TravelTimeMatrix {
+	ret := model.TravelTimeMatrix{}
+	for _, w := range req.Origins {
+		ret.Origins = append(ret.Origins, wpiWaypoint(w))
+	}
+	for _, w := range req.Destinations {
+		ret.Destinations = append(ret.Destinations, wpiWaypoint(w))
+	}
+	ret.Durations = make([][]*model.Duration, len(req.Origins))
+	ret.Distances = make([][]*model.Distance, len(req.Origins))
+	for i := range req.Origins {
+		ret.Durations[i] = make([]*model.Duration, len(req.Destinations))
+		ret.Distances[i] = make([]*model.Distance, len(req.Destinations))
+	}
+	return &ret
+}
+
+func wpiWaypoint(w *model.WaypointInput) *model.Waypoint {
+	if w == nil {
+		return nil
+	}
+	return &model.Waypoint{
+		Lon:  w.Lon,
+		Lat:  w.Lat,
+		Name: w.Name,
+	}
+}
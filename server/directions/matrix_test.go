@@ -0,0 +1,127 @@
+package directions
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMatrixHandler struct {
+	inflight    int32
+	maxInflight int32
+}
+
+func (h *testMatrixHandler) Request(ctx context.Context, req model.DirectionRequest) (*model.Directions, error) {
+	n := atomic.AddInt32(&h.inflight, 1)
+	defer atomic.AddInt32(&h.inflight, -1)
+	for {
+		cur := atomic.LoadInt32(&h.maxInflight)
+		if n <= cur || atomic.CompareAndSwapInt32(&h.maxInflight, cur, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	if req.To.Lon < 0 {
+		return &model.Directions{Success: false}, nil
+	}
+	return &model.Directions{
+		Success:    true,
+		DataSource: ptr("test"),
+		Duration:   &model.Duration{Duration: req.From.Lon + req.To.Lon, Units: model.DurationUnitSeconds},
+		Distance:   &model.Distance{Distance: req.From.Lat + req.To.Lat, Units: model.DistanceUnitKilometers},
+	}, nil
+}
+
+func TestRequestMatrix(t *testing.T) {
+	req := MatrixRequest{
+		Origins:      []*model.WaypointInput{{Lon: 1, Lat: 10}, {Lon: 2, Lat: 20}, {Lon: 3, Lat: 30}},
+		Destinations: []*model.WaypointInput{{Lon: 100, Lat: 1000}, {Lon: -1, Lat: 0}},
+	}
+	h := &testMatrixHandler{}
+	ret, err := RequestMatrix(context.Background(), h, req, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ret.Success)
+	assert.Equal(t, "test", *ret.DataSource)
+	assert.LessOrEqual(t, h.maxInflight, int32(2))
+	assert.Equal(t, 3, len(ret.Durations))
+	for i, row := range ret.Durations {
+		assert.Equal(t, 2, len(row))
+		if assert.NotNil(t, row[0]) {
+			assert.Equal(t, float64(i+1+100), row[0].Duration)
+			assert.Equal(t, float64((i+1)*10+1000), ret.Distances[i][0].Distance)
+		}
+		assert.Nil(t, row[1], "no route")
+		assert.Nil(t, ret.Distances[i][1], "no route")
+	}
+}
+
+func TestRequestMatrix_NoneFound(t *testing.T) {
+	req := MatrixRequest{
+		Origins:      []*model.WaypointInput{{Lon: 1, Lat: 10}, {Lon: 2, Lat: 20}},
+		Destinations: []*model.WaypointInput{{Lon: -1, Lat: 0}},
+	}
+	ret, err := RequestMatrix(context.Background(), &testMatrixHandler{}, req, 2)
+	assert.NoError(t, err)
+	assert.False(t, ret.Success)
+	assert.NotNil(t, ret.Exception)
+}
+
+func TestRequestMatrix_Errors(t *testing.T) {
+	req := MatrixRequest{
+		Origins:      []*model.WaypointInput{{Lon: 1, Lat: 10}, {Lon: 2, Lat: 20}},
+		Destinations: []*model.WaypointInput{{Lon: 100, Lat: 1000}},
+	}
+	reqErr := errors.New("request failed")
+	ret, err := RequestMatrix(context.Background(), &testErrorHandler{err: reqErr}, req, 2)
+	assert.ErrorIs(t, err, reqErr)
+	assert.False(t, ret.Success)
+	if assert.NotNil(t, ret.Exception) {
+		assert.Equal(t, reqErr.Error(), *ret.Exception)
+	}
+}
+
+func TestRequestMatrix_Canceled(t *testing.T) {
+	var many []*model.WaypointInput
+	for i := 0; i < 20; i++ {
+		many = append(many, &model.WaypointInput{Lon: 1, Lat: 1})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h := &testErrorHandler{}
+	ret, err := RequestMatrix(ctx, h, MatrixRequest{Origins: many, Destinations: many}, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, ret.Success)
+	assert.Less(t, int(atomic.LoadInt32(&h.count)), len(many)*len(many), "expected scheduling to stop")
+}
+
+type testErrorHandler struct {
+	err   error
+	count int32
+}
+
+func (h *testErrorHandler) Request(ctx context.Context, req model.DirectionRequest) (*model.Directions, error) {
+	atomic.AddInt32(&h.count, 1)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, h.err
+}
+
+func TestValidateMatrixRequest(t *testing.T) {
+	wp := &model.WaypointInput{}
+	assert.Error(t, ValidateMatrixRequest(MatrixRequest{}))
+	assert.Error(t, ValidateMatrixRequest(MatrixRequest{Origins: []*model.WaypointInput{wp}}))
+	assert.NoError(t, ValidateMatrixRequest(MatrixRequest{Origins: []*model.WaypointInput{wp}, Destinations: []*model.WaypointInput{wp}}))
+	var many []*model.WaypointInput
+	for i := 0; i < 51; i++ {
+		many = append(many, wp)
+	}
+	assert.Error(t, ValidateMatrixRequest(MatrixRequest{Origins: many, Destinations: many}))
+}
@@ -0,0 +1,131 @@
+package directions
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// DirectionRequest routing options
+const (
+	OptionArriveBy              = "arrive_by"
+	OptionNumItineraries        = "num_itineraries"
+	OptionMaxWalkDistance       = "max_walk_distance"
+	OptionWheelchair            = "wheelchair"
+	OptionAllowedRouteTypes     = "allowed_route_types"
+	OptionBannedRouteOnestopIds = "banned_route_onestop_ids"
+	OptionWalkSpeed             = "walk_speed"
+	OptionMaxTransfers          = "max_transfers"
+)
+
+// RequestOptions returns the routing options set in a request
+func RequestOptions(req model.DirectionRequest) []string {
+	var ret []string
+	if req.ArriveBy != nil && *req.ArriveBy {
+		ret = append(ret, OptionArriveBy)
+	}
+	if req.NumItineraries != nil {
+		ret = append(ret, OptionNumItineraries)
+	}
+	if req.MaxWalkDistance != nil {
+		ret = append(ret, OptionMaxWalkDistance)
+	}
+	if req.Wheelchair != nil && *req.Wheelchair {
+		ret = append(ret, OptionWheelchair)
+	}
+	if len(req.AllowedRouteTypes) > 0 {
+		ret = append(ret, OptionAllowedRouteTypes)
+	}
+	if len(req.BannedRouteOnestopIds) > 0 {
+		ret = append(ret, OptionBannedRouteOnestopIds)
+	}
+	if req.WalkSpeed != nil {
+		ret = append(ret, OptionWalkSpeed)
+	}
+	if req.MaxTransfers != nil {
+		ret = append(ret, OptionMaxTransfers)
+	}
+	return ret
+}
+
+// IgnoredOptions returns a message listing the options set in a request that a handler does not support, or nil
+func IgnoredOptions(req model.DirectionRequest, supported ...string) *string {
+	var ignored []string
+	for _, opt := range RequestOptions(req) {
+		found := false
+		for _, s := range supported {
+			if s == opt {
+				found = true
+			}
+		}
+		if !found {
+			ignored = append(ignored, opt)
+		}
+	}
+	if len(ignored) == 0 {
+		return nil
+	}
+	a := "ignored unsupported options: " + strings.Join(ignored, ", ")
+	return &a
+}
+
+func validateOptions(req model.DirectionRequest) error {
+	if req.NumItineraries != nil && *req.NumItineraries < 1 {
+		return errors.New("num_itineraries must be at least 1")
+	}
+	if req.MaxWalkDistance != nil && *req.MaxWalkDistance < 0 {
+		return errors.New("max_walk_distance must not be negative")
+	}
+	if req.WalkSpeed != nil && *req.WalkSpeed <= 0 {
+		return errors.New("walk_speed must be positive")
+	}
+	if req.MaxTransfers != nil && *req.MaxTransfers < 0 {
+		return errors.New("max_transfers must not be negative")
+	}
+	return nil
+}
+
+// ArriveBy shifts each itinerary in a response to arrive at the requested time.
+// This is used by handlers for time-independent modes.
+func ArriveBy(d *model.Directions, arriveAt time.Time) {
+	if d == nil {
+		return
+	}
+	for _, itin := range d.Itineraries {
+		shiftItinerary(itin, arriveAt.Sub(itin.EndTime))
+	}
+	if len(d.Itineraries) > 0 {
+		r0 := d.Itineraries[0]
+		d.StartTime = &r0.StartTime
+		d.EndTime = &r0.EndTime
+	}
+}
+
+func shiftItinerary(itin *model.Itinerary, delta time.Duration) {
+	itin.StartTime = itin.StartTime.Add(delta)
+	itin.EndTime = itin.EndTime.Add(delta)
+	shiftWaypoint(itin.From, delta)
+	shiftWaypoint(itin.To, delta)
+	for _, leg := range itin.Legs {
+		leg.StartTime = leg.StartTime.Add(delta)
+		leg.EndTime = leg.EndTime.Add(delta)
+		shiftWaypoint(leg.From, delta)
+		shiftWaypoint(leg.To, delta)
+		for _, step := range leg.Steps {
+			step.StartTime = step.StartTime.Add(delta)
+			step.EndTime = step.EndTime.Add(delta)
+			shiftWaypoint(step.To, delta)
+		}
+		for _, stop := range leg.Stops {
+			stop.Departure = stop.Departure.Add(delta)
+		}
+	}
+}
+
+func shiftWaypoint(w *model.Waypoint, delta time.Duration) {
+	if w != nil && w.Stop != nil {
+		w.Stop.Departure = w.Stop.Departure.Add(delta)
+	}
+}
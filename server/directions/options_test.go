@@ -0,0 +1,77 @@
+package directions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoredOptions(t *testing.T) {
+	req := model.DirectionRequest{
+		ArriveBy:          ptr(true),
+		Wheelchair:        ptr(false),
+		MaxTransfers:      ptr(1),
+		AllowedRouteTypes: []int{3},
+	}
+	assert.Equal(t, []string{OptionArriveBy, OptionAllowedRouteTypes, OptionMaxTransfers}, RequestOptions(req))
+	if a := IgnoredOptions(req, OptionArriveBy); assert.NotNil(t, a) {
+		assert.Equal(t, "ignored unsupported options: allowed_route_types, max_transfers", *a)
+	}
+	assert.Nil(t, IgnoredOptions(req, OptionArriveBy, OptionAllowedRouteTypes, OptionMaxTransfers))
+	assert.Nil(t, IgnoredOptions(model.DirectionRequest{}))
+}
+
+func TestValidateDirectionRequest_Options(t *testing.T) {
+	wp := &model.WaypointInput{Lon: -122.4, Lat: 37.7}
+	tcs := []struct {
+		name string
+		req  model.DirectionRequest
+		ok   bool
+	}{
+		{name: "no options", req: model.DirectionRequest{}, ok: true},
+		{name: "num_itineraries", req: model.DirectionRequest{NumItineraries: ptr(0)}, ok: false},
+		{name: "max_walk_distance", req: model.DirectionRequest{MaxWalkDistance: ptr(-1.0)}, ok: false},
+		{name: "walk_speed", req: model.DirectionRequest{WalkSpeed: ptr(0.0)}, ok: false},
+		{name: "max_transfers", req: model.DirectionRequest{MaxTransfers: ptr(-1)}, ok: false},
+		{name: "max_transfers zero", req: model.DirectionRequest{MaxTransfers: ptr(0)}, ok: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.From = wp
+			tc.req.To = wp
+			err := ValidateDirectionRequest(tc.req)
+			assert.Equal(t, tc.ok, err == nil)
+		})
+	}
+}
+
+func TestArriveBy(t *testing.T) {
+	start := time.Unix(1000, 0).In(time.UTC)
+	end := start.Add(600 * time.Second)
+	arriveAt := time.Unix(5000, 0).In(time.UTC)
+	itin := &model.Itinerary{
+		StartTime: start,
+		EndTime:   end,
+		Legs: []*model.Leg{{
+			StartTime: start,
+			EndTime:   end,
+			To:        &model.Waypoint{Stop: &model.WaypointStop{Departure: end}},
+			Steps:     []*model.Step{{StartTime: start, EndTime: end}},
+			Stops:     []*model.WaypointDeparture{{Departure: start}},
+		}},
+	}
+	d := &model.Directions{Itineraries: []*model.Itinerary{itin}}
+	ArriveBy(d, arriveAt)
+	delta := arriveAt.Sub(end)
+	assert.Equal(t, arriveAt, itin.EndTime)
+	assert.Equal(t, start.Add(delta), itin.StartTime)
+	assert.Equal(t, arriveAt, *d.EndTime)
+	assert.Equal(t, start.Add(delta), *d.StartTime)
+	leg := itin.Legs[0]
+	assert.Equal(t, arriveAt, leg.EndTime)
+	assert.Equal(t, arriveAt, leg.To.Stop.Departure)
+	assert.Equal(t, arriveAt, leg.Steps[0].EndTime)
+	assert.Equal(t, start.Add(delta), leg.Stops[0].Departure)
+}
@@ -0,0 +1,170 @@
+package pathways
+
+import (
+	"container/heap"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// GTFS pathway_mode values
+const (
+	ModeWalkway        = 1
+	ModeStairs         = 2
+	ModeMovingSidewalk = 3
+	ModeEscalator      = 4
+	ModeElevator       = 5
+	ModeFareGate       = 6
+	ModeExitGate       = 7
+)
+
+const (
+	walkSpeed          = 1.4   // m/s
+	defaultTraversal   = 10.0  // seconds, when a pathway has no time, length or coordinates
+	maxWheelchairSlope = 0.083 // 1:12 ramp
+)
+
+// Edge is a pathway traversed in one direction
+type Edge struct {
+	Pathway  *model.Pathway
+	From     int
+	To       int
+	Reverse  bool    // traversed from to_stop_id to from_stop_id
+	Duration float64 // seconds
+	Length   float64 // meters
+}
+
+// Signpost returns the signage for the direction of travel
+func (e *Edge) Signpost() string {
+	if e.Reverse {
+		return e.Pathway.ReverseSignpostedAs.Val
+	}
+	return e.Pathway.SignpostedAs.Val
+}
+
+// Graph is the pathways network of a station, keyed by stop ID
+type Graph struct {
+	Stops map[int]*model.Stop
+	edges map[int][]*Edge
+}
+
+// NewGraph builds a graph from pathways between stops; bidirectional pathways add an edge in each direction
+func NewGraph(stops []*model.Stop, pathways []*model.Pathway) *Graph {
+	g := &Graph{Stops: map[int]*model.Stop{}, edges: map[int][]*Edge{}}
+	for _, s := range stops {
+		g.Stops[s.ID] = s
+	}
+	seen := map[int]bool{}
+	for _, pw := range pathways {
+		if pw == nil || seen[pw.ID] {
+			continue
+		}
+		seen[pw.ID] = true
+		from, to := pw.FromStopID.Int(), pw.ToStopID.Int()
+		g.addEdge(pw, from, to, false)
+		if pw.IsBidirectional.Val == 1 {
+			g.addEdge(pw, to, from, true)
+		}
+	}
+	return g
+}
+
+func (g *Graph) addEdge(pw *model.Pathway, from int, to int, reverse bool) {
+	e := Edge{Pathway: pw, From: from, To: to, Reverse: reverse}
+	e.Length = pw.Length.Val
+	if !pw.Length.Valid {
+		e.Length = g.distance(from, to)
+	}
+	if pw.TraversalTime.Valid {
+		e.Duration = float64(pw.TraversalTime.Val)
+	} else if e.Length > 0 {
+		e.Duration = e.Length / walkSpeed
+	} else {
+		e.Duration = defaultTraversal
+	}
+	g.edges[from] = append(g.edges[from], &e)
+}
+
+func (g *Graph) distance(from int, to int) float64 {
+	a, b := g.Stops[from], g.Stops[to]
+	if a == nil || b == nil || !a.Geometry.Valid || !b.Geometry.Valid {
+		return 0
+	}
+	ac, bc := a.Coordinates(), b.Coordinates()
+	return tlxy.DistanceHaversine(tlxy.Point{Lon: ac[0], Lat: ac[1]}, tlxy.Point{Lon: bc[0], Lat: bc[1]})
+}
+
+// ShortestPath returns the fastest sequence of edges between two stops, or nil if there is no path.
+// If wheelchair is set, stairs, escalators and steep slopes are avoided.
+func (g *Graph) ShortestPath(from int, to int, wheelchair bool) []*Edge {
+	dist := map[int]float64{from: 0}
+	prev := map[int]*Edge{}
+	done := map[int]bool{}
+	pq := &edgeQueue{{stop: from}}
+	for pq.Len() > 0 {
+		cur := heap.Pop(pq).(queueItem)
+		if done[cur.stop] {
+			continue
+		}
+		done[cur.stop] = true
+		if cur.stop == to {
+			break
+		}
+		for _, e := range g.edges[cur.stop] {
+			if wheelchair && !accessible(e.Pathway) {
+				continue
+			}
+			d := cur.dist + e.Duration
+			if old, ok := dist[e.To]; ok && old <= d {
+				continue
+			}
+			dist[e.To] = d
+			prev[e.To] = e
+			heap.Push(pq, queueItem{stop: e.To, dist: d})
+		}
+	}
+	if !done[to] {
+		return nil
+	}
+	var path []*Edge
+	for cur := to; cur != from; cur = prev[cur].From {
+		path = append(path, prev[cur])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+func accessible(pw *model.Pathway) bool {
+	switch pw.PathwayMode.Val {
+	case ModeStairs, ModeEscalator:
+		return false
+	}
+	if pw.StairCount.Val > 0 {
+		return false
+	}
+	if pw.MaxSlope.Val > maxWheelchairSlope || pw.MaxSlope.Val < -maxWheelchairSlope {
+		return false
+	}
+	return true
+}
+
+type queueItem struct {
+	stop int
+	dist float64
+}
+
+type edgeQueue []queueItem
+
+func (q edgeQueue) Len() int           { return len(q) }
+func (q edgeQueue) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q edgeQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *edgeQueue) Push(x any)        { *q = append(*q, x.(queueItem)) }
+func (q *edgeQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
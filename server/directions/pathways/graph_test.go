@@ -0,0 +1,177 @@
+package pathways
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-lib/gtfs"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStop(id int, stopID string, name string, lon, lat float64) *model.Stop {
+	s := model.Stop{}
+	s.ID = id
+	s.StopID = tt.NewString(stopID)
+	s.StopName = tt.NewString(name)
+	s.Geometry = tt.NewPoint(lon, lat)
+	return &s
+}
+
+func testPathway(id int, from int, to int, mode int, bidirectional bool, traversal int) *model.Pathway {
+	pw := model.Pathway{Pathway: gtfs.Pathway{
+		PathwayID:   tt.NewString("pw" + strconv.Itoa(id)),
+		FromStopID:  tt.NewString(strconv.Itoa(from)),
+		ToStopID:    tt.NewString(strconv.Itoa(to)),
+		PathwayMode: tt.NewInt(mode),
+	}}
+	pw.ID = id
+	if bidirectional {
+		pw.IsBidirectional = tt.NewInt(1)
+	}
+	if traversal > 0 {
+		pw.TraversalTime = tt.NewInt(traversal)
+	}
+	return &pw
+}
+
+// testStation is an entrance (2) connected to a platform (5) by stairs through a mezzanine (3),
+// or by a slower elevator (4); a faster one-way exit gate leads from the platform back to the entrance.
+func testStation() *Graph {
+	stops := []*model.Stop{
+		testStop(1, "station", "Station", -122.2700, 37.8000),
+		testStop(2, "entrance", "Main St Entrance", -122.2700, 37.8010),
+		testStop(3, "mezzanine", "Mezzanine", -122.2702, 37.8005),
+		testStop(4, "elevator", "Elevator Lobby", -122.2698, 37.8005),
+		testStop(5, "platform", "Platform 1", -122.2700, 37.8000),
+	}
+	stairs := testPathway(10, 2, 3, ModeStairs, true, 30)
+	stairs.SignpostedAs = tt.NewString("Trains")
+	stairs.ReverseSignpostedAs = tt.NewString("Main St")
+	pathways := []*model.Pathway{
+		stairs,
+		testPathway(11, 3, 5, ModeFareGate, true, 10),
+		testPathway(12, 2, 4, ModeWalkway, true, 40),
+		testPathway(13, 4, 5, ModeElevator, true, 60),
+		testPathway(14, 5, 2, ModeExitGate, false, 20),
+		stairs, // duplicate from the to_stop_id lookup
+	}
+	return NewGraph(stops, pathways)
+}
+
+func pathIDs(path []*Edge) []string {
+	var ret []string
+	for _, e := range path {
+		ret = append(ret, e.Pathway.PathwayID.Val)
+	}
+	return ret
+}
+
+func TestGraph_ShortestPath(t *testing.T) {
+	g := testStation()
+	t.Run("fastest", func(t *testing.T) {
+		path := g.ShortestPath(2, 5, false)
+		assert.Equal(t, []string{"pw10", "pw11"}, pathIDs(path))
+		assert.Equal(t, "Trains", path[0].Signpost())
+	})
+	t.Run("reverse", func(t *testing.T) {
+		path := g.ShortestPath(3, 2, false)
+		if assert.Equal(t, []string{"pw10"}, pathIDs(path)) {
+			assert.True(t, path[0].Reverse)
+			assert.Equal(t, "Main St", path[0].Signpost())
+		}
+	})
+	t.Run("wheelchair", func(t *testing.T) {
+		assert.Equal(t, []string{"pw12", "pw13"}, pathIDs(g.ShortestPath(2, 5, true)))
+	})
+	t.Run("one way", func(t *testing.T) {
+		assert.Equal(t, []string{"pw14"}, pathIDs(g.ShortestPath(5, 2, false)))
+		assert.Equal(t, []string{"pw10", "pw11"}, pathIDs(g.ShortestPath(2, 5, false)))
+	})
+	t.Run("no path", func(t *testing.T) {
+		assert.Nil(t, g.ShortestPath(2, 1, false))
+	})
+}
+
+func TestGraph_Duration(t *testing.T) {
+	stops := []*model.Stop{
+		testStop(1, "a", "A", -122.2700, 37.8000),
+		testStop(2, "b", "B", -122.2700, 37.8010),
+	}
+	withLength := testPathway(1, 1, 2, ModeWalkway, false, 0)
+	withLength.Length = tt.NewFloat(14)
+	g := NewGraph(stops, []*model.Pathway{withLength, testPathway(2, 2, 1, ModeWalkway, false, 0)})
+	if path := g.ShortestPath(1, 2, false); assert.Len(t, path, 1) {
+		assert.InDelta(t, 10.0, path[0].Duration, 0.001)
+		assert.InDelta(t, 14.0, path[0].Length, 0.001)
+	}
+	if path := g.ShortestPath(2, 1, false); assert.Len(t, path, 1) {
+		assert.InDelta(t, 111.2, path[0].Length, 0.1)
+		assert.InDelta(t, path[0].Length/walkSpeed, path[0].Duration, 0.001)
+	}
+}
+
+func TestAccessible(t *testing.T) {
+	tcs := []struct {
+		name   string
+		mode   int
+		stairs int
+		slope  float64
+		expect bool
+	}{
+		{"walkway", ModeWalkway, 0, 0, true},
+		{"elevator", ModeElevator, 0, 0, true},
+		{"stairs", ModeStairs, 0, 0, false},
+		{"escalator", ModeEscalator, 0, 0, false},
+		{"walkway with steps", ModeWalkway, 2, 0, false},
+		{"ramp", ModeWalkway, 0, 0.05, true},
+		{"steep ramp", ModeWalkway, 0, 0.1, false},
+		{"steep ramp down", ModeWalkway, 0, -0.1, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			pw := testPathway(1, 1, 2, tc.mode, false, 0)
+			if tc.stairs > 0 {
+				pw.StairCount = tt.NewInt(tc.stairs)
+			}
+			if tc.slope != 0 {
+				pw.MaxSlope = tt.NewFloat(tc.slope)
+			}
+			assert.Equal(t, tc.expect, accessible(pw))
+		})
+	}
+}
+
+func TestMakeDirections(t *testing.T) {
+	g := testStation()
+	departAt := time.Date(2022, 1, 1, 8, 0, 0, 0, time.UTC)
+	d := makeDirections(g, g.Stops[2], g.Stops[5], g.ShortestPath(2, 5, true), departAt)
+	assert.True(t, d.Success)
+	if !assert.Len(t, d.Itineraries, 1) || !assert.Len(t, d.Itineraries[0].Legs, 1) {
+		return
+	}
+	leg := d.Itineraries[0].Legs[0]
+	assert.Equal(t, model.StepModeWalk, *leg.Mode)
+	assert.Equal(t, 100.0, leg.Duration.Duration)
+	assert.Equal(t, departAt.Add(100*time.Second), leg.EndTime)
+	assert.Equal(t, "Main St Entrance", *leg.From.Name)
+	assert.Equal(t, "Platform 1", *leg.To.Name)
+	assert.Equal(t, 3, len(leg.Geometry.ToPoints()))
+	var instructions []string
+	var offsets []int
+	for _, step := range leg.Steps {
+		instructions = append(instructions, step.Instruction)
+		offsets = append(offsets, step.GeometryOffset)
+	}
+	assert.Equal(t, []string{"Walk to Elevator Lobby", "Take the elevator to Platform 1"}, instructions)
+	assert.Equal(t, []int{0, 1}, offsets)
+	assert.Equal(t, departAt.Add(40*time.Second), leg.Steps[1].StartTime)
+
+	// Signposted
+	d = makeDirections(g, g.Stops[2], g.Stops[5], g.ShortestPath(2, 5, false), departAt)
+	if assert.Len(t, d.Itineraries, 1) {
+		assert.Equal(t, "Take the stairs, following signs for Trains", d.Itineraries[0].Legs[0].Steps[0].Instruction)
+	}
+}
@@ -0,0 +1,221 @@
+package pathways
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/internal/clock"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// Request is a request for walking directions between two stops, platforms or entrances within a station
+type Request struct {
+	Station    *model.Stop
+	FromStopID string // GTFS stop_id
+	ToStopID   string // GTFS stop_id
+	Wheelchair bool
+	DepartAt   *time.Time
+}
+
+// Router finds walking directions within a station using GTFS pathways
+type Router struct {
+	Clock  clock.Clock
+	Finder model.Finder
+}
+
+func NewRouter(finder model.Finder) *Router {
+	return &Router{Finder: finder}
+}
+
+func (h *Router) Request(ctx context.Context, req Request) (*model.Directions, error) {
+	if req.Station == nil || req.FromStopID == "" || req.ToStopID == "" {
+		return &model.Directions{Success: false, Exception: aws.String("invalid input")}, nil
+	}
+	if req.Station.LocationType.Val != 1 {
+		return &model.Directions{Success: false, Exception: aws.String("stop is not a station")}, nil
+	}
+
+	// Prepare time
+	departAt := time.Now().In(time.UTC)
+	if h.Clock != nil {
+		departAt = h.Clock.Now()
+	}
+	if req.DepartAt != nil {
+		departAt = *req.DepartAt
+	}
+	departAt = departAt.In(time.UTC)
+
+	// Load station graph
+	g, err := h.loadGraph(ctx, req.Station)
+	if err != nil {
+		return nil, err
+	}
+	from, to := g.findStop(req.FromStopID), g.findStop(req.ToStopID)
+	if from == nil || to == nil {
+		return &model.Directions{Success: false, Exception: aws.String("stop not found in station")}, nil
+	}
+
+	// Find path
+	path := g.ShortestPath(from.ID, to.ID, req.Wheelchair)
+	if path == nil && from.ID != to.ID {
+		return &model.Directions{Success: false, Exception: aws.String("no pathway route found")}, nil
+	}
+	return makeDirections(g, from, to, path, departAt), nil
+}
+
+// loadGraph loads the stops in a station, including boarding areas, and the pathways between them
+func (h *Router) loadGraph(ctx context.Context, station *model.Stop) (*Graph, error) {
+	stops := []*model.Stop{station}
+	keys := []int{station.ID}
+	for depth := 0; depth < 2 && len(keys) > 0; depth++ {
+		children, err := h.Finder.StopsByParentStopIDs(ctx, nil, nil, keys)
+		if err != nil {
+			return nil, err
+		}
+		keys = nil
+		for _, group := range children {
+			for _, s := range group {
+				stops = append(stops, s)
+				keys = append(keys, s.ID)
+			}
+		}
+	}
+	var stopIds []int
+	for _, s := range stops {
+		stopIds = append(stopIds, s.ID)
+	}
+	fromPathways, err := h.Finder.PathwaysByFromStopIDs(ctx, nil, nil, stopIds)
+	if err != nil {
+		return nil, err
+	}
+	toPathways, err := h.Finder.PathwaysByToStopIDs(ctx, nil, nil, stopIds)
+	if err != nil {
+		return nil, err
+	}
+	var pathways []*model.Pathway
+	for _, group := range append(fromPathways, toPathways...) {
+		pathways = append(pathways, group...)
+	}
+	log.For(ctx).Trace().Int("station", station.ID).Int("stops", len(stops)).Int("pathways", len(pathways)).Msg("pathways: loaded station graph")
+	return NewGraph(stops, pathways), nil
+}
+
+func (g *Graph) findStop(stopID string) *model.Stop {
+	for _, s := range g.Stops {
+		if s.StopID.Val == stopID {
+			return s
+		}
+	}
+	return nil
+}
+
+func makeDirections(g *Graph, from *model.Stop, to *model.Stop, path []*Edge, departAt time.Time) *model.Directions {
+	walk := model.StepModeWalk
+	leg := model.Leg{
+		StartTime: departAt,
+		From:      stopWaypoint(from),
+		To:        stopWaypoint(to),
+		Mode:      &walk,
+	}
+	var coords []float64
+	addCoords := func(s *model.Stop) {
+		if s != nil && s.Geometry.Valid {
+			c := s.Coordinates()
+			coords = append(coords, c[0], c[1], 0)
+		}
+	}
+	addCoords(from)
+	t := departAt
+	duration, distance := 0.0, 0.0
+	for _, e := range path {
+		stepTo := g.Stops[e.To]
+		step := model.Step{
+			Duration:       makeDuration(e.Duration),
+			Distance:       makeDistance(e.Length),
+			StartTime:      t,
+			EndTime:        t.Add(time.Duration(e.Duration * float64(time.Second))),
+			To:             stopWaypoint(stepTo),
+			Mode:           walk,
+			Instruction:    instruction(e, stepTo),
+			GeometryOffset: max(0, len(coords)/3-1),
+		}
+		leg.Steps = append(leg.Steps, &step)
+		addCoords(stepTo)
+		t = step.EndTime
+		duration += e.Duration
+		distance += e.Length
+	}
+	leg.EndTime = t
+	leg.Duration = makeDuration(duration)
+	leg.Distance = makeDistance(distance)
+	leg.Geometry = tt.NewLineStringFromFlatCoords(coords)
+
+	itin := model.Itinerary{
+		Duration:  leg.Duration,
+		Distance:  leg.Distance,
+		StartTime: leg.StartTime,
+		EndTime:   leg.EndTime,
+		From:      leg.From,
+		To:        leg.To,
+		Legs:      []*model.Leg{&leg},
+	}
+	return &model.Directions{
+		Success:     true,
+		DataSource:  aws.String("GTFS pathways"),
+		Origin:      leg.From,
+		Destination: leg.To,
+		Duration:    itin.Duration,
+		Distance:    itin.Distance,
+		StartTime:   &itin.StartTime,
+		EndTime:     &itin.EndTime,
+		Itineraries: []*model.Itinerary{&itin},
+	}
+}
+
+var modeInstructions = map[int64]string{
+	ModeWalkway:        "Walk",
+	ModeStairs:         "Take the stairs",
+	ModeMovingSidewalk: "Take the moving walkway",
+	ModeEscalator:      "Take the escalator",
+	ModeElevator:       "Take the elevator",
+	ModeFareGate:       "Go through the fare gate",
+	ModeExitGate:       "Go through the exit gate",
+}
+
+func instruction(e *Edge, to *model.Stop) string {
+	verb, ok := modeInstructions[e.Pathway.PathwayMode.Val]
+	if !ok {
+		verb = "Walk"
+	}
+	if sign := e.Signpost(); sign != "" {
+		return fmt.Sprintf("%s, following signs for %s", verb, sign)
+	}
+	if to != nil && to.StopName.Val != "" {
+		return fmt.Sprintf("%s to %s", verb, to.StopName.Val)
+	}
+	return verb
+}
+
+func stopWaypoint(s *model.Stop) *model.Waypoint {
+	if s == nil {
+		return nil
+	}
+	c := s.Coordinates()
+	return &model.Waypoint{
+		Lon:  c[0],
+		Lat:  c[1],
+		Name: aws.String(s.StopName.Val),
+	}
+}
+
+func makeDuration(t float64) *model.Duration {
+	return &model.Duration{Duration: t, Units: model.DurationUnitSeconds}
+}
+
+func makeDistance(meters float64) *model.Distance {
+	return &model.Distance{Distance: meters / 1000, Units: model.DistanceUnitKilometers}
+}
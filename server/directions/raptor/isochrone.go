@@ -0,0 +1,84 @@
+package raptor
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-server/server/directions"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+const isochroneCellSize = 50.0 // m
+
+// Isochrones returns the area reachable by transit and walking within each cutoff.
+// Each stop reached before a cutoff contributes the distance that can be walked in the remaining time.
+func (h *Router) Isochrones(ctx context.Context, req directions.IsochroneRequest) (*model.Isochrones, error) {
+	if err := directions.ValidateIsochroneRequest(req); err != nil {
+		return &model.Isochrones{Success: false, Exception: aws.String("invalid input")}, nil
+	}
+	if req.Mode != model.StepModeTransit {
+		return &model.Isochrones{Success: false, Exception: aws.String("unsupported travel mode")}, nil
+	}
+
+	// Prepare departure time
+	departAt := time.Now().In(time.UTC)
+	if h.Clock != nil {
+		departAt = h.Clock.Now()
+	}
+	if req.DepartAt != nil {
+		departAt = *req.DepartAt
+	}
+	// Ensure we are in UTC
+	departAt = departAt.In(time.UTC)
+
+	// Get timetable for feeds near the origin
+	tab, err := h.timetable(ctx, departAt, []model.PointRadius{{Lon: req.Origin.Lon, Lat: req.Origin.Lat, Radius: defaultMaxWalkDistance}})
+	if err != nil {
+		log.For(ctx).Error().Err(err).Msg("raptor: failed to load timetable")
+		return &model.Isochrones{Success: false, Exception: aws.String("could not calculate isochrones")}, nil
+	}
+
+	// Earliest arrival at each stop within the largest cutoff
+	opts := Options{WalkSpeed: defaultWalkSpeed, MaxTransfers: defaultMaxTransfers}
+	origin := tlxy.Point{Lon: req.Origin.Lon, Lat: req.Origin.Lat}
+	maxCutoff := 0
+	for _, c := range req.Cutoffs {
+		maxCutoff = max(maxCutoff, c)
+	}
+	start := departAt.Unix()
+	arrivals := Reach(tab, start, accessStops(tab, origin, defaultMaxWalkDistance), opts, start+int64(maxCutoff*60))
+
+	// Prepare response
+	ret := model.Isochrones{
+		Origin:     &model.Waypoint{Lon: req.Origin.Lon, Lat: req.Origin.Lat, Name: req.Origin.Name},
+		Success:    true,
+		DataSource: aws.String("Transitland"),
+	}
+	for _, cutoff := range req.Cutoffs {
+		limit := start + int64(cutoff*60)
+		circles := []directions.ReachCircle{{
+			Lon:    origin.Lon,
+			Lat:    origin.Lat,
+			Radius: min(defaultMaxWalkDistance, float64(cutoff*60)*opts.WalkSpeed),
+		}}
+		for s, t := range arrivals {
+			if t < 0 || t > limit {
+				continue
+			}
+			stop := tab.Stops[s]
+			circles = append(circles, directions.ReachCircle{
+				Lon:    stop.Lon,
+				Lat:    stop.Lat,
+				Radius: min(defaultMaxWalkDistance, float64(limit-t)*opts.WalkSpeed),
+			})
+		}
+		ret.Isochrones = append(ret.Isochrones, &model.Isochrone{
+			Cutoff:   cutoff,
+			Geometry: directions.ReachPolygon(circles, isochroneCellSize),
+		})
+	}
+	return &ret, nil
+}
@@ -0,0 +1,83 @@
+package raptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/server/directions"
+	dt "github.com/interline-io/transitland-server/server/directions/directionstest"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReach(t *testing.T) {
+	tab := testTimetableWithWalking()
+	arrivals := Reach(tab, t0, testAccess(tab, -122.4010, 37.7890), Options{WalkSpeed: 1.4, MaxTransfers: 3}, t0+1200)
+	a, _ := tab.StopIndex(1)
+	b, _ := tab.StopIndex(2)
+	b2, _ := tab.StopIndex(3)
+	c, _ := tab.StopIndex(4)
+	assert.Less(t, arrivals[a], t0+60)
+	assert.Equal(t, t0+900, arrivals[b])
+	assert.Greater(t, arrivals[b2], t0+900)
+	assert.Equal(t, int64(-1), arrivals[c])
+}
+
+func TestRouter_Isochrones(t *testing.T) {
+	h := &Router{Timetable: testTimetableWithWalking()}
+	departAt := time.Unix(t0, 0)
+	ret, err := h.Isochrones(context.Background(), directions.IsochroneRequest{
+		Origin:   &dt.BaseFrom,
+		Mode:     model.StepModeTransit,
+		DepartAt: &departAt,
+		Cutoffs:  []int{20, 60},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.True(t, ret.Success) || !assert.Equal(t, 2, len(ret.Isochrones)) {
+		return
+	}
+	stopB := [2]float64{-122.4200, 37.7860}
+	stopC := [2]float64{-122.4465, 37.7821}
+	far := [2]float64{-122.3000, 37.7000}
+	iso20 := ret.Isochrones[0]
+	assert.Equal(t, 20, iso20.Cutoff)
+	assert.True(t, contains(iso20.Geometry, dt.BaseFrom.Lon, dt.BaseFrom.Lat))
+	assert.True(t, contains(iso20.Geometry, stopB[0], stopB[1]))
+	assert.False(t, contains(iso20.Geometry, stopC[0], stopC[1]))
+	iso60 := ret.Isochrones[1]
+	assert.Equal(t, 60, iso60.Cutoff)
+	assert.True(t, contains(iso60.Geometry, stopC[0], stopC[1]))
+	assert.False(t, contains(iso60.Geometry, far[0], far[1]))
+
+	// Only transit is supported
+	ret, err = h.Isochrones(context.Background(), directions.IsochroneRequest{Origin: &dt.BaseFrom, Mode: model.StepModeWalk, Cutoffs: []int{20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, ret.Success)
+}
+
+// contains checks if a point is inside any outer ring of a multipolygon
+func contains(mp tt.MultiPolygon, lon float64, lat float64) bool {
+	if !mp.Valid {
+		return false
+	}
+	for i := 0; i < mp.Val.NumPolygons(); i++ {
+		ring := mp.Val.Polygon(i).LinearRing(0).Coords()
+		inside := false
+		for k := 0; k < len(ring)-1; k++ {
+			p, q := ring[k], ring[k+1]
+			if (p[1] > lat) != (q[1] > lat) && lon < (q[0]-p[0])*(lat-p[1])/(q[1]-p[1])+p[0] {
+				inside = !inside
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
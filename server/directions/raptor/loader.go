@@ -0,0 +1,395 @@
+package raptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+const (
+	timetableCacheSize       = 4               // timetables kept in memory
+	maxTimetableFeedVersions = 20              // feed versions in a single timetable
+	maxTimetableTrips        = 500_000         // trips in a single timetable, including each run of a frequency-based trip
+	timetableBuildTimeout    = 5 * time.Minute // builds continue in the background after the request that started them is done
+	maxTimetableTransfers    = 100_000         // transfers.txt records in a single feed version
+	loaderPageSize           = 10_000          // entities fetched per query
+)
+
+// timetableCache holds recently built timetables, keyed by feed versions and service dates.
+// Only one build runs at a time for each key; other requests for the same key wait for it.
+type timetableCache struct {
+	lock    sync.Mutex
+	keys    []string
+	values  map[string]*Timetable
+	pending map[string]*timetableBuild
+}
+
+type timetableBuild struct {
+	done chan struct{}
+	tab  *Timetable
+	err  error
+}
+
+func (c *timetableCache) Get(key string) (*Timetable, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	v, ok := c.values[key]
+	return v, ok
+}
+
+func (c *timetableCache) Add(key string, v *Timetable) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.add(key, v)
+}
+
+func (c *timetableCache) add(key string, v *Timetable) {
+	if c.values == nil {
+		c.values = map[string]*Timetable{}
+	}
+	if _, ok := c.values[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.values[key] = v
+	for len(c.keys) > timetableCacheSize {
+		delete(c.values, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+}
+
+// GetOrBuild returns a cached timetable, or starts a build in the background and waits for it until ctx is done
+func (c *timetableCache) GetOrBuild(ctx context.Context, key string, build func(context.Context) (*Timetable, error)) (*Timetable, error) {
+	c.lock.Lock()
+	if v, ok := c.values[key]; ok {
+		c.lock.Unlock()
+		return v, nil
+	}
+	if c.pending == nil {
+		c.pending = map[string]*timetableBuild{}
+	}
+	tb, ok := c.pending[key]
+	if !ok {
+		tb = &timetableBuild{done: make(chan struct{})}
+		c.pending[key] = tb
+		go func() {
+			buildCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timetableBuildTimeout)
+			defer cancel()
+			tab, err := build(buildCtx)
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			if err == nil {
+				c.add(key, tab)
+			}
+			delete(c.pending, key)
+			tb.tab, tb.err = tab, err
+			close(tb.done)
+		}()
+	}
+	c.lock.Unlock()
+	select {
+	case <-tb.done:
+		return tb.tab, tb.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+var timetables = &timetableCache{}
+
+type feedVersionInfo struct {
+	id            int
+	sha1          string
+	feedOnestopID string
+	loc           *time.Location
+	dates         []time.Time
+	agencies      []*model.Agency
+}
+
+// LoadTimetable builds a timetable from the active feed versions near any of the points in area for service
+// running on the local day of departAt, the day before, and the day after. If area is empty, all active
+// feed versions are used. Building a timetable is bounded by the number of feed versions and trips.
+func LoadTimetable(ctx context.Context, finder model.Finder, departAt time.Time, area []model.PointRadius, transferDistance float64) (*Timetable, error) {
+	if finder == nil {
+		return nil, errors.New("no finder")
+	}
+	fvis, err := activeFeedVersions(ctx, finder, departAt, area)
+	if err != nil {
+		return nil, err
+	}
+	if len(fvis) > maxTimetableFeedVersions {
+		return nil, fmt.Errorf("timetable would include %d feed versions, more than the limit of %d", len(fvis), maxTimetableFeedVersions)
+	}
+	var keyParts []string
+	for _, fvi := range fvis {
+		keyParts = append(keyParts, fmt.Sprintf("%d:%s", fvi.id, fvi.dates[0].Format("20060102")))
+	}
+	key := fmt.Sprintf("%s:%0.0f", strings.Join(keyParts, ","), transferDistance)
+	return timetables.GetOrBuild(ctx, key, func(ctx context.Context) (*Timetable, error) {
+		b := NewBuilder()
+		for _, fvi := range fvis {
+			if err := loadFeedVersion(ctx, finder, b, fvi); err != nil {
+				return nil, err
+			}
+		}
+		b.AddWalkingTransfers(transferDistance)
+		return b.Build(), nil
+	})
+}
+
+func activeFeedVersions(ctx context.Context, finder model.Finder, departAt time.Time, area []model.PointRadius) ([]feedVersionInfo, error) {
+	var feeds []*model.Feed
+	if len(area) == 0 {
+		ents, err := finder.FindFeeds(ctx, nil, nil, nil, &model.FeedFilter{Spec: []model.FeedSpecTypes{model.FeedSpecTypesGtfs}})
+		if err != nil {
+			return nil, err
+		}
+		feeds = ents
+	}
+	seen := map[int]bool{}
+	for _, near := range area {
+		ents, err := finder.FindFeeds(ctx, nil, nil, nil, &model.FeedFilter{Spec: []model.FeedSpecTypes{model.FeedSpecTypesGtfs}, Near: &near})
+		if err != nil {
+			return nil, err
+		}
+		for _, ent := range ents {
+			if !seen[ent.ID] {
+				seen[ent.ID] = true
+				feeds = append(feeds, ent)
+			}
+		}
+	}
+	var feedIds []int
+	feedOnestopIds := map[int]string{}
+	for _, feed := range feeds {
+		feedIds = append(feedIds, feed.ID)
+		feedOnestopIds[feed.ID] = feed.FeedID
+	}
+	if len(feedIds) == 0 {
+		return nil, nil
+	}
+	states, _ := finder.FeedStatesByFeedIDs(ctx, feedIds)
+	var fvids []int
+	for _, state := range states {
+		if state != nil && state.FeedVersionID.Valid {
+			fvids = append(fvids, state.FeedVersionID.Int())
+		}
+	}
+	sort.Ints(fvids)
+	fvs, _ := finder.FeedVersionsByIDs(ctx, fvids)
+	agencies, err := finder.AgenciesByFeedVersionIDs(ctx, nil, nil, fvids)
+	if err != nil {
+		return nil, err
+	}
+	var ret []feedVersionInfo
+	for i, fv := range fvs {
+		if fv == nil {
+			continue
+		}
+		// Use the first agency timezone for the feed version service day
+		loc := time.UTC
+		for _, agency := range agencies[i] {
+			if l, err := time.LoadLocation(agency.AgencyTimezone.Val); err == nil {
+				loc = l
+				break
+			}
+		}
+		local := departAt.In(loc)
+		d := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+		ret = append(ret, feedVersionInfo{
+			id:            fv.ID,
+			sha1:          fv.SHA1,
+			feedOnestopID: feedOnestopIds[fv.FeedID],
+			loc:           loc,
+			dates:         []time.Time{d, d.AddDate(0, 0, -1), d.AddDate(0, 0, 1)},
+			agencies:      agencies[i],
+		})
+	}
+	return ret, nil
+}
+
+func loadFeedVersion(ctx context.Context, finder model.Finder, b *Builder, fvi feedVersionInfo) error {
+	fvids := []int{fvi.id}
+	agencyLookup := map[int]*Agency{}
+	for _, ent := range fvi.agencies {
+		agencyLookup[ent.ID] = &Agency{
+			AgencyID:   ent.AgencyID.Val,
+			AgencyName: ent.AgencyName.Val,
+			OnestopID:  ent.OnestopID,
+		}
+	}
+	routes, err := findAll(func(limit *int, after *model.Cursor) ([]*model.Route, error) {
+		return finder.FindRoutes(ctx, limit, after, nil, &model.RouteFilter{FeedVersionSha1: &fvi.sha1})
+	}, func(ent *model.Route) model.Cursor {
+		return model.NewCursor(ent.FeedVersionID, ent.ID)
+	})
+	if err != nil {
+		return err
+	}
+	routeLookup := map[int]*Route{}
+	for _, ent := range routes {
+		routeLookup[ent.ID] = &Route{
+			RouteID:        ent.RouteID.Val,
+			RouteShortName: ent.RouteShortName.Val,
+			RouteLongName:  ent.RouteLongName.Val,
+			OnestopID:      derefString(ent.OnestopID),
+			RouteType:      ent.RouteType.Int(),
+			RouteColor:     ent.RouteColor.Val,
+			RouteTextColor: ent.RouteTextColor.Val,
+			Agency:         agencyLookup[ent.AgencyID.Int()],
+		}
+	}
+	stops, err := findAll(func(limit *int, after *model.Cursor) ([]*model.Stop, error) {
+		return finder.FindStops(ctx, limit, after, nil, &model.StopFilter{FeedVersionSha1: &fvi.sha1})
+	}, func(ent *model.Stop) model.Cursor {
+		return model.NewCursor(ent.FeedVersionID, ent.ID)
+	})
+	if err != nil {
+		return err
+	}
+	for _, ent := range stops {
+		b.AddStop(Stop{
+			ID:        ent.ID,
+			StopID:    ent.StopID.Val,
+			StopName:  ent.StopName.Val,
+			StopCode:  ent.StopCode.Val,
+			OnestopID: derefString(ent.OnestopID),
+			Lon:       ent.Geometry.X(),
+			Lat:       ent.Geometry.Y(),
+			// Wheelchair boarding is not inherited from parent stations
+			WheelchairBoarding: ent.WheelchairBoarding.Int(),
+		})
+	}
+
+	// Stop-to-stop transfers from transfers.txt; route and trip specific transfers are not used
+	transferLimit := maxTimetableTransfers
+	transfers, err := finder.TransfersByFeedVersionIDs(ctx, &transferLimit, fvids)
+	if err != nil {
+		return err
+	}
+	if len(transfers[0]) >= transferLimit {
+		return fmt.Errorf("feed version %s has %d or more transfers, more than can be loaded", fvi.sha1, transferLimit)
+	}
+	for _, ent := range transfers[0] {
+		if ent.FromRouteID.Valid || ent.ToRouteID.Valid || ent.FromTripID.Valid || ent.ToTripID.Valid {
+			continue
+		}
+		from, ok1 := b.StopIndex(ent.FromStopID.Int())
+		to, ok2 := b.StopIndex(ent.ToStopID.Int())
+		if ok1 && ok2 {
+			b.AddTransfer(from, to, ent.TransferType.Int(), ent.MinTransferTime.Int())
+		}
+	}
+
+	// Trips for each service day
+	for _, d := range fvi.dates {
+		serviceDate := tt.NewDate(d)
+		trips, err := findAll(func(limit *int, after *model.Cursor) ([]*model.Trip, error) {
+			return finder.FindTrips(ctx, limit, after, nil, &model.TripFilter{FeedVersionSha1: &fvi.sha1, ServiceDate: &serviceDate})
+		}, func(ent *model.Trip) model.Cursor {
+			return model.NewCursor(ent.FeedVersionID, ent.ID)
+		})
+		if err != nil {
+			return err
+		}
+		// Service day times are relative to noon minus 12 hours
+		midnight := time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, fvi.loc).Add(-12 * time.Hour).Unix()
+		if err := loadTrips(ctx, finder, b, fvi, trips, routeLookup, midnight); err != nil {
+			return err
+		}
+		if len(b.trips) > maxTimetableTrips {
+			return fmt.Errorf("timetable would include more than %d trips", maxTimetableTrips)
+		}
+	}
+	return nil
+}
+
+func loadTrips(ctx context.Context, finder model.Finder, b *Builder, fvi feedVersionInfo, trips []*model.Trip, routeLookup map[int]*Route, midnight int64) error {
+	tripLookup := map[int]*model.Trip{}
+	var keys []model.FVPair
+	for _, trip := range trips {
+		tripLookup[trip.ID] = trip
+		keys = append(keys, model.FVPair{FeedVersionID: fvi.id, EntityID: trip.ID})
+	}
+	expandFrequencies := true
+	batchSize := 1000
+	for start := 0; start < len(keys); start += batchSize {
+		batch := keys[start:min(start+batchSize, len(keys))]
+		sts, err := finder.StopTimesByTripIDs(ctx, nil, &model.TripStopTimeFilter{ExpandFrequencies: &expandFrequencies}, batch)
+		if err != nil {
+			return err
+		}
+		for i, group := range sts {
+			trip := tripLookup[batch[i].EntityID]
+			if trip == nil {
+				continue
+			}
+			// Each frequency-based trip run is added as a separate trip
+			var cur *Trip
+			curStart := -1
+			for _, st := range group {
+				if cur == nil || st.StartTime.Int() != curStart {
+					if cur != nil {
+						b.AddTrip(cur)
+					}
+					curStart = st.StartTime.Int()
+					cur = &Trip{
+						TripID:               trip.TripID.Val,
+						TripShortName:        trip.TripShortName.Val,
+						Headsign:             trip.TripHeadsign.Val,
+						FeedOnestopID:        fvi.feedOnestopID,
+						FeedVersionSHA1:      fvi.sha1,
+						Route:                routeLookup[trip.RouteID.Int()],
+						WheelchairAccessible: trip.WheelchairAccessible.Int(),
+					}
+				}
+				stopIdx, ok := b.StopIndex(st.StopID.Int())
+				if !ok {
+					continue
+				}
+				cur.Stops = append(cur.Stops, stopIdx)
+				cur.StopSequences = append(cur.StopSequences, st.StopSequence.Int())
+				cur.Arrivals = append(cur.Arrivals, midnight+int64(st.ArrivalTime.Int()))
+				cur.Departures = append(cur.Departures, midnight+int64(st.DepartureTime.Int()))
+				cur.PickupTypes = append(cur.PickupTypes, st.PickupType.Int())
+				cur.DropOffTypes = append(cur.DropOffTypes, st.DropOffType.Int())
+			}
+			if cur != nil {
+				b.AddTrip(cur)
+			}
+		}
+	}
+	return nil
+}
+
+// findAll pages through the results of find until a page is not full
+func findAll[T any](find func(limit *int, after *model.Cursor) ([]T, error), cursor func(T) model.Cursor) ([]T, error) {
+	var ret []T
+	var after *model.Cursor
+	limit := loaderPageSize
+	for {
+		ents, err := find(&limit, after)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, ents...)
+		if len(ents) < limit {
+			return ret, nil
+		}
+		c := cursor(ents[len(ents)-1])
+		after = &c
+	}
+}
+
+func derefString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
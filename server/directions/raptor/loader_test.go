@@ -0,0 +1,79 @@
+package raptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-server/internal/testconfig"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/interline-io/transitland-server/server/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter_LoadTimetable(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+	}
+	cfg := testconfig.Config(t, testconfig.Options{})
+	ctx := model.WithConfig(context.Background(), cfg)
+	departAt := time.Date(2018, 5, 30, 23, 0, 0, 0, time.UTC)
+	h := &Router{}
+	ret, err := h.Request(ctx, model.DirectionRequest{
+		Mode:     model.StepModeTransit,
+		From:     &model.WaypointInput{Lon: -122.395, Lat: 37.7764},
+		To:       &model.WaypointInput{Lon: -121.9031, Lat: 37.3293},
+		DepartAt: &departAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.True(t, ret.Success) || !assert.Greater(t, len(ret.Itineraries), 0) {
+		return
+	}
+	var transitLegs []*model.Leg
+	for _, leg := range ret.Itineraries[0].Legs {
+		if leg.Trip != nil {
+			transitLegs = append(transitLegs, leg)
+		}
+	}
+	if assert.Equal(t, 1, len(transitLegs)) {
+		leg := transitLegs[0]
+		assert.Equal(t, "CT", leg.Trip.FeedID)
+		assert.Equal(t, "Caltrain", leg.Trip.Route.Agency.AgencyName)
+		assert.False(t, leg.StartTime.Before(departAt))
+		if assert.Greater(t, len(leg.Stops), 1) {
+			assert.Equal(t, "San Francisco Caltrain", leg.Stops[0].StopName)
+			assert.Equal(t, "San Jose Diridon Caltrain", leg.Stops[len(leg.Stops)-1].StopName)
+		}
+	}
+}
+
+func TestTimetableCache_GetOrBuild(t *testing.T) {
+	c := &timetableCache{}
+	builds := 0
+	release := make(chan struct{})
+	build := func(ctx context.Context) (*Timetable, error) {
+		builds++
+		<-release
+		return NewBuilder().Build(), nil
+	}
+	// A request that gives up does not stop the build
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.GetOrBuild(ctx, "a", build)
+	assert.ErrorIs(t, err, context.Canceled)
+	// Later requests wait for the build already running
+	done := make(chan *Timetable)
+	go func() {
+		tab, _ := c.GetOrBuild(context.Background(), "a", build)
+		done <- tab
+	}()
+	close(release)
+	tab := <-done
+	assert.NotNil(t, tab)
+	cached, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Same(t, tab, cached)
+	assert.Equal(t, 1, builds)
+}
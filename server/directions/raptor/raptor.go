@@ -0,0 +1,393 @@
+package raptor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const inf = int64(math.MaxInt64)
+
+// Access is a walking connection between the origin or destination and a stop
+type Access struct {
+	Stop     int
+	Distance float64 // meters
+}
+
+// Options control the search
+type Options struct {
+	WalkSpeed         float64 // meters per second
+	MaxTransfers      int
+	Wheelchair        bool             // only use accessible trips and stops
+	AllowedRouteTypes map[int]bool     // if set, only routes with these route types can be boarded
+	BannedRoutes      map[string]bool  // routes that can not be boarded, by route onestop id
+	BannedTrips       map[string]bool  // trips that can not be boarded, by trip key
+	ArrivalDelays     map[string]int64 // realtime arrival delays in seconds, by trip key
+}
+
+func (o Options) arrivalTime(trip *Trip, pos int) int64 {
+	if len(o.ArrivalDelays) == 0 {
+		return trip.Arrivals[pos]
+	}
+	return trip.Arrivals[pos] + o.ArrivalDelays[trip.key()]
+}
+
+func (o Options) canBoard(trip *Trip) bool {
+	if len(o.BannedTrips) > 0 && o.BannedTrips[trip.key()] {
+		return false
+	}
+	if o.Wheelchair && trip.WheelchairAccessible == 2 {
+		return false
+	}
+	if r := trip.Route; r != nil {
+		if len(o.AllowedRouteTypes) > 0 && !o.AllowedRouteTypes[r.RouteType] {
+			return false
+		}
+		if len(o.BannedRoutes) > 0 && o.BannedRoutes[r.OnestopID] {
+			return false
+		}
+	}
+	return true
+}
+
+// canUseStop returns false for stops where passengers can not board or alight
+func (o Options) canUseStop(stop Stop) bool {
+	return !o.Wheelchair || stop.WheelchairBoarding != 2
+}
+
+func (o Options) walkTime(distance float64) int64 {
+	speed := o.WalkSpeed
+	if speed <= 0 {
+		speed = defaultWalkSpeed
+	}
+	return int64(math.Ceil(distance / speed))
+}
+
+func (o Options) transferTime(tr Transfer) int64 {
+	return max(o.walkTime(tr.Distance), int64(tr.MinTime))
+}
+
+// LegKind is the type of a journey leg
+type LegKind int
+
+const (
+	LegWalk LegKind = iota
+	LegTransit
+)
+
+// JourneyLeg is a single walking or transit leg.
+// FromStop and ToStop are -1 for the origin and destination.
+type JourneyLeg struct {
+	Kind      LegKind
+	FromStop  int
+	ToStop    int
+	StartTime int64
+	EndTime   int64
+	Distance  float64 // meters, walking legs only
+	Trip      *Trip
+	BoardPos  int
+	AlightPos int
+}
+
+// Journey is a complete path from origin to destination
+type Journey struct {
+	Legs      []JourneyLeg
+	DepartAt  int64
+	ArriveAt  int64
+	Transfers int
+}
+
+// key identifies a journey by the trips it uses
+func (j Journey) key() string {
+	var parts []string
+	for _, leg := range j.Legs {
+		if leg.Kind == LegTransit {
+			parts = append(parts, fmt.Sprintf("%s:%d:%d", leg.Trip.key(), leg.BoardPos, leg.AlightPos))
+		}
+	}
+	if len(parts) == 0 {
+		return "walk"
+	}
+	return strings.Join(parts, ",")
+}
+
+type labelKind int
+
+const (
+	labelNone labelKind = iota
+	labelAccess
+	labelTransit
+	labelTransfer
+)
+
+type label struct {
+	kind      labelKind
+	trip      *Trip
+	boardPos  int
+	alightPos int
+	fromStop  int
+	distance  float64
+}
+
+// Search runs a RAPTOR earliest arrival search departing at departAt.
+// It returns the Pareto-optimal journeys by arrival time and number of transfers, fewest transfers first.
+func Search(tab *Timetable, departAt int64, access []Access, egress []Access, opts Options) []Journey {
+	journeys, _ := search(tab, departAt, access, egress, opts, inf)
+	return journeys
+}
+
+// Reach returns the earliest arrival time at each stop departing at departAt, or -1 if the stop can not be reached before limit
+func Reach(tab *Timetable, departAt int64, access []Access, opts Options, limit int64) []int64 {
+	_, best := search(tab, departAt, access, nil, opts, limit+1)
+	ret := make([]int64, len(best))
+	for i, t := range best {
+		ret[i] = t
+		if t == inf {
+			ret[i] = -1
+		}
+	}
+	return ret
+}
+
+// search is the RAPTOR search; arrivals at or after limit are pruned
+func search(tab *Timetable, departAt int64, access []Access, egress []Access, opts Options, limit int64) ([]Journey, []int64) {
+	nStops := len(tab.Stops)
+	rounds := opts.MaxTransfers + 1
+	arr := make([][]int64, rounds+1)
+	ready := make([][]int64, rounds+1)
+	labels := make([][]label, rounds+1)
+	best := make([]int64, nStops)
+	marked := make([]bool, nStops)
+
+	// Round 0: walk from origin
+	arr[0] = make([]int64, nStops)
+	ready[0] = make([]int64, nStops)
+	labels[0] = make([]label, nStops)
+	for i := range arr[0] {
+		arr[0][i] = inf
+		ready[0][i] = inf
+		best[i] = inf
+	}
+	for _, a := range access {
+		t := departAt + opts.walkTime(a.Distance)
+		if t < arr[0][a.Stop] && t < limit {
+			arr[0][a.Stop] = t
+			ready[0][a.Stop] = t
+			best[a.Stop] = t
+			labels[0][a.Stop] = label{kind: labelAccess, distance: a.Distance}
+			marked[a.Stop] = true
+		}
+	}
+
+	egressTimes := map[int]int64{}
+	egressDistances := map[int]float64{}
+	for _, e := range egress {
+		t := opts.walkTime(e.Distance)
+		if cur, ok := egressTimes[e.Stop]; !ok || t < cur {
+			egressTimes[e.Stop] = t
+			egressDistances[e.Stop] = e.Distance
+		}
+	}
+	egressStops := make([]int, 0, len(egressTimes))
+	for s := range egressTimes {
+		egressStops = append(egressStops, s)
+	}
+	sort.Ints(egressStops)
+
+	var journeys []Journey
+	bestArrival := limit
+	for k := 1; k <= rounds; k++ {
+		arr[k] = append([]int64{}, arr[k-1]...)
+		ready[k] = append([]int64{}, ready[k-1]...)
+		labels[k] = make([]label, nStops)
+
+		// Collect patterns serving marked stops, with the earliest marked position
+		queue := map[int]int{}
+		for s, ok := range marked {
+			if !ok {
+				continue
+			}
+			for _, ps := range tab.stopPatterns[s] {
+				if cur, ok := queue[ps.pattern]; !ok || ps.position < cur {
+					queue[ps.pattern] = ps.position
+				}
+			}
+			marked[s] = false
+		}
+		if len(queue) == 0 {
+			break
+		}
+		patternIds := make([]int, 0, len(queue))
+		for p := range queue {
+			patternIds = append(patternIds, p)
+		}
+		sort.Ints(patternIds)
+
+		// Scan patterns
+		var transitStops []int
+		for _, pid := range patternIds {
+			pattern := tab.Patterns[pid]
+			var trip *Trip
+			tripIdx := -1
+			boardPos := 0
+			for i := queue[pid]; i < len(pattern.Stops); i++ {
+				s := pattern.Stops[i]
+				if !opts.canUseStop(tab.Stops[s]) {
+					continue
+				}
+				if trip != nil && trip.canDropOff(i) {
+					at := opts.arrivalTime(trip, i)
+					if at < best[s] && at < bestArrival {
+						if labels[k][s].kind == labelNone {
+							transitStops = append(transitStops, s)
+						}
+						arr[k][s] = at
+						best[s] = at
+						ready[k][s] = readyTime(at, tab.MinTransferTimes[s])
+						labels[k][s] = label{kind: labelTransit, trip: trip, boardPos: boardPos, alightPos: i}
+						marked[s] = true
+					}
+				}
+				// Check if an earlier trip can be boarded here
+				r := ready[k-1][s]
+				if r == inf || (trip != nil && r > trip.Departures[i]) {
+					continue
+				}
+				j := sort.Search(len(pattern.Trips), func(j int) bool {
+					return pattern.Trips[j].Departures[i] >= r
+				})
+				for j < len(pattern.Trips) && (!opts.canBoard(pattern.Trips[j]) || !pattern.Trips[j].canPickup(i)) {
+					j++
+				}
+				if j < len(pattern.Trips) && (trip == nil || j < tripIdx) {
+					trip = pattern.Trips[j]
+					tripIdx = j
+					boardPos = i
+				}
+			}
+		}
+
+		// Footpaths from stops reached by transit in this round
+		sort.Ints(transitStops)
+		for _, s := range transitStops {
+			if labels[k][s].kind != labelTransit {
+				continue
+			}
+			for _, tr := range tab.Transfers[s] {
+				t := arr[k][s] + opts.transferTime(tr)
+				if t < best[tr.ToStop] && t < bestArrival {
+					arr[k][tr.ToStop] = t
+					best[tr.ToStop] = t
+					ready[k][tr.ToStop] = t
+					labels[k][tr.ToStop] = label{kind: labelTransfer, fromStop: s, distance: tr.Distance}
+					marked[tr.ToStop] = true
+				}
+			}
+		}
+
+		// Check destination
+		roundBest := inf
+		roundStop := -1
+		for _, e := range egressStops {
+			if arr[k][e] == inf {
+				continue
+			}
+			if t := arr[k][e] + egressTimes[e]; t < roundBest {
+				roundBest = t
+				roundStop = e
+			}
+		}
+		if roundStop >= 0 && roundBest < bestArrival {
+			bestArrival = roundBest
+			j := reconstruct(labels, arr, k, roundStop, opts)
+			j.Legs = append(j.Legs, JourneyLeg{
+				Kind:      LegWalk,
+				FromStop:  roundStop,
+				ToStop:    -1,
+				StartTime: arr[k][roundStop],
+				EndTime:   roundBest,
+				Distance:  egressDistances[roundStop],
+			})
+			j.DepartAt = j.Legs[0].StartTime
+			j.ArriveAt = roundBest
+			journeys = append(journeys, j)
+		}
+	}
+	return journeys, best
+}
+
+func readyTime(t int64, minTransferTime int) int64 {
+	if minTransferTime == forbidden {
+		return inf
+	}
+	return t + int64(minTransferTime)
+}
+
+// reconstruct follows labels back from stop s in round k to the origin
+func reconstruct(labels [][]label, arr [][]int64, k int, s int, opts Options) Journey {
+	var legs []JourneyLeg
+	r := k
+	for r >= 0 {
+		// Find the round that set the current arrival time at this stop
+		for r > 0 && labels[r][s].kind == labelNone {
+			r--
+		}
+		lb := labels[r][s]
+		switch lb.kind {
+		case labelTransit:
+			board := lb.trip.Stops[lb.boardPos]
+			legs = append(legs, JourneyLeg{
+				Kind:      LegTransit,
+				FromStop:  board,
+				ToStop:    s,
+				StartTime: lb.trip.Departures[lb.boardPos],
+				EndTime:   lb.trip.Arrivals[lb.alightPos], // scheduled; realtime estimates are applied to the response
+				Trip:      lb.trip,
+				BoardPos:  lb.boardPos,
+				AlightPos: lb.alightPos,
+			})
+			s = board
+			r--
+		case labelTransfer:
+			legs = append(legs, JourneyLeg{
+				Kind:      LegWalk,
+				FromStop:  lb.fromStop,
+				ToStop:    s,
+				StartTime: arr[r][lb.fromStop],
+				EndTime:   arr[r][s],
+				Distance:  lb.distance,
+			})
+			s = lb.fromStop
+		case labelAccess:
+			// Leave just in time for the first departure
+			end := arr[0][s]
+			if len(legs) > 0 {
+				end = legs[len(legs)-1].StartTime
+			}
+			legs = append(legs, JourneyLeg{
+				Kind:      LegWalk,
+				FromStop:  -1,
+				ToStop:    s,
+				StartTime: end - opts.walkTime(lb.distance),
+				EndTime:   end,
+				Distance:  lb.distance,
+			})
+			r = -1
+		default:
+			r = -1
+		}
+	}
+	// Reverse into travel order
+	ret := Journey{}
+	for i := len(legs) - 1; i >= 0; i-- {
+		if legs[i].Kind == LegTransit {
+			ret.Transfers++
+		}
+		ret.Legs = append(ret.Legs, legs[i])
+	}
+	if ret.Transfers > 0 {
+		ret.Transfers--
+	}
+	return ret
+}
@@ -0,0 +1,236 @@
+package raptor
+
+import (
+	"testing"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test network:
+// Line 1 runs A -> B, Line 2 runs B2 -> C, and Line 3 runs A -> C slowly.
+// B and B2 are about 20m apart.
+const t0 = int64(1234567890)
+
+func testTimetable(walkDistance float64, transferType int, minTransferTime int) *Timetable {
+	b := NewBuilder()
+	a := b.AddStop(Stop{ID: 1, StopID: "A", StopName: "Stop A", Lon: -122.4005, Lat: 37.7890})
+	sb := b.AddStop(Stop{ID: 2, StopID: "B", StopName: "Stop B", Lon: -122.4200, Lat: 37.7860})
+	sb2 := b.AddStop(Stop{ID: 3, StopID: "B2", StopName: "Stop B2", Lon: -122.4202, Lat: 37.7861})
+	c := b.AddStop(Stop{ID: 4, StopID: "C", StopName: "Stop C", Lon: -122.4465, Lat: 37.7821})
+	route := &Route{RouteID: "r", RouteShortName: "R", Agency: &Agency{AgencyID: "ag", AgencyName: "Agency"}}
+	b.AddTrip(testTrip("l1a", route, []int{a, sb}, t0+300, t0+900))
+	b.AddTrip(testTrip("l1b", route, []int{a, sb}, t0+900, t0+1500))
+	b.AddTrip(testTrip("l2a", route, []int{sb2, c}, t0+1000, t0+1600))
+	b.AddTrip(testTrip("l2b", route, []int{sb2, c}, t0+2000, t0+2600))
+	b.AddTrip(testTrip("l3a", route, []int{a, c}, t0+600, t0+3000))
+	if transferType >= 0 {
+		b.AddTransfer(sb, sb2, transferType, minTransferTime)
+	}
+	b.AddWalkingTransfers(walkDistance)
+	return b.Build()
+}
+
+func testTrip(tripId string, route *Route, stops []int, times ...int64) *Trip {
+	trip := &Trip{TripID: tripId, Route: route, Stops: stops, Arrivals: times, Departures: times}
+	for i := range stops {
+		trip.StopSequences = append(trip.StopSequences, i+1)
+	}
+	return trip
+}
+
+func testAccess(tab *Timetable, lon float64, lat float64) []Access {
+	return accessStops(tab, tlxy.Point{Lon: lon, Lat: lat}, 500)
+}
+
+func tripIds(j Journey) []string {
+	var ret []string
+	for _, leg := range j.Legs {
+		if leg.Kind == LegTransit {
+			ret = append(ret, leg.Trip.TripID)
+		}
+	}
+	return ret
+}
+
+func TestSearch(t *testing.T) {
+	tcs := []struct {
+		name            string
+		walkDistance    float64
+		transferType    int
+		minTransferTime int
+		departAt        int64
+		expectTrips     [][]string
+	}{
+		{name: "walking transfer", walkDistance: 100, transferType: -1, departAt: t0, expectTrips: [][]string{{"l3a"}, {"l1a", "l2a"}}},
+		{name: "no transfer", walkDistance: 0, transferType: -1, departAt: t0, expectTrips: [][]string{{"l3a"}}},
+		{name: "recommended transfer", walkDistance: 0, transferType: 0, departAt: t0, expectTrips: [][]string{{"l3a"}, {"l1a", "l2a"}}},
+		{name: "min transfer time", walkDistance: 0, transferType: 2, minTransferTime: 300, departAt: t0, expectTrips: [][]string{{"l3a"}, {"l1a", "l2b"}}},
+		{name: "transfer not possible", walkDistance: 100, transferType: 3, departAt: t0, expectTrips: [][]string{{"l3a"}}},
+		{name: "later departure", walkDistance: 100, transferType: -1, departAt: t0 + 700, expectTrips: [][]string{{"l1b", "l2b"}}},
+		{name: "no service", walkDistance: 100, transferType: -1, departAt: t0 + 4000, expectTrips: nil},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tab := testTimetable(tc.walkDistance, tc.transferType, tc.minTransferTime)
+			journeys := Search(
+				tab,
+				tc.departAt,
+				testAccess(tab, -122.4010, 37.7890),
+				testAccess(tab, -122.4470, 37.7820),
+				Options{WalkSpeed: 1.4, MaxTransfers: 3},
+			)
+			var got [][]string
+			for _, j := range journeys {
+				got = append(got, tripIds(j))
+			}
+			assert.Equal(t, tc.expectTrips, got)
+		})
+	}
+}
+
+func TestSearch_StopMinTransferTime(t *testing.T) {
+	tcs := []struct {
+		name            string
+		transferType    int
+		minTransferTime int
+		expectTrips     []string
+	}{
+		{name: "none", transferType: -1, expectTrips: []string{"t1", "t2"}},
+		{name: "min transfer time", transferType: 2, minTransferTime: 120, expectTrips: []string{"t1", "t3"}},
+		{name: "not possible", transferType: 3, expectTrips: nil},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBuilder()
+			a := b.AddStop(Stop{ID: 1, Lon: -122.4005, Lat: 37.7890})
+			sb := b.AddStop(Stop{ID: 2, Lon: -122.4200, Lat: 37.7860})
+			c := b.AddStop(Stop{ID: 3, Lon: -122.4465, Lat: 37.7821})
+			b.AddTrip(testTrip("t1", nil, []int{a, sb}, t0+300, t0+900))
+			b.AddTrip(testTrip("t2", nil, []int{sb, c}, t0+950, t0+1500))
+			b.AddTrip(testTrip("t3", nil, []int{sb, c}, t0+1200, t0+1800))
+			if tc.transferType >= 0 {
+				b.AddTransfer(sb, sb, tc.transferType, tc.minTransferTime)
+			}
+			tab := b.Build()
+			journeys := Search(tab, t0, []Access{{Stop: a}}, []Access{{Stop: c}}, Options{MaxTransfers: 3})
+			var got []string
+			if len(journeys) > 0 {
+				got = tripIds(journeys[0])
+			}
+			assert.Equal(t, tc.expectTrips, got)
+		})
+	}
+}
+
+func TestSearch_PickupDropOffTypes(t *testing.T) {
+	tcs := []struct {
+		name         string
+		pickupTypes  []int
+		dropOffTypes []int
+		to           int
+		expectTrips  []string
+	}{
+		{name: "none", to: 2, expectTrips: []string{"t1"}},
+		{name: "no pickup", pickupTypes: []int{1, 0, 0}, to: 2, expectTrips: []string{"t2"}},
+		{name: "no drop off", dropOffTypes: []int{0, 0, 1}, to: 2, expectTrips: []string{"t2"}},
+		{name: "no drop off at intermediate stop", dropOffTypes: []int{0, 1, 0}, to: 1, expectTrips: []string{"t2"}},
+		{name: "no drop off at other stop", dropOffTypes: []int{0, 1, 0}, to: 2, expectTrips: []string{"t1"}},
+		{name: "phone agency", pickupTypes: []int{2, 0, 0}, dropOffTypes: []int{0, 0, 3}, to: 2, expectTrips: []string{"t1"}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBuilder()
+			a := b.AddStop(Stop{ID: 1, Lon: -122.4005, Lat: 37.7890})
+			sb := b.AddStop(Stop{ID: 2, Lon: -122.4200, Lat: 37.7860})
+			c := b.AddStop(Stop{ID: 3, Lon: -122.4465, Lat: 37.7821})
+			t1 := testTrip("t1", nil, []int{a, sb, c}, t0+300, t0+900, t0+1500)
+			t1.PickupTypes = tc.pickupTypes
+			t1.DropOffTypes = tc.dropOffTypes
+			b.AddTrip(t1)
+			b.AddTrip(testTrip("t2", nil, []int{a, sb, c}, t0+600, t0+1200, t0+1800))
+			tab := b.Build()
+			journeys := Search(tab, t0, []Access{{Stop: a}}, []Access{{Stop: tc.to}}, Options{MaxTransfers: 3})
+			var got []string
+			if len(journeys) > 0 {
+				got = tripIds(journeys[0])
+			}
+			assert.Equal(t, tc.expectTrips, got)
+		})
+	}
+}
+
+func testTimetableWithWalking() *Timetable {
+	return testTimetable(100, -1, 0)
+}
+
+func TestSearch_Legs(t *testing.T) {
+	tab := testTimetableWithWalking()
+	journeys := Search(
+		tab,
+		t0,
+		testAccess(tab, -122.4010, 37.7890),
+		testAccess(tab, -122.4470, 37.7820),
+		Options{WalkSpeed: 1.4, MaxTransfers: 3},
+	)
+	if !assert.Equal(t, 2, len(journeys)) {
+		return
+	}
+	j := journeys[1]
+	assert.Equal(t, 1, j.Transfers)
+	var kinds []LegKind
+	for _, leg := range j.Legs {
+		kinds = append(kinds, leg.Kind)
+	}
+	assert.Equal(t, []LegKind{LegWalk, LegTransit, LegWalk, LegTransit, LegWalk}, kinds)
+	// Leave just in time to board the first trip
+	assert.Equal(t, t0+300, j.Legs[0].EndTime)
+	assert.Equal(t, j.DepartAt, j.Legs[0].StartTime)
+	assert.Less(t, j.DepartAt, t0+300)
+	// Transfer walk from B to B2
+	assert.Equal(t, "B", tab.Stops[j.Legs[2].FromStop].StopID)
+	assert.Equal(t, "B2", tab.Stops[j.Legs[2].ToStop].StopID)
+	assert.Equal(t, t0+900, j.Legs[2].StartTime)
+	// Arrival at destination
+	assert.Equal(t, t0+1600, j.Legs[4].StartTime)
+	assert.Equal(t, j.ArriveAt, j.Legs[4].EndTime)
+}
+
+func TestSearch_MaxTransfers(t *testing.T) {
+	tab := testTimetableWithWalking()
+	journeys := Search(
+		tab,
+		t0,
+		testAccess(tab, -122.4010, 37.7890),
+		testAccess(tab, -122.4470, 37.7820),
+		Options{WalkSpeed: 1.4, MaxTransfers: 0},
+	)
+	if assert.Equal(t, 1, len(journeys)) {
+		assert.Equal(t, []string{"l3a"}, tripIds(journeys[0]))
+	}
+}
+
+func TestBuilder_Patterns(t *testing.T) {
+	b := NewBuilder()
+	a := b.AddStop(Stop{ID: 1})
+	c := b.AddStop(Stop{ID: 2})
+	assert.Equal(t, a, b.AddStop(Stop{ID: 1}))
+	b.AddTrip(&Trip{TripID: "t1", Stops: []int{a, c}, Departures: []int64{100, 200}, Arrivals: []int64{100, 200}})
+	b.AddTrip(&Trip{TripID: "t2", Stops: []int{a, c}, Departures: []int64{150, 250}, Arrivals: []int64{150, 250}})
+	// Overtakes t2
+	b.AddTrip(&Trip{TripID: "t3", Stops: []int{a, c}, Departures: []int64{160, 180}, Arrivals: []int64{160, 180}})
+	// Different direction
+	b.AddTrip(&Trip{TripID: "t4", Stops: []int{c, a}, Departures: []int64{100, 200}, Arrivals: []int64{100, 200}})
+	// Ignored
+	b.AddTrip(&Trip{TripID: "t5", Stops: []int{a}, Departures: []int64{100}, Arrivals: []int64{100}})
+	tab := b.Build()
+	var got [][]string
+	for _, p := range tab.Patterns {
+		var tids []string
+		for _, trip := range p.Trips {
+			tids = append(tids, trip.TripID)
+		}
+		got = append(got, tids)
+	}
+	assert.ElementsMatch(t, [][]string{{"t1", "t2"}, {"t3"}, {"t4"}}, got)
+}
@@ -0,0 +1,461 @@
+package raptor
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/internal/clock"
+	"github.com/interline-io/transitland-server/server/directions"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+func init() {
+	if err := directions.RegisterRouter("raptor", func(cfg directions.HandlerConfig) (directions.Handler, error) {
+		return &Router{}, nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+const (
+	defaultWalkSpeed        = 1.4    // m/s
+	defaultMaxWalkDistance  = 1000.0 // m
+	defaultTransferDistance = 250.0  // m
+	defaultMaxTransfers     = 3
+	arriveByWindow          = 12 * 60 * 60 // s; how far before an arrive by time to search for departures
+	maxSearches             = 10           // per requested itinerary
+)
+
+// Router is an in-process RAPTOR transit router
+type Router struct {
+	Clock     clock.Clock
+	Timetable *Timetable // if not set, built from the active feed versions using the Finder in the request context
+}
+
+func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*model.Directions, error) {
+	return h.request(ctx, req, requestOptions(req))
+}
+
+// Replan searches again, avoiding canceled trips and using realtime arrival delays from the previous response
+func (h *Router) Replan(ctx context.Context, req model.DirectionRequest, prev *model.Directions) (*model.Directions, error) {
+	opts := requestOptions(req)
+	opts.BannedTrips = map[string]bool{}
+	opts.ArrivalDelays = map[string]int64{}
+	for _, itin := range prev.Itineraries {
+		for _, leg := range itin.Legs {
+			if leg.Trip == nil || leg.Realtime == nil {
+				continue
+			}
+			key := tripKey(leg.Trip.FeedVersionSha1, leg.Trip.TripID)
+			switch leg.Realtime.ScheduleRelationship {
+			case model.ScheduleRelationshipCanceled, model.ScheduleRelationshipDeleted, model.ScheduleRelationshipSkipped:
+				opts.BannedTrips[key] = true
+			}
+			if leg.Realtime.ArrivalDelay != nil {
+				opts.ArrivalDelays[key] = int64(*leg.Realtime.ArrivalDelay)
+			}
+		}
+	}
+	return h.request(ctx, req, opts)
+}
+
+func (h *Router) request(ctx context.Context, req model.DirectionRequest, opts Options) (*model.Directions, error) {
+	if err := directions.ValidateDirectionRequest(req); err != nil {
+		return &model.Directions{Success: false, Exception: aws.String("invalid input")}, nil
+	}
+	if req.Mode != model.StepModeTransit {
+		return &model.Directions{Success: false, Exception: aws.String("unsupported travel mode")}, nil
+	}
+
+	// Prepare departure time
+	departAt := time.Now().In(time.UTC)
+	if h.Clock != nil {
+		departAt = h.Clock.Now()
+	}
+	if req.DepartAt == nil {
+		req.DepartAt = &departAt
+	} else {
+		departAt = *req.DepartAt
+	}
+	// Ensure we are in UTC
+	departAt = departAt.In(time.UTC)
+
+	maxWalkDistance := defaultMaxWalkDistance
+	if req.MaxWalkDistance != nil {
+		maxWalkDistance = *req.MaxWalkDistance
+	}
+
+	// Get timetable for feeds near the origin and destination
+	tab, err := h.timetable(ctx, departAt, []model.PointRadius{
+		{Lon: req.From.Lon, Lat: req.From.Lat, Radius: maxWalkDistance},
+		{Lon: req.To.Lon, Lat: req.To.Lat, Radius: maxWalkDistance},
+	})
+	if err != nil {
+		log.For(ctx).Error().Err(err).Msg("raptor: failed to load timetable")
+		return &model.Directions{Success: false, Exception: aws.String("could not calculate route")}, nil
+	}
+
+	// Search
+	fromPt := tlxy.Point{Lon: req.From.Lon, Lat: req.From.Lat}
+	toPt := tlxy.Point{Lon: req.To.Lon, Lat: req.To.Lat}
+	numItineraries := 0
+	if req.NumItineraries != nil {
+		numItineraries = *req.NumItineraries
+	}
+	access := accessStops(tab, fromPt, maxWalkDistance)
+	egress := accessStops(tab, toPt, maxWalkDistance)
+	arriveBy := req.ArriveBy != nil && *req.ArriveBy
+	var journeys []Journey
+	if arriveBy {
+		journeys = searchArriveBy(tab, departAt.Unix(), access, egress, opts, numItineraries)
+	} else {
+		journeys = searchDepartAt(tab, departAt.Unix(), access, egress, opts, numItineraries)
+	}
+
+	// Include a direct walk when it is faster than any transit option
+	if walkDistance := tlxy.DistanceHaversine(fromPt, toPt); walkDistance <= maxWalkDistance {
+		walkStart := departAt.Unix()
+		walkEnd := walkStart + opts.walkTime(walkDistance)
+		if arriveBy {
+			walkStart, walkEnd = walkStart-opts.walkTime(walkDistance), walkStart
+		}
+		if len(journeys) == 0 || (!arriveBy && walkEnd <= journeys[0].ArriveAt) || (arriveBy && walkStart >= journeys[0].DepartAt) {
+			walk := Journey{
+				DepartAt: walkStart,
+				ArriveAt: walkEnd,
+				Legs: []JourneyLeg{{
+					Kind:      LegWalk,
+					FromStop:  -1,
+					ToStop:    -1,
+					StartTime: walkStart,
+					EndTime:   walkEnd,
+					Distance:  walkDistance,
+				}},
+			}
+			journeys = append([]Journey{walk}, journeys...)
+		}
+	}
+	if numItineraries > 0 && len(journeys) > numItineraries {
+		journeys = journeys[:numItineraries]
+	}
+	if len(journeys) == 0 {
+		return &model.Directions{Success: false, Exception: aws.String("could not calculate route")}, nil
+	}
+
+	// Prepare response
+	ret := model.Directions{
+		Origin:      wpiWaypoint(req.From),
+		Destination: wpiWaypoint(req.To),
+		Success:     true,
+		Exception:   nil,
+		DataSource:  aws.String("Transitland"),
+	}
+	for _, j := range journeys {
+		ret.Itineraries = append(ret.Itineraries, makeItinerary(tab, j, req))
+	}
+	r0 := ret.Itineraries[0]
+	ret.Duration = r0.Duration
+	ret.Distance = r0.Distance
+	ret.StartTime = &r0.StartTime
+	ret.EndTime = &r0.EndTime
+	return &ret, nil
+}
+
+func (h *Router) timetable(ctx context.Context, departAt time.Time, area []model.PointRadius) (*Timetable, error) {
+	if h.Timetable != nil {
+		return h.Timetable, nil
+	}
+	return LoadTimetable(ctx, model.ForContext(ctx).Finder, departAt, area, defaultTransferDistance)
+}
+
+func requestOptions(req model.DirectionRequest) Options {
+	opts := Options{WalkSpeed: defaultWalkSpeed, MaxTransfers: defaultMaxTransfers}
+	if req.WalkSpeed != nil {
+		opts.WalkSpeed = *req.WalkSpeed
+	}
+	if req.MaxTransfers != nil {
+		opts.MaxTransfers = *req.MaxTransfers
+	}
+	if req.Wheelchair != nil {
+		opts.Wheelchair = *req.Wheelchair
+	}
+	if len(req.AllowedRouteTypes) > 0 {
+		opts.AllowedRouteTypes = map[int]bool{}
+		for _, v := range req.AllowedRouteTypes {
+			opts.AllowedRouteTypes[v] = true
+		}
+	}
+	if len(req.BannedRouteOnestopIds) > 0 {
+		opts.BannedRoutes = map[string]bool{}
+		for _, v := range req.BannedRouteOnestopIds {
+			opts.BannedRoutes[v] = true
+		}
+	}
+	return opts
+}
+
+// searchDepartAt returns journeys departing after departAt, earliest arrival first.
+// If n is set, later departures are searched until n distinct journeys are found.
+func searchDepartAt(tab *Timetable, departAt int64, access []Access, egress []Access, opts Options, n int) []Journey {
+	var ret []Journey
+	seen := map[string]bool{}
+	for i := 0; i < max(n, 1)*maxSearches; i++ {
+		found := Search(tab, departAt, access, egress, opts)
+		if len(found) == 0 {
+			break
+		}
+		for _, j := range found {
+			if k := j.key(); !seen[k] {
+				seen[k] = true
+				ret = append(ret, j)
+			}
+		}
+		if n <= 0 || len(ret) >= n {
+			break
+		}
+		// Leave just after the earliest departure found
+		departAt = found[0].DepartAt
+		for _, j := range found {
+			departAt = min(departAt, j.DepartAt)
+		}
+		departAt++
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].ArriveAt == ret[j].ArriveAt {
+			return ret[i].Transfers < ret[j].Transfers
+		}
+		return ret[i].ArriveAt < ret[j].ArriveAt
+	})
+	return ret
+}
+
+// searchArriveBy returns journeys arriving before arriveAt, latest departure first.
+// If n is set, earlier arrivals are searched until n distinct journeys are found.
+func searchArriveBy(tab *Timetable, arriveAt int64, access []Access, egress []Access, opts Options, n int) []Journey {
+	var ret []Journey
+	seen := map[string]bool{}
+	for i := 0; i < max(n, 1)*maxSearches; i++ {
+		found := latestDeparture(tab, arriveAt, access, egress, opts)
+		if len(found) == 0 {
+			break
+		}
+		for _, j := range found {
+			if k := j.key(); !seen[k] {
+				seen[k] = true
+				ret = append(ret, j)
+			}
+		}
+		if n <= 0 || len(ret) >= n {
+			break
+		}
+		// Arrive just before the earliest arrival found
+		arriveAt = found[0].ArriveAt
+		for _, j := range found {
+			arriveAt = min(arriveAt, j.ArriveAt)
+		}
+		arriveAt--
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].DepartAt == ret[j].DepartAt {
+			if ret[i].ArriveAt == ret[j].ArriveAt {
+				return ret[i].Transfers < ret[j].Transfers
+			}
+			return ret[i].ArriveAt < ret[j].ArriveAt
+		}
+		return ret[i].DepartAt > ret[j].DepartAt
+	})
+	return ret
+}
+
+// latestDeparture finds the latest departure time that arrives before arriveAt.
+// Earliest arrival never decreases with a later departure, so a binary search over departure times is used.
+func latestDeparture(tab *Timetable, arriveAt int64, access []Access, egress []Access, opts Options) []Journey {
+	search := func(departAt int64) []Journey {
+		var ret []Journey
+		for _, j := range Search(tab, departAt, access, egress, opts) {
+			if j.ArriveAt <= arriveAt {
+				ret = append(ret, j)
+			}
+		}
+		return ret
+	}
+	lo, hi := arriveAt-arriveByWindow, arriveAt
+	best := search(lo)
+	if len(best) == 0 {
+		return nil
+	}
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		if found := search(mid); len(found) > 0 {
+			lo = mid
+			best = found
+		} else {
+			hi = mid - 1
+		}
+	}
+	return best
+}
+
+func accessStops(tab *Timetable, pt tlxy.Point, maxDistance float64) []Access {
+	var ret []Access
+	for stop, distance := range tab.NearbyStops(pt, maxDistance) {
+		ret = append(ret, Access{Stop: stop, Distance: distance})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Stop < ret[j].Stop })
+	return ret
+}
+
+func makeItinerary(tab *Timetable, j Journey, req model.DirectionRequest) *model.Itinerary {
+	itin := model.Itinerary{}
+	itin.From = wpiWaypoint(req.From)
+	itin.To = wpiWaypoint(req.To)
+	itin.StartTime = unixTime(j.DepartAt)
+	itin.EndTime = unixTime(j.ArriveAt)
+	itin.Duration = makeDuration(float64(j.ArriveAt - j.DepartAt))
+	totalDistance := 0.0
+	for _, jleg := range j.Legs {
+		leg := model.Leg{}
+		leg.StartTime = unixTime(jleg.StartTime)
+		leg.EndTime = unixTime(jleg.EndTime)
+		leg.Duration = makeDuration(float64(jleg.EndTime - jleg.StartTime))
+		leg.From = stopWaypoint(tab, jleg.FromStop, req.From, leg.StartTime)
+		leg.To = stopWaypoint(tab, jleg.ToStop, req.To, leg.EndTime)
+		distance := jleg.Distance
+		var coords []float64
+		if jleg.Kind == LegTransit {
+			sm := model.StepModeTransit
+			leg.Mode = &sm
+			trip := jleg.Trip
+			leg.Trip = makeLegTrip(trip)
+			distance = 0
+			for i := jleg.BoardPos; i <= jleg.AlightPos; i++ {
+				stop := tab.Stops[trip.Stops[i]]
+				if i > jleg.BoardPos {
+					prev := tab.Stops[trip.Stops[i-1]]
+					distance += tlxy.DistanceHaversine(tlxy.Point{Lon: prev.Lon, Lat: prev.Lat}, tlxy.Point{Lon: stop.Lon, Lat: stop.Lat})
+				}
+				wp := model.WaypointDeparture{
+					Lon:           stop.Lon,
+					Lat:           stop.Lat,
+					Departure:     unixTime(trip.Departures[i]),
+					StopID:        stop.StopID,
+					StopName:      stop.StopName,
+					StopCode:      stop.StopCode,
+					StopOnestopID: stop.OnestopID,
+					StopIndex:     aws.Int(i),
+				}
+				if i < len(trip.StopSequences) {
+					wp.StopSequence = aws.Int(trip.StopSequences[i])
+				}
+				leg.Stops = append(leg.Stops, &wp)
+				coords = append(coords, stop.Lon, stop.Lat, 0)
+			}
+		} else {
+			sm := model.StepModeWalk
+			leg.Mode = &sm
+			coords = []float64{leg.From.Lon, leg.From.Lat, 0, leg.To.Lon, leg.To.Lat, 0}
+			step := model.Step{}
+			step.Duration = leg.Duration
+			step.Distance = makeDistance(distance)
+			step.StartTime = leg.StartTime
+			step.EndTime = leg.EndTime
+			step.To = leg.To
+			step.Mode = model.StepModeWalk
+			step.GeometryOffset = 0
+			leg.Steps = append(leg.Steps, &step)
+		}
+		leg.Distance = makeDistance(distance)
+		leg.Geometry = tt.NewLineStringFromFlatCoords(coords)
+		totalDistance += distance
+		itin.Legs = append(itin.Legs, &leg)
+	}
+	itin.Distance = makeDistance(totalDistance)
+	return &itin
+}
+
+func makeLegTrip(trip *Trip) *model.LegTrip {
+	ret := model.LegTrip{
+		TripID:          trip.TripID,
+		TripShortName:   trip.TripShortName,
+		Headsign:        trip.Headsign,
+		FeedID:          trip.FeedOnestopID,
+		FeedVersionSha1: trip.FeedVersionSHA1,
+	}
+	if r := trip.Route; r != nil {
+		ret.Route = &model.LegRoute{
+			RouteID:        r.RouteID,
+			RouteShortName: r.RouteShortName,
+			RouteLongName:  r.RouteLongName,
+			RouteOnestopID: r.OnestopID,
+			RouteType:      r.RouteType,
+			RouteColor:     optString(r.RouteColor),
+			RouteTextColor: optString(r.RouteTextColor),
+		}
+		if a := r.Agency; a != nil {
+			ret.Route.Agency = &model.LegRouteAgency{
+				AgencyID:        a.AgencyID,
+				AgencyName:      a.AgencyName,
+				AgencyOnestopID: a.OnestopID,
+			}
+		}
+	}
+	return &ret
+}
+
+// stopWaypoint returns a waypoint for a stop index, or the input waypoint for the origin or destination
+func stopWaypoint(tab *Timetable, idx int, w *model.WaypointInput, t time.Time) *model.Waypoint {
+	if idx < 0 || idx >= len(tab.Stops) {
+		return wpiWaypoint(w)
+	}
+	stop := tab.Stops[idx]
+	return &model.Waypoint{
+		Lon:  stop.Lon,
+		Lat:  stop.Lat,
+		Name: aws.String(stop.StopName),
+		Stop: &model.WaypointStop{
+			Lon:           stop.Lon,
+			Lat:           stop.Lat,
+			Departure:     t,
+			StopID:        stop.StopID,
+			StopName:      stop.StopName,
+			StopCode:      stop.StopCode,
+			StopOnestopID: stop.OnestopID,
+		},
+	}
+}
+
+func wpiWaypoint(w *model.WaypointInput) *model.Waypoint {
+	if w == nil {
+		return nil
+	}
+	return &model.Waypoint{
+		Lon:  w.Lon,
+		Lat:  w.Lat,
+		Name: w.Name,
+	}
+}
+
+func unixTime(t int64) time.Time {
+	return time.Unix(t, 0).In(time.UTC)
+}
+
+func optString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+func makeDuration(t float64) *model.Duration {
+	return &model.Duration{Duration: float64(t), Units: model.DurationUnitSeconds}
+}
+
+// makeDistance converts meters to kilometers
+func makeDistance(v float64) *model.Distance {
+	return &model.Distance{Distance: v / 1000.0, Units: model.DistanceUnitKilometers}
+}
@@ -0,0 +1,286 @@
+package raptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dt "github.com/interline-io/transitland-server/server/directions/directionstest"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	bt := dt.MakeBasicTests()
+	noRoutable := bt["no_routable_dest_fail"]
+	noRoutable.Mode = model.StepModeTransit
+	shortWalk := bt["transit"]
+	shortWalk.To = &model.WaypointInput{Lon: -122.405001, Lat: 37.789001}
+	tcs := []dt.TestCase{
+		{
+			Name:     "transit",
+			Req:      bt["transit"],
+			Success:  true,
+			Duration: 1365,
+			Distance: 4.209,
+		},
+		{
+			Name:     "short walk",
+			Req:      shortWalk,
+			Success:  true,
+			Duration: 251,
+			Distance: 0.351,
+		},
+		{
+			Name:    "ped",
+			Req:     bt["ped"],
+			Success: false,
+		},
+		{
+			Name:    "no_dest_fail",
+			Req:     bt["no_dest_fail"],
+			Success: false,
+		},
+		{
+			Name:    "no_routable_dest_fail",
+			Req:     noRoutable,
+			Success: false,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			h := &Router{Timetable: testTimetableWithWalking()}
+			dt.HandlerTest(t, h, tc)
+		})
+	}
+}
+
+func TestRouter_Legs(t *testing.T) {
+	h := &Router{Timetable: testTimetableWithWalking()}
+	ret, err := h.Request(context.Background(), dt.MakeBasicTests()["transit"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 2, len(ret.Itineraries)) {
+		return
+	}
+	itin := ret.Itineraries[0]
+	var modes []model.StepMode
+	for _, leg := range itin.Legs {
+		modes = append(modes, *leg.Mode)
+	}
+	assert.Equal(t, []model.StepMode{model.StepModeWalk, model.StepModeTransit, model.StepModeWalk, model.StepModeTransit, model.StepModeWalk}, modes)
+	assert.Equal(t, itin.StartTime, itin.Legs[0].StartTime)
+	assert.Equal(t, itin.EndTime, itin.Legs[4].EndTime)
+
+	// Transit legs
+	leg := itin.Legs[1]
+	if assert.NotNil(t, leg.Trip) {
+		assert.Equal(t, "l1a", leg.Trip.TripID)
+		assert.Equal(t, "r", leg.Trip.Route.RouteID)
+		assert.Equal(t, "ag", leg.Trip.Route.Agency.AgencyID)
+	}
+	if assert.Equal(t, 2, len(leg.Stops)) {
+		assert.Equal(t, "A", leg.Stops[0].StopID)
+		assert.Equal(t, "B", leg.Stops[1].StopID)
+		assert.Equal(t, 1, *leg.Stops[0].StopSequence)
+		assert.Equal(t, 1, *leg.Stops[1].StopIndex)
+	}
+	if assert.NotNil(t, leg.From.Stop) {
+		assert.Equal(t, "A", leg.From.Stop.StopID)
+	}
+	assert.Equal(t, "l2a", itin.Legs[3].Trip.TripID)
+
+	// Slower itinerary without transfers
+	itin = ret.Itineraries[1]
+	assert.Equal(t, 3, len(itin.Legs))
+	assert.Equal(t, "l3a", itin.Legs[1].Trip.TripID)
+}
+
+func TestRouter_Replan(t *testing.T) {
+	tcs := []struct {
+		name        string
+		realtime    model.LegRealtime
+		expectTrips []string
+	}{
+		{
+			name:        "no changes",
+			realtime:    model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipScheduled},
+			expectTrips: []string{"l1a", "l2a"},
+		},
+		{
+			name:        "delayed",
+			realtime:    model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipScheduled, ArrivalDelay: ptr(600)},
+			expectTrips: []string{"l1a", "l2b"},
+		},
+		{
+			name:        "canceled",
+			realtime:    model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipCanceled},
+			expectTrips: []string{"l1b", "l2b"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Router{Timetable: testTimetableWithWalking()}
+			req := dt.MakeBasicTests()["transit"]
+			prev, err := h.Request(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rt := tc.realtime
+			prev.Itineraries[0].Legs[1].Realtime = &rt
+			ret, err := h.Replan(context.Background(), req, prev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, leg := range ret.Itineraries[0].Legs {
+				if leg.Trip != nil {
+					got = append(got, leg.Trip.TripID)
+				}
+			}
+			assert.Equal(t, tc.expectTrips, got)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestRouter_Options(t *testing.T) {
+	tripIds := func(itin *model.Itinerary) []string {
+		var ret []string
+		for _, leg := range itin.Legs {
+			if leg.Trip != nil {
+				ret = append(ret, leg.Trip.TripID)
+			}
+		}
+		return ret
+	}
+	inaccessible := func(tab *Timetable) {
+		for _, p := range tab.Patterns {
+			for _, trip := range p.Trips {
+				if trip.TripID == "l1a" {
+					trip.WheelchairAccessible = 2
+				}
+			}
+		}
+	}
+	arriveAt := time.Unix(t0+3000, 0)
+	tcs := []struct {
+		name        string
+		req         func(*model.DirectionRequest)
+		modify      func(*Timetable)
+		success     bool
+		expectTrips [][]string
+	}{
+		{
+			name:        "num_itineraries",
+			req:         func(r *model.DirectionRequest) { r.NumItineraries = ptr(3) },
+			success:     true,
+			expectTrips: [][]string{{"l1a", "l2a"}, {"l1b", "l2b"}, {"l3a"}},
+		},
+		{
+			name:        "num_itineraries one",
+			req:         func(r *model.DirectionRequest) { r.NumItineraries = ptr(1) },
+			success:     true,
+			expectTrips: [][]string{{"l1a", "l2a"}},
+		},
+		{
+			name: "arrive_by",
+			req: func(r *model.DirectionRequest) {
+				r.ArriveBy = ptr(true)
+				r.DepartAt = &arriveAt
+			},
+			success:     true,
+			expectTrips: [][]string{{"l1b", "l2b"}},
+		},
+		{
+			name:        "max_transfers",
+			req:         func(r *model.DirectionRequest) { r.MaxTransfers = ptr(0) },
+			success:     true,
+			expectTrips: [][]string{{"l3a"}},
+		},
+		{
+			name:        "wheelchair",
+			req:         func(r *model.DirectionRequest) { r.Wheelchair = ptr(true) },
+			modify:      inaccessible,
+			success:     true,
+			expectTrips: [][]string{{"l1b", "l2b"}, {"l3a"}},
+		},
+		{
+			name:        "wheelchair not requested",
+			modify:      inaccessible,
+			success:     true,
+			expectTrips: [][]string{{"l1a", "l2a"}, {"l3a"}},
+		},
+		{
+			name:    "allowed_route_types",
+			req:     func(r *model.DirectionRequest) { r.AllowedRouteTypes = []int{3} },
+			success: false,
+		},
+		{
+			name:        "allowed_route_types includes route",
+			req:         func(r *model.DirectionRequest) { r.AllowedRouteTypes = []int{0, 3} },
+			success:     true,
+			expectTrips: [][]string{{"l1a", "l2a"}, {"l3a"}},
+		},
+		{
+			name:    "max_walk_distance",
+			req:     func(r *model.DirectionRequest) { r.MaxWalkDistance = ptr(10.0) },
+			success: false,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tab := testTimetableWithWalking()
+			if tc.modify != nil {
+				tc.modify(tab)
+			}
+			h := &Router{Timetable: tab}
+			req := dt.MakeBasicTests()["transit"]
+			if tc.req != nil {
+				tc.req(&req)
+			}
+			ret, err := h.Request(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.success, ret.Success)
+			if !tc.success {
+				return
+			}
+			assert.Nil(t, ret.Exception)
+			var got [][]string
+			for _, itin := range ret.Itineraries {
+				got = append(got, tripIds(itin))
+				if req.ArriveBy != nil && *req.ArriveBy {
+					assert.False(t, itin.EndTime.After(arriveAt), "arrives before requested time")
+				}
+			}
+			assert.Equal(t, tc.expectTrips, got)
+		})
+	}
+}
+
+func TestRouter_BannedRoutes(t *testing.T) {
+	tab := testTimetableWithWalking()
+	for _, p := range tab.Patterns {
+		for _, trip := range p.Trips {
+			if trip.TripID == "l3a" {
+				trip.Route = &Route{RouteID: "r3", OnestopID: "r-r3"}
+			}
+		}
+	}
+	h := &Router{Timetable: tab}
+	req := dt.MakeBasicTests()["transit"]
+	req.BannedRouteOnestopIds = []string{"r-r3"}
+	ret, err := h.Request(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 1, len(ret.Itineraries)) {
+		assert.Equal(t, "l1a", ret.Itineraries[0].Legs[1].Trip.TripID)
+	}
+}
@@ -0,0 +1,346 @@
+package raptor
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+)
+
+// Stop is a stop in the timetable
+type Stop struct {
+	ID        int
+	StopID    string
+	StopName  string
+	StopCode  string
+	OnestopID string
+	Lon       float64
+	Lat       float64
+	// GTFS wheelchair_boarding; 2 is not accessible
+	WheelchairBoarding int
+}
+
+// Agency is the agency operating a route
+type Agency struct {
+	AgencyID   string
+	AgencyName string
+	OnestopID  string
+}
+
+// Route is the route serving a trip
+type Route struct {
+	RouteID        string
+	RouteShortName string
+	RouteLongName  string
+	OnestopID      string
+	RouteType      int
+	RouteColor     string
+	RouteTextColor string
+	Agency         *Agency
+}
+
+// Trip is a single trip, or a single run of a frequency-based trip.
+// Stops are timetable stop indexes; times are unix seconds.
+type Trip struct {
+	TripID          string
+	TripShortName   string
+	Headsign        string
+	FeedOnestopID   string
+	FeedVersionSHA1 string
+	Route           *Route
+	// GTFS wheelchair_accessible; 2 is not accessible
+	WheelchairAccessible int
+	Stops                []int
+	StopSequences        []int
+	Arrivals             []int64
+	Departures           []int64
+	// GTFS pickup_type and drop_off_type for each stop; 1 is not available
+	PickupTypes  []int
+	DropOffTypes []int
+}
+
+// canPickup checks if passengers can board at a stop position
+func (t *Trip) canPickup(pos int) bool {
+	return pos >= len(t.PickupTypes) || t.PickupTypes[pos] != 1
+}
+
+// canDropOff checks if passengers can alight at a stop position
+func (t *Trip) canDropOff(pos int) bool {
+	return pos >= len(t.DropOffTypes) || t.DropOffTypes[pos] != 1
+}
+
+func (t *Trip) key() string {
+	return tripKey(t.FeedVersionSHA1, t.TripID)
+}
+
+func tripKey(fvsha1 string, tripId string) string {
+	return fvsha1 + ":" + tripId
+}
+
+// Pattern is a group of trips that visit the same stops in the same order and never overtake each other
+type Pattern struct {
+	Stops []int
+	Trips []*Trip
+}
+
+// Transfer is a footpath between two stops
+type Transfer struct {
+	ToStop   int
+	Distance float64 // meters
+	MinTime  int     // seconds
+}
+
+type patternStop struct {
+	pattern  int
+	position int
+}
+
+// forbidden marks a stop where transfers are not possible
+const forbidden = math.MaxInt32
+
+// Timetable is the immutable data used by the RAPTOR search
+type Timetable struct {
+	Stops            []Stop
+	Patterns         []*Pattern
+	Transfers        [][]Transfer
+	MinTransferTimes []int
+	stopPatterns     [][]patternStop
+	stopIndex        map[int]int
+}
+
+// StopIndex returns the timetable index for a database stop ID
+func (t *Timetable) StopIndex(id int) (int, bool) {
+	idx, ok := t.stopIndex[id]
+	return idx, ok
+}
+
+// NearbyStops returns the stops within the given distance (meters) of a point, with their distance
+func (t *Timetable) NearbyStops(pt tlxy.Point, maxDistance float64) map[int]float64 {
+	ret := map[int]float64{}
+	for i, s := range t.Stops {
+		if d := tlxy.DistanceHaversine(pt, tlxy.Point{Lon: s.Lon, Lat: s.Lat}); d <= maxDistance {
+			ret[i] = d
+		}
+	}
+	return ret
+}
+
+// Builder assembles a Timetable
+type Builder struct {
+	stops            []Stop
+	stopIndex        map[int]int
+	trips            []*Trip
+	transfers        map[[2]int]Transfer
+	blocked          map[[2]int]bool
+	minTransferTimes map[int]int
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		stopIndex:        map[int]int{},
+		transfers:        map[[2]int]Transfer{},
+		blocked:          map[[2]int]bool{},
+		minTransferTimes: map[int]int{},
+	}
+}
+
+// AddStop adds a stop and returns its timetable index
+func (b *Builder) AddStop(s Stop) int {
+	if idx, ok := b.stopIndex[s.ID]; ok {
+		return idx
+	}
+	idx := len(b.stops)
+	b.stops = append(b.stops, s)
+	b.stopIndex[s.ID] = idx
+	return idx
+}
+
+// StopIndex returns the timetable index for a database stop ID
+func (b *Builder) StopIndex(id int) (int, bool) {
+	idx, ok := b.stopIndex[id]
+	return idx, ok
+}
+
+// AddTrip adds a trip; trips with fewer than two stops are ignored
+func (b *Builder) AddTrip(t *Trip) {
+	if len(t.Stops) < 2 || len(t.Stops) != len(t.Arrivals) || len(t.Stops) != len(t.Departures) {
+		return
+	}
+	b.trips = append(b.trips, t)
+}
+
+// AddTransfer adds a transfers.txt record between two stop indexes.
+// A transfer_type 2 record on the same stop sets the minimum transfer time at that stop;
+// transfer_type 3 marks the transfer as not possible.
+func (b *Builder) AddTransfer(from int, to int, transferType int, minTransferTime int) {
+	if transferType == 3 {
+		if from == to {
+			b.minTransferTimes[from] = forbidden
+		} else {
+			b.blocked[[2]int{from, to}] = true
+		}
+		return
+	}
+	if transferType == 2 && from == to {
+		b.minTransferTimes[from] = minTransferTime
+		return
+	}
+	if from == to {
+		return
+	}
+	if transferType != 2 {
+		minTransferTime = 0
+	}
+	b.transfers[[2]int{from, to}] = Transfer{
+		ToStop:   to,
+		Distance: b.stopDistance(from, to),
+		MinTime:  minTransferTime,
+	}
+}
+
+// AddWalkingTransfers adds footpaths between all stops within maxDistance meters of each other
+func (b *Builder) AddWalkingTransfers(maxDistance float64) {
+	if maxDistance <= 0 {
+		return
+	}
+	// Bucket stops into a grid roughly maxDistance on a side
+	cellSize := maxDistance / 111_000.0
+	cell := func(s Stop) [2]int {
+		return [2]int{int(math.Floor(s.Lon / cellSize)), int(math.Floor(s.Lat / cellSize))}
+	}
+	grid := map[[2]int][]int{}
+	for i, s := range b.stops {
+		c := cell(s)
+		grid[c] = append(grid[c], i)
+	}
+	for i, s := range b.stops {
+		c := cell(s)
+		// Longitude cells shrink away from the equator
+		lonCells := int(math.Ceil(1 / math.Max(math.Cos(s.Lat*math.Pi/180), 0.01)))
+		for dx := -lonCells; dx <= lonCells; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, j := range grid[[2]int{c[0] + dx, c[1] + dy}] {
+					if i == j {
+						continue
+					}
+					key := [2]int{i, j}
+					if _, ok := b.transfers[key]; ok {
+						continue
+					}
+					if d := b.stopDistance(i, j); d <= maxDistance {
+						b.transfers[key] = Transfer{ToStop: j, Distance: d}
+					}
+				}
+			}
+		}
+	}
+}
+
+func (b *Builder) stopDistance(from int, to int) float64 {
+	a := b.stops[from]
+	c := b.stops[to]
+	return tlxy.DistanceHaversine(tlxy.Point{Lon: a.Lon, Lat: a.Lat}, tlxy.Point{Lon: c.Lon, Lat: c.Lat})
+}
+
+// Build groups trips into patterns and returns the Timetable
+func (b *Builder) Build() *Timetable {
+	ret := &Timetable{
+		Stops:            b.stops,
+		Transfers:        make([][]Transfer, len(b.stops)),
+		MinTransferTimes: make([]int, len(b.stops)),
+		stopPatterns:     make([][]patternStop, len(b.stops)),
+		stopIndex:        b.stopIndex,
+	}
+	for k, v := range b.minTransferTimes {
+		ret.MinTransferTimes[k] = v
+	}
+
+	// Footpaths, in a stable order
+	var keys [][2]int
+	for k := range b.transfers {
+		if !b.blocked[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] == keys[j][0] {
+			return keys[i][1] < keys[j][1]
+		}
+		return keys[i][0] < keys[j][0]
+	})
+	for _, k := range keys {
+		ret.Transfers[k[0]] = append(ret.Transfers[k[0]], b.transfers[k])
+	}
+
+	// Group trips by stop sequence and where passengers can board and alight
+	trips := make([]*Trip, len(b.trips))
+	copy(trips, b.trips)
+	sort.SliceStable(trips, func(i, j int) bool {
+		return trips[i].Departures[0] < trips[j].Departures[0]
+	})
+	groups := map[string][]*Pattern{}
+	var groupKeys []string
+	for _, trip := range trips {
+		key := stopsKey(trip.Stops) + ":" + availabilityKey(trip)
+		if _, ok := groups[key]; !ok {
+			groupKeys = append(groupKeys, key)
+		}
+		// Split into a new pattern when a trip would overtake the previous one
+		var pattern *Pattern
+		for _, p := range groups[key] {
+			if !overtakes(p.Trips[len(p.Trips)-1], trip) {
+				pattern = p
+				break
+			}
+		}
+		if pattern == nil {
+			pattern = &Pattern{Stops: trip.Stops}
+			groups[key] = append(groups[key], pattern)
+		}
+		pattern.Trips = append(pattern.Trips, trip)
+	}
+	for _, key := range groupKeys {
+		for _, p := range groups[key] {
+			pidx := len(ret.Patterns)
+			ret.Patterns = append(ret.Patterns, p)
+			for pos, s := range p.Stops {
+				ret.stopPatterns[s] = append(ret.stopPatterns[s], patternStop{pattern: pidx, position: pos})
+			}
+		}
+	}
+	return ret
+}
+
+// overtakes checks if b arrives or departs before a at any stop
+func overtakes(a *Trip, b *Trip) bool {
+	for i := range a.Stops {
+		if b.Arrivals[i] < a.Arrivals[i] || b.Departures[i] < a.Departures[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// availabilityKey lists the stop positions where passengers can not board or alight
+func availabilityKey(trip *Trip) string {
+	var s []string
+	for i := range trip.Stops {
+		if !trip.canPickup(i) {
+			s = append(s, "p"+strconv.Itoa(i))
+		}
+		if !trip.canDropOff(i) {
+			s = append(s, "d"+strconv.Itoa(i))
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func stopsKey(stops []int) string {
+	s := make([]string, len(stops))
+	for i, v := range stops {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
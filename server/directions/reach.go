@@ -0,0 +1,264 @@
+package directions
+
+import (
+	"math"
+	"sort"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/twpayne/go-geom"
+)
+
+const maxReachCells = 1_000_000
+
+// ReachCircle is a point and the distance that can be walked from it
+type ReachCircle struct {
+	Lon    float64
+	Lat    float64
+	Radius float64 // meters
+}
+
+// ReachPolygon returns the union of a set of circles as a valid MultiPolygon.
+// The circles are rasterized on a grid with the given cell size in meters and the outline of the covered cells is traced.
+func ReachPolygon(circles []ReachCircle, cellSize float64) tt.MultiPolygon {
+	var pts []ReachCircle
+	for _, c := range circles {
+		if c.Radius > 0 {
+			pts = append(pts, c)
+		}
+	}
+	if len(pts) == 0 {
+		return tt.MultiPolygon{}
+	}
+
+	// Project to a local grid in meters
+	approx := tlxy.NewApprox(tlxy.Point{Lon: pts[0].Lon, Lat: pts[0].Lat})
+	lonMeters, latMeters := approx.LonMeters(), approx.LatMeters()
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range pts {
+		x, y := c.Lon*lonMeters, c.Lat*latMeters
+		minX, minY = math.Min(minX, x-c.Radius), math.Min(minY, y-c.Radius)
+		maxX, maxY = math.Max(maxX, x+c.Radius), math.Max(maxY, y+c.Radius)
+	}
+	if cellSize <= 0 {
+		cellSize = 50
+	}
+	for ((maxX-minX)/cellSize+2)*((maxY-minY)/cellSize+2) > maxReachCells {
+		cellSize *= 2
+	}
+	originX, originY := minX-cellSize, minY-cellSize
+	nx := int(math.Ceil((maxX-originX)/cellSize)) + 1
+	ny := int(math.Ceil((maxY-originY)/cellSize)) + 1
+
+	// Mark cells with centers inside any circle
+	g := reachGrid{nx: nx, ny: ny, cells: make([]bool, nx*ny)}
+	for _, c := range pts {
+		x, y := c.Lon*lonMeters-originX, c.Lat*latMeters-originY
+		i0, i1 := max(0, int((x-c.Radius)/cellSize)), min(nx-1, int((x+c.Radius)/cellSize))
+		j0, j1 := max(0, int((y-c.Radius)/cellSize)), min(ny-1, int((y+c.Radius)/cellSize))
+		r2 := c.Radius * c.Radius
+		for j := j0; j <= j1; j++ {
+			dy := (float64(j)+0.5)*cellSize - y
+			for i := i0; i <= i1; i++ {
+				dx := (float64(i)+0.5)*cellSize - x
+				if dx*dx+dy*dy <= r2 {
+					g.cells[j*nx+i] = true
+				}
+			}
+		}
+	}
+
+	// Trace outlines and convert back to coordinates
+	toCoord := func(v reachVertex) geom.Coord {
+		return geom.Coord{
+			(originX + float64(v.i)*cellSize) / lonMeters,
+			(originY + float64(v.j)*cellSize) / latMeters,
+		}
+	}
+	var coords [][][]geom.Coord
+	for _, poly := range g.polygons() {
+		var rings [][]geom.Coord
+		for _, ring := range poly {
+			var rc []geom.Coord
+			for _, v := range ring {
+				rc = append(rc, toCoord(v))
+			}
+			rings = append(rings, append(rc, rc[0]))
+		}
+		coords = append(coords, rings)
+	}
+	if len(coords) == 0 {
+		return tt.MultiPolygon{}
+	}
+	mp, err := geom.NewMultiPolygon(geom.XY).SetCoords(coords)
+	if err != nil {
+		return tt.MultiPolygon{}
+	}
+	mp.SetSRID(4326)
+	return tt.NewMultiPolygon(mp)
+}
+
+type reachVertex struct {
+	i, j int
+}
+
+type reachEdge struct {
+	from, to reachVertex
+}
+
+func (e reachEdge) dir() reachVertex {
+	return reachVertex{e.to.i - e.from.i, e.to.j - e.from.j}
+}
+
+type reachGrid struct {
+	nx, ny int
+	cells  []bool
+}
+
+func (g *reachGrid) in(i, j int) bool {
+	return i >= 0 && j >= 0 && i < g.nx && j < g.ny && g.cells[j*g.nx+i]
+}
+
+// polygons returns the outlines of the covered cells as polygons, each an outer ring followed by holes.
+// Outer rings are counter-clockwise and holes are clockwise.
+func (g *reachGrid) polygons() [][][]reachVertex {
+	// Boundary edges, directed with the covered cell on the left
+	out := map[reachVertex][]reachEdge{}
+	var edges []reachEdge
+	add := func(a, b reachVertex) {
+		e := reachEdge{a, b}
+		out[a] = append(out[a], e)
+		edges = append(edges, e)
+	}
+	for j := 0; j < g.ny; j++ {
+		for i := 0; i < g.nx; i++ {
+			if !g.in(i, j) {
+				continue
+			}
+			if !g.in(i, j-1) {
+				add(reachVertex{i, j}, reachVertex{i + 1, j})
+			}
+			if !g.in(i+1, j) {
+				add(reachVertex{i + 1, j}, reachVertex{i + 1, j + 1})
+			}
+			if !g.in(i, j+1) {
+				add(reachVertex{i + 1, j + 1}, reachVertex{i, j + 1})
+			}
+			if !g.in(i-1, j) {
+				add(reachVertex{i, j + 1}, reachVertex{i, j})
+			}
+		}
+	}
+
+	// Chain edges into rings, turning left at vertices shared by diagonal cells
+	used := map[reachEdge]bool{}
+	var outers, holes [][]reachVertex
+	for _, start := range edges {
+		if used[start] {
+			continue
+		}
+		var ring []reachVertex
+		e := start
+		for {
+			used[e] = true
+			ring = append(ring, e.from)
+			next, ok := nextReachEdge(e, out[e.to], used)
+			if !ok {
+				break
+			}
+			e = next
+		}
+		ring = simplifyRing(ring)
+		if len(ring) < 3 {
+			continue
+		}
+		if ringArea(ring) > 0 {
+			outers = append(outers, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	// Assign each hole to the smallest outer ring that contains it
+	sort.SliceStable(outers, func(a, b int) bool { return ringArea(outers[a]) < ringArea(outers[b]) })
+	ret := make([][][]reachVertex, len(outers))
+	for i, ring := range outers {
+		ret[i] = [][]reachVertex{ring}
+	}
+	for _, hole := range holes {
+		// A point just inside the hole, to the right of its first edge
+		a, b := hole[0], hole[1]
+		d := reachVertex{b.i - a.i, b.j - a.j}
+		px := float64(a.i+b.i)/2 + 0.25*sign(d.j)
+		py := float64(a.j+b.j)/2 - 0.25*sign(d.i)
+		for i, ring := range outers {
+			if ringContains(ring, px, py) {
+				ret[i] = append(ret[i], hole)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// nextReachEdge picks the next unused edge, preferring a left turn, then straight, then a right turn
+func nextReachEdge(e reachEdge, candidates []reachEdge, used map[reachEdge]bool) (reachEdge, bool) {
+	d := e.dir()
+	prefs := []reachVertex{
+		{-d.j, d.i}, // left
+		d,           // straight
+		{d.j, -d.i}, // right
+	}
+	for _, p := range prefs {
+		for _, c := range candidates {
+			if !used[c] && c.dir() == p {
+				return c, true
+			}
+		}
+	}
+	return reachEdge{}, false
+}
+
+// simplifyRing removes vertices between collinear edges
+func simplifyRing(ring []reachVertex) []reachVertex {
+	n := len(ring)
+	var ret []reachVertex
+	for k := 0; k < n; k++ {
+		prev, cur, next := ring[(k+n-1)%n], ring[k], ring[(k+1)%n]
+		if (cur.i-prev.i)*(next.j-cur.j)-(cur.j-prev.j)*(next.i-cur.i) != 0 {
+			ret = append(ret, cur)
+		}
+	}
+	return ret
+}
+
+// ringArea returns the signed area of a ring; positive if counter-clockwise
+func ringArea(ring []reachVertex) float64 {
+	a := 0
+	for k := range ring {
+		p, q := ring[k], ring[(k+1)%len(ring)]
+		a += p.i*q.j - q.i*p.j
+	}
+	return float64(a) / 2
+}
+
+func ringContains(ring []reachVertex, x, y float64) bool {
+	inside := false
+	for k := range ring {
+		p, q := ring[k], ring[(k+1)%len(ring)]
+		px, py, qx, qy := float64(p.i), float64(p.j), float64(q.i), float64(q.j)
+		if (py > y) != (qy > y) && x < (qx-px)*(y-py)/(qy-py)+px {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func sign(v int) float64 {
+	if v > 0 {
+		return 1
+	} else if v < 0 {
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package directions
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReachPolygon(t *testing.T) {
+	t.Run("single circle", func(t *testing.T) {
+		mp := ReachPolygon([]ReachCircle{{Lon: -122.4, Lat: 37.8, Radius: 1000}}, 25)
+		if !assert.True(t, mp.Valid) {
+			return
+		}
+		assert.Equal(t, 1, mp.Val.NumPolygons())
+		pg := mp.Val.Polygon(0)
+		assert.Equal(t, 1, pg.NumLinearRings())
+		// Area in square degrees, converted to approximate square meters
+		area := pg.Area() * 111320 * 111320 * math.Cos(37.8*math.Pi/180)
+		assert.InDelta(t, math.Pi*1000*1000, area, 0.05*math.Pi*1000*1000)
+		// Closed, counter-clockwise outer ring
+		ring := pg.LinearRing(0)
+		assert.Equal(t, ring.Coord(0), ring.Coord(ring.NumCoords()-1))
+		assert.Greater(t, ringSignedArea(ring.FlatCoords()), 0.0)
+	})
+	t.Run("overlapping circles", func(t *testing.T) {
+		mp := ReachPolygon([]ReachCircle{
+			{Lon: -122.4, Lat: 37.8, Radius: 500},
+			{Lon: -122.395, Lat: 37.8, Radius: 500},
+		}, 25)
+		if assert.True(t, mp.Valid) {
+			assert.Equal(t, 1, mp.Val.NumPolygons())
+		}
+	})
+	t.Run("separate circles", func(t *testing.T) {
+		mp := ReachPolygon([]ReachCircle{
+			{Lon: -122.4, Lat: 37.8, Radius: 200},
+			{Lon: -122.3, Lat: 37.8, Radius: 200},
+			{Lon: -122.2, Lat: 37.8, Radius: 0},
+		}, 25)
+		if assert.True(t, mp.Valid) {
+			assert.Equal(t, 2, mp.Val.NumPolygons())
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		mp := ReachPolygon(nil, 25)
+		assert.False(t, mp.Valid)
+	})
+}
+
+func TestReachGrid_Polygons(t *testing.T) {
+	// 4x4 ring of cells around a hole, and a cell touching diagonally
+	//   . . . . . X
+	//   . X X X . .
+	//   . X . X . .
+	//   . X X X . .
+	g := reachGrid{nx: 6, ny: 4, cells: make([]bool, 24)}
+	for _, c := range [][2]int{{1, 0}, {2, 0}, {3, 0}, {1, 1}, {3, 1}, {1, 2}, {2, 2}, {3, 2}, {4, 3}} {
+		g.cells[c[1]*g.nx+c[0]] = true
+	}
+	polys := g.polygons()
+	if !assert.Equal(t, 2, len(polys)) {
+		return
+	}
+	// Smallest first
+	assert.Equal(t, 1, len(polys[0]))
+	assert.Equal(t, 1.0, ringArea(polys[0][0]))
+	if assert.Equal(t, 2, len(polys[1])) {
+		assert.Equal(t, 9.0, ringArea(polys[1][0]))
+		assert.Equal(t, -1.0, ringArea(polys[1][1]))
+	}
+}
+
+func ringSignedArea(flat []float64) float64 {
+	a := 0.0
+	for i := 0; i+3 < len(flat); i += 2 {
+		a += flat[i]*flat[i+3] - flat[i+2]*flat[i+1]
+	}
+	return a / 2
+}
@@ -0,0 +1,196 @@
+package directions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// Replanner is implemented by handlers that can plan again around realtime disruptions.
+// The previous response is passed with realtime annotations applied to each TRANSIT leg.
+type Replanner interface {
+	Replan(context.Context, model.DirectionRequest, *model.Directions) (*model.Directions, error)
+}
+
+// ApplyRealtime annotates TRANSIT legs with realtime estimates, schedule relationship and alerts,
+// and flags itineraries that are no longer feasible. Returns true if any itinerary is not feasible.
+func ApplyRealtime(ctx context.Context, d *model.Directions) bool {
+	cfg := model.ForContext(ctx)
+	if d == nil || cfg.Finder == nil || cfg.RTFinder == nil {
+		return false
+	}
+	infeasible := false
+	for _, itin := range d.Itineraries {
+		for _, leg := range itin.Legs {
+			if leg.Trip == nil {
+				continue
+			}
+			rt, err := legRealtime(ctx, cfg, leg)
+			if err != nil {
+				log.For(ctx).Error().Err(err).Str("trip_id", leg.Trip.TripID).Msg("directions: failed to get realtime data for leg")
+				continue
+			}
+			leg.Realtime = rt
+		}
+		warnings := checkItinerary(itin)
+		feasible := len(warnings) == 0
+		itin.RealtimeFeasible = &feasible
+		itin.RealtimeWarnings = warnings
+		if !feasible {
+			infeasible = true
+		}
+	}
+	return infeasible
+}
+
+func legRealtime(ctx context.Context, cfg model.Config, leg *model.Leg) (*model.LegRealtime, error) {
+	// Find the static trip
+	limit := 1
+	tripId := leg.Trip.TripID
+	fvsha1 := leg.Trip.FeedVersionSha1
+	trips, err := cfg.Finder.FindTrips(ctx, &limit, nil, nil, &model.TripFilter{TripID: &tripId, FeedVersionSha1: &fvsha1})
+	if err != nil {
+		return nil, err
+	}
+	if len(trips) == 0 {
+		return nil, nil
+	}
+	trip := trips[0]
+
+	// Trip status
+	ret := model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipStatic}
+	if rtt := cfg.RTFinder.FindTrip(ctx, trip); rtt != nil {
+		ret.ScheduleRelationship = model.ScheduleRelationshipScheduled
+		if rtt.Trip != nil && rtt.Trip.ScheduleRelationship != nil {
+			sr := model.ScheduleRelationship(rtt.Trip.ScheduleRelationship.String())
+			if sr.IsValid() {
+				ret.ScheduleRelationship = sr
+			}
+		}
+	}
+	active := true
+	ret.Alerts = cfg.RTFinder.FindAlertsForTrip(ctx, trip, nil, &active)
+
+	// Estimated departure from the boarding stop and arrival at the alighting stop
+	if len(leg.Stops) == 0 {
+		return &ret, nil
+	}
+	board := leg.Stops[0]
+	alight := leg.Stops[len(leg.Stops)-1]
+	if est, delay, skipped := estimateTime(ctx, cfg, trip, board, leg.StartTime, true); skipped {
+		ret.ScheduleRelationship = model.ScheduleRelationshipSkipped
+	} else {
+		ret.EstimatedDeparture = est
+		ret.DepartureDelay = delay
+	}
+	if est, delay, skipped := estimateTime(ctx, cfg, trip, alight, leg.EndTime, false); skipped {
+		ret.ScheduleRelationship = model.ScheduleRelationshipSkipped
+	} else {
+		ret.EstimatedArrival = est
+		ret.ArrivalDelay = delay
+	}
+	return &ret, nil
+}
+
+// estimateTime returns the estimated time and delay at a stop, or true if the stop is skipped
+func estimateTime(ctx context.Context, cfg model.Config, trip *model.Trip, wp *model.WaypointDeparture, scheduled time.Time, departure bool) (*time.Time, *int, bool) {
+	if wp.StopSequence == nil {
+		return nil, nil, false
+	}
+	st := model.StopTime{}
+	st.TripID = tt.NewString(strconv.Itoa(trip.ID))
+	st.StopSequence = tt.NewInt(*wp.StopSequence)
+	st.FeedVersionID = trip.FeedVersionID
+	rtStu, ok := cfg.RTFinder.FindStopTimeUpdate(ctx, trip, &st)
+	if !ok {
+		return nil, nil, false
+	}
+	return stopTimeUpdateEstimate(rtStu, scheduled, departure)
+}
+
+func stopTimeUpdateEstimate(rtStu *model.RTStopTimeUpdate, scheduled time.Time, departure bool) (*time.Time, *int, bool) {
+	if rtStu == nil {
+		return nil, nil, false
+	}
+	delay := rtStu.LastDelay
+	if stu := rtStu.StopTimeUpdate; stu != nil {
+		if stu.GetScheduleRelationship() == pb.TripUpdate_StopTimeUpdate_SKIPPED {
+			return nil, nil, true
+		}
+		// Prefer the matching event, then fall back to the other
+		ste := stu.Arrival
+		if departure || ste == nil {
+			ste = stu.Departure
+		}
+		if ste == nil {
+			ste = stu.Arrival
+		}
+		if ste != nil && ste.Time != nil && *ste.Time != 0 {
+			t := time.Unix(*ste.Time, 0).In(time.UTC)
+			d := int(t.Sub(scheduled).Seconds())
+			return &t, &d, false
+		}
+		if ste != nil && ste.Delay != nil {
+			delay = ste.Delay
+		}
+	}
+	if delay == nil {
+		return nil, nil, false
+	}
+	d := int(*delay)
+	t := scheduled.Add(time.Duration(d) * time.Second).In(time.UTC)
+	return &t, &d, false
+}
+
+// checkItinerary returns the reasons an itinerary is no longer feasible with realtime estimates
+func checkItinerary(itin *model.Itinerary) []string {
+	var warnings []string
+	var prev *model.Leg
+	walk := 0.0
+	for _, leg := range itin.Legs {
+		if leg.Trip == nil {
+			if prev != nil && leg.Duration != nil {
+				walk += leg.Duration.Duration
+			}
+			continue
+		}
+		if rt := leg.Realtime; rt != nil {
+			switch rt.ScheduleRelationship {
+			case model.ScheduleRelationshipCanceled, model.ScheduleRelationshipDeleted:
+				warnings = append(warnings, fmt.Sprintf("trip %s is canceled", leg.Trip.TripID))
+			case model.ScheduleRelationshipSkipped:
+				warnings = append(warnings, fmt.Sprintf("trip %s does not stop at a boarding or alighting stop", leg.Trip.TripID))
+			}
+		}
+		// Check the connection from the previous transit leg
+		if prev != nil {
+			arrival := legArrival(prev).Add(time.Duration(walk) * time.Second)
+			if arrival.After(legDeparture(leg)) {
+				warnings = append(warnings, fmt.Sprintf("transfer from trip %s to trip %s is missed", prev.Trip.TripID, leg.Trip.TripID))
+			}
+		}
+		prev = leg
+		walk = 0
+	}
+	return warnings
+}
+
+func legDeparture(leg *model.Leg) time.Time {
+	if leg.Realtime != nil && leg.Realtime.EstimatedDeparture != nil {
+		return *leg.Realtime.EstimatedDeparture
+	}
+	return leg.StartTime
+}
+
+func legArrival(leg *model.Leg) time.Time {
+	if leg.Realtime != nil && leg.Realtime.EstimatedArrival != nil {
+		return *leg.Realtime.EstimatedArrival
+	}
+	return leg.EndTime
+}
@@ -0,0 +1,125 @@
+package directions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckItinerary(t *testing.T) {
+	t0 := time.Unix(1234567890, 0).In(time.UTC)
+	transitLeg := func(tripId string, start int, end int, rt *model.LegRealtime) *model.Leg {
+		return &model.Leg{
+			StartTime: t0.Add(time.Duration(start) * time.Second),
+			EndTime:   t0.Add(time.Duration(end) * time.Second),
+			Duration:  &model.Duration{Duration: float64(end - start)},
+			Trip:      &model.LegTrip{TripID: tripId},
+			Realtime:  rt,
+		}
+	}
+	walkLeg := func(start int, end int) *model.Leg {
+		return &model.Leg{
+			StartTime: t0.Add(time.Duration(start) * time.Second),
+			EndTime:   t0.Add(time.Duration(end) * time.Second),
+			Duration:  &model.Duration{Duration: float64(end - start)},
+		}
+	}
+	est := func(v int) *time.Time {
+		a := t0.Add(time.Duration(v) * time.Second)
+		return &a
+	}
+	tcs := []struct {
+		name   string
+		legs   []*model.Leg
+		expect []string
+	}{
+		{
+			name: "static",
+			legs: []*model.Leg{walkLeg(0, 100), transitLeg("a", 100, 900, nil), walkLeg(900, 960), transitLeg("b", 1000, 1600, nil)},
+		},
+		{
+			name: "delay absorbed",
+			legs: []*model.Leg{
+				transitLeg("a", 100, 900, &model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipScheduled, EstimatedArrival: est(930)}),
+				walkLeg(900, 960),
+				transitLeg("b", 1000, 1600, nil),
+			},
+		},
+		{
+			name: "missed transfer",
+			legs: []*model.Leg{
+				transitLeg("a", 100, 900, &model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipScheduled, EstimatedArrival: est(960)}),
+				walkLeg(900, 960),
+				transitLeg("b", 1000, 1600, nil),
+			},
+			expect: []string{"transfer from trip a to trip b is missed"},
+		},
+		{
+			name: "connecting trip also delayed",
+			legs: []*model.Leg{
+				transitLeg("a", 100, 900, &model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipScheduled, EstimatedArrival: est(960)}),
+				walkLeg(900, 960),
+				transitLeg("b", 1000, 1600, &model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipScheduled, EstimatedDeparture: est(1100)}),
+			},
+		},
+		{
+			name:   "canceled",
+			legs:   []*model.Leg{transitLeg("a", 100, 900, &model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipCanceled})},
+			expect: []string{"trip a is canceled"},
+		},
+		{
+			name:   "skipped",
+			legs:   []*model.Leg{transitLeg("a", 100, 900, &model.LegRealtime{ScheduleRelationship: model.ScheduleRelationshipSkipped})},
+			expect: []string{"trip a does not stop at a boarding or alighting stop"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, checkItinerary(&model.Itinerary{Legs: tc.legs}))
+		})
+	}
+}
+
+func TestStopTimeUpdateEstimate(t *testing.T) {
+	t0 := time.Unix(1234567890, 0).In(time.UTC)
+	i32 := func(v int32) *int32 { return &v }
+	i64 := func(v int64) *int64 { return &v }
+	skipped := pb.TripUpdate_StopTimeUpdate_SKIPPED
+	tcs := []struct {
+		name        string
+		rtStu       *model.RTStopTimeUpdate
+		departure   bool
+		expectDelay *int
+		expectSkip  bool
+	}{
+		{name: "none", rtStu: nil},
+		{name: "last delay", rtStu: &model.RTStopTimeUpdate{LastDelay: i32(60)}, expectDelay: ptr(60)},
+		{name: "departure delay", departure: true, rtStu: &model.RTStopTimeUpdate{StopTimeUpdate: &pb.TripUpdate_StopTimeUpdate{
+			Arrival:   &pb.TripUpdate_StopTimeEvent{Delay: i32(30)},
+			Departure: &pb.TripUpdate_StopTimeEvent{Delay: i32(90)},
+		}}, expectDelay: ptr(90)},
+		{name: "arrival delay", rtStu: &model.RTStopTimeUpdate{StopTimeUpdate: &pb.TripUpdate_StopTimeUpdate{
+			Arrival:   &pb.TripUpdate_StopTimeEvent{Delay: i32(30)},
+			Departure: &pb.TripUpdate_StopTimeEvent{Delay: i32(90)},
+		}}, expectDelay: ptr(30)},
+		{name: "arrival time", rtStu: &model.RTStopTimeUpdate{StopTimeUpdate: &pb.TripUpdate_StopTimeUpdate{
+			Arrival: &pb.TripUpdate_StopTimeEvent{Time: i64(t0.Unix() + 120)},
+		}}, expectDelay: ptr(120)},
+		{name: "skipped", rtStu: &model.RTStopTimeUpdate{StopTimeUpdate: &pb.TripUpdate_StopTimeUpdate{
+			ScheduleRelationship: &skipped,
+		}}, expectSkip: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			est, delay, skip := stopTimeUpdateEstimate(tc.rtStu, t0, tc.departure)
+			assert.Equal(t, tc.expectSkip, skip)
+			assert.Equal(t, tc.expectDelay, delay)
+			if tc.expectDelay != nil && assert.NotNil(t, est) {
+				assert.Equal(t, t0.Add(time.Duration(*tc.expectDelay)*time.Second), *est)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package directions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+var errHandlerTimeout = errors.New("routing handler timed out")
+
+// Registry sends requests to the configured chain of handlers for each mode.
+// Handlers are tried in order until one succeeds;
+// handlers that keep failing are skipped by a circuit breaker until a cooldown has passed.
+type Registry struct {
+	names             []string
+	handlers          map[string]*routerHandler
+	modes             map[string][]*routerHandler
+	enableRealtime    bool
+	matrixConcurrency int
+	cache             *ResponseCache
+}
+
+// NewRegistry creates the configured handlers using the registered router types
+func NewRegistry(cfg Config) (*Registry, error) {
+	r := &Registry{
+		handlers:          map[string]*routerHandler{},
+		modes:             map[string][]*routerHandler{},
+		enableRealtime:    cfg.EnableRealtime,
+		matrixConcurrency: cfg.MatrixConcurrency,
+	}
+	if r.matrixConcurrency <= 0 {
+		r.matrixConcurrency = defaultMatrixConcurrency
+	}
+	for _, hc := range cfg.Handlers {
+		if hc.Name == "" {
+			return nil, errors.New("handler name required")
+		}
+		if _, ok := r.handlers[hc.Name]; ok {
+			return nil, fmt.Errorf("handler '%s' already configured", hc.Name)
+		}
+		routerType := hc.Type
+		if routerType == "" {
+			routerType = hc.Name
+		}
+		hf, ok := getHandler(routerType)
+		if !ok {
+			return nil, fmt.Errorf("handler '%s': unknown router type '%s'", hc.Name, routerType)
+		}
+		h, err := hf(hc)
+		if err != nil {
+			return nil, fmt.Errorf("handler '%s': %w", hc.Name, err)
+		}
+		r.names = append(r.names, hc.Name)
+		r.handlers[hc.Name] = &routerHandler{
+			name:    hc.Name,
+			handler: h,
+			timeout: hc.Timeout,
+			breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		}
+	}
+	for mode, names := range cfg.Modes {
+		for _, name := range names {
+			rh, ok := r.handlers[name]
+			if !ok {
+				log.Infof("Routing handler '%s' for mode '%s' is not configured, skipping", name, mode)
+				continue
+			}
+			r.modes[mode] = append(r.modes[mode], rh)
+		}
+	}
+	return r, nil
+}
+
+// SetCache sets the cache used for directions responses; nil disables caching
+func (r *Registry) SetCache(c *ResponseCache) {
+	r.cache = c
+}
+
+// HandlerStatus is the circuit breaker state of a configured handler
+type HandlerStatus struct {
+	Name     string
+	Failures int  // Consecutive failures
+	Open     bool // Handler is currently skipped
+}
+
+// Status returns the state of each configured handler
+func (r *Registry) Status() []HandlerStatus {
+	var ret []HandlerStatus
+	for _, name := range r.names {
+		failures, open := r.handlers[name].breaker.status()
+		ret = append(ret, HandlerStatus{Name: name, Failures: failures, Open: open})
+	}
+	return ret
+}
+
+// chain returns the handlers to try for a request.
+// A preferred handler name overrides the mode chains.
+func (r *Registry) chain(pref string, mode model.StepMode, departAt *time.Time) []*routerHandler {
+	if pref != "" {
+		if rh, ok := r.handlers[pref]; ok {
+			return []*routerHandler{rh}
+		}
+		return nil
+	}
+	keys := []string{mode.String(), ModeDefault}
+	if mode == model.StepModeAuto && departAt == nil {
+		// Realtime auto requires aws
+		keys = []string{ModeTraffic, mode.String(), ModeDefault}
+	}
+	for _, key := range keys {
+		if c := r.modes[key]; len(c) > 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+var defaultRegistry atomic.Pointer[Registry]
+
+func init() {
+	defaultRegistry.Store(&Registry{})
+}
+
+// SetDefaultRegistry sets the registry used by HandleRequest, HandleMatrixRequest and HandleIsochroneRequest
+func SetDefaultRegistry(r *Registry) {
+	defaultRegistry.Store(r)
+}
+
+// DefaultRegistry returns the registry set by SetDefaultRegistry; by default no handlers are configured
+func DefaultRegistry() *Registry {
+	return defaultRegistry.Load()
+}
+
+type routerHandler struct {
+	name    string
+	handler Handler
+	timeout time.Duration
+	breaker *breaker
+}
+
+// Request calls the handler with the handler timeout
+func (rh *routerHandler) Request(ctx context.Context, req model.DirectionRequest) (*model.Directions, error) {
+	return call(ctx, rh, func(ctx context.Context) (*model.Directions, error) {
+		return rh.handler.Request(ctx, req)
+	})
+}
+
+// record updates the circuit breaker; failures caused by the caller canceling the request are not counted
+func (rh *routerHandler) record(ctx context.Context, ok bool) {
+	if !ok && ctx.Err() != nil {
+		return
+	}
+	rh.breaker.record(ok)
+}
+
+// call runs fn with the handler timeout.
+// Returns errHandlerTimeout if fn has not returned when the timeout is reached.
+func call[T any](ctx context.Context, rh *routerHandler, fn func(context.Context) (T, error)) (T, error) {
+	if rh.timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, rh.timeout)
+	defer cancel()
+	type result struct {
+		v   T
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		v, err := fn(ctx)
+		ch <- result{v, err}
+	}()
+	select {
+	case res := <-ch:
+		return res.v, res.err
+	case <-ctx.Done():
+		var zero T
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, fmt.Errorf("%w: %s", errHandlerTimeout, rh.name)
+		}
+		return zero, ctx.Err()
+	}
+}
+
+// tryChain calls fn for each handler in the chain until one succeeds, skipping handlers with an open circuit breaker.
+// Returns the result of the last handler called, or a nil handler if every handler was skipped.
+func tryChain[T any](ctx context.Context, chain []*routerHandler, fn func(*routerHandler) (T, bool, error)) (T, *routerHandler, error) {
+	var ret T
+	var err error
+	var served *routerHandler
+	for _, rh := range chain {
+		if !rh.breaker.allow() {
+			log.For(ctx).Debug().Str("handler", rh.name).Msg("directions: skipping handler with open circuit breaker")
+			continue
+		}
+		var ok bool
+		served = rh
+		ret, ok, err = fn(rh)
+		rh.record(ctx, ok)
+		if ok || ctx.Err() != nil {
+			break
+		}
+		log.For(ctx).Info().Err(err).Str("handler", rh.name).Msg("directions: handler failed")
+	}
+	return ret, served, err
+}
+
+// handlerDataSource appends the name of the handler that served a response to its data source
+func handlerDataSource(name string, dataSource *string) *string {
+	if dataSource == nil || *dataSource == "" {
+		return &name
+	}
+	a := fmt.Sprintf("%s (%s)", *dataSource, name)
+	return &a
+}
@@ -0,0 +1,222 @@
+package directions
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// testHandlers are created by the "test" router type by handler name
+var testHandlers = map[string]Handler{}
+
+func init() {
+	if err := RegisterRouter("test", func(cfg HandlerConfig) (Handler, error) {
+		return testHandlers[cfg.Name], nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+type testHandler struct {
+	fail  bool
+	delay time.Duration
+	calls int32
+}
+
+func (h *testHandler) Request(ctx context.Context, req model.DirectionRequest) (*model.Directions, error) {
+	atomic.AddInt32(&h.calls, 1)
+	if h.delay > 0 {
+		select {
+		case <-time.After(h.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if h.fail {
+		return &model.Directions{Success: false, Exception: ptr("could not calculate route")}, nil
+	}
+	return &model.Directions{Success: true, DataSource: ptr("TEST")}, nil
+}
+
+func newTestRegistry(t *testing.T, cfg Config, hs map[string]Handler) *Registry {
+	testHandlers = hs
+	for i := range cfg.Handlers {
+		cfg.Handlers[i].Type = "test"
+	}
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func testDirectionRequest(mode model.StepMode) model.DirectionRequest {
+	return model.DirectionRequest{
+		From: &model.WaypointInput{Lon: -122.4, Lat: 37.8},
+		To:   &model.WaypointInput{Lon: -122.3, Lat: 37.9},
+		Mode: mode,
+	}
+}
+
+func TestRegistry_Fallback(t *testing.T) {
+	a, b := &testHandler{fail: true}, &testHandler{}
+	r := newTestRegistry(t, Config{
+		Handlers: []HandlerConfig{{Name: "a"}, {Name: "b"}},
+		Modes:    map[string][]string{"WALK": {"a", "b"}},
+	}, map[string]Handler{"a": a, "b": b})
+	ret, err := r.HandleRequest(context.Background(), "", testDirectionRequest(model.StepModeWalk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ret.Success)
+	assert.Equal(t, "TEST (b)", *ret.DataSource)
+	assert.Equal(t, int32(1), a.calls)
+	assert.Equal(t, int32(1), b.calls)
+
+	// Last handler response is returned if all fail
+	b.fail = true
+	ret, err = r.HandleRequest(context.Background(), "", testDirectionRequest(model.StepModeWalk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, ret.Success)
+	assert.Equal(t, "b", *ret.DataSource)
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	a, b := &testHandler{delay: time.Second}, &testHandler{}
+	r := newTestRegistry(t, Config{
+		Handlers: []HandlerConfig{{Name: "a", Timeout: 10 * time.Millisecond}, {Name: "b"}},
+		Modes:    map[string][]string{"WALK": {"a", "b"}, "AUTO": {"a"}},
+	}, map[string]Handler{"a": a, "b": b})
+	ret, err := r.HandleRequest(context.Background(), "", testDirectionRequest(model.StepModeWalk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ret.Success)
+	assert.Equal(t, "TEST (b)", *ret.DataSource)
+
+	ret, err = r.HandleRequest(context.Background(), "", testDirectionRequest(model.StepModeAuto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, ret.Success)
+	assert.Equal(t, "routing handler timed out", *ret.Exception)
+	assert.Equal(t, "a", *ret.DataSource)
+}
+
+func TestRegistry_CircuitBreaker(t *testing.T) {
+	a, b := &testHandler{fail: true}, &testHandler{}
+	r := newTestRegistry(t, Config{
+		Handlers:         []HandlerConfig{{Name: "a"}, {Name: "b"}},
+		Modes:            map[string][]string{"WALK": {"a", "b"}},
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	}, map[string]Handler{"a": a, "b": b})
+	now := time.Unix(1234567890, 0)
+	r.handlers["a"].breaker.now = func() time.Time { return now }
+	req := testDirectionRequest(model.StepModeWalk)
+	for i := 0; i < 4; i++ {
+		if _, err := r.HandleRequest(context.Background(), "", req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, int32(2), a.calls, "skipped after threshold")
+	assert.Equal(t, int32(4), b.calls)
+	assert.Equal(t, []HandlerStatus{{Name: "a", Failures: 2, Open: true}, {Name: "b"}}, r.Status())
+
+	// Tried again after cooldown; success closes the breaker
+	now = now.Add(2 * time.Minute)
+	a.fail = false
+	ret, err := r.HandleRequest(context.Background(), "", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "TEST (a)", *ret.DataSource)
+	assert.Equal(t, []HandlerStatus{{Name: "a"}, {Name: "b"}}, r.Status())
+
+	// All handlers skipped
+	a.fail = true
+	b.fail = true
+	for i := 0; i < 2; i++ {
+		ret, err = r.HandleRequest(context.Background(), "", req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "b", *ret.DataSource)
+	}
+	ret, err = r.HandleRequest(context.Background(), "", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "no routing handler available for mode", *ret.Exception)
+}
+
+func TestRegistry_Chain(t *testing.T) {
+	hs := map[string]Handler{"a": &testHandler{}, "b": &testHandler{}, "c": &testHandler{}, "d": &testHandler{}}
+	r := newTestRegistry(t, Config{
+		Handlers: []HandlerConfig{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}},
+		Modes: map[string][]string{
+			"TRANSIT":   {"a", "missing", "b"},
+			"AUTO":      {"b"},
+			ModeTraffic: {"c"},
+			ModeDefault: {"d"},
+		},
+	}, hs)
+	departAt := time.Unix(1234567890, 0)
+	names := func(c []*routerHandler) []string {
+		var ret []string
+		for _, rh := range c {
+			ret = append(ret, rh.name)
+		}
+		return ret
+	}
+	assert.Equal(t, []string{"a", "b"}, names(r.chain("", model.StepModeTransit, nil)))
+	assert.Equal(t, []string{"c"}, names(r.chain("", model.StepModeAuto, nil)))
+	assert.Equal(t, []string{"b"}, names(r.chain("", model.StepModeAuto, &departAt)))
+	assert.Equal(t, []string{"d"}, names(r.chain("", model.StepModeWalk, nil)))
+	assert.Equal(t, []string{"c"}, names(r.chain("c", model.StepModeTransit, nil)))
+	assert.Nil(t, r.chain("missing", model.StepModeTransit, nil))
+
+	// No handlers configured
+	ret, err := (&Registry{}).HandleRequest(context.Background(), "", testDirectionRequest(model.StepModeWalk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "no routing handler found for mode", *ret.Exception)
+}
+
+func TestNewRegistry(t *testing.T) {
+	_, err := NewRegistry(Config{Handlers: []HandlerConfig{{Name: "a", Type: "unknown"}}})
+	assert.ErrorContains(t, err, "unknown router type")
+	_, err = NewRegistry(Config{Handlers: []HandlerConfig{{Name: "a", Type: "test"}, {Name: "a", Type: "test"}}})
+	assert.ErrorContains(t, err, "already configured")
+	_, err = NewRegistry(Config{Handlers: []HandlerConfig{{Type: "test"}}})
+	assert.ErrorContains(t, err, "name required")
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("TL_ROUTER_TRANSIT", "raptor, tlrouter")
+	t.Setenv("TL_ROUTER_DEFAULT", "line")
+	t.Setenv("TL_VALHALLA_ENDPOINT", "http://localhost:8002")
+	t.Setenv("TL_VALHALLA_TIMEOUT", "5s")
+	t.Setenv("TL_ROUTER_BREAKER_THRESHOLD", "0")
+	cfg := ConfigFromEnv()
+	assert.Equal(t, []string{"raptor", "tlrouter"}, cfg.Modes["TRANSIT"])
+	assert.Equal(t, []string{"line"}, cfg.Modes[ModeDefault])
+	assert.Equal(t, 0, cfg.BreakerThreshold)
+	assert.Equal(t, defaultMatrixConcurrency, cfg.MatrixConcurrency)
+	var names []string
+	for _, hc := range cfg.Handlers {
+		names = append(names, hc.Name)
+		if hc.Name == "valhalla" {
+			assert.Equal(t, "http://localhost:8002", hc.Endpoint)
+			assert.Equal(t, 5*time.Second, hc.Timeout)
+		}
+	}
+	assert.Equal(t, []string{"line", "raptor", "bikeshare", "valhalla"}, names)
+}
@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -23,20 +22,17 @@ import (
 )
 
 func init() {
-	apikey := os.Getenv("TL_TLROUTER_APIKEY")
-	endpoint := os.Getenv("TL_TLROUTER_ENDPOINT")
-	if endpoint == "" {
-		return
-	}
-	// TODO: configurable timeout
-	client := &http.Client{
-		Timeout: 60 * time.Second,
-	}
-	if os.Getenv("TL_DIRECTIONS_ENABLE_CACHE") != "" {
-		client.Transport = httpcache.NewCache(nil, nil, httpcache.NewTTLCache(16*1024, 24*time.Hour))
-	}
-	if err := directions.RegisterRouter("tlrouter", func() directions.Handler {
-		return NewRouter(client, endpoint, apikey)
+	if err := directions.RegisterRouter("tlrouter", func(cfg directions.HandlerConfig) (directions.Handler, error) {
+		if cfg.Endpoint == "" {
+			return nil, errors.New("endpoint required")
+		}
+		client := &http.Client{
+			Timeout: cfg.Timeout,
+		}
+		if cfg.Cache {
+			client.Transport = httpcache.NewCache(nil, nil, httpcache.NewTTLCache(16*1024, 24*time.Hour))
+		}
+		return NewRouter(client, cfg.Endpoint, cfg.APIKey), nil
 	}); err != nil {
 		panic(err)
 	}
@@ -93,6 +89,26 @@ func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*mode
 	departAt = departAt.In(time.UTC)
 	input.UnixTime = departAt.Unix()
 
+	// Routing options
+	if req.ArriveBy != nil {
+		input.ArriveBy = *req.ArriveBy
+	}
+	if req.NumItineraries != nil {
+		input.MaxItineraries = *req.NumItineraries
+	}
+	if req.MaxWalkDistance != nil {
+		input.MaxWalkingDistance = *req.MaxWalkDistance
+	}
+	if req.WalkSpeed != nil {
+		input.WalkingSpeed = *req.WalkSpeed
+	}
+	if req.MaxTransfers != nil {
+		input.MaxK = *req.MaxTransfers + 1
+	}
+	if req.Wheelchair != nil {
+		input.Wheelchair = *req.Wheelchair
+	}
+
 	// Make request
 	res, err := makeRequest(ctx, input, h.client, h.endpoint, h.apikey)
 	if err != nil || len(res.Plan.Itineraries) == 0 {
@@ -104,7 +120,15 @@ func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*mode
 	ret.Origin = wpiWaypoint(req.From)
 	ret.Destination = wpiWaypoint(req.To)
 	ret.Success = true
-	ret.Exception = nil
+	ret.Exception = directions.IgnoredOptions(
+		req,
+		directions.OptionArriveBy,
+		directions.OptionNumItineraries,
+		directions.OptionMaxWalkDistance,
+		directions.OptionWheelchair,
+		directions.OptionWalkSpeed,
+		directions.OptionMaxTransfers,
+	)
 	return ret, nil
 }
 
@@ -119,6 +143,24 @@ func makeRequest(ctx context.Context, req Request, client *http.Client, endpoint
 	q.Add("unixTime", fmt.Sprintf("%d", req.UnixTime))
 	q.Add("mode", req.Mode)
 	q.Add("includeWalkingItinerary", "true")
+	if req.ArriveBy {
+		q.Add("arriveBy", "true")
+	}
+	if req.MaxItineraries > 0 {
+		q.Add("maxItineraries", fmt.Sprintf("%d", req.MaxItineraries))
+	}
+	if req.MaxWalkingDistance > 0 {
+		q.Add("maxWalkingDistance", fmt.Sprintf("%f", req.MaxWalkingDistance))
+	}
+	if req.WalkingSpeed > 0 {
+		q.Add("walkingSpeed", fmt.Sprintf("%f", req.WalkingSpeed))
+	}
+	if req.MaxK > 0 {
+		q.Add("maxK", fmt.Sprintf("%d", req.MaxK))
+	}
+	if req.Wheelchair {
+		q.Add("wheelchair", "true")
+	}
 	if req.UseFallbackDates {
 		q.Add("useFallbackDates", "true")
 	}
@@ -128,7 +170,7 @@ func makeRequest(ctx context.Context, req Request, client *http.Client, endpoint
 	reqJson, _ := json.Marshal(req)
 
 	// Make request
-	hreq, err := http.NewRequest("GET", reqUrl, bytes.NewReader(reqJson))
+	hreq, err := http.NewRequestWithContext(ctx, "GET", reqUrl, bytes.NewReader(reqJson))
 	if err != nil {
 		return nil, errors.Join(errors.New("failed to create request"), err)
 	}
@@ -325,6 +367,7 @@ type Request struct {
 	MaxK                int     `json:"maxK"`
 	MaxTripTime         int     `json:"maxTripTime"`
 	TransferTimePenalty int     `json:"transferTimePenalty"`
+	Wheelchair          bool    `json:"wheelchair"`
 
 	// Fallbacks
 	UseFallbackDates bool `json:"useFallbackDates"`
@@ -1,6 +1,9 @@
 package tlrouter
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -10,6 +13,7 @@ import (
 	dt "github.com/interline-io/transitland-server/server/directions/directionstest"
 	"github.com/interline-io/transitland-server/server/testutil"
 	"github.com/interline-io/transitland-server/testdata"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRouter(t *testing.T) {
@@ -65,3 +69,47 @@ func makeTestRouter(tr http.RoundTripper) (*Router, error) {
 	}
 	return NewRouter(client, endpoint, apikey), nil
 }
+
+type captureTransport struct {
+	req *http.Request
+}
+
+func (c *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.req = req
+	return nil, errors.New("not available")
+}
+
+func TestRouter_Options(t *testing.T) {
+	req := dt.MakeBasicTests()["transit"]
+	arriveBy := true
+	numItineraries := 3
+	maxWalkDistance := 500.0
+	walkSpeed := 1.2
+	maxTransfers := 1
+	wheelchair := true
+	req.ArriveBy = &arriveBy
+	req.NumItineraries = &numItineraries
+	req.MaxWalkDistance = &maxWalkDistance
+	req.WalkSpeed = &walkSpeed
+	req.MaxTransfers = &maxTransfers
+	req.Wheelchair = &wheelchair
+	tr := &captureTransport{}
+	h, err := makeTestRouter(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Request(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if tr.req == nil {
+		t.Fatal("expected request")
+	}
+	q := tr.req.URL.Query()
+	assert.Equal(t, "true", q.Get("arriveBy"))
+	assert.Equal(t, "3", q.Get("maxItineraries"))
+	assert.Equal(t, "500.000000", q.Get("maxWalkingDistance"))
+	assert.Equal(t, "1.200000", q.Get("walkingSpeed"))
+	assert.Equal(t, "2", q.Get("maxK"))
+	assert.Equal(t, "true", q.Get("wheelchair"))
+	assert.Equal(t, fmt.Sprintf("%d", dt.BaseTime.Unix()), q.Get("unixTime"))
+}
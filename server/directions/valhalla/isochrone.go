@@ -0,0 +1,116 @@
+package valhalla
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/server/directions"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/geojson"
+)
+
+// Isochrones uses the Valhalla isochrone endpoint
+func (h *Router) Isochrones(ctx context.Context, req directions.IsochroneRequest) (*model.Isochrones, error) {
+	if err := directions.ValidateIsochroneRequest(req); err != nil {
+		return &model.Isochrones{Success: false, Exception: aws.String("invalid input")}, nil
+	}
+
+	// Prepare request
+	input := IsochroneRequest{Polygons: true}
+	input.Locations = append(input.Locations, RequestLocation{Lon: req.Origin.Lon, Lat: req.Origin.Lat})
+	switch req.Mode {
+	case model.StepModeAuto:
+		input.Costing = "auto"
+	case model.StepModeBicycle:
+		input.Costing = "bicycle"
+	case model.StepModeWalk:
+		input.Costing = "pedestrian"
+	default:
+		return &model.Isochrones{Success: false, Exception: aws.String("unsupported travel mode")}, nil
+	}
+	for _, c := range req.Cutoffs {
+		input.Contours = append(input.Contours, IsochroneContour{Time: float64(c)})
+	}
+
+	// Make request
+	res := geojson.FeatureCollection{}
+	if err := makeJsonRequest(ctx, "isochrone", input, &res, h.client, h.endpoint, h.apikey); err != nil || len(res.Features) == 0 {
+		log.For(ctx).Error().Err(err).Msg("valhalla router failed to calculate isochrones")
+		return &model.Isochrones{Success: false, Exception: aws.String("could not calculate isochrones")}, nil
+	}
+
+	// Prepare response, in the requested cutoff order
+	contours := map[int]tt.MultiPolygon{}
+	for _, f := range res.Features {
+		var contour float64
+		if v, ok := f.Properties["contour"].(float64); ok {
+			contour = v
+		} else if v, ok := f.Properties["contour"].(json.Number); ok {
+			contour, _ = v.Float64()
+		}
+		if mp, ok := toMultiPolygon(f.Geometry); ok {
+			contours[int(contour)] = mp
+		}
+	}
+	ret := model.Isochrones{
+		Origin:     wpiWaypoint(req.Origin),
+		Success:    true,
+		DataSource: aws.String("OSM"),
+	}
+	for _, c := range req.Cutoffs {
+		ret.Isochrones = append(ret.Isochrones, &model.Isochrone{Cutoff: c, Geometry: contours[c]})
+	}
+	return &ret, nil
+}
+
+func toMultiPolygon(g geom.T) (tt.MultiPolygon, bool) {
+	var mp *geom.MultiPolygon
+	switch v := g.(type) {
+	case *geom.Polygon:
+		mp = geom.NewMultiPolygon(geom.XY)
+		if err := mp.Push(geom.NewPolygonFlat(geom.XY, toXY(v.FlatCoords(), v.Stride()), toXYEnds(v.Ends(), v.Stride()))); err != nil {
+			return tt.MultiPolygon{}, false
+		}
+	case *geom.MultiPolygon:
+		mp = v
+	default:
+		return tt.MultiPolygon{}, false
+	}
+	mp.SetSRID(4326)
+	return tt.NewMultiPolygon(mp), true
+}
+
+// toXY drops any coordinates after lon, lat
+func toXY(flat []float64, stride int) []float64 {
+	if stride == 2 {
+		return flat
+	}
+	var ret []float64
+	for i := 0; i+1 < len(flat); i += stride {
+		ret = append(ret, flat[i], flat[i+1])
+	}
+	return ret
+}
+
+func toXYEnds(ends []int, stride int) []int {
+	var ret []int
+	for _, e := range ends {
+		ret = append(ret, e/stride*2)
+	}
+	return ret
+}
+
+type IsochroneRequest struct {
+	Locations []RequestLocation  `json:"locations"`
+	Costing   string             `json:"costing"`
+	Contours  []IsochroneContour `json:"contours"`
+	Polygons  bool               `json:"polygons"`
+}
+
+type IsochroneContour struct {
+	Time float64 `json:"time"` // minutes
+}
@@ -0,0 +1,78 @@
+package valhalla
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/interline-io/transitland-server/server/directions"
+	dt "github.com/interline-io/transitland-server/server/directions/directionstest"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter_Isochrones(t *testing.T) {
+	tr := &responseTransport{body: `{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "properties": {"contour": 30, "metric": "time"}, "geometry": {"type": "Polygon", "coordinates": [[[-122.5, 37.7], [-122.3, 37.7], [-122.3, 37.9], [-122.5, 37.9], [-122.5, 37.7]]]}},
+			{"type": "Feature", "properties": {"contour": 15, "metric": "time"}, "geometry": {"type": "Polygon", "coordinates": [[[-122.45, 37.75], [-122.35, 37.75], [-122.35, 37.85], [-122.45, 37.85], [-122.45, 37.75]]]}}
+		]
+	}`}
+	h, err := makeTestRouter(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := h.Isochrones(context.Background(), directions.IsochroneRequest{
+		Origin:  &dt.BaseFrom,
+		Mode:    model.StepModeWalk,
+		Cutoffs: []int{15, 30},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ret.Success)
+	assert.True(t, strings.HasSuffix(tr.url, "/isochrone"))
+	if assert.Equal(t, 2, len(ret.Isochrones)) {
+		assert.Equal(t, 15, ret.Isochrones[0].Cutoff)
+		assert.Equal(t, 30, ret.Isochrones[1].Cutoff)
+		for _, iso := range ret.Isochrones {
+			if assert.True(t, iso.Geometry.Valid) {
+				assert.Equal(t, 1, iso.Geometry.Val.NumPolygons())
+			}
+		}
+		assert.Equal(t, -122.45, ret.Isochrones[0].Geometry.Val.Polygon(0).Coord(0)[0])
+	}
+}
+
+func TestRouter_Isochrones_Request(t *testing.T) {
+	tr := &captureTransport{}
+	h, err := makeTestRouter(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := h.Isochrones(context.Background(), directions.IsochroneRequest{
+		Origin:  &dt.BaseFrom,
+		Mode:    model.StepModeBicycle,
+		Cutoffs: []int{10, 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, ret.Success)
+	input := IsochroneRequest{}
+	if err := json.Unmarshal(tr.body, &input); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "bicycle", input.Costing)
+	assert.True(t, input.Polygons)
+	assert.Equal(t, []IsochroneContour{{Time: 10}, {Time: 20}}, input.Contours)
+
+	// Transit is not supported
+	ret, err = h.Isochrones(context.Background(), directions.IsochroneRequest{Origin: &dt.BaseFrom, Mode: model.StepModeTransit, Cutoffs: []int{10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, ret.Success)
+}
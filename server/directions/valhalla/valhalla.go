@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -21,19 +21,17 @@ import (
 )
 
 func init() {
-	endpoint := os.Getenv("TL_VALHALLA_ENDPOINT")
-	apikey := os.Getenv("TL_VALHALLA_API_KEY")
-	if endpoint == "" {
-		return
-	}
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	if os.Getenv("TL_DIRECTIONS_ENABLE_CACHE") != "" {
-		client.Transport = httpcache.NewCache(nil, nil, httpcache.NewTTLCache(16*1024, 24*time.Hour))
-	}
-	if err := directions.RegisterRouter("valhalla", func() directions.Handler {
-		return NewRouter(client, endpoint, apikey)
+	if err := directions.RegisterRouter("valhalla", func(cfg directions.HandlerConfig) (directions.Handler, error) {
+		if cfg.Endpoint == "" {
+			return nil, errors.New("endpoint required")
+		}
+		client := &http.Client{
+			Timeout: cfg.Timeout,
+		}
+		if cfg.Cache {
+			client.Transport = httpcache.NewCache(nil, nil, httpcache.NewTTLCache(16*1024, 24*time.Hour))
+		}
+		return NewRouter(client, cfg.Endpoint, cfg.APIKey), nil
 	}); err != nil {
 		panic(err)
 	}
@@ -77,6 +75,28 @@ func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*mode
 		return &model.Directions{Success: false, Exception: aws.String("unsupported travel mode")}, nil
 	}
 
+	// Routing options
+	supported := []string{directions.OptionArriveBy, directions.OptionNumItineraries}
+	if req.NumItineraries != nil && *req.NumItineraries > 1 {
+		input.Alternates = *req.NumItineraries - 1
+	}
+	if input.Costing == "pedestrian" {
+		supported = append(supported, directions.OptionMaxWalkDistance, directions.OptionWheelchair, directions.OptionWalkSpeed)
+		opts := PedestrianCostingOptions{}
+		if req.Wheelchair != nil && *req.Wheelchair {
+			opts.Type = "wheelchair"
+		}
+		if req.WalkSpeed != nil {
+			opts.WalkingSpeed = *req.WalkSpeed * 3.6
+		}
+		if req.MaxWalkDistance != nil {
+			opts.MaxDistance = *req.MaxWalkDistance
+		}
+		if opts != (PedestrianCostingOptions{}) {
+			input.CostingOptions = &CostingOptions{Pedestrian: &opts}
+		}
+	}
+
 	// Prepare time
 	departAt := time.Now().In(time.UTC)
 	if h.Clock != nil {
@@ -98,57 +118,132 @@ func (h *Router) Request(ctx context.Context, req model.DirectionRequest) (*mode
 	}
 	// Prepare response
 	ret := makeDirections(res, departAt)
+	if req.ArriveBy != nil && *req.ArriveBy {
+		directions.ArriveBy(ret, departAt)
+	}
 	ret.Origin = wpiWaypoint(req.From)
 	ret.Destination = wpiWaypoint(req.To)
 	ret.Success = true
-	ret.Exception = nil
+	ret.Exception = directions.IgnoredOptions(req, supported...)
+	return ret, nil
+}
+
+// Matrix uses the Valhalla sources_to_targets endpoint
+func (h *Router) Matrix(ctx context.Context, req directions.MatrixRequest) (*model.TravelTimeMatrix, error) {
+	if err := directions.ValidateMatrixRequest(req); err != nil {
+		return &model.TravelTimeMatrix{Success: false, Exception: aws.String("invalid input")}, nil
+	}
+
+	// Prepare request
+	input := MatrixRequest{Units: "kilometers"}
+	for _, w := range req.Origins {
+		input.Sources = append(input.Sources, RequestLocation{Lon: w.Lon, Lat: w.Lat})
+	}
+	for _, w := range req.Destinations {
+		input.Targets = append(input.Targets, RequestLocation{Lon: w.Lon, Lat: w.Lat})
+	}
+	switch req.Mode {
+	case model.StepModeAuto:
+		input.Costing = "auto"
+	case model.StepModeBicycle:
+		input.Costing = "bicycle"
+	case model.StepModeWalk, model.StepModeTransit:
+		input.Costing = "pedestrian"
+	default:
+		return &model.TravelTimeMatrix{Success: false, Exception: aws.String("unsupported travel mode")}, nil
+	}
+
+	// Make request
+	res := MatrixResponse{}
+	if err := makeJsonRequest(ctx, "sources_to_targets", input, &res, h.client, h.endpoint, h.apikey); err != nil || len(res.SourcesToTargets) != len(req.Origins) {
+		log.For(ctx).Error().Err(err).Msg("valhalla router failed to calculate matrix")
+		return &model.TravelTimeMatrix{Success: false, Exception: aws.String("could not calculate matrix")}, nil
+	}
+
+	// Prepare response
+	ret := directions.NewMatrix(req)
+	for i, row := range res.SourcesToTargets {
+		for _, cell := range row {
+			j := cell.ToIndex
+			if j < 0 || j >= len(req.Destinations) || cell.Time == nil || cell.Distance == nil {
+				continue
+			}
+			ret.Durations[i][j] = makeDuration(*cell.Time)
+			ret.Distances[i][j] = makeDistance(*cell.Distance, res.Units)
+		}
+	}
+	ret.Success = true
+	ret.DataSource = aws.String("OSM")
 	return ret, nil
 }
 
 func makeRequest(ctx context.Context, req Request, client *http.Client, endpoint string, apikey string) (*Response, error) {
-	reqUrl := fmt.Sprintf("%s/route", endpoint)
-	hreq, err := http.NewRequest("GET", reqUrl, nil)
-	if err != nil {
+	res := Response{}
+	if err := makeJsonRequest(ctx, "route", req, &res, client, endpoint, apikey); err != nil {
 		return nil, err
 	}
+	return &res, nil
+}
+
+func makeJsonRequest(ctx context.Context, action string, req any, res any, client *http.Client, endpoint string, apikey string) error {
+	reqUrl := fmt.Sprintf("%s/%s", endpoint, action)
+	hreq, err := http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
+	if err != nil {
+		return err
+	}
 	reqJson, err := json.Marshal(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	hreq.Body = io.NopCloser(bytes.NewReader(reqJson))
 	hreq.Header.Add("api_key", apikey)
 	log.For(ctx).Debug().Str("url", hreq.URL.String()).Str("body", string(reqJson)).Msg("valhalla request")
 	resp, err := client.Do(hreq)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
-	}
-	res := Response{}
-	if err := json.Unmarshal(body, &res); err != nil {
-		return nil, err
+		return err
 	}
-	return &res, nil
+	return json.Unmarshal(body, res)
 }
 
 func makeDirections(res *Response, departAt time.Time) *model.Directions {
-	// Create itinerary summary
-	itin := model.Itinerary{}
-
-	// Valhalla responses have single itineraries
 	ret := model.Directions{}
+	ret.DataSource = aws.String("OSM")
+
+	// Valhalla responses have a single trip, with optional alternates
+	trips := []Trip{res.Trip}
+	for _, alt := range res.Alternates {
+		trips = append(trips, alt.Trip)
+	}
+	for _, trip := range trips {
+		if itin := makeItinerary(trip, res.Units, departAt); itin != nil {
+			ret.Itineraries = append(ret.Itineraries, itin)
+		}
+	}
+	if len(ret.Itineraries) == 0 {
+		return &model.Directions{Success: false, Exception: aws.String("no legs in response")}
+	}
+
+	// Add summary
+	itin := ret.Itineraries[0]
 	ret.Duration = itin.Duration
 	ret.Distance = itin.Distance
 	ret.StartTime = &itin.StartTime
 	ret.EndTime = &itin.EndTime
-	ret.DataSource = aws.String("OSM")
+	return &ret
+}
+
+func makeItinerary(trip Trip, units string, departAt time.Time) *model.Itinerary {
+	// Create itinerary summary
+	itin := model.Itinerary{}
 
 	// Create legs for itinerary
 	prevLegDepartAt := departAt
-	for _, vleg := range res.Trip.Legs {
+	for _, vleg := range trip.Legs {
 		// Decode shape using custom 1e6 scale
 		shapeDecoder := polyline.Codec{
 			Dim:   2,
@@ -167,7 +262,7 @@ func makeDirections(res *Response, departAt time.Time) *model.Directions {
 		// Process leg
 		leg := model.Leg{}
 		leg.Duration = makeDuration(vleg.Summary.Time)
-		leg.Distance = makeDistance(vleg.Summary.Length, res.Units)
+		leg.Distance = makeDistance(vleg.Summary.Length, units)
 
 		if len(vleg.Maneuvers) > 0 {
 			// Set mode
@@ -207,7 +302,7 @@ func makeDirections(res *Response, departAt time.Time) *model.Directions {
 		for _, vstep := range vleg.Maneuvers {
 			step := model.Step{}
 			step.Duration = makeDuration(vstep.Time)
-			step.Distance = makeDistance(vstep.Length, res.Units)
+			step.Distance = makeDistance(vstep.Length, units)
 			step.StartTime = prevStepDepartAt
 			step.EndTime = prevStepDepartAt.Add(time.Duration(vstep.Time) * time.Second)
 			step.GeometryOffset = vstep.BeginShapeIndex
@@ -222,26 +317,53 @@ func makeDirections(res *Response, departAt time.Time) *model.Directions {
 		itin.Legs = append(itin.Legs, &leg)
 	}
 	if len(itin.Legs) == 0 {
-		return &model.Directions{Success: false, Exception: aws.String("no legs in response")}
+		return nil
 	}
 
 	// Add summary
-	itin.Duration = makeDuration(res.Trip.Summary.Time)
-	itin.Distance = makeDistance(res.Trip.Summary.Length, res.Units)
+	itin.Duration = makeDuration(trip.Summary.Time)
+	itin.Distance = makeDistance(trip.Summary.Length, units)
 	itin.StartTime = departAt
-	itin.EndTime = departAt.Add(time.Duration(res.Trip.Summary.Time) * time.Second)
+	itin.EndTime = departAt.Add(time.Duration(trip.Summary.Time) * time.Second)
 	itin.From = itin.Legs[0].From
 	itin.To = itin.Legs[0].To
-	ret.Duration = itin.Duration
-	ret.Distance = itin.Distance
-	ret.Itineraries = append(ret.Itineraries, &itin)
-
-	return &ret
+	return &itin
 }
 
 type Request struct {
-	Locations []RequestLocation `json:"locations"`
-	Costing   string            `json:"costing"`
+	Locations      []RequestLocation `json:"locations"`
+	Costing        string            `json:"costing"`
+	CostingOptions *CostingOptions   `json:"costing_options,omitempty"`
+	Alternates     int               `json:"alternates,omitempty"`
+}
+
+type CostingOptions struct {
+	Pedestrian *PedestrianCostingOptions `json:"pedestrian,omitempty"`
+}
+
+type PedestrianCostingOptions struct {
+	Type         string  `json:"type,omitempty"`
+	WalkingSpeed float64 `json:"walking_speed,omitempty"` // km/h
+	MaxDistance  float64 `json:"max_distance,omitempty"`  // meters
+}
+
+type MatrixRequest struct {
+	Sources []RequestLocation `json:"sources"`
+	Targets []RequestLocation `json:"targets"`
+	Costing string            `json:"costing"`
+	Units   string            `json:"units"`
+}
+
+type MatrixResponse struct {
+	SourcesToTargets [][]MatrixCell `json:"sources_to_targets"`
+	Units            string         `json:"units"`
+}
+
+type MatrixCell struct {
+	Distance  *float64 `json:"distance"`
+	Time      *float64 `json:"time"`
+	FromIndex int      `json:"from_index"`
+	ToIndex   int      `json:"to_index"`
 }
 
 type RequestLocation struct {
@@ -250,8 +372,13 @@ type RequestLocation struct {
 }
 
 type Response struct {
-	Trip  Trip   `json:"trip"`
-	Units string `json:"units"`
+	Trip       Trip        `json:"trip"`
+	Alternates []Alternate `json:"alternates"`
+	Units      string      `json:"units"`
+}
+
+type Alternate struct {
+	Trip Trip `json:"trip"`
 }
 
 type Trip struct {
@@ -1,15 +1,23 @@
 package valhalla
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/interline-io/transitland-server/server/directions"
 	dt "github.com/interline-io/transitland-server/server/directions/directionstest"
+	"github.com/interline-io/transitland-server/server/model"
 	"github.com/interline-io/transitland-server/server/testutil"
 	"github.com/interline-io/transitland-server/testdata"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRouter(t *testing.T) {
@@ -87,3 +95,109 @@ func makeTestRouter(tr http.RoundTripper) (*Router, error) {
 	}
 	return NewRouter(client, endpoint, apikey), nil
 }
+
+type captureTransport struct {
+	body []byte
+}
+
+func (c *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		c.body, _ = io.ReadAll(req.Body)
+	}
+	return nil, errors.New("not available")
+}
+
+func TestRouter_Options(t *testing.T) {
+	req := dt.MakeBasicTests()["ped"]
+	numItineraries := 3
+	maxWalkDistance := 500.0
+	walkSpeed := 1.0
+	wheelchair := true
+	maxTransfers := 1
+	req.NumItineraries = &numItineraries
+	req.MaxWalkDistance = &maxWalkDistance
+	req.WalkSpeed = &walkSpeed
+	req.Wheelchair = &wheelchair
+	req.MaxTransfers = &maxTransfers
+	tr := &captureTransport{}
+	h, err := makeTestRouter(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Request(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	input := Request{}
+	if err := json.Unmarshal(tr.body, &input); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "pedestrian", input.Costing)
+	assert.Equal(t, 2, input.Alternates)
+	if assert.NotNil(t, input.CostingOptions) && assert.NotNil(t, input.CostingOptions.Pedestrian) {
+		assert.Equal(t, "wheelchair", input.CostingOptions.Pedestrian.Type)
+		assert.InDelta(t, 3.6, input.CostingOptions.Pedestrian.WalkingSpeed, 0.001)
+		assert.InDelta(t, 500.0, input.CostingOptions.Pedestrian.MaxDistance, 0.001)
+	}
+}
+
+func TestMakeDirections_Alternates(t *testing.T) {
+	trip := func(seconds float64) Trip {
+		return Trip{
+			Summary: Summary{Time: seconds, Length: 1.0},
+			Legs: []Leg{{
+				Shape:     "_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+				Summary:   Summary{Time: seconds, Length: 1.0},
+				Maneuvers: []Maneuver{{Time: seconds, Length: 1.0, TravelMode: "pedestrian"}},
+			}},
+		}
+	}
+	res := Response{Trip: trip(100), Alternates: []Alternate{{Trip: trip(200)}}, Units: "kilometers"}
+	ret := makeDirections(&res, dt.BaseTime)
+	if assert.Len(t, ret.Itineraries, 2) {
+		assert.Equal(t, 100.0, ret.Itineraries[0].Duration.Duration)
+		assert.Equal(t, 200.0, ret.Itineraries[1].Duration.Duration)
+	}
+	assert.Equal(t, 100.0, ret.Duration.Duration)
+}
+
+type responseTransport struct {
+	url  string
+	body string
+}
+
+func (c *responseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.url = req.URL.String()
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(c.body)), Request: req}, nil
+}
+
+func TestRouter_Matrix(t *testing.T) {
+	tr := &responseTransport{body: `{
+		"units": "kilometers",
+		"sources_to_targets": [
+			[{"distance": 4.387, "time": 3130, "from_index": 0, "to_index": 0}, {"distance": null, "time": null, "from_index": 0, "to_index": 1}],
+			[{"distance": 1.5, "time": 1000, "from_index": 1, "to_index": 0}, {"distance": 0, "time": 0, "from_index": 1, "to_index": 1}]
+		]
+	}`}
+	h, err := makeTestRouter(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := model.WaypointInput{Lon: -122.42, Lat: 37.78}
+	ret, err := h.Matrix(context.Background(), directions.MatrixRequest{
+		Origins:      []*model.WaypointInput{&dt.BaseFrom, &other},
+		Destinations: []*model.WaypointInput{&dt.BaseTo, &other},
+		Mode:         model.StepModeWalk,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ret.Success)
+	assert.True(t, strings.HasSuffix(tr.url, "/sources_to_targets"))
+	assert.Equal(t, 3130.0, ret.Durations[0][0].Duration)
+	assert.Equal(t, 4.387, ret.Distances[0][0].Distance)
+	assert.Nil(t, ret.Durations[0][1])
+	assert.Nil(t, ret.Distances[0][1])
+	assert.Equal(t, 1000.0, ret.Durations[1][0].Duration)
+	assert.Equal(t, 0.0, ret.Durations[1][1].Duration)
+	assert.Equal(t, 2, len(ret.Origins))
+}
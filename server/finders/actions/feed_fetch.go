@@ -139,15 +139,30 @@ func RTFetch(ctx context.Context, target string, feedId string, feedUrl string,
 }
 
 func GbfsFetch(ctx context.Context, feedId string, feedUrl string) error {
+	_, err := gbfsFetch(ctx, feedId, feedUrl)
+	return err
+}
+
+// PollFetch fetches a GTFS-RT or GBFS feed URL of the given type.
+// It returns how long the response may be cached, if known.
+func PollFetch(ctx context.Context, feedId string, urlType string, feedUrl string) (time.Duration, error) {
+	if urlType == "gbfs_auto_discovery" {
+		result, err := gbfsFetch(ctx, feedId, feedUrl)
+		return time.Duration(result.TTL) * time.Second, err
+	}
+	return 0, RTFetch(ctx, feedId, feedId, feedUrl, urlType)
+}
+
+func gbfsFetch(ctx context.Context, feedId string, feedUrl string) (gbfs.Result, error) {
 	cfg := model.ForContext(ctx)
 	gfeeds, err := cfg.Finder.FindFeeds(ctx, nil, nil, nil, &model.FeedFilter{OnestopID: &feedId})
 	if err != nil {
 		log.For(ctx).Error().Err(err).Msg("gbfs-fetch: error loading source feed")
-		return err
+		return gbfs.Result{}, err
 	}
 	if len(gfeeds) == 0 {
 		log.For(ctx).Error().Err(err).Msg("gbfs-fetch: source feed not found")
-		return errors.New("feed not found")
+		return gbfs.Result{}, errors.New("feed not found")
 	}
 
 	// Make request
@@ -165,10 +180,10 @@ func GbfsFetch(ctx context.Context, feedId string, feedUrl string) error {
 		opts,
 	)
 	if err != nil {
-		return err
+		return result, err
 	}
 	if result.FetchError != nil {
-		return result.FetchError
+		return result, result.FetchError
 	}
 
 	// Save to cache
@@ -178,7 +193,7 @@ func GbfsFetch(ctx context.Context, feedId string, feedUrl string) error {
 			cfg.GbfsFinder.AddData(ctx, key, feed)
 		}
 	}
-	return nil
+	return result, nil
 }
 
 func fetchCheckFeed(ctx context.Context, feedId string) (*model.Feed, error) {
@@ -360,6 +360,9 @@ func censusDatasetGeographySelect(limit *int, where *model.CensusDatasetGeograph
 		} else if loc.Within != nil && loc.Within.Valid {
 			jj, _ := geojson.Marshal(loc.Within.Val)
 			qJoin = sq.StatementBuilder.Select().Column("ST_GeomFromGeoJSON(?) as buffer", string(jj))
+		} else if loc.WithinMultipolygon != nil && loc.WithinMultipolygon.Valid {
+			jj, _ := geojson.Marshal(loc.WithinMultipolygon.Val)
+			qJoin = sq.StatementBuilder.Select().Column("ST_GeomFromGeoJSON(?) as buffer", string(jj))
 		} else if loc.Near != nil {
 			radius := checkFloat(&loc.Near.Radius, 0, 1_000_000)
 			qJoin = sq.StatementBuilder.Select().Column("ST_Buffer(ST_MakePoint(?,?)::geography, ?) as buffer", loc.Near.Lon, loc.Near.Lat, radius)
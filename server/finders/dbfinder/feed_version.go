@@ -161,6 +161,23 @@ func (f *Finder) FeedInfosByFeedVersionIDs(ctx context.Context, limit *int, keys
 	return arrangeGroup(keys, ents, func(ent *model.FeedInfo) int { return ent.FeedVersionID }), err
 }
 
+func (f *Finder) TransfersByFeedVersionIDs(ctx context.Context, limit *int, keys []int) ([][]*model.Transfer, error) {
+	var ents []*model.Transfer
+	err := dbutil.Select(ctx,
+		f.db,
+		lateralWrap(
+			quickSelectOrder("gtfs_transfers", limit, nil, nil, "id"),
+			"feed_versions",
+			"id",
+			"gtfs_transfers",
+			"feed_version_id",
+			keys,
+		),
+		&ents,
+	)
+	return arrangeGroup(keys, ents, func(ent *model.Transfer) int { return ent.FeedVersionID }), err
+}
+
 func feedVersionSelect(limit *int, after *model.Cursor, ids []int, permFilter *model.PermFilter, where *model.FeedVersionFilter) sq.SelectBuilder {
 	q := sq.StatementBuilder.
 		Select(
@@ -0,0 +1,209 @@
+package dbfinder
+
+import (
+	"context"
+	"time"
+
+	"github.com/interline-io/transitland-server/server/dbutil"
+	"github.com/interline-io/transitland-server/server/model"
+	sq "github.com/irees/squirrel"
+)
+
+// FindFlexServices returns GTFS-Flex stop times in active feed versions whose location or location group
+// is within the specified radius. If at is provided, only stop times with a pickup/drop off window
+// including that local time on an active service day are returned.
+func (f *Finder) FindFlexServices(ctx context.Context, limit *int, near model.PointRadius, at *time.Time) ([]*model.FlexService, error) {
+	var ents []*model.FlexService
+	radius := checkFloat(&near.Radius, 0, 1_000_000)
+	q := sq.StatementBuilder.
+		Select(
+			"gtfs_trips.feed_version_id",
+			"gtfs_trips.id AS trip_id",
+			"sts.stop_sequence",
+			"sts.location_id",
+			"sts.location_group_id",
+			"sts.start_pickup_drop_off_window + gtfs_trips.journey_pattern_offset AS start_pickup_drop_off_window",
+			"sts.end_pickup_drop_off_window + gtfs_trips.journey_pattern_offset AS end_pickup_drop_off_window",
+			"sts.pickup_type",
+			"sts.drop_off_type",
+			"sts.pickup_booking_rule_id",
+			"sts.drop_off_booking_rule_id",
+		).
+		From("gtfs_trips").
+		Join("gtfs_trips t2 ON t2.trip_id::text = gtfs_trips.journey_pattern_id AND gtfs_trips.feed_version_id = t2.feed_version_id").
+		Join("gtfs_stop_times sts ON sts.trip_id = t2.id AND sts.feed_version_id = t2.feed_version_id").
+		Join("feed_versions on feed_versions.id = gtfs_trips.feed_version_id").
+		Join("current_feeds on current_feeds.id = feed_versions.feed_id").
+		Join("feed_states on feed_states.feed_version_id = gtfs_trips.feed_version_id").
+		Where(sq.Or{
+			sq.Expr("sts.location_id IN (SELECT gtfs_locations.id FROM gtfs_locations WHERE ST_DWithin(gtfs_locations.geometry, ST_MakePoint(?,?), ?))", near.Lon, near.Lat, radius),
+			sq.Expr(`sts.location_group_id IN (
+				SELECT gtfs_location_group_stops.location_group_id
+				FROM gtfs_location_group_stops
+				JOIN gtfs_stops ON gtfs_stops.id = gtfs_location_group_stops.stop_id
+				WHERE ST_DWithin(gtfs_stops.geometry, ST_MakePoint(?,?), ?))`, near.Lon, near.Lat, radius),
+		}).
+		Limit(checkLimit(limit)).
+		OrderBy("gtfs_trips.feed_version_id, gtfs_trips.id, sts.stop_sequence")
+
+	if at != nil {
+		// Local date and time in the agency timezone
+		q = q.
+			Column("local_time.service_date").
+			Join("gtfs_routes on gtfs_routes.id = gtfs_trips.route_id").
+			Join("gtfs_agencies on gtfs_agencies.id = gtfs_routes.agency_id").
+			JoinClause(`JOIN LATERAL (
+				SELECT
+					(?::timestamptz AT TIME ZONE gtfs_agencies.agency_timezone)::date AS service_date,
+					extract(epoch FROM (?::timestamptz AT TIME ZONE gtfs_agencies.agency_timezone)::time)::int AS seconds
+				) local_time ON true`, at.UTC(), at.UTC()).
+			Where("sts.start_pickup_drop_off_window + gtfs_trips.journey_pattern_offset <= local_time.seconds").
+			Where("sts.end_pickup_drop_off_window + gtfs_trips.journey_pattern_offset >= local_time.seconds").
+			Where(`((
+				EXISTS (
+					SELECT 1 FROM gtfs_calendars gc
+					WHERE gc.id = gtfs_trips.service_id
+						AND gc.start_date <= local_time.service_date
+						AND gc.end_date >= local_time.service_date
+						AND (CASE EXTRACT(isodow FROM local_time.service_date)
+							WHEN 1 THEN gc.monday = 1
+							WHEN 2 THEN gc.tuesday = 1
+							WHEN 3 THEN gc.wednesday = 1
+							WHEN 4 THEN gc.thursday = 1
+							WHEN 5 THEN gc.friday = 1
+							WHEN 6 THEN gc.saturday = 1
+							WHEN 7 THEN gc.sunday = 1
+						END))
+				AND NOT EXISTS (
+					SELECT 1 FROM gtfs_calendar_dates gcd
+					WHERE gcd.service_id = gtfs_trips.service_id AND gcd.date = local_time.service_date AND gcd.exception_type = 2)
+				) OR EXISTS (
+					SELECT 1 FROM gtfs_calendar_dates gcd
+					WHERE gcd.service_id = gtfs_trips.service_id AND gcd.date = local_time.service_date AND gcd.exception_type = 1))`)
+	}
+
+	// Handle permissions
+	q = pfJoinCheckFv(q, f.PermFilter(ctx))
+	if err := dbutil.Select(ctx, f.db, q, &ents); err != nil {
+		return nil, logErr(ctx, err)
+	}
+	return ents, nil
+}
+
+// FlexStopTimesByTripIDs returns the GTFS-Flex attributes of the stop times of each trip.
+// These columns are loaded separately from stop times so that regular stop time queries do not depend on them.
+func (f *Finder) FlexStopTimesByTripIDs(ctx context.Context, keys []int) ([][]*model.FlexService, []error) {
+	var ents []*model.FlexService
+	q := sq.StatementBuilder.
+		Select(
+			"gtfs_trips.feed_version_id",
+			"gtfs_trips.id AS trip_id",
+			"sts.stop_sequence",
+			"sts.location_id",
+			"sts.location_group_id",
+			"sts.start_pickup_drop_off_window + gtfs_trips.journey_pattern_offset AS start_pickup_drop_off_window",
+			"sts.end_pickup_drop_off_window + gtfs_trips.journey_pattern_offset AS end_pickup_drop_off_window",
+			"sts.pickup_type",
+			"sts.drop_off_type",
+			"sts.pickup_booking_rule_id",
+			"sts.drop_off_booking_rule_id",
+		).
+		From("gtfs_trips").
+		Join("gtfs_trips t2 ON t2.trip_id::text = gtfs_trips.journey_pattern_id AND gtfs_trips.feed_version_id = t2.feed_version_id").
+		Join("gtfs_stop_times sts ON sts.trip_id = t2.id AND sts.feed_version_id = t2.feed_version_id").
+		Where(In("gtfs_trips.id", keys)).
+		Where(sq.Or{
+			sq.NotEq{"sts.location_id": nil},
+			sq.NotEq{"sts.location_group_id": nil},
+			sq.NotEq{"sts.start_pickup_drop_off_window": nil},
+			sq.NotEq{"sts.pickup_booking_rule_id": nil},
+			sq.NotEq{"sts.drop_off_booking_rule_id": nil},
+		}).
+		OrderBy("gtfs_trips.id", "sts.stop_sequence")
+	if err := dbutil.Select(ctx, f.db, q, &ents); err != nil {
+		return nil, logExtendErr(ctx, len(keys), err)
+	}
+	return arrangeGroup(keys, ents, func(ent *model.FlexService) int { return ent.TripID }), nil
+}
+
+func (f *Finder) LocationsByIDs(ctx context.Context, ids []int) ([]*model.Location, []error) {
+	var ents []*model.Location
+	q := sq.StatementBuilder.
+		Select(
+			"gtfs_locations.id",
+			"gtfs_locations.feed_version_id",
+			"gtfs_locations.location_id",
+			"gtfs_locations.stop_name",
+			"gtfs_locations.stop_desc",
+			"gtfs_locations.zone_id",
+			"gtfs_locations.stop_url",
+			"gtfs_locations.geometry",
+		).
+		From("gtfs_locations").
+		Where(In("gtfs_locations.id", ids))
+	if err := dbutil.Select(ctx, f.db, q, &ents); err != nil {
+		return nil, logExtendErr(ctx, len(ids), err)
+	}
+	return arrangeBy(ids, ents, func(ent *model.Location) int { return ent.ID }), nil
+}
+
+func (f *Finder) LocationGroupsByIDs(ctx context.Context, ids []int) ([]*model.LocationGroup, []error) {
+	var ents []*model.LocationGroup
+	q := sq.StatementBuilder.
+		Select(
+			"gtfs_location_groups.id",
+			"gtfs_location_groups.feed_version_id",
+			"gtfs_location_groups.location_group_id",
+			"gtfs_location_groups.location_group_name",
+		).
+		From("gtfs_location_groups").
+		Where(In("gtfs_location_groups.id", ids))
+	if err := dbutil.Select(ctx, f.db, q, &ents); err != nil {
+		return nil, logExtendErr(ctx, len(ids), err)
+	}
+	return arrangeBy(ids, ents, func(ent *model.LocationGroup) int { return ent.ID }), nil
+}
+
+func (f *Finder) BookingRulesByIDs(ctx context.Context, ids []int) ([]*model.BookingRule, []error) {
+	var ents []*model.BookingRule
+	q := sq.StatementBuilder.
+		Select(
+			"gtfs_booking_rules.id",
+			"gtfs_booking_rules.feed_version_id",
+			"gtfs_booking_rules.booking_rule_id",
+			"gtfs_booking_rules.booking_type",
+			"gtfs_booking_rules.prior_notice_duration_min",
+			"gtfs_booking_rules.prior_notice_duration_max",
+			"gtfs_booking_rules.prior_notice_last_day",
+			"gtfs_booking_rules.prior_notice_last_time",
+			"gtfs_booking_rules.prior_notice_start_day",
+			"gtfs_booking_rules.prior_notice_start_time",
+			"gtfs_booking_rules.message",
+			"gtfs_booking_rules.pickup_message",
+			"gtfs_booking_rules.drop_off_message",
+			"gtfs_booking_rules.phone_number",
+			"gtfs_booking_rules.info_url",
+			"gtfs_booking_rules.booking_url",
+		).
+		From("gtfs_booking_rules").
+		Where(In("gtfs_booking_rules.id", ids))
+	if err := dbutil.Select(ctx, f.db, q, &ents); err != nil {
+		return nil, logExtendErr(ctx, len(ids), err)
+	}
+	return arrangeBy(ids, ents, func(ent *model.BookingRule) int { return ent.ID }), nil
+}
+
+func (f *Finder) StopsByLocationGroupIDs(ctx context.Context, limit *int, where *model.StopFilter, keys []int) ([][]*model.Stop, error) {
+	var ents []*model.Stop
+	qso := stopSelect(limit, nil, nil, false, f.PermFilter(ctx), where)
+	qso = qso.
+		Join("gtfs_location_group_stops on gtfs_location_group_stops.stop_id = gtfs_stops.id").
+		Where(In("gtfs_location_group_stops.location_group_id", keys)).
+		Column("gtfs_location_group_stops.location_group_id as with_location_group_id")
+	err := dbutil.Select(ctx,
+		f.db,
+		qso,
+		&ents,
+	)
+	return arrangeGroup(keys, ents, func(ent *model.Stop) int { return ent.WithLocationGroupID.Int() }), err
+}
@@ -0,0 +1,97 @@
+package dbfinder
+
+import (
+	"context"
+	"time"
+
+	"github.com/interline-io/transitland-server/server/dbutil"
+	"github.com/interline-io/transitland-server/server/model"
+	sq "github.com/irees/squirrel"
+)
+
+type gbfsStationAvailability struct {
+	StationID string
+	model.GbfsStationAvailability
+}
+
+func (f *Finder) GbfsStationAvailabilityByStationIDs(ctx context.Context, limit *int, where *model.GbfsStationAvailabilityFilter, keys []string) ([][]*model.GbfsStationAvailability, error) {
+	if where == nil || where.Interval <= 0 {
+		return make([][]*model.GbfsStationAvailability, len(keys)), nil
+	}
+	var ents []*gbfsStationAvailability
+	q := gbfsStationSnapshotSelect(where.FeedOnestopID, where.SystemID, keys, f.PermFilter(ctx)).
+		Column(sq.Expr("to_timestamp(floor(extract(epoch from ext_gbfs_station_snapshots.captured_at) / ?) * ?) as start", where.Interval, where.Interval)).
+		Columns(
+			"min(ext_gbfs_station_snapshots.num_bikes_available) as min_bikes_available",
+			"max(ext_gbfs_station_snapshots.num_bikes_available) as max_bikes_available",
+			"min(ext_gbfs_station_snapshots.num_docks_available) as min_docks_available",
+			"max(ext_gbfs_station_snapshots.num_docks_available) as max_docks_available",
+		).
+		Where("ext_gbfs_station_snapshots.captured_at >= ?", where.Start).
+		Where("ext_gbfs_station_snapshots.captured_at < ?", where.End).
+		GroupBy("ext_gbfs_station_snapshots.station_id", "start").
+		OrderBy("ext_gbfs_station_snapshots.station_id", "start")
+	if err := dbutil.Select(ctx, f.db, q, &ents); err != nil {
+		return nil, logErr(ctx, err)
+	}
+	ret := make([][]*model.GbfsStationAvailability, len(keys))
+	for i, group := range arrangeGroup(keys, ents, func(ent *gbfsStationAvailability) string { return ent.StationID }) {
+		for _, ent := range group {
+			ent.Start = ent.Start.In(where.Start.Location())
+			ent.End = ent.Start.Add(time.Duration(where.Interval) * time.Second)
+			ret[i] = append(ret[i], &ent.GbfsStationAvailability)
+		}
+	}
+	return ret, nil
+}
+
+type gbfsStationOccupancy struct {
+	StationID string
+	model.GbfsStationOccupancy
+}
+
+func (f *Finder) GbfsStationOccupancyByStationIDs(ctx context.Context, limit *int, where *model.GbfsStationOccupancyFilter, keys []string) ([][]*model.GbfsStationOccupancy, error) {
+	if where == nil {
+		return make([][]*model.GbfsStationOccupancy, len(keys)), nil
+	}
+	var ents []*gbfsStationOccupancy
+	q := gbfsStationSnapshotSelect(where.FeedOnestopID, where.SystemID, keys, f.PermFilter(ctx)).
+		Column(sq.Expr("extract(dow from ext_gbfs_station_snapshots.captured_at at time zone ?)::int as dow", where.Timezone)).
+		Column(sq.Expr("extract(hour from ext_gbfs_station_snapshots.captured_at at time zone ?)::int as hour", where.Timezone)).
+		GroupBy("ext_gbfs_station_snapshots.station_id", "dow", "hour").
+		OrderBy("ext_gbfs_station_snapshots.station_id", "dow", "hour")
+	if where.Dow != nil {
+		q = q.Where("extract(dow from ext_gbfs_station_snapshots.captured_at at time zone ?) = ?", where.Timezone, *where.Dow)
+	}
+	if err := dbutil.Select(ctx, f.db, q, &ents); err != nil {
+		return nil, logErr(ctx, err)
+	}
+	ret := make([][]*model.GbfsStationOccupancy, len(keys))
+	for i, group := range arrangeGroup(keys, ents, func(ent *gbfsStationOccupancy) string { return ent.StationID }) {
+		for _, ent := range group {
+			ret[i] = append(ret[i], &ent.GbfsStationOccupancy)
+		}
+	}
+	return ret, nil
+}
+
+// gbfsStationSnapshotSelect returns the common aggregate columns for availability snapshots of stations in a feed and system
+func gbfsStationSnapshotSelect(feedOnestopId string, systemId string, stationIds []string, permFilter *model.PermFilter) sq.SelectBuilder {
+	q := sq.StatementBuilder.
+		Select(
+			"ext_gbfs_station_snapshots.station_id",
+			"count(*) as snapshots",
+			"avg(ext_gbfs_station_snapshots.num_bikes_available)::double precision as avg_bikes_available",
+			"avg(ext_gbfs_station_snapshots.num_docks_available)::double precision as avg_docks_available",
+			"(count(*) filter (where ext_gbfs_station_snapshots.num_bikes_available = 0))::double precision / count(*) as empty_ratio",
+			"(count(*) filter (where ext_gbfs_station_snapshots.num_docks_available = 0))::double precision / count(*) as full_ratio",
+		).
+		From("ext_gbfs_station_snapshots").
+		Join("current_feeds on current_feeds.id = ext_gbfs_station_snapshots.feed_id").
+		Where(sq.Eq{
+			"current_feeds.onestop_id":             feedOnestopId,
+			"ext_gbfs_station_snapshots.system_id": systemId,
+		}).
+		Where(In("ext_gbfs_station_snapshots.station_id", stationIds))
+	return pfJoinCheck(q, permFilter)
+}
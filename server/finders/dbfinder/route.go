@@ -30,6 +30,45 @@ func (f *Finder) RouteStopBuffer(ctx context.Context, limit *int, radius *float6
 	return ents, nil
 }
 
+func (f *Finder) RouteOnTimePerformance(ctx context.Context, routeId int, dateRange model.DateRange, threshold int) (*model.RouteOnTimePerformance, error) {
+	q := sq.StatementBuilder.
+		Select(
+			"count(*) as observations",
+			"count(*) filter (where obs.delay < -obs.threshold) as early",
+			"count(*) filter (where obs.delay between -obs.threshold and obs.threshold) as on_time",
+			"count(*) filter (where obs.delay > obs.threshold) as late",
+			"avg(obs.delay)::double precision as average_delay",
+		).
+		FromSelect(
+			sq.StatementBuilder.
+				Select(
+					"ext_performance_stop_observations.observed_arrival_time - ext_performance_stop_observations.scheduled_arrival_time as delay",
+				).
+				Column(sq.Expr("?::int as threshold", threshold)).
+				From("ext_performance_stop_observations").
+				Join("gtfs_routes on gtfs_routes.feed_version_id = ext_performance_stop_observations.feed_version_id and gtfs_routes.route_id = ext_performance_stop_observations.route_id").
+				Where(sq.Eq{"gtfs_routes.id": routeId}).
+				Where("ext_performance_stop_observations.trip_start_date >= ?", dateRange.StartDate).
+				Where("ext_performance_stop_observations.trip_start_date <= ?", dateRange.EndDate).
+				Where("ext_performance_stop_observations.observed_arrival_time is not null").
+				Where("ext_performance_stop_observations.scheduled_arrival_time is not null"),
+			"obs",
+		)
+	ent := model.RouteOnTimePerformance{
+		StartDate: dateRange.StartDate,
+		EndDate:   dateRange.EndDate,
+		Threshold: threshold,
+	}
+	if err := dbutil.Get(ctx, f.db, q, &ent); err != nil {
+		return nil, logErr(ctx, err)
+	}
+	if ent.Observations > 0 {
+		r := float64(ent.OnTime) / float64(ent.Observations)
+		ent.OnTimeRatio = &r
+	}
+	return &ent, nil
+}
+
 func (f *Finder) RouteAttributesByRouteIDs(ctx context.Context, ids []int) ([]*model.RouteAttribute, []error) {
 	var ents []*model.RouteAttribute
 	q := sq.StatementBuilder.Select("*").From("ext_plus_route_attributes").Where(In("route_id", ids))
@@ -82,14 +82,23 @@ func (f *Finder) StopTimesByStopIDs(ctx context.Context, limit *int, where *mode
 }
 
 func stopTimeSelect(tpairs []model.FVPair, spairs []model.FVPair, where *model.TripStopTimeFilter) sq.SelectBuilder {
+	// Frequency-based trips are expanded into trip runs when requested
+	expandFreqs := where != nil && (where.StartTime != nil || nilOr(where.ExpandFrequencies, false))
+	arrivalTime := "sts.arrival_time + gtfs_trips.journey_pattern_offset"
+	departureTime := "sts.departure_time + gtfs_trips.journey_pattern_offset"
+	if expandFreqs {
+		freqOffset := " + coalesce(freq.freq_start - trip_first_departure.first_departure_time, 0)"
+		arrivalTime += freqOffset
+		departureTime += freqOffset
+	}
 	q := sq.StatementBuilder.Select(
 		"gtfs_trips.journey_pattern_id",
 		"gtfs_trips.journey_pattern_offset",
 		"gtfs_trips.id AS trip_id",
 		"gtfs_trips.feed_version_id",
 		"sts.stop_id",
-		"sts.arrival_time + gtfs_trips.journey_pattern_offset AS arrival_time",
-		"sts.departure_time + gtfs_trips.journey_pattern_offset AS departure_time",
+		arrivalTime+" AS arrival_time",
+		departureTime+" AS departure_time",
 		"sts.stop_sequence",
 		"sts.shape_dist_traveled",
 		"sts.pickup_type",
@@ -104,15 +113,37 @@ func stopTimeSelect(tpairs []model.FVPair, spairs []model.FVPair, where *model.T
 		Join("feed_versions on feed_versions.id = gtfs_trips.feed_version_id").
 		Join("current_feeds on current_feeds.id = feed_versions.feed_id").
 		Join("gtfs_trips t2 ON t2.trip_id::text = gtfs_trips.journey_pattern_id AND gtfs_trips.feed_version_id = t2.feed_version_id").
-		Join("gtfs_stop_times sts ON sts.trip_id = t2.id AND sts.feed_version_id = t2.feed_version_id").
-		OrderBy("sts.stop_sequence, sts.arrival_time")
+		Join("gtfs_stop_times sts ON sts.trip_id = t2.id AND sts.feed_version_id = t2.feed_version_id")
+
+	if expandFreqs {
+		q = q.
+			Column("freq.freq_start AS start_time").
+			JoinClause(`left join lateral (
+			select
+				generate_series(start_time, end_time, headway_secs) freq_start
+			from gtfs_frequencies
+			where gtfs_frequencies.trip_id = gtfs_trips.id
+			) freq on true`).
+			JoinClause(`join lateral (
+			select 
+				min(sts2.departure_time) first_departure_time
+			from gtfs_stop_times sts2 
+			where sts2.trip_id = t2.id and sts2.feed_version_id = t2.feed_version_id
+			) trip_first_departure on true`).
+			OrderBy("freq.freq_start, sts.stop_sequence, sts.arrival_time")
+		if where.StartTime != nil {
+			q = q.Where(sq.Eq{"freq.freq_start": where.StartTime.Int()})
+		}
+	} else {
+		q = q.OrderBy("sts.stop_sequence, sts.arrival_time")
+	}
 
 	if where != nil {
 		if where.Start != nil {
-			q = q.Where(sq.GtOrEq{"sts.departure_time + gtfs_trips.journey_pattern_offset": where.Start.Int()})
+			q = q.Where(sq.GtOrEq{departureTime: where.Start.Int()})
 		}
 		if where.End != nil {
-			q = q.Where(sq.LtOrEq{"sts.arrival_time + gtfs_trips.journey_pattern_offset": where.End.Int()})
+			q = q.Where(sq.LtOrEq{arrivalTime: where.End.Int()})
 		}
 	}
 	if len(tpairs) > 0 {
@@ -184,6 +215,7 @@ func stopDeparturesSelect(fvid int, sids []int, where *model.StopTimeFilter) sq.
 		"sts.stop_id",
 		"sts.arrival_time_freq AS arrival_time",
 		"sts.departure_time_freq AS departure_time",
+		"freq.freq_start AS start_time",
 		"sts.stop_sequence",
 		"sts.shape_dist_traveled",
 		"sts.pickup_type",
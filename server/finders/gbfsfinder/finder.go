@@ -3,6 +3,7 @@ package gbfsfinder
 import (
 	"context"
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,6 +18,8 @@ import (
 )
 
 type Finder struct {
+	// OnUpdate is called with the topic after data is received
+	OnUpdate         func(ctx context.Context, topic string)
 	client           *redis.Client
 	cache            *ecache.Cache[gbfs.GbfsFeed]
 	ttlRecheck       time.Duration
@@ -24,6 +27,7 @@ type Finder struct {
 	prefix           string
 	bikeSearchKey    string
 	stationSearchKey string
+	zoneSearchKey    string
 }
 
 func NewFinder(client *redis.Client) *Finder {
@@ -36,6 +40,7 @@ func NewFinder(client *redis.Client) *Finder {
 		prefix:           "gbfs",
 		bikeSearchKey:    fmt.Sprintf("%s:bike-bbox", "gbfs"),
 		stationSearchKey: fmt.Sprintf("%s:station-bbox", "gbfs"),
+		zoneSearchKey:    fmt.Sprintf("%s:zone-bbox", "gbfs"),
 	}
 }
 
@@ -50,6 +55,9 @@ func (c *Finder) AddData(ctx context.Context, topic string, sf gbfs.GbfsFeed) er
 	if c.client != nil {
 		bbox := geom.NewBounds(geom.XY)
 		for _, ent := range sf.Bikes {
+			if !ent.Lon.Valid || !ent.Lat.Valid {
+				continue
+			}
 			bbox.Extend(geom.NewPoint(geom.XY).MustSetCoords(geom.Coord{ent.Lon.Val, ent.Lat.Val}))
 		}
 		bc := fmt.Sprintf("%0.5f,%0.5f,%0.5f,%0.5f", bbox.Min(0), bbox.Min(1), bbox.Max(0), bbox.Max(1))
@@ -68,38 +76,101 @@ func (c *Finder) AddData(ctx context.Context, topic string, sf gbfs.GbfsFeed) er
 			return err
 		}
 	}
+	// Geosearch index geofencing zones; global rules apply everywhere
+	if c.client != nil {
+		bbox := geom.NewBounds(geom.XY)
+		for _, zone := range sf.GeofencingZones {
+			if zone == nil {
+				continue
+			}
+			for _, f := range zone.Features {
+				if f != nil && f.Geometry.Valid {
+					bbox.Extend(f.Geometry.Val)
+				}
+			}
+		}
+		if len(sf.GeofencingRules) > 0 {
+			bbox.Set(-180, -90, 180, 90)
+		}
+		bc := fmt.Sprintf("%0.5f,%0.5f,%0.5f,%0.5f", bbox.Min(0), bbox.Min(1), bbox.Max(0), bbox.Max(1))
+		if err := c.client.HSet(ctx, c.zoneSearchKey, topic, bc).Err(); err != nil {
+			return err
+		}
+	}
+	if c.OnUpdate != nil {
+		c.OnUpdate(ctx, topic)
+	}
 	return nil
 }
 
+func (c *Finder) GetData(ctx context.Context, topic string) (gbfs.GbfsFeed, bool) {
+	return c.cache.Get(ctx, topic)
+}
+
 func (c *Finder) FindBikes(ctx context.Context, limit *int, where *model.GbfsBikeRequest) ([]*model.GbfsFreeBikeStatus, error) {
-	if where == nil || where.Near == nil {
+	if where == nil {
 		return nil, nil
 	}
-	where.Near.Radius = checkFloat(&where.Near.Radius, 0, 1_000_000)
-	pt := *where.Near
-	ptxy := tlxy.Point{Lon: pt.Lon, Lat: pt.Lat}
-	topicKeys, err := c.geosearch(ctx, c.bikeSearchKey, pt)
+	area, ok := newSearchArea(where.Near, where.Bbox)
+	if !ok {
+		return nil, nil
+	}
+	topicKeys, err := c.geosearch(ctx, c.bikeSearchKey, area.bounds())
 	if err != nil {
 		return nil, err
 	}
 	var ret []*model.GbfsFreeBikeStatus
+	dists := map[*model.GbfsFreeBikeStatus]float64{}
 	for _, topicKey := range topicKeys {
+		if !checkFeed(topicKey, where.FeedOnestopID) {
+			continue
+		}
 		sf, ok := c.cache.Get(ctx, topicKey)
-		if !ok {
+		if !ok || !checkSystem(sf, where.SystemID) {
 			continue
 		}
+		vehicleTypes := map[string]*gbfs.VehicleType{}
+		for _, vt := range sf.VehicleTypes {
+			if vt != nil {
+				vehicleTypes[vt.VehicleTypeID.Val] = vt
+			}
+		}
 		for _, ent := range sf.Bikes {
-			if d := tlxy.DistanceHaversine(ptxy, tlxy.Point{Lon: ent.Lon.Val, Lat: ent.Lat.Val}); d > pt.Radius {
+			// Vehicles at a station may not have a location
+			if !ent.Lon.Valid || !ent.Lat.Valid {
 				continue
 			}
+			d, ok := area.distance(ent.Lon.Val, ent.Lat.Val)
+			if !ok {
+				continue
+			}
+			if where.MinCurrentRangeMeters != nil && ent.CurrentRangeMeters.Val < *where.MinCurrentRangeMeters {
+				continue
+			}
+			if where.FormFactor != nil || where.PropulsionType != nil {
+				vt := vehicleTypes[ent.VehicleTypeID.Val]
+				if vt == nil {
+					continue
+				}
+				if where.FormFactor != nil && vt.FormFactor.Val != *where.FormFactor {
+					continue
+				}
+				if where.PropulsionType != nil && vt.PropulsionType.Val != *where.PropulsionType {
+					continue
+				}
+			}
 			b := model.GbfsFreeBikeStatus{
 				FreeBikeStatus: ent,
-				Feed:           &model.GbfsFeed{GbfsFeed: &sf},
+				Feed:           &model.GbfsFeed{FeedOnestopID: feedOnestopIDFromTopic(topicKey), GbfsFeed: &sf},
 			}
+			dists[&b] = d
 			ret = append(ret, &b)
 		}
 	}
 	sort.Slice(ret, func(i, j int) bool {
+		if di, dj := dists[ret[i]], dists[ret[j]]; di != dj {
+			return di < dj
+		}
 		return ret[i].BikeID.Val < ret[j].BikeID.Val
 	})
 	if limit != nil && len(ret) > *limit {
@@ -109,34 +180,62 @@ func (c *Finder) FindBikes(ctx context.Context, limit *int, where *model.GbfsBik
 }
 
 func (c *Finder) FindDocks(ctx context.Context, limit *int, where *model.GbfsDockRequest) ([]*model.GbfsStationInformation, error) {
-	if where == nil || where.Near == nil {
+	if where == nil {
+		return nil, nil
+	}
+	area, ok := newSearchArea(where.Near, where.Bbox)
+	if !ok {
 		return nil, nil
 	}
-	where.Near.Radius = checkFloat(&where.Near.Radius, 0, 1_000_000)
-	pt := *where.Near
-	ptxy := tlxy.Point{Lon: pt.Lon, Lat: pt.Lat}
-	topicKeys, err := c.geosearch(ctx, c.stationSearchKey, pt)
+	topicKeys, err := c.geosearch(ctx, c.stationSearchKey, area.bounds())
 	if err != nil {
 		return nil, err
 	}
 	var ret []*model.GbfsStationInformation
+	dists := map[*model.GbfsStationInformation]float64{}
 	for _, topicKey := range topicKeys {
+		if !checkFeed(topicKey, where.FeedOnestopID) {
+			continue
+		}
 		sf, ok := c.cache.Get(ctx, topicKey)
-		if !ok {
+		if !ok || !checkSystem(sf, where.SystemID) {
 			continue
 		}
+		statuses := map[string]*gbfs.StationStatus{}
+		for _, ss := range sf.StationStatus {
+			if ss != nil {
+				statuses[ss.StationID.Val] = ss
+			}
+		}
 		for _, ent := range sf.StationInformation {
-			if d := tlxy.DistanceHaversine(ptxy, tlxy.Point{Lon: ent.Lon.Val, Lat: ent.Lat.Val}); d > pt.Radius {
+			d, ok := area.distance(ent.Lon.Val, ent.Lat.Val)
+			if !ok {
 				continue
 			}
+			if where.MinBikesAvailable != nil || where.MinDocksAvailable != nil {
+				ss := statuses[ent.StationID.Val]
+				if ss == nil {
+					continue
+				}
+				if where.MinBikesAvailable != nil && ss.NumBikesAvailable.Val < int64(*where.MinBikesAvailable) {
+					continue
+				}
+				if where.MinDocksAvailable != nil && ss.NumDocksAvailable.Val < int64(*where.MinDocksAvailable) {
+					continue
+				}
+			}
 			b := model.GbfsStationInformation{
 				StationInformation: ent,
-				Feed:               &model.GbfsFeed{GbfsFeed: &sf},
+				Feed:               &model.GbfsFeed{FeedOnestopID: feedOnestopIDFromTopic(topicKey), GbfsFeed: &sf},
 			}
+			dists[&b] = d
 			ret = append(ret, &b)
 		}
 	}
 	sort.Slice(ret, func(i, j int) bool {
+		if di, dj := dists[ret[i]], dists[ret[j]]; di != dj {
+			return di < dj
+		}
 		return ret[i].StationID.Val < ret[j].StationID.Val
 	})
 	if limit != nil && len(ret) > *limit {
@@ -145,7 +244,46 @@ func (c *Finder) FindDocks(ctx context.Context, limit *int, where *model.GbfsDoc
 	return ret, nil
 }
 
-func (c *Finder) geosearch(ctx context.Context, key string, pt model.PointRadius) ([]string, error) {
+// FindGeofenceChecks evaluates geofencing rules along a path for each system with geofencing zones or global rules
+func (c *Finder) FindGeofenceChecks(ctx context.Context, where *model.GbfsGeofenceCheckRequest) ([]*model.GbfsGeofenceCheck, error) {
+	if where == nil || len(where.Path) == 0 {
+		return nil, nil
+	}
+	bounds := geom.NewBounds(geom.XY)
+	for _, pt := range where.Path {
+		bounds.Extend(geom.NewPoint(geom.XY).MustSetCoords(geom.Coord{pt.Lon, pt.Lat}))
+	}
+	topicKeys, err := c.geosearch(ctx, c.zoneSearchKey, bounds)
+	if err != nil {
+		return nil, err
+	}
+	vehicleTypeID := ""
+	if where.VehicleTypeID != nil {
+		vehicleTypeID = *where.VehicleTypeID
+	}
+	var ret []*model.GbfsGeofenceCheck
+	for _, topicKey := range topicKeys {
+		sf, ok := c.cache.Get(ctx, topicKey)
+		if !ok || !checkSystem(sf, where.SystemID) {
+			continue
+		}
+		if len(sf.GeofencingZones) == 0 && len(sf.GeofencingRules) == 0 {
+			continue
+		}
+		ret = append(ret, &model.GbfsGeofenceCheck{
+			Feed:          &model.GbfsFeed{FeedOnestopID: feedOnestopIDFromTopic(topicKey), GbfsFeed: &sf},
+			VehicleTypeID: vehicleTypeID,
+			GeofenceCheck: gbfs.CheckGeofence(sf, where.Path, vehicleTypeID, where.At),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return systemID(ret[i].Feed.GbfsFeed) < systemID(ret[j].Feed.GbfsFeed)
+	})
+	return ret, nil
+}
+
+// geosearch returns the topics with an indexed bounding box overlapping the search bounds
+func (c *Finder) geosearch(ctx context.Context, key string, bounds *geom.Bounds) ([]string, error) {
 	topicKeys := map[string]bool{}
 	if c.client != nil {
 		cmd := c.client.HGetAll(ctx, key)
@@ -164,13 +302,9 @@ func (c *Finder) geosearch(ctx context.Context, key string, pt model.PointRadius
 			}
 			bbox := geom.NewBounds(geom.XY)
 			bbox.Set(coords...)
-			if bbox.OverlapsPoint(geom.XY, geom.Coord{pt.Lon, pt.Lat}) {
-				// fmt.Println("in box", topicKey, pt.Lon, pt.Lat)
+			if bbox.Overlaps(geom.XY, bounds) {
 				topicKeys[topicKey] = true
-			} else {
-				// fmt.Println("not in box", topicKey, pt.Lon, pt.Lat)
 			}
-
 		}
 	} else {
 		// If not using redis, get local keys. This is not perfect.
@@ -185,6 +319,81 @@ func (c *Finder) geosearch(ctx context.Context, key string, pt model.PointRadius
 	return ret, nil
 }
 
+// searchArea is a radius around a point, a bounding box, or both
+type searchArea struct {
+	near   *model.PointRadius
+	bbox   *model.BoundingBox
+	center tlxy.Point
+}
+
+func newSearchArea(near *model.PointRadius, bbox *model.BoundingBox) (*searchArea, bool) {
+	if near == nil && bbox == nil {
+		return nil, false
+	}
+	area := searchArea{bbox: bbox}
+	if near != nil {
+		pt := *near
+		pt.Radius = checkFloat(&pt.Radius, 0, 1_000_000)
+		area.near = &pt
+		area.center = tlxy.Point{Lon: pt.Lon, Lat: pt.Lat}
+	} else {
+		area.center = tlxy.Point{Lon: (bbox.MinLon + bbox.MaxLon) / 2, Lat: (bbox.MinLat + bbox.MaxLat) / 2}
+	}
+	return &area, true
+}
+
+// distance returns the distance in meters from the center of the search area, and if the point is within the area
+func (a *searchArea) distance(lon float64, lat float64) (float64, bool) {
+	if a.bbox != nil && (lon < a.bbox.MinLon || lon > a.bbox.MaxLon || lat < a.bbox.MinLat || lat > a.bbox.MaxLat) {
+		return 0, false
+	}
+	d := tlxy.DistanceHaversine(a.center, tlxy.Point{Lon: lon, Lat: lat})
+	if a.near != nil && d > a.near.Radius {
+		return 0, false
+	}
+	return d, true
+}
+
+// bounds returns an approximate bounding box of the search area
+func (a *searchArea) bounds() *geom.Bounds {
+	if a.bbox != nil {
+		return geom.NewBounds(geom.XY).Set(a.bbox.MinLon, a.bbox.MinLat, a.bbox.MaxLon, a.bbox.MaxLat)
+	}
+	dlat := a.near.Radius / metersPerDegree
+	dlon := dlat / math.Max(math.Cos(a.near.Lat*math.Pi/180), 0.01)
+	return geom.NewBounds(geom.XY).Set(a.near.Lon-dlon, a.near.Lat-dlat, a.near.Lon+dlon, a.near.Lat+dlat)
+}
+
+const metersPerDegree = 111_320.0
+
+// checkFeed checks the feed onestop_id of a topic, which is stored as "<feed>:<language>"
+func checkFeed(topicKey string, feedOnestopID *string) bool {
+	if feedOnestopID == nil {
+		return true
+	}
+	return feedOnestopIDFromTopic(topicKey) == *feedOnestopID
+}
+
+// feedOnestopIDFromTopic returns the feed onestop_id from a "feed:language" topic key
+func feedOnestopIDFromTopic(topicKey string) string {
+	feedID, _, _ := strings.Cut(topicKey, ":")
+	return feedID
+}
+
+func systemID(sf *gbfs.GbfsFeed) string {
+	if sf.SystemInformation == nil {
+		return ""
+	}
+	return sf.SystemInformation.SystemID.Val
+}
+
+func checkSystem(sf gbfs.GbfsFeed, systemID *string) bool {
+	if systemID == nil {
+		return true
+	}
+	return sf.SystemInformation != nil && sf.SystemInformation.SystemID.Val == *systemID
+}
+
 func checkFloat(v *float64, min float64, max float64) float64 {
 	if v == nil || *v < min {
 		return min
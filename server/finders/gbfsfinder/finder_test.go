@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/interline-io/transitland-lib/tlxy"
 	"github.com/interline-io/transitland-server/internal/gbfs"
@@ -76,3 +77,147 @@ func testSetupGbfs(gbf model.GbfsFinder) error {
 	}
 	return nil
 }
+
+func TestGbfsFinder_Filters(t *testing.T) {
+	gbf := testSetupGbfsV3(t)
+	str := func(v string) *string { return &v }
+	num := func(v int) *int { return &v }
+	flt := func(v float64) *float64 { return &v }
+	near := &model.PointRadius{Lon: -122.4049, Lat: 37.7853, Radius: 5000}
+	bbox := &model.BoundingBox{MinLon: -122.42, MinLat: 37.77, MaxLon: -122.40, MaxLat: 37.79}
+
+	bikeTcs := []struct {
+		name   string
+		where  model.GbfsBikeRequest
+		expect []string
+	}{
+		{"near", model.GbfsBikeRequest{Near: near}, []string{"v1"}},
+		{"bbox", model.GbfsBikeRequest{Bbox: bbox}, []string{"v1"}},
+		{"bbox outside", model.GbfsBikeRequest{Bbox: &model.BoundingBox{MinLon: 0, MinLat: 0, MaxLon: 1, MaxLat: 1}}, nil},
+		{"system_id", model.GbfsBikeRequest{Near: near, SystemID: str("example_v3")}, []string{"v1"}},
+		{"system_id other", model.GbfsBikeRequest{Near: near, SystemID: str("other")}, nil},
+		{"feed_onestop_id", model.GbfsBikeRequest{Near: near, FeedOnestopID: str("gbfs-v3")}, []string{"v1"}},
+		{"feed_onestop_id other", model.GbfsBikeRequest{Near: near, FeedOnestopID: str("other")}, nil},
+		{"form_factor", model.GbfsBikeRequest{Near: near, FormFactor: str("bicycle")}, []string{"v1"}},
+		{"form_factor scooter", model.GbfsBikeRequest{Near: near, FormFactor: str("scooter")}, nil},
+		{"propulsion_type", model.GbfsBikeRequest{Near: near, PropulsionType: str("electric_assist")}, []string{"v1"}},
+		{"propulsion_type human", model.GbfsBikeRequest{Near: near, PropulsionType: str("human")}, nil},
+		{"min_current_range_meters", model.GbfsBikeRequest{Near: near, MinCurrentRangeMeters: flt(1000)}, []string{"v1"}},
+		{"min_current_range_meters too high", model.GbfsBikeRequest{Near: near, MinCurrentRangeMeters: flt(20000)}, nil},
+	}
+	for _, tc := range bikeTcs {
+		t.Run("FindBikes "+tc.name, func(t *testing.T) {
+			bikes, err := gbf.FindBikes(context.Background(), nil, &tc.where)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, b := range bikes {
+				got = append(got, b.BikeID.Val)
+			}
+			assert.Equal(t, tc.expect, got)
+		})
+	}
+
+	dockTcs := []struct {
+		name   string
+		where  model.GbfsDockRequest
+		expect []string
+	}{
+		{"near", model.GbfsDockRequest{Near: near}, []string{"s1", "s2"}},
+		{"near sorted by distance", model.GbfsDockRequest{Near: &model.PointRadius{Lon: -122.4196, Lat: 37.7650, Radius: 5000}}, []string{"s2", "s1"}},
+		{"bbox", model.GbfsDockRequest{Bbox: &model.BoundingBox{MinLon: -122.41, MinLat: 37.78, MaxLon: -122.40, MaxLat: 37.79}}, []string{"s1"}},
+		{"min_bikes_available", model.GbfsDockRequest{Near: near, MinBikesAvailable: num(1)}, []string{"s1"}},
+		{"min_docks_available", model.GbfsDockRequest{Near: near, MinDocksAvailable: num(13)}, []string{"s2"}},
+		{"system_id", model.GbfsDockRequest{Near: near, SystemID: str("other")}, nil},
+	}
+	for _, tc := range dockTcs {
+		t.Run("FindDocks "+tc.name, func(t *testing.T) {
+			docks, err := gbf.FindDocks(context.Background(), nil, &tc.where)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range docks {
+				got = append(got, d.StationID.Val)
+			}
+			assert.Equal(t, tc.expect, got)
+		})
+	}
+}
+
+func TestGbfsFinder_GeofenceChecks(t *testing.T) {
+	gbf := testSetupGbfsV3(t)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	str := func(v string) *string { return &v }
+	inZone := tlxy.Point{Lon: -122.405, Lat: 37.785}
+	outside := tlxy.Point{Lon: -122.42, Lat: 37.785}
+	t.Run("point in zone", func(t *testing.T) {
+		checks, err := gbf.FindGeofenceChecks(context.Background(), &model.GbfsGeofenceCheckRequest{Path: []tlxy.Point{inZone}, VehicleTypeID: str("ebike"), At: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Len(t, checks, 1) {
+			return
+		}
+		c := checks[0]
+		assert.Equal(t, "example_v3", c.SystemInformation().SystemID.Val)
+		assert.Equal(t, "ebike", c.VehicleType().VehicleTypeID.Val)
+		assert.False(t, c.RideStartAllowed)
+		assert.False(t, c.RideEndAllowed)
+		assert.True(t, c.RideThroughAllowed)
+		assert.Equal(t, int64(10), c.MaximumSpeedKph.Val)
+		assert.Equal(t, []string{"Union Square"}, c.Zones)
+	})
+	t.Run("line through zone", func(t *testing.T) {
+		checks, err := gbf.FindGeofenceChecks(context.Background(), &model.GbfsGeofenceCheckRequest{Path: []tlxy.Point{outside, inZone, {Lon: -122.395, Lat: 37.785}}, At: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Len(t, checks, 1) {
+			return
+		}
+		c := checks[0]
+		assert.True(t, c.RideStartAllowed)
+		assert.True(t, c.RideEndAllowed)
+		assert.True(t, c.RideThroughAllowed)
+		assert.Equal(t, int64(10), c.MaximumSpeedKph.Val)
+		assert.Equal(t, []string{"Union Square"}, c.Zones)
+	})
+	t.Run("global rules", func(t *testing.T) {
+		checks, err := gbf.FindGeofenceChecks(context.Background(), &model.GbfsGeofenceCheckRequest{Path: []tlxy.Point{outside}, At: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, checks, 1) {
+			assert.True(t, checks[0].RideStartAllowed)
+			assert.False(t, checks[0].MaximumSpeedKph.Valid)
+			assert.Len(t, checks[0].Zones, 0)
+		}
+	})
+	t.Run("system_id", func(t *testing.T) {
+		checks, err := gbf.FindGeofenceChecks(context.Background(), &model.GbfsGeofenceCheckRequest{Path: []tlxy.Point{inZone}, SystemID: str("other"), At: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, checks, 0)
+	})
+}
+
+func testSetupGbfsV3(t testing.TB) *Finder {
+	ts := httptest.NewServer(&gbfs.TestGbfsServer{Version: "3.0", Path: testdata.Path("server", "gbfs-v3")})
+	defer ts.Close()
+	opts := gbfs.Options{}
+	opts.FeedURL = fmt.Sprintf("%s/%s", ts.URL, "gbfs.json")
+	feeds, _, err := gbfs.Fetch(context.Background(), nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbf := NewFinder(nil)
+	for _, feed := range feeds {
+		if err := gbf.AddData(context.Background(), "gbfs-v3:en", feed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gbf
+}
@@ -0,0 +1,148 @@
+package rtfinder
+
+import (
+	"strconv"
+
+	"github.com/interline-io/transitland-lib/rt/pb"
+)
+
+// alertMatcher matches GTFS-RT alert informed entities against a GTFS entity.
+//
+// Following the GTFS-RT specification, every field set on an EntitySelector must apply.
+// Each field of the matcher is either a value that must be equal, a wildcard that
+// accepts any value, or unset, in which case selectors that set that field do not match.
+// Additionally, a selector must refer to the entity itself, e.g. an agency-wide
+// alert is not returned as a stop alert.
+type alertMatcher struct {
+	agencyID    matchField
+	routeID     matchField
+	routeType   matchField
+	directionID matchField
+	tripID      matchField
+	stopID      matchField
+	refers      func(*pb.EntitySelector) bool
+}
+
+type matchField struct {
+	Value string
+	Valid bool
+	Any   bool
+}
+
+func matchValue(v string) matchField {
+	return matchField{Value: v, Valid: v != ""}
+}
+
+func matchAny() matchField {
+	return matchField{Any: true}
+}
+
+func (f matchField) check(v *string) bool {
+	if v == nil || f.Any {
+		return true
+	}
+	return f.Valid && f.Value == *v
+}
+
+// agencyAlertMatcher matches agency-wide alerts.
+func agencyAlertMatcher(agencyID string) *alertMatcher {
+	return &alertMatcher{
+		agencyID: matchValue(agencyID),
+		refers:   func(s *pb.EntitySelector) bool { return s.AgencyId != nil },
+	}
+}
+
+// routeAlertMatcher matches alerts that apply to a route, including alerts for a route_type or a single direction.
+func routeAlertMatcher(agencyID string, routeID string, routeType int) *alertMatcher {
+	return &alertMatcher{
+		agencyID:    matchValue(agencyID),
+		routeID:     matchValue(routeID),
+		routeType:   matchValue(strconv.Itoa(routeType)),
+		directionID: matchAny(),
+		refers: func(s *pb.EntitySelector) bool {
+			return s.RouteId != nil || s.RouteType != nil || (s.Trip != nil && s.Trip.RouteId != nil)
+		},
+	}
+}
+
+// stopAlertMatcher matches alerts that apply to a stop.
+// If routeID is empty, alerts for this stop on any route are matched.
+func stopAlertMatcher(stopID string, agencyID string, routeID string, routeType int) *alertMatcher {
+	m := &alertMatcher{
+		agencyID:    matchAny(),
+		routeID:     matchAny(),
+		routeType:   matchAny(),
+		directionID: matchAny(),
+		stopID:      matchValue(stopID),
+		refers:      func(s *pb.EntitySelector) bool { return s.StopId != nil },
+	}
+	if routeID != "" {
+		m.agencyID = matchValue(agencyID)
+		m.routeID = matchValue(routeID)
+		m.routeType = matchValue(strconv.Itoa(routeType))
+	}
+	return m
+}
+
+// tripAlertMatcher matches alerts that apply to a trip, including alerts at a stop on that trip.
+func tripAlertMatcher(tripID string, routeID string, directionID int) *alertMatcher {
+	return &alertMatcher{
+		agencyID:    matchAny(),
+		routeID:     matchValue(routeID),
+		routeType:   matchAny(),
+		directionID: matchValue(strconv.Itoa(directionID)),
+		tripID:      matchValue(tripID),
+		stopID:      matchAny(),
+		refers: func(s *pb.EntitySelector) bool {
+			return s.Trip != nil && s.Trip.TripId != nil
+		},
+	}
+}
+
+// Match returns true if any informed entity of the alert applies.
+func (m *alertMatcher) Match(alert *pb.Alert) bool {
+	if alert == nil {
+		return false
+	}
+	for _, s := range alert.GetInformedEntity() {
+		if m.MatchSelector(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSelector returns true if every field set on the selector applies.
+func (m *alertMatcher) MatchSelector(s *pb.EntitySelector) bool {
+	if s == nil || !m.refers(s) {
+		return false
+	}
+	var tripID, tripRouteID, tripDirectionID *string
+	if s.Trip != nil {
+		tripID = s.Trip.TripId
+		tripRouteID = s.Trip.RouteId
+		tripDirectionID = uintStr(s.Trip.DirectionId)
+	}
+	return m.agencyID.check(s.AgencyId) &&
+		m.routeID.check(s.RouteId) &&
+		m.routeID.check(tripRouteID) &&
+		m.routeType.check(intStr(s.RouteType)) &&
+		m.directionID.check(uintStr(s.DirectionId)) &&
+		m.directionID.check(tripDirectionID) &&
+		m.tripID.check(tripID) &&
+		m.stopID.check(s.StopId)
+}
+
+func intStr(v *int32) *string {
+	if v == nil {
+		return nil
+	}
+	return pstr(strconv.Itoa(int(*v)))
+}
+
+func uintStr(v *uint32) *string {
+	if v == nil {
+		return nil
+	}
+	return pstr(strconv.Itoa(int(*v)))
+}
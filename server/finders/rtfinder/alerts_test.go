@@ -0,0 +1,42 @@
+package rtfinder
+
+import (
+	"testing"
+
+	"github.com/interline-io/transitland-lib/rt"
+	"github.com/interline-io/transitland-server/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertMatcher(t *testing.T) {
+	msg, err := rt.ReadFile(testdata.Path("server", "rt", "BA-alerts-selectors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		name    string
+		matcher *alertMatcher
+		expect  []string
+	}{
+		{"agency", agencyAlertMatcher("BART"), nil},
+		{"route", routeAlertMatcher("BART", "03", 1), []string{"s3", "s6"}},
+		{"route other route_type", routeAlertMatcher("BART", "03", 3), []string{"s6"}},
+		{"route other agency", routeAlertMatcher("AC", "03", 1), []string{"s6"}},
+		{"stop", stopAlertMatcher("FTVL", "", "", 0), []string{"s1", "s2", "s4"}},
+		{"stop on route", stopAlertMatcher("FTVL", "BART", "03", 1), []string{"s1", "s4"}},
+		{"stop on other route", stopAlertMatcher("FTVL", "BART", "07", 1), []string{"s4"}},
+		{"trip", tripAlertMatcher("1031527WKDY", "03", 0), []string{"s5"}},
+		{"trip on other route", tripAlertMatcher("1031527WKDY", "05", 0), nil},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var found []string
+			for _, ent := range msg.Entity {
+				if tc.matcher.Match(ent.Alert) {
+					found = append(found, ent.GetId())
+				}
+			}
+			assert.ElementsMatch(t, tc.expect, found)
+		})
+	}
+}
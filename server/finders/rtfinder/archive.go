@@ -0,0 +1,201 @@
+package rtfinder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-server/internal/clock"
+	"github.com/interline-io/transitland-server/server/model"
+	"google.golang.org/protobuf/proto"
+)
+
+// ArchiveStore saves RT messages by topic and timestamp
+type ArchiveStore interface {
+	// Put saves a message for a topic at the given time
+	Put(context.Context, string, time.Time, []byte) error
+	// Get returns the most recent message for a topic at or before the given time, along with the message time
+	Get(context.Context, string, time.Time) ([]byte, time.Time, bool, error)
+}
+
+// Number of replayed Sources to keep in memory
+var archiveSourceCacheSize = 100
+
+// ArchiveCache wraps a Cache, archiving each added message.
+// When the context specifies an as-of time, sources are replayed from the archive.
+type ArchiveCache struct {
+	Cache
+	Clock   clock.Clock
+	store   ArchiveStore
+	lock    sync.Mutex
+	sources map[string]*Source
+}
+
+func NewArchiveCache(cache Cache, store ArchiveStore) *ArchiveCache {
+	return &ArchiveCache{
+		Cache:   cache,
+		Clock:   &clock.Real{},
+		store:   store,
+		sources: map[string]*Source{},
+	}
+}
+
+// AddData archives the message using the header timestamp, or the current time if not set.
+func (f *ArchiveCache) AddData(ctx context.Context, topic string, data []byte) error {
+	t := f.Clock.Now()
+	msg := pb.FeedMessage{}
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	if ts := msg.GetHeader().GetTimestamp(); ts > 0 {
+		t = time.Unix(int64(ts), 0).In(time.UTC)
+	}
+	if err := f.store.Put(ctx, topic, t, data); err != nil {
+		log.For(ctx).Error().Err(err).Str("topic", topic).Msg("archive cache: failed to archive message")
+	}
+	return f.Cache.AddData(ctx, topic, data)
+}
+
+// GetSource returns the archived source if the context has an as-of time, otherwise the current source.
+func (f *ArchiveCache) GetSource(ctx context.Context, topic string) (*Source, bool) {
+	asOf, ok := model.AsOfForContext(ctx)
+	if !ok {
+		return f.Cache.GetSource(ctx, topic)
+	}
+	data, t, ok, err := f.store.Get(ctx, topic, asOf)
+	if err != nil {
+		log.For(ctx).Error().Err(err).Str("topic", topic).Msg("archive cache: failed to get message")
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	key := fmt.Sprintf("%s:%d", topic, t.Unix())
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if s, ok := f.sources[key]; ok {
+		return s, true
+	}
+	s, _ := NewSource(topic)
+	if err := s.process(ctx, data); err != nil {
+		log.For(ctx).Error().Err(err).Str("topic", topic).Msg("archive cache: failed to process message")
+		return nil, false
+	}
+	if len(f.sources) >= archiveSourceCacheSize {
+		f.sources = map[string]*Source{}
+	}
+	f.sources[key] = s
+	return s, true
+}
+
+////////
+
+// LocalArchiveStore saves messages to a local directory, one file per message.
+// Files are named <topic>/<unix timestamp>.pb; captured messages can be copied in directly for replay
+// before the store is first used. Message times are indexed in memory for each topic when first accessed.
+type LocalArchiveStore struct {
+	// Retention is how long messages are kept before the message time of the most recent message; zero keeps all messages
+	Retention time.Duration
+	path      string
+	lock      sync.Mutex
+	index     map[string][]int64
+}
+
+func NewLocalArchiveStore(path string) (*LocalArchiveStore, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalArchiveStore{path: path, index: map[string][]int64{}}, nil
+}
+
+func (s *LocalArchiveStore) Put(ctx context.Context, topic string, t time.Time, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	dir := s.topicPath(topic)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	times, err := s.topicIndex(topic)
+	if err != nil {
+		return err
+	}
+	ts := t.Unix()
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.pb", ts)), data, 0644); err != nil {
+		return err
+	}
+	// Messages usually arrive in order
+	i := sort.Search(len(times), func(i int) bool { return times[i] >= ts })
+	if i == len(times) || times[i] != ts {
+		times = slices.Insert(times, i, ts)
+	}
+	// Remove expired messages
+	if s.Retention > 0 {
+		cutoff := times[len(times)-1] - int64(s.Retention.Seconds())
+		n := sort.Search(len(times), func(i int) bool { return times[i] >= cutoff })
+		for _, expired := range times[:n] {
+			if err := os.Remove(filepath.Join(dir, fmt.Sprintf("%d.pb", expired))); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.For(ctx).Error().Err(err).Str("topic", topic).Msg("archive store: failed to remove expired message")
+			}
+		}
+		times = times[n:]
+	}
+	s.index[topic] = times
+	return nil
+}
+
+func (s *LocalArchiveStore) Get(ctx context.Context, topic string, t time.Time) ([]byte, time.Time, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	times, err := s.topicIndex(topic)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	// Find the last message at or before t
+	i := sort.Search(len(times), func(i int) bool { return times[i] > t.Unix() })
+	if i == 0 {
+		return nil, time.Time{}, false, nil
+	}
+	found := times[i-1]
+	data, err := os.ReadFile(filepath.Join(s.topicPath(topic), fmt.Sprintf("%d.pb", found)))
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	return data, time.Unix(found, 0).In(time.UTC), true, nil
+}
+
+// topicIndex returns the sorted message times for a topic, reading the topic directory on first access.
+func (s *LocalArchiveStore) topicIndex(topic string) ([]int64, error) {
+	if times, ok := s.index[topic]; ok {
+		return times, nil
+	}
+	entries, err := os.ReadDir(s.topicPath(topic))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	times := []int64{}
+	for _, ent := range entries {
+		ts, err := strconv.ParseInt(strings.TrimSuffix(ent.Name(), ".pb"), 10, 64)
+		if err != nil || ent.IsDir() {
+			continue
+		}
+		times = append(times, ts)
+	}
+	slices.Sort(times)
+	s.index[topic] = times
+	return times, nil
+}
+
+func (s *LocalArchiveStore) topicPath(topic string) string {
+	return filepath.Join(s.path, url.PathEscape(topic))
+}
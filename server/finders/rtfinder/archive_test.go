@@ -0,0 +1,128 @@
+package rtfinder
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestArchiveCache(t *testing.T) {
+	store, err := NewLocalArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtCache := NewArchiveCache(NewLocalCache(), store)
+	testCache(t, rtCache)
+}
+
+func TestArchiveCache_Subscribe(t *testing.T) {
+	store, err := NewLocalArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtCache := NewArchiveCache(NewLocalCache(), store)
+	testCacheSubscribe(t, rtCache)
+}
+
+func TestArchiveCache_Replay(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtCache := NewArchiveCache(NewLocalCache(), store)
+	topic := "rtdata:test:realtime_trip_updates"
+	for _, ts := range []uint64{1000, 2000, 3000} {
+		v := "2.0"
+		rtdata, _ := proto.Marshal(&pb.FeedMessage{Header: &pb.FeedHeader{GtfsRealtimeVersion: &v, Timestamp: &ts}})
+		if err := rtCache.AddData(ctx, topic, rtdata); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tcs := []struct {
+		name   string
+		asOf   int64
+		expect uint64
+		ok     bool
+	}{
+		{"before first message", 999, 0, false},
+		{"at message time", 2000, 2000, true},
+		{"between messages", 2500, 2000, true},
+		{"after last message", 5000, 3000, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			s, ok := rtCache.GetSource(model.WithAsOf(ctx, time.Unix(tc.asOf, 0)), topic)
+			assert.Equal(t, tc.ok, ok)
+			if ok {
+				assert.Equal(t, tc.expect, s.GetTimestamp())
+			}
+		})
+	}
+	t.Run("without as_of", func(t *testing.T) {
+		s, ok := rtCache.GetSource(ctx, topic)
+		if assert.True(t, ok) {
+			assert.Equal(t, uint64(3000), s.GetTimestamp())
+		}
+	})
+	t.Run("unknown topic", func(t *testing.T) {
+		_, ok := rtCache.GetSource(model.WithAsOf(ctx, time.Unix(2000, 0)), "unknown")
+		assert.False(t, ok)
+	})
+}
+
+func TestLocalArchiveStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewLocalArchiveStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic := "rtdata:test:realtime_trip_updates"
+	// Out of order messages are indexed
+	for _, ts := range []int64{3000, 1000, 2000} {
+		if err := store.Put(ctx, topic, time.Unix(ts, 0), []byte(strconv.FormatInt(ts, 10))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(t *testing.T, s *LocalArchiveStore, at int64, expect string) {
+		t.Helper()
+		data, _, ok, err := s.Get(ctx, topic, time.Unix(at, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expect != "", ok)
+		assert.Equal(t, expect, string(data))
+	}
+	check(t, store, 999, "")
+	check(t, store, 1500, "1000")
+	check(t, store, 2000, "2000")
+	check(t, store, 9999, "3000")
+	t.Run("existing files", func(t *testing.T) {
+		reopened, err := NewLocalArchiveStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, reopened, 2500, "2000")
+	})
+	t.Run("retention", func(t *testing.T) {
+		store.Retention = 1500 * time.Second
+		if err := store.Put(ctx, topic, time.Unix(4000, 0), []byte("4000")); err != nil {
+			t.Fatal(err)
+		}
+		check(t, store, 2000, "")
+		check(t, store, 3500, "3000")
+		entries, err := os.ReadDir(store.topicPath(topic))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 2, len(entries))
+	})
+}
@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/interline-io/log"
 	"github.com/interline-io/transitland-lib/rt/pb"
 	"github.com/interline-io/transitland-lib/tldb"
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-lib/tt"
 	"github.com/interline-io/transitland-server/internal/clock"
 	"github.com/interline-io/transitland-server/server/model"
 )
@@ -19,15 +23,23 @@ type Cache interface {
 	AddFeedMessage(context.Context, string, *pb.FeedMessage) error
 	AddData(context.Context, string, []byte) error
 	GetSource(context.Context, string) (*Source, bool)
+	Subscribe(context.Context, string) <-chan struct{}
 	Close() error
 }
 
+// Realtime url types that can be subscribed to
+var rtUrlTypes = []string{"realtime_trip_updates", "realtime_alerts", "realtime_vehicle_positions"}
+
 ////////
 
 type Finder struct {
 	Clock clock.Clock
-	cache Cache
-	lc    *lookupCache
+	// OnTripUpdates is called with the feed onestop_id after TripUpdates are received
+	OnTripUpdates func(ctx context.Context, feed string)
+	// BlockDelays enables estimating delays for trips without realtime data from earlier trips in the same block
+	BlockDelays bool
+	cache       Cache
+	lc          *lookupCache
 }
 
 func NewFinder(cache Cache, db tldb.Ext) *Finder {
@@ -38,14 +50,84 @@ func NewFinder(cache Cache, db tldb.Ext) *Finder {
 	}
 }
 
+// now returns the as-of time for replaying archived data, or the current time
+func (f *Finder) now(ctx context.Context) time.Time {
+	if t, ok := model.AsOfForContext(ctx); ok {
+		return t
+	}
+	return f.Clock.Now()
+}
+
 func (f *Finder) AddData(ctx context.Context, topic string, data []byte) error {
-	return f.cache.AddData(ctx, topic, data)
+	if err := f.cache.AddData(ctx, topic, data); err != nil {
+		return err
+	}
+	if f.OnTripUpdates != nil {
+		if feed, urlType, ok := splitTopicKey(topic); ok && urlType == "realtime_trip_updates" {
+			f.OnTripUpdates(ctx, feed)
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel that receives a value whenever RT data of the given url types is updated for any of the specified feeds.
+// All realtime url types are used if none are specified. The channel is closed when ctx is done.
+func (f *Finder) Subscribe(ctx context.Context, feeds []string, urlTypes ...string) <-chan struct{} {
+	if len(urlTypes) == 0 {
+		urlTypes = rtUrlTypes
+	}
+	out := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	for _, feed := range feeds {
+		for _, urlType := range urlTypes {
+			wg.Add(1)
+			go func(ch <-chan struct{}) {
+				defer wg.Done()
+				for range ch {
+					select {
+					case out <- struct{}{}:
+					default:
+					}
+				}
+			}(f.cache.Subscribe(ctx, getTopicKey(feed, urlType)))
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// SubscribeFeedVersions is similar to Subscribe, using the feeds associated with each feed version.
+func (f *Finder) SubscribeFeedVersions(ctx context.Context, fvids []int, urlTypes ...string) <-chan struct{} {
+	feeds := map[string]bool{}
+	var topics []string
+	for _, fvid := range fvids {
+		a, _ := f.lc.GetFeedVersionRTFeeds(fvid)
+		for _, topic := range a {
+			if !feeds[topic] {
+				feeds[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return f.Subscribe(ctx, topics, urlTypes...)
 }
 
 func (f *Finder) GetGtfsTripID(ctx context.Context, id int) (string, bool) {
 	return f.lc.GetGtfsTripID(id)
 }
 
+func (f *Finder) GetGtfsStopID(ctx context.Context, id int) (string, bool) {
+	return f.lc.GetGtfsStopID(id)
+}
+
+func (f *Finder) GetRTFeedFeedVersions(ctx context.Context, feed string) ([]int, bool) {
+	return f.lc.GetRTFeedFeedVersions(feed)
+}
+
 func (f *Finder) StopTimezone(ctx context.Context, id int, known string) (*time.Location, bool) {
 	return f.lc.StopTimezone(ctx, id, known)
 }
@@ -53,7 +135,7 @@ func (f *Finder) StopTimezone(ctx context.Context, id int, known string) (*time.
 func (f *Finder) FindTrip(ctx context.Context, t *model.Trip) *pb.TripUpdate {
 	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
 	for _, topic := range topics {
-		if a, ok := f.getTrip(ctx, topic, t.TripID.Val); ok {
+		if a, ok := f.getTripRun(ctx, topic, t.TripID.Val, t.StartTime); ok {
 			return a
 		}
 	}
@@ -61,14 +143,20 @@ func (f *Finder) FindTrip(ctx context.Context, t *model.Trip) *pb.TripUpdate {
 }
 
 func (f *Finder) FindAlertsForTrip(ctx context.Context, t *model.Trip, limit *int, active *bool) []*model.Alert {
+	routeID, _ := f.lc.GetGtfsRouteID(t.RouteID.Int())
+	m := tripAlertMatcher(t.TripID.Val, routeID, t.DirectionID.Int())
+	return f.findAlerts(ctx, t.FeedVersionID, limit, active, m)
+}
+
+func (f *Finder) FindAlertsForFeeds(ctx context.Context, feeds []string, limit *int, active *bool) []*model.Alert {
 	foundAlerts := []*model.Alert{}
-	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
-	tnow := f.Clock.Now()
-	for _, topic := range topics {
+	tnow := f.now(ctx)
+	for _, topic := range feeds {
 		a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_alerts"))
 		if a == nil || !ok {
-			return foundAlerts
+			continue
 		}
+		fvids, _ := f.lc.GetRTFeedFeedVersions(topic)
 		for _, alert := range a.alerts {
 			if alert == nil {
 				continue
@@ -76,58 +164,21 @@ func (f *Finder) FindAlertsForTrip(ctx context.Context, t *model.Trip, limit *in
 			if !checkAlertActivePeriod(tnow, active, alert) {
 				continue
 			}
-			found := false
-			for _, s := range alert.GetInformedEntity() {
-				// trip must match
-				// route, stop, agency are not checked
-				if s == nil || s.Trip == nil {
-					continue
-				}
-				if s.Trip.GetTripId() == t.TripID.Val {
-					found = true
-				}
-			}
-			if found {
-				foundAlerts = append(foundAlerts, makeAlert(alert))
-			}
+			foundAlerts = append(foundAlerts, makeAlert(alert, topic, fvids))
 		}
 	}
 	return limitAlerts(foundAlerts, limit)
 }
 
-func (f *Finder) FindAlertsForRoute(ctx context.Context, t *model.Route, limit *int, active *bool) []*model.Alert {
-	foundAlerts := []*model.Alert{}
-	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
-	tnow := f.Clock.Now()
-	for _, topic := range topics {
-		a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_alerts"))
-		if a == nil || !ok {
-			continue
-		}
-		for _, alert := range a.alerts {
-			if !checkAlertActivePeriod(tnow, active, alert) {
-				continue
-			}
-			if alert == nil {
-				continue
-			}
-			found := false
-			for _, s := range alert.GetInformedEntity() {
-				// trip and stop must be empty
-				// route must match
-				if s == nil || s.Trip != nil || s.GetStopId() != "" {
-					continue
-				}
-				if s.GetRouteId() == t.RouteID.Val {
-					found = true
-				}
-			}
-			if found {
-				foundAlerts = append(foundAlerts, makeAlert(alert))
-			}
-		}
+// FindAlertsForRoute returns alerts that apply to the route.
+// Alerts for the provided stops are also included when they apply on this route.
+func (f *Finder) FindAlertsForRoute(ctx context.Context, t *model.Route, stops []*model.Stop, limit *int, active *bool) []*model.Alert {
+	agencyID, _ := f.lc.GetGtfsAgencyID(t.AgencyID.Int())
+	matchers := []*alertMatcher{routeAlertMatcher(agencyID, t.RouteID.Val, t.RouteType.Int())}
+	for _, stop := range stops {
+		matchers = append(matchers, stopAlertMatcher(stop.StopID.Val, agencyID, t.RouteID.Val, t.RouteType.Int()))
 	}
-	return limitAlerts(foundAlerts, limit)
+	return f.findAlerts(ctx, t.FeedVersionID, limit, active, matchers...)
 }
 
 func (f *Finder) GetMessage(ctx context.Context, topic string, topicKey string) (*pb.FeedMessage, bool) {
@@ -140,69 +191,43 @@ func (f *Finder) GetMessage(ctx context.Context, topic string, topicKey string)
 }
 
 func (f *Finder) FindAlertsForAgency(ctx context.Context, t *model.Agency, limit *int, active *bool) []*model.Alert {
-	foundAlerts := []*model.Alert{}
-	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
-	tnow := f.Clock.Now()
-	for _, topic := range topics {
-		a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_alerts"))
-		if a == nil || !ok {
-			continue
-		}
-		for _, alert := range a.alerts {
-			if alert == nil {
-				continue
-			}
-			if !checkAlertActivePeriod(tnow, active, alert) {
-				continue
-			}
-			found := false
-			for _, s := range alert.GetInformedEntity() {
-				// trip, route, stop must be empty
-				// agency must match
-				if s == nil || s.Trip != nil || s.GetRouteId() != "" || s.GetStopId() != "" {
-					continue
-				}
-				if s.GetAgencyId() == t.AgencyID.Val {
-					found = true
-				}
-			}
-			if found {
-				foundAlerts = append(foundAlerts, makeAlert(alert))
-			}
-		}
+	m := agencyAlertMatcher(t.AgencyID.Val)
+	return f.findAlerts(ctx, t.FeedVersionID, limit, active, m)
+}
+
+// FindAlertsForStop returns alerts that apply to the stop.
+// If a route is provided, only alerts that apply to the stop on that route are included.
+func (f *Finder) FindAlertsForStop(ctx context.Context, t *model.Stop, r *model.Route, limit *int, active *bool) []*model.Alert {
+	m := stopAlertMatcher(t.StopID.Val, "", "", 0)
+	if r != nil {
+		agencyID, _ := f.lc.GetGtfsAgencyID(r.AgencyID.Int())
+		m = stopAlertMatcher(t.StopID.Val, agencyID, r.RouteID.Val, r.RouteType.Int())
 	}
-	return limitAlerts(foundAlerts, limit)
+	return f.findAlerts(ctx, t.FeedVersionID, limit, active, m)
 }
 
-func (f *Finder) FindAlertsForStop(ctx context.Context, t *model.Stop, limit *int, active *bool) []*model.Alert {
+// findAlerts returns alerts from the feeds associated with a feed version that satisfy any matcher.
+func (f *Finder) findAlerts(ctx context.Context, fvid int, limit *int, active *bool, matchers ...*alertMatcher) []*model.Alert {
 	foundAlerts := []*model.Alert{}
-	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
-	tnow := f.Clock.Now()
+	topics, _ := f.lc.GetFeedVersionRTFeeds(fvid)
+	tnow := f.now(ctx)
 	for _, topic := range topics {
 		a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_alerts"))
 		if a == nil || !ok {
 			continue
 		}
 		for _, alert := range a.alerts {
-			if !checkAlertActivePeriod(tnow, active, alert) {
+			if alert == nil {
 				continue
 			}
-			if alert == nil {
+			if !checkAlertActivePeriod(tnow, active, alert) {
 				continue
 			}
-			found := false
-			for _, s := range alert.GetInformedEntity() {
-				// agency, route can be anything
-				// trip must be empty
-				if s == nil || s.Trip != nil {
-					continue
+			for _, m := range matchers {
+				if m.Match(alert) {
+					foundAlerts = append(foundAlerts, makeAlert(alert, topic, []int{fvid}))
+					break
 				}
-				if s.GetStopId() == t.StopID.Val {
-					found = true
-				}
-			}
-			if found {
-				foundAlerts = append(foundAlerts, makeAlert(alert))
 			}
 		}
 	}
@@ -216,7 +241,7 @@ func (f *Finder) FindStopTimeUpdate(ctx context.Context, t *model.Trip, st *mode
 	// Attempt to match on stop sequence
 	for _, topic := range topics {
 		// Match on trip
-		rtTrip, rtok := f.getTrip(ctx, topic, tid.Val)
+		rtTrip, rtok := f.getTripRun(ctx, topic, tid.Val, st.StartTime)
 		if !rtok {
 			continue
 		}
@@ -231,7 +256,7 @@ func (f *Finder) FindStopTimeUpdate(ctx context.Context, t *model.Trip, st *mode
 	// Attempt to match on stop id
 	for _, topic := range topics {
 		// Match on trip
-		rtTrip, rtok := f.getTrip(ctx, topic, tid.Val)
+		rtTrip, rtok := f.getTripRun(ctx, topic, tid.Val, st.StartTime)
 		if !rtok {
 			continue
 		}
@@ -265,10 +290,139 @@ func (f *Finder) FindStopTimeUpdate(ctx context.Context, t *model.Trip, st *mode
 		// Matched on trip, but no match on stop sequence or stop_id
 		return &model.RTStopTimeUpdate{TripUpdate: rtTrip, LastDelay: copyPtr(lastDelay)}, true
 	}
+	// No realtime data for this trip; optionally estimate a delay from earlier trips in the block
+	if f.BlockDelays && !st.StartTime.Valid {
+		if delay, ok := f.findBlockDelay(ctx, t, st); ok {
+			log.For(ctx).Trace().Str("trip_id", t.TripID.Val).Int32("delay", delay).Msgf("estimated delay from block")
+			return &model.RTStopTimeUpdate{LastDelay: &delay, EstimatedDelay: true}, true
+		}
+	}
 	// log.For(ctx).Trace().Str("trip_id", t.TripID.Val).Int("seq", seq).Msgf("no stop time update found")
 	return nil, false
 }
 
+// findBlockDelay estimates the delay for a trip from the closest earlier trip in the same block with a TripUpdate
+func (f *Finder) findBlockDelay(ctx context.Context, t *model.Trip, st *model.StopTime) (int32, bool) {
+	blockTrips, ok := f.lc.GetBlockTrips(t.ID)
+	if !ok || len(blockTrips) < 2 {
+		return 0, false
+	}
+	serviceDate := ""
+	if st.ServiceDate.Valid {
+		serviceDate = st.ServiceDate.Val.Format("20060102")
+	}
+	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
+	return estimateBlockDelay(blockTrips, t.ID, func(tid string) (int32, bool) {
+		for _, topic := range topics {
+			rtTrip, ok := f.getTrip(ctx, topic, tid)
+			if !ok {
+				continue
+			}
+			// Skip TripUpdates for a different service date
+			if startDate := rtTrip.GetTrip().GetStartDate(); startDate != "" && serviceDate != "" && startDate != serviceDate {
+				continue
+			}
+			return tripUpdateDelay(rtTrip)
+		}
+		return 0, false
+	})
+}
+
+// estimateBlockDelay propagates the delay of the closest earlier trip in the block that has a known delay.
+// Layover time between trips absorbs delay; early running is not propagated.
+func estimateBlockDelay(blockTrips []blockTrip, id int, tripDelay func(string) (int32, bool)) (int32, bool) {
+	idx := -1
+	for i, bt := range blockTrips {
+		if bt.ID == id {
+			idx = i
+			break
+		}
+	}
+	layover := 0
+	for i := idx - 1; i >= 0; i-- {
+		prev, next := blockTrips[i], blockTrips[i+1]
+		if prev.LastArrival.Valid && next.FirstDeparture.Valid {
+			layover += max(0, next.FirstDeparture.Int()-prev.LastArrival.Int())
+		}
+		if delay, ok := tripDelay(prev.TripID); ok {
+			return int32(max(0, int(delay)-layover)), true
+		}
+	}
+	return 0, false
+}
+
+// tripUpdateDelay returns the last known delay for a TripUpdate
+func tripUpdateDelay(rtTrip *pb.TripUpdate) (int32, bool) {
+	if rtTrip.GetTrip().GetScheduleRelationship() == pb.TripDescriptor_CANCELED {
+		return 0, false
+	}
+	var lastDelay *int32
+	for _, ste := range rtTrip.StopTimeUpdate {
+		if ste.Arrival != nil && ste.Arrival.Delay != nil {
+			lastDelay = ste.Arrival.Delay
+		}
+		if ste.Departure != nil && ste.Departure.Delay != nil {
+			lastDelay = ste.Departure.Delay
+		}
+	}
+	if lastDelay == nil {
+		lastDelay = rtTrip.Delay
+	}
+	if lastDelay == nil {
+		return 0, false
+	}
+	return *lastDelay, true
+}
+
+func (f *Finder) FindVehiclePositionForTrip(ctx context.Context, t *model.Trip) *model.VehiclePosition {
+	if t.TripID.Val == "" {
+		return nil
+	}
+	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
+	for _, topic := range topics {
+		a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_vehicle_positions"))
+		if a == nil || !ok {
+			continue
+		}
+		if vp, ok := a.GetVehiclePositionForTrip(t.TripID.Val); ok {
+			return makeVehiclePosition(t.FeedVersionID, vp)
+		}
+	}
+	return nil
+}
+
+func (f *Finder) FindVehiclePositionsForRoute(ctx context.Context, t *model.Route, limit *int) []*model.VehiclePosition {
+	foundVehicles := []*model.VehiclePosition{}
+	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
+	seen := map[string]bool{}
+	for _, topic := range topics {
+		a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_vehicle_positions"))
+		if a == nil || !ok {
+			continue
+		}
+		vps := append([]*pb.VehiclePosition{}, a.GetVehiclePositionsForRoute(t.RouteID.Val)...)
+		// Vehicles that only specify a trip are matched using the route of the static trip
+		for _, vp := range a.GetVehiclePositionsWithoutRoute() {
+			if rid, ok := f.lc.GetTripRouteID(t.FeedVersionID, vp.GetTrip().GetTripId()); ok && rid == t.ID {
+				vps = append(vps, vp)
+			}
+		}
+		for _, vp := range vps {
+			// Vehicles may be present in more than one feed
+			vid := vp.GetVehicle().GetId()
+			if vid != "" && seen[vid] {
+				continue
+			}
+			seen[vid] = true
+			foundVehicles = append(foundVehicles, makeVehiclePosition(t.FeedVersionID, vp))
+		}
+	}
+	if limit != nil && len(foundVehicles) > *limit {
+		return foundVehicles[0:*limit]
+	}
+	return foundVehicles
+}
+
 // TODO: put this method on consumer and wrap, as with GetTrip
 func (f *Finder) GetAddedTripsForStop(ctx context.Context, t *model.Stop) []*pb.TripUpdate {
 	sid := t.StopID
@@ -295,6 +449,58 @@ func (f *Finder) GetAddedTripsForStop(ctx context.Context, t *model.Stop) []*pb.
 	return ret
 }
 
+// FindTripModifications returns the TripModifications selected for a trip.
+// TripModifications, Shape and Stop entities are expected in the trip updates feed.
+func (f *Finder) FindTripModifications(ctx context.Context, t *model.Trip) (*model.RTTripModifications, bool) {
+	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
+	for _, topic := range topics {
+		a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_trip_updates"))
+		if a == nil || !ok {
+			continue
+		}
+		mods, shapeID, ok := a.GetTripModifications(t.TripID.Val)
+		if !ok {
+			continue
+		}
+		ret := model.RTTripModifications{
+			TripModifications: mods,
+			Stops:             map[string]*model.Stop{},
+		}
+		if shape, ok := a.GetShape(shapeID); ok && shapeID != "" {
+			ret.Shape = makeShape(shape)
+		}
+		for _, mod := range mods.Modifications {
+			for _, rs := range mod.ReplacementStops {
+				if stop, ok := a.GetStop(rs.GetStopId()); ok {
+					ret.Stops[rs.GetStopId()] = makeStop(stop, t.FeedVersionID)
+				}
+			}
+		}
+		return &ret, true
+	}
+	return nil, false
+}
+
+// FindTripModificationsForStop returns the trip_ids of modified trips that use this stop as a replacement stop.
+func (f *Finder) FindTripModificationsForStop(ctx context.Context, t *model.Stop) []string {
+	var ret []string
+	seen := map[string]bool{}
+	topics, _ := f.lc.GetFeedVersionRTFeeds(t.FeedVersionID)
+	for _, topic := range topics {
+		a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_trip_updates"))
+		if a == nil || !ok {
+			continue
+		}
+		for _, tid := range a.GetTripModificationsForStop(t.StopID.Val) {
+			if !seen[tid] {
+				seen[tid] = true
+				ret = append(ret, tid)
+			}
+		}
+	}
+	return ret
+}
+
 func (f *Finder) MakeTrip(ctx context.Context, obj *model.Trip) (*model.Trip, error) {
 	t := model.Trip{}
 	t.FeedVersionID = obj.FeedVersionID
@@ -325,6 +531,21 @@ func (f *Finder) getTrip(ctx context.Context, topic string, tid string) (*pb.Tri
 	return trip, ok
 }
 
+// getTripRun returns the TripUpdate for a trip; expanded frequency-based trip runs must also match start_time
+func (f *Finder) getTripRun(ctx context.Context, topic string, tid string, startTime tt.Seconds) (*pb.TripUpdate, bool) {
+	if !startTime.Valid {
+		return f.getTrip(ctx, topic, tid)
+	}
+	if tid == "" {
+		return nil, false
+	}
+	a, ok := f.cache.GetSource(ctx, getTopicKey(topic, "realtime_trip_updates"))
+	if !ok {
+		return nil, false
+	}
+	return a.GetTripRun(tid, startTime.Int())
+}
+
 func checkAlertActivePeriod(t time.Time, active *bool, a *pb.Alert) bool {
 	if active == nil || *active == false {
 		return true
@@ -366,8 +587,8 @@ func limitAlerts(alerts []*model.Alert, limit *int) []*model.Alert {
 	return alerts
 }
 
-func makeAlert(a *pb.Alert) *model.Alert {
-	r := model.Alert{}
+func makeAlert(a *pb.Alert, feed string, fvids []int) *model.Alert {
+	r := model.Alert{FeedOnestopID: pstr(feed)}
 	if a.Cause != nil {
 		r.Cause = pstr(a.Cause.String())
 	}
@@ -394,6 +615,130 @@ func makeAlert(a *pb.Alert) *model.Alert {
 	r.TtsHeaderText = newTranslation(a.TtsHeaderText)
 	r.TtsDescriptionText = newTranslation(a.TtsDescriptionText)
 	r.URL = newTranslation(a.Url)
+	for _, s := range a.InformedEntity {
+		if s == nil {
+			continue
+		}
+		ent := model.RTEntitySelector{
+			AgencyID:       pstr(s.GetAgencyId()),
+			RouteID:        pstr(s.GetRouteId()),
+			StopID:         pstr(s.GetStopId()),
+			FeedVersionIDs: fvids,
+		}
+		if s.RouteType != nil {
+			v := int(*s.RouteType)
+			ent.RouteType = &v
+		}
+		if s.DirectionId != nil {
+			v := int(*s.DirectionId)
+			ent.DirectionID = &v
+		}
+		if s.Trip != nil {
+			ent.Trip = makeTripDescriptor(s.Trip)
+		}
+		r.InformedEntity = append(r.InformedEntity, &ent)
+	}
+	return &r
+}
+
+// makeShape decodes a GTFS-RT Shape, using the cumulative distance in meters as the measure value
+func makeShape(v *pb.Shape) *model.Shape {
+	pts, err := tlxy.DecodePolylineString(v.GetEncodedPolyline())
+	if err != nil || len(pts) < 2 {
+		return nil
+	}
+	var coords []float64
+	dist := 0.0
+	for i, pt := range pts {
+		if i > 0 {
+			dist += tlxy.DistanceHaversine(pts[i-1], pt)
+		}
+		coords = append(coords, pt.Lon, pt.Lat, dist)
+	}
+	r := model.Shape{}
+	r.ShapeID.Set(v.GetShapeId())
+	r.Geometry = tt.NewLineStringFromFlatCoords(coords)
+	return &r
+}
+
+// makeStop creates a Stop from a GTFS-RT Stop entity; these are not present in the static feed
+func makeStop(v *pb.Stop, fvid int) *model.Stop {
+	r := model.Stop{}
+	r.FeedVersionID = fvid
+	r.StopID.Set(v.GetStopId())
+	if name := firstTranslation(v.StopName); name != "" {
+		r.StopName.Set(name)
+	}
+	if code := firstTranslation(v.StopCode); code != "" {
+		r.StopCode.Set(code)
+	}
+	if v.StopTimezone != nil {
+		r.StopTimezone.Set(v.GetStopTimezone())
+	}
+	if v.StopLat != nil && v.StopLon != nil {
+		r.Geometry = tt.NewPoint(float64(v.GetStopLon()), float64(v.GetStopLat()))
+	}
+	return &r
+}
+
+func makeVehiclePosition(fvid int, v *pb.VehiclePosition) *model.VehiclePosition {
+	r := model.VehiclePosition{
+		FeedVersionID: fvid,
+		RTStopID:      v.GetStopId(),
+	}
+	if vd := v.Vehicle; vd != nil {
+		r.Vehicle = &model.RTVehicleDescriptor{
+			ID:           pstr(vd.GetId()),
+			Label:        pstr(vd.GetLabel()),
+			LicensePlate: pstr(vd.GetLicensePlate()),
+		}
+	}
+	if td := v.Trip; td != nil {
+		r.Trip = makeTripDescriptor(td)
+	}
+	if pos := v.Position; pos != nil {
+		pt := tt.NewPoint(float64(pos.GetLongitude()), float64(pos.GetLatitude()))
+		r.Position = &pt
+	}
+	if v.CurrentStopSequence != nil {
+		seq := int(v.GetCurrentStopSequence())
+		r.CurrentStopSequence = &seq
+	}
+	if v.CurrentStatus != nil {
+		r.CurrentStatus = pstr(v.CurrentStatus.String())
+	}
+	if v.CongestionLevel != nil {
+		r.CongestionLevel = pstr(v.CongestionLevel.String())
+	}
+	if v.Timestamp != nil {
+		ts := time.Unix(int64(v.GetTimestamp()), 0).In(time.UTC)
+		r.Timestamp = &ts
+	}
+	return &r
+}
+
+func makeTripDescriptor(td *pb.TripDescriptor) *model.RTTripDescriptor {
+	r := model.RTTripDescriptor{
+		TripID:  pstr(td.GetTripId()),
+		RouteID: pstr(td.GetRouteId()),
+	}
+	if td.DirectionId != nil {
+		dir := int(td.GetDirectionId())
+		r.DirectionID = &dir
+	}
+	if td.StartTime != nil {
+		if st, err := tt.NewSecondsFromString(td.GetStartTime()); err == nil {
+			r.StartTime = &st
+		}
+	}
+	if td.StartDate != nil {
+		if sd, err := tt.ParseDate(td.GetStartDate()); err == nil {
+			r.StartDate = &sd
+		}
+	}
+	if td.ScheduleRelationship != nil {
+		r.ScheduleRelationship = pstr(td.ScheduleRelationship.String())
+	}
 	return &r
 }
 
@@ -422,10 +767,30 @@ func newTranslation(v *pb.TranslatedString) []*model.RTTranslation {
 	return ret
 }
 
+func firstTranslation(v *pb.TranslatedString) string {
+	for _, tr := range v.GetTranslation() {
+		return tr.GetText()
+	}
+	return ""
+}
+
 func getTopicKey(topic string, t string) string {
 	return fmt.Sprintf("rtdata:%s:%s", topic, t)
 }
 
+// splitTopicKey returns the feed and url type for a topic key
+func splitTopicKey(key string) (string, string, bool) {
+	prefix, rest, ok := strings.Cut(key, ":")
+	if !ok || prefix != "rtdata" {
+		return "", "", false
+	}
+	i := strings.LastIndex(rest, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
+
 func copyPtr[T any, PT *T](v PT) PT {
 	if v == nil {
 		return nil
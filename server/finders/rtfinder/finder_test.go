@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -39,3 +41,115 @@ func testCache(t *testing.T, rtCache Cache) {
 		t.Errorf("got %d items, expected %d", len(found), len(feeds))
 	}
 }
+
+func testCacheSubscribe(t *testing.T, rtCache Cache) {
+	ctx, cancel := context.WithCancel(context.Background())
+	topic := fmt.Sprintf("sub-%d", time.Now().UnixNano())
+	other := fmt.Sprintf("other-%d", time.Now().UnixNano())
+	updates := rtCache.Subscribe(ctx, topic)
+	v := "2.0"
+	ts := uint64(time.Now().UnixNano())
+	rtdata, _ := proto.Marshal(&pb.FeedMessage{Header: &pb.FeedHeader{GtfsRealtimeVersion: &v, Timestamp: &ts}})
+	// Updates to other topics do not signal
+	rtCache.AddData(ctx, other, rtdata)
+	rtCache.AddData(ctx, topic, rtdata)
+	select {
+	case <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for update")
+	}
+	// Channel is closed when context is done
+	cancel()
+	for range updates {
+	}
+	rtCache.Close()
+}
+
+func TestEstimateBlockDelay(t *testing.T) {
+	newBlockTrip := func(id int, dep string, arr string) blockTrip {
+		bt := blockTrip{ID: id, TripID: fmt.Sprintf("t%d", id)}
+		bt.FirstDeparture, _ = tt.NewSecondsFromString(dep)
+		bt.LastArrival, _ = tt.NewSecondsFromString(arr)
+		return bt
+	}
+	blockTrips := []blockTrip{
+		newBlockTrip(1, "08:00:00", "09:00:00"),
+		newBlockTrip(2, "09:10:00", "10:00:00"),
+		newBlockTrip(3, "10:05:00", "11:00:00"),
+	}
+	delays := func(v map[string]int32) func(string) (int32, bool) {
+		return func(tid string) (int32, bool) {
+			d, ok := v[tid]
+			return d, ok
+		}
+	}
+	tcs := []struct {
+		name   string
+		id     int
+		delays map[string]int32
+		expect int32
+		ok     bool
+	}{
+		{"next trip", 2, map[string]int32{"t1": 900}, 300, true},
+		{"absorbed by layover", 2, map[string]int32{"t1": 300}, 0, true},
+		{"early running not propagated", 2, map[string]int32{"t1": -120}, 0, true},
+		{"accumulated layovers", 3, map[string]int32{"t1": 1200}, 300, true},
+		{"closest earlier trip", 3, map[string]int32{"t1": 1200, "t2": 420}, 120, true},
+		{"first trip in block", 1, map[string]int32{"t2": 600}, 0, false},
+		{"no earlier data", 2, map[string]int32{"t3": 600}, 0, false},
+		{"trip not in block", 4, map[string]int32{"t1": 600}, 0, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, ok := estimateBlockDelay(blockTrips, tc.id, delays(tc.delays))
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expect, delay)
+		})
+	}
+}
+
+func TestTripUpdateDelay(t *testing.T) {
+	tu := &pb.TripUpdate{
+		Trip: &pb.TripDescriptor{TripId: proto.String("t1")},
+		StopTimeUpdate: []*pb.TripUpdate_StopTimeUpdate{
+			{StopSequence: proto.Uint32(1), Departure: &pb.TripUpdate_StopTimeEvent{Delay: proto.Int32(60)}},
+			{StopSequence: proto.Uint32(2), Arrival: &pb.TripUpdate_StopTimeEvent{Delay: proto.Int32(120)}},
+			{StopSequence: proto.Uint32(3), Arrival: &pb.TripUpdate_StopTimeEvent{Time: proto.Int64(1000)}},
+		},
+	}
+	delay, ok := tripUpdateDelay(tu)
+	assert.True(t, ok)
+	assert.Equal(t, int32(120), delay)
+	t.Run("trip delay", func(t *testing.T) {
+		delay, ok := tripUpdateDelay(&pb.TripUpdate{Delay: proto.Int32(30)})
+		assert.True(t, ok)
+		assert.Equal(t, int32(30), delay)
+	})
+	t.Run("canceled", func(t *testing.T) {
+		tu2 := proto.Clone(tu).(*pb.TripUpdate)
+		tu2.Trip.ScheduleRelationship = pb.TripDescriptor_CANCELED.Enum()
+		_, ok := tripUpdateDelay(tu2)
+		assert.False(t, ok)
+	})
+	t.Run("no delay", func(t *testing.T) {
+		_, ok := tripUpdateDelay(&pb.TripUpdate{})
+		assert.False(t, ok)
+	})
+}
+
+func TestFinder_OnTripUpdates(t *testing.T) {
+	ctx := context.Background()
+	f := NewFinder(NewLocalCache(), nil)
+	var got []string
+	f.OnTripUpdates = func(ctx context.Context, feed string) {
+		got = append(got, feed)
+	}
+	data, err := proto.Marshal(&pb.FeedMessage{Header: &pb.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, f.AddData(ctx, getTopicKey("BA", "realtime_trip_updates"), data))
+	assert.NoError(t, f.AddData(ctx, getTopicKey("BA", "realtime_alerts"), data))
+	assert.NoError(t, f.AddData(ctx, getTopicKey("CT", "realtime_trip_updates"), data))
+	assert.Equal(t, []string{"BA", "CT"}, got)
+}
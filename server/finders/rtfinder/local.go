@@ -8,13 +8,15 @@ import (
 )
 
 type LocalCache struct {
-	lock    sync.Mutex
-	sources map[string]*Source
+	lock     sync.Mutex
+	sources  map[string]*Source
+	notifier *topicNotifier
 }
 
 func NewLocalCache() *LocalCache {
 	return &LocalCache{
-		sources: map[string]*Source{},
+		sources:  map[string]*Source{},
+		notifier: newTopicNotifier(),
 	}
 }
 
@@ -34,13 +36,22 @@ func (f *LocalCache) AddFeedMessage(ctx context.Context, topic string, rtmsg *pb
 
 func (f *LocalCache) AddData(ctx context.Context, topic string, data []byte) error {
 	f.lock.Lock()
-	defer f.lock.Unlock()
 	s, ok := f.sources[topic]
 	if !ok {
 		s, _ = NewSource(topic)
 		f.sources[topic] = s
 	}
-	return s.process(ctx, data)
+	err := s.process(ctx, data)
+	f.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	f.notifier.notify(topic)
+	return nil
+}
+
+func (f *LocalCache) Subscribe(ctx context.Context, topic string) <-chan struct{} {
+	return f.notifier.subscribe(ctx, topic)
 }
 
 func (f *LocalCache) Close() error {
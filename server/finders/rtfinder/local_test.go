@@ -8,3 +8,8 @@ func TestLocalCache(t *testing.T) {
 	rtCache := NewLocalCache()
 	testCache(t, rtCache)
 }
+
+func TestLocalCache_Subscribe(t *testing.T) {
+	rtCache := NewLocalCache()
+	testCacheSubscribe(t, rtCache)
+}
@@ -2,34 +2,47 @@ package rtfinder
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/tt"
 	"github.com/interline-io/transitland-server/server/caches/tzcache"
 	"github.com/jmoiron/sqlx"
 )
 
 type lookupCache struct {
-	db              sqlx.Ext
-	fvidSourceCache *simpleCache[int, []string]
-	fvidFeedCache   *simpleCache[int, string]
-	gtfsTripIdCache *simpleCache[int, string]
-	gtfsStopIdCache *simpleCache[int, string]
-	routeIdCache    *simpleCache[skey, int]
-	tzCache         *tzcache.Cache[int]
-	rtLookupLock    sync.Mutex
+	db                sqlx.Ext
+	fvidSourceCache   *simpleCache[int, []string]
+	feedFvidCache     *simpleCache[string, []int]
+	fvidFeedCache     *simpleCache[int, string]
+	gtfsTripIdCache   *simpleCache[int, string]
+	gtfsStopIdCache   *simpleCache[int, string]
+	gtfsRouteIdCache  *simpleCache[int, string]
+	gtfsAgencyIdCache *simpleCache[int, string]
+	routeIdCache      *simpleCache[skey, int]
+	tripRouteIdCache  *simpleCache[skey, int]
+	blockTripsCache   *simpleCache[int, []blockTrip]
+	tzCache           *tzcache.Cache[int]
+	rtLookupLock      sync.Mutex
 }
 
 func newLookupCache(db sqlx.Ext) *lookupCache {
 	return &lookupCache{
-		db:              db,
-		tzCache:         tzcache.NewCache[int](),
-		fvidSourceCache: newSimpleCache[int, []string](),
-		fvidFeedCache:   newSimpleCache[int, string](),
-		gtfsTripIdCache: newSimpleCache[int, string](),
-		gtfsStopIdCache: newSimpleCache[int, string](),
-		routeIdCache:    newSimpleCache[skey, int](),
+		db:                db,
+		tzCache:           tzcache.NewCache[int](),
+		fvidSourceCache:   newSimpleCache[int, []string](),
+		feedFvidCache:     newSimpleCache[string, []int](),
+		fvidFeedCache:     newSimpleCache[int, string](),
+		gtfsTripIdCache:   newSimpleCache[int, string](),
+		gtfsStopIdCache:   newSimpleCache[int, string](),
+		gtfsRouteIdCache:  newSimpleCache[int, string](),
+		gtfsAgencyIdCache: newSimpleCache[int, string](),
+		routeIdCache:      newSimpleCache[skey, int](),
+		tripRouteIdCache:  newSimpleCache[skey, int](),
+		blockTripsCache:   newSimpleCache[int, []blockTrip](),
 	}
 }
 
@@ -44,6 +57,21 @@ func (f *lookupCache) GetRouteID(fvid int, tid string) (int, bool) {
 	return eid, err == nil
 }
 
+// GetTripRouteID returns the route of a static trip, by GTFS trip_id
+func (f *lookupCache) GetTripRouteID(fvid int, tid string) (int, bool) {
+	sk := skey{fvid, tid}
+	if a, ok := f.tripRouteIdCache.Get(sk); ok {
+		return a, a > 0
+	}
+	eid := 0
+	err := sqlx.Get(f.db, &eid, "select route_id from gtfs_trips where feed_version_id = $1 and trip_id = $2 limit 1", fvid, tid)
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		// Do not cache other errors, which may be transient
+		f.tripRouteIdCache.Set(sk, eid)
+	}
+	return eid, err == nil
+}
+
 func (f *lookupCache) GetGtfsTripID(id int) (string, bool) {
 	if a, ok := f.gtfsTripIdCache.Get(id); ok {
 		return a, ok
@@ -66,6 +94,28 @@ func (f *lookupCache) GetGtfsStopID(id int) (string, bool) {
 	return eid, err == nil
 }
 
+func (f *lookupCache) GetGtfsRouteID(id int) (string, bool) {
+	if a, ok := f.gtfsRouteIdCache.Get(id); ok {
+		return a, ok
+	}
+	q := `select route_id from gtfs_routes where id = $1 limit 1`
+	eid := ""
+	err := sqlx.Get(f.db, &eid, q, id)
+	f.gtfsRouteIdCache.Set(id, eid)
+	return eid, err == nil
+}
+
+func (f *lookupCache) GetGtfsAgencyID(id int) (string, bool) {
+	if a, ok := f.gtfsAgencyIdCache.Get(id); ok {
+		return a, ok
+	}
+	q := `select agency_id from gtfs_agencies where id = $1 limit 1`
+	eid := ""
+	err := sqlx.Get(f.db, &eid, q, id)
+	f.gtfsAgencyIdCache.Set(id, eid)
+	return eid, err == nil
+}
+
 func (f *lookupCache) GetFeedVersionRTFeeds(id int) ([]string, bool) {
 	f.rtLookupLock.Lock()
 	defer f.rtLookupLock.Unlock()
@@ -97,6 +147,72 @@ func (f *lookupCache) GetFeedVersionRTFeeds(id int) ([]string, bool) {
 	return eid, true
 }
 
+// GetRTFeedFeedVersions returns the active feed versions associated with a realtime feed.
+// This is the reverse of GetFeedVersionRTFeeds.
+func (f *lookupCache) GetRTFeedFeedVersions(feed string) ([]int, bool) {
+	f.rtLookupLock.Lock()
+	defer f.rtLookupLock.Unlock()
+	if a, ok := f.feedFvidCache.Get(feed); ok {
+		return a, ok
+	}
+	q := `
+	select 
+		distinct fs.feed_version_id
+	from current_feeds cf
+	join current_operators_in_feed coif on coif.feed_id = cf.id 
+	join current_operators_in_feed coif2 on coif2.resolved_onestop_id = coif.resolved_onestop_id 
+	join feed_states fs on fs.feed_id = coif2.feed_id
+	where cf.onestop_id = $1 and fs.feed_version_id is not null
+	order by fs.feed_version_id
+	`
+	var eid []int
+	err := sqlx.Select(
+		f.db,
+		&eid,
+		q,
+		feed,
+	)
+	f.feedFvidCache.Set(feed, eid) // set before return
+	if err != nil {
+		return nil, false
+	}
+	return eid, true
+}
+
+// GetBlockTrips returns the trips that share a block_id and service_id with a trip, ordered by first departure.
+// Trips without a block_id return no results.
+func (f *lookupCache) GetBlockTrips(id int) ([]blockTrip, bool) {
+	if a, ok := f.blockTripsCache.Get(id); ok {
+		return a, ok
+	}
+	q := `
+	select 
+		t2.id,
+		t2.trip_id,
+		min(sts.departure_time + t2.journey_pattern_offset) as first_departure,
+		max(sts.arrival_time + t2.journey_pattern_offset) as last_arrival
+	from gtfs_trips t1
+	join gtfs_trips t2 on t2.feed_version_id = t1.feed_version_id and t2.block_id = t1.block_id and t2.service_id = t1.service_id
+	join gtfs_trips jp on jp.trip_id::text = t2.journey_pattern_id and jp.feed_version_id = t2.feed_version_id
+	join gtfs_stop_times sts on sts.trip_id = jp.id and sts.feed_version_id = jp.feed_version_id
+	where t1.id = $1 and t1.block_id is not null and t1.block_id <> ''
+	group by t2.id, t2.trip_id
+	order by first_departure, t2.id
+	`
+	var ents []blockTrip
+	err := sqlx.Select(
+		f.db,
+		&ents,
+		q,
+		id,
+	)
+	f.blockTripsCache.Set(id, ents) // set before return
+	if err != nil {
+		return nil, false
+	}
+	return ents, true
+}
+
 // StopTimezone looks up the timezone for a stop
 func (f *lookupCache) StopTimezone(ctx context.Context, id int, known string) (*time.Location, bool) {
 	// Need to lock while looking up or setting.
@@ -165,6 +281,13 @@ type skey struct {
 	eid  string
 }
 
+type blockTrip struct {
+	ID             int        `db:"id"`
+	TripID         string     `db:"trip_id"`
+	FirstDeparture tt.Seconds `db:"first_departure"`
+	LastArrival    tt.Seconds `db:"last_arrival"`
+}
+
 ///
 
 type simpleCache[K comparable, V any] struct {
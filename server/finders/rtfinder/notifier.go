@@ -0,0 +1,54 @@
+package rtfinder
+
+import (
+	"context"
+	"sync"
+)
+
+// topicNotifier signals subscribers when a topic has been updated.
+// Notifications are coalesced: a slow subscriber receives at most one pending signal.
+type topicNotifier struct {
+	lock sync.Mutex
+	subs map[string]map[chan struct{}]bool
+}
+
+func newTopicNotifier() *topicNotifier {
+	return &topicNotifier{
+		subs: map[string]map[chan struct{}]bool{},
+	}
+}
+
+// subscribe returns a channel that receives a value after each update to topic.
+// The channel is closed when ctx is done.
+func (n *topicNotifier) subscribe(ctx context.Context, topic string) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	n.lock.Lock()
+	if n.subs[topic] == nil {
+		n.subs[topic] = map[chan struct{}]bool{}
+	}
+	n.subs[topic][ch] = true
+	n.lock.Unlock()
+	go func() {
+		<-ctx.Done()
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		delete(n.subs[topic], ch)
+		if len(n.subs[topic]) == 0 {
+			delete(n.subs, topic)
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+// notify signals all current subscribers for topic
+func (n *topicNotifier) notify(topic string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for ch := range n.subs[topic] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
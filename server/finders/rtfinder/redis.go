@@ -31,6 +31,7 @@ type RedisCache struct {
 	lock      sync.Mutex
 	client    *redis.Client
 	listeners map[string]*listener
+	notifier  *topicNotifier
 }
 
 func NewRedisCache(client *redis.Client) *RedisCache {
@@ -38,6 +39,7 @@ func NewRedisCache(client *redis.Client) *RedisCache {
 	f := RedisCache{
 		client:    client,
 		listeners: map[string]*listener{},
+		notifier:  newTopicNotifier(),
 		ctx:       ctx,
 	}
 	return &f
@@ -76,6 +78,12 @@ func (f *RedisCache) AddData(ctx context.Context, topic string, data []byte) err
 	return nil
 }
 
+func (f *RedisCache) Subscribe(ctx context.Context, topic string) <-chan struct{} {
+	// Ensure a listener is running for this topic
+	f.GetSource(ctx, topic)
+	return f.notifier.subscribe(ctx, topic)
+}
+
 func (f *RedisCache) Close() error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -111,6 +119,7 @@ func (f *RedisCache) startListener(ctx context.Context, topic string) (*listener
 				log.For(ctx).Error().Err(err).Str("topic", topic).Int("bytes", len(rmsg.Payload)).Msg("cache: error processing update")
 			} else {
 				log.For(ctx).Trace().Str("topic", topic).Int("bytes", len(rmsg.Payload)).Msg("cache: processed update")
+				f.notifier.notify(topic)
 			}
 		}
 	}(f.client, topic, ls)
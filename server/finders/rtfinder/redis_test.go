@@ -16,3 +16,13 @@ func TestRedisCache(t *testing.T) {
 	rtCache := NewRedisCache(client)
 	testCache(t, rtCache)
 }
+
+func TestRedisCache_Subscribe(t *testing.T) {
+	if a, ok := testutil.CheckTestRedisClient(); !ok {
+		t.Skip(a)
+		return
+	}
+	client := testutil.MustOpenTestRedisClient(t)
+	rtCache := NewRedisCache(client)
+	testCacheSubscribe(t, rtCache)
+}
@@ -2,23 +2,54 @@ package rtfinder
 
 import (
 	"context"
+	"sort"
 
 	"github.com/interline-io/log"
 	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-lib/tt"
 	"google.golang.org/protobuf/proto"
 )
 
 type Source struct {
-	feed         string
-	msg          *pb.FeedMessage
-	entityByTrip map[string]*pb.TripUpdate
-	alerts       []*pb.Alert
+	feed            string
+	msg             *pb.FeedMessage
+	entityByTrip    map[string]*pb.TripUpdate
+	entityByRun     map[tripRun]*pb.TripUpdate
+	alerts          []*pb.Alert
+	vehicleByTrip   map[string]*pb.VehiclePosition
+	vehicleByID     map[string]*pb.VehiclePosition
+	vehiclesByRoute map[string][]*pb.VehiclePosition
+	vehiclesNoRoute []*pb.VehiclePosition // vehicles with a trip_id but no route_id
+	tripMods        map[string]tripModification
+	tripModsByStop  map[string][]string
+	shapes          map[string]*pb.Shape
+	stops           map[string]*pb.Stop
+}
+
+// tripRun identifies a single run of a frequency-based trip by trip_id and start_time
+type tripRun struct {
+	tripID    string
+	startTime int
+}
+
+// tripModification is a TripModifications entity as selected for a single trip
+type tripModification struct {
+	mods    *pb.TripModifications
+	shapeID string
 }
 
 func NewSource(feed string) (*Source, error) {
 	f := Source{
-		feed:         feed,
-		entityByTrip: map[string]*pb.TripUpdate{},
+		feed:            feed,
+		entityByTrip:    map[string]*pb.TripUpdate{},
+		entityByRun:     map[tripRun]*pb.TripUpdate{},
+		vehicleByTrip:   map[string]*pb.VehiclePosition{},
+		vehicleByID:     map[string]*pb.VehiclePosition{},
+		vehiclesByRoute: map[string][]*pb.VehiclePosition{},
+		tripMods:        map[string]tripModification{},
+		tripModsByStop:  map[string][]string{},
+		shapes:          map[string]*pb.Shape{},
+		stops:           map[string]*pb.Stop{},
 	}
 	return &f, nil
 }
@@ -35,11 +66,67 @@ func (f *Source) GetTrip(tid string) (*pb.TripUpdate, bool) {
 	return nil, false
 }
 
+// GetTripRun returns the TripUpdate for a trip run, matching both trip_id and start_time
+func (f *Source) GetTripRun(tid string, startTime int) (*pb.TripUpdate, bool) {
+	a, ok := f.entityByRun[tripRun{tripID: tid, startTime: startTime}]
+	return a, ok
+}
+
+func (f *Source) GetVehiclePositionForTrip(tid string) (*pb.VehiclePosition, bool) {
+	a, ok := f.vehicleByTrip[tid]
+	return a, ok
+}
+
+func (f *Source) GetVehiclePosition(vid string) (*pb.VehiclePosition, bool) {
+	a, ok := f.vehicleByID[vid]
+	return a, ok
+}
+
+func (f *Source) GetVehiclePositionsForRoute(rid string) []*pb.VehiclePosition {
+	return f.vehiclesByRoute[rid]
+}
+
+// GetVehiclePositionsWithoutRoute returns vehicles that specify a trip_id but not a route_id.
+// The route must be resolved from the static trip.
+func (f *Source) GetVehiclePositionsWithoutRoute() []*pb.VehiclePosition {
+	return f.vehiclesNoRoute
+}
+
+// GetTripModifications returns the modifications selected for a trip, and the replacement shape_id, if any.
+func (f *Source) GetTripModifications(tid string) (*pb.TripModifications, string, bool) {
+	a, ok := f.tripMods[tid]
+	return a.mods, a.shapeID, ok
+}
+
+// GetTripModificationsForStop returns the trips that have a modification using this stop as a replacement stop.
+func (f *Source) GetTripModificationsForStop(sid string) []string {
+	return f.tripModsByStop[sid]
+}
+
+func (f *Source) GetShape(shapeID string) (*pb.Shape, bool) {
+	a, ok := f.shapes[shapeID]
+	return a, ok
+}
+
+func (f *Source) GetStop(stopID string) (*pb.Stop, bool) {
+	a, ok := f.stops[stopID]
+	return a, ok
+}
+
 func (f *Source) processMessage(ctx context.Context, rtmsg *pb.FeedMessage) error {
 	f.msg = rtmsg
 	defaultTimestamp := rtmsg.GetHeader().GetTimestamp()
 	a := map[string]*pb.TripUpdate{}
+	byRun := map[tripRun]*pb.TripUpdate{}
 	var alerts []*pb.Alert
+	vehicleByTrip := map[string]*pb.VehiclePosition{}
+	vehicleByID := map[string]*pb.VehiclePosition{}
+	vehiclesByRoute := map[string][]*pb.VehiclePosition{}
+	var vehiclesNoRoute []*pb.VehiclePosition
+	tripMods := map[string]tripModification{}
+	tripModsByStop := map[string][]string{}
+	shapes := map[string]*pb.Shape{}
+	stops := map[string]*pb.Stop{}
 	for _, ent := range rtmsg.Entity {
 		if v := ent.TripUpdate; v != nil {
 			// Set default timestamp
@@ -48,15 +135,86 @@ func (f *Source) processMessage(ctx context.Context, rtmsg *pb.FeedMessage) erro
 			}
 			tid := v.GetTrip().GetTripId()
 			a[tid] = v
+			// Index frequency-based trip runs by start_time
+			if st := v.GetTrip().GetStartTime(); st != "" {
+				if startTime, err := tt.NewSecondsFromString(st); err == nil && startTime.Valid {
+					byRun[tripRun{tripID: tid, startTime: startTime.Int()}] = v
+				}
+			}
 		}
 		if v := ent.Alert; v != nil {
 			alerts = append(alerts, v)
 		}
-		// todo: vehicle positions...
+		if v := ent.Vehicle; v != nil {
+			// Set default timestamp
+			if v.Timestamp == nil {
+				v.Timestamp = &defaultTimestamp
+			}
+			// Use entity id if no vehicle id is provided
+			vid := v.GetVehicle().GetId()
+			if vid == "" {
+				vid = ent.GetId()
+			}
+			// Keep only the most recent position for each vehicle
+			if prev, ok := vehicleByID[vid]; ok && prev.GetTimestamp() > v.GetTimestamp() {
+				continue
+			}
+			vehicleByID[vid] = v
+		}
+		if v := ent.TripModifications; v != nil {
+			for _, sel := range v.SelectedTrips {
+				for _, tid := range sel.TripIds {
+					tripMods[tid] = tripModification{mods: v, shapeID: sel.GetShapeId()}
+					for _, mod := range v.Modifications {
+						for _, rs := range mod.ReplacementStops {
+							if sid := rs.GetStopId(); sid != "" {
+								tripModsByStop[sid] = append(tripModsByStop[sid], tid)
+							}
+						}
+					}
+				}
+			}
+		}
+		if v := ent.Shape; v != nil {
+			shapes[v.GetShapeId()] = v
+		}
+		if v := ent.Stop; v != nil {
+			stops[v.GetStopId()] = v
+		}
+	}
+	// Build trip and route indexes from deduplicated vehicles
+	for _, v := range vehicleByID {
+		if tid := v.GetTrip().GetTripId(); tid != "" {
+			if prev, ok := vehicleByTrip[tid]; !ok || prev.GetTimestamp() < v.GetTimestamp() {
+				vehicleByTrip[tid] = v
+			}
+		}
+		if rid := v.GetTrip().GetRouteId(); rid != "" {
+			vehiclesByRoute[rid] = append(vehiclesByRoute[rid], v)
+		} else if v.GetTrip().GetTripId() != "" {
+			vehiclesNoRoute = append(vehiclesNoRoute, v)
+		}
+	}
+	for _, vs := range vehiclesByRoute {
+		sort.Slice(vs, func(i, j int) bool {
+			return vs[i].GetVehicle().GetId() < vs[j].GetVehicle().GetId()
+		})
 	}
-	log.For(ctx).Trace().Str("feed_id", f.feed).Int("trip_updates", len(a)).Int("alerts", len(alerts)).Msg("rtsource: processed data")
+	sort.Slice(vehiclesNoRoute, func(i, j int) bool {
+		return vehiclesNoRoute[i].GetVehicle().GetId() < vehiclesNoRoute[j].GetVehicle().GetId()
+	})
+	log.For(ctx).Trace().Str("feed_id", f.feed).Int("trip_updates", len(a)).Int("alerts", len(alerts)).Int("vehicle_positions", len(vehicleByID)).Int("trip_modifications", len(tripMods)).Msg("rtsource: processed data")
 	f.entityByTrip = a
+	f.entityByRun = byRun
 	f.alerts = alerts
+	f.vehicleByTrip = vehicleByTrip
+	f.vehicleByID = vehicleByID
+	f.vehiclesByRoute = vehiclesByRoute
+	f.vehiclesNoRoute = vehiclesNoRoute
+	f.tripMods = tripMods
+	f.tripModsByStop = tripModsByStop
+	f.shapes = shapes
+	f.stops = stops
 	return nil
 }
 
@@ -0,0 +1,127 @@
+package rtfinder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/interline-io/transitland-lib/rt"
+	"github.com/interline-io/transitland-server/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource_VehiclePositions(t *testing.T) {
+	msg, err := rt.ReadFile(testdata.Path("server", "rt", "CT-vehicle-positions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewSource("CT")
+	if err := s.processMessage(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("by trip", func(t *testing.T) {
+		vp, ok := s.GetVehiclePositionForTrip("101")
+		if assert.True(t, ok) {
+			assert.Equal(t, "v101", vp.GetVehicle().GetId())
+			assert.Equal(t, "70241", vp.GetStopId())
+		}
+		_, ok = s.GetVehiclePositionForTrip("unknown")
+		assert.False(t, ok)
+	})
+	t.Run("by vehicle uses latest position", func(t *testing.T) {
+		vp, ok := s.GetVehiclePosition("v305")
+		if assert.True(t, ok) {
+			assert.Equal(t, uint64(1527699595), vp.GetTimestamp())
+		}
+	})
+	t.Run("by route", func(t *testing.T) {
+		assert.Equal(t, 1, len(s.GetVehiclePositionsForRoute("Lo-130")))
+		assert.Equal(t, 1, len(s.GetVehiclePositionsForRoute("Bu-130")))
+		assert.Equal(t, 0, len(s.GetVehiclePositionsForRoute("Li-130")))
+	})
+	t.Run("without route", func(t *testing.T) {
+		vps := s.GetVehiclePositionsWithoutRoute()
+		if assert.Equal(t, 1, len(vps)) {
+			assert.Equal(t, "v103", vps[0].GetVehicle().GetId())
+		}
+		vp, ok := s.GetVehiclePositionForTrip("103")
+		if assert.True(t, ok) {
+			assert.Equal(t, "v103", vp.GetVehicle().GetId())
+		}
+	})
+}
+
+func TestSource_TripModifications(t *testing.T) {
+	msg, err := rt.ReadFile(testdata.Path("server", "rt", "BA-trip-modifications.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewSource("BA")
+	if err := s.processMessage(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("by trip", func(t *testing.T) {
+		mods, shapeID, ok := s.GetTripModifications("1031527WKDY")
+		if assert.True(t, ok) {
+			assert.Equal(t, "detour-shape-1", shapeID)
+			assert.Equal(t, []string{"20180530"}, mods.GetServiceDates())
+			assert.Equal(t, 1, len(mods.GetModifications()))
+		}
+		_, _, ok = s.GetTripModifications("unknown")
+		assert.False(t, ok)
+	})
+	t.Run("by replacement stop", func(t *testing.T) {
+		assert.Equal(t, []string{"1031527WKDY"}, s.GetTripModificationsForStop("OAKL"))
+		assert.Equal(t, []string{"1031527WKDY"}, s.GetTripModificationsForStop("detour-stop-1"))
+		assert.Equal(t, 0, len(s.GetTripModificationsForStop("COLS")))
+	})
+	t.Run("shape", func(t *testing.T) {
+		shape, ok := s.GetShape("detour-shape-1")
+		if assert.True(t, ok) {
+			m := makeShape(shape)
+			if assert.NotNil(t, m) {
+				assert.Equal(t, 4, len(m.Geometry.ToPoints()))
+			}
+		}
+	})
+	t.Run("stop", func(t *testing.T) {
+		stop, ok := s.GetStop("detour-stop-1")
+		if assert.True(t, ok) {
+			m := makeStop(stop, 1)
+			assert.Equal(t, "Detour stop", m.StopName.Val)
+			assert.InDelta(t, -122.215, m.Geometry.X(), 0.0001)
+			assert.InDelta(t, 37.74, m.Geometry.Y(), 0.0001)
+		}
+	})
+}
+
+func TestSource_TripRuns(t *testing.T) {
+	msg, err := rt.ReadFile(testdata.Path("server", "rt", "EX-frequencies.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewSource("EX")
+	if err := s.processMessage(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		name      string
+		tripID    string
+		startTime int
+		expect    int32
+		ok        bool
+	}{
+		{"first run", "STBA", 6*3600 + 30*60, 120, true},
+		{"second run", "STBA", 7 * 3600, 300, true},
+		{"no update for run", "STBA", 6 * 3600, 0, false},
+		{"unknown trip", "unknown", 7 * 3600, 0, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tu, ok := s.GetTripRun(tc.tripID, tc.startTime)
+			assert.Equal(t, tc.ok, ok)
+			if ok {
+				assert.Equal(t, tc.expect, tu.StopTimeUpdate[0].GetDeparture().GetDelay())
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package gql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// ALERTS
+
+func (r *queryResolver) Alerts(ctx context.Context, limit *int, where *model.AlertFilter) ([]*model.Alert, error) {
+	ctx = addMetric(ctx, "alerts")
+	if where == nil {
+		where = &model.AlertFilter{}
+	}
+	feeds, err := alertFeeds(ctx, where)
+	if err != nil {
+		return nil, err
+	}
+	alerts := model.ForContext(ctx).RTFinder.FindAlertsForFeeds(ctx, feeds, nil, where.Active)
+	return filterAlerts(ctx, alerts, limit, where)
+}
+
+// alertFeeds returns the realtime feeds that should be checked for alerts.
+// If no feeds or operators are specified, all feeds that provide alerts are returned.
+func alertFeeds(ctx context.Context, where *model.AlertFilter) ([]string, error) {
+	cfg := model.ForContext(ctx)
+	if err := checkGeo(cfg.MaxRadius, nil, where.Bbox); err != nil {
+		return nil, err
+	}
+	if len(where.FeedOnestopIds) == 0 && len(where.OperatorOnestopIds) == 0 {
+		urlType := model.FeedSourceURLTypesRealtimeAlerts
+		ents, err := cfg.Finder.FindFeeds(ctx, nil, nil, nil, &model.FeedFilter{SourceURL: &model.FeedSourceURL{Type: &urlType}})
+		if err != nil {
+			return nil, err
+		}
+		var feeds []string
+		for _, ent := range ents {
+			feeds = append(feeds, ent.FeedID)
+		}
+		return feeds, nil
+	}
+	// Only check feeds that are visible to the caller
+	var feeds []string
+	for _, osid := range where.FeedOnestopIds {
+		ents, err := cfg.Finder.FindFeeds(ctx, ptr(1), nil, nil, &model.FeedFilter{OnestopID: &osid})
+		if err != nil {
+			return nil, err
+		}
+		for _, ent := range ents {
+			feeds = append(feeds, ent.FeedID)
+		}
+	}
+	// Realtime data may be published under any feed associated with an operator
+	for _, osid := range where.OperatorOnestopIds {
+		ents, err := LoaderFor(ctx).FeedsByOperatorOnestopIDs.Load(ctx, feedLoaderParam{OperatorOnestopID: osid, Limit: ptr(MAXLIMIT)})()
+		if err != nil {
+			return nil, err
+		}
+		for _, ent := range ents {
+			feeds = append(feeds, ent.FeedID)
+		}
+	}
+	return feeds, nil
+}
+
+// filterAlerts applies the cause, effect, severity and bbox filters.
+func filterAlerts(ctx context.Context, alerts []*model.Alert, limit *int, where *model.AlertFilter) ([]*model.Alert, error) {
+	lim := checkLimit(limit)
+	ret := []*model.Alert{}
+	for _, alert := range alerts {
+		if !checkAlertValue(where.Cause, alert.Cause) || !checkAlertValue(where.Effect, alert.Effect) || !checkAlertValue(where.SeverityLevel, alert.SeverityLevel) {
+			continue
+		}
+		if where.Bbox != nil {
+			ok, err := checkAlertBbox(ctx, alert, where.Bbox)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		ret = append(ret, alert)
+		if len(ret) >= *lim {
+			break
+		}
+	}
+	return ret, nil
+}
+
+func checkAlertValue(check *string, value *string) bool {
+	if check == nil {
+		return true
+	}
+	return value != nil && strings.EqualFold(*check, *value)
+}
+
+// checkAlertBbox checks if any informed entity is within the bounding box
+func checkAlertBbox(ctx context.Context, alert *model.Alert, bbox *model.BoundingBox) (bool, error) {
+	ldr := LoaderFor(ctx)
+	for _, ent := range alert.InformedEntity {
+		routeID := ent.RouteID
+		if routeID == nil && ent.Trip != nil {
+			routeID = ent.Trip.RouteID
+		}
+		for _, fvid := range ent.FeedVersionIDs {
+			var found int
+			if ent.StopID != nil {
+				stops, err := ldr.StopsByFeedVersionIDs.Load(ctx, stopLoaderParam{FeedVersionID: fvid, Limit: ptr(1), Where: &model.StopFilter{StopID: ent.StopID, Bbox: bbox}})()
+				if err != nil {
+					return false, err
+				}
+				found += len(stops)
+			} else if routeID != nil {
+				routes, err := ldr.RoutesByFeedVersionIDs.Load(ctx, routeLoaderParam{FeedVersionID: fvid, Limit: ptr(1), Where: &model.RouteFilter{RouteID: routeID, Bbox: bbox}})()
+				if err != nil {
+					return false, err
+				}
+				found += len(routes)
+			} else if ent.Trip != nil && ent.Trip.TripID != nil {
+				trips, err := ldr.TripsByFeedVersionIDs.Load(ctx, tripLoaderParam{FeedVersionID: fvid, Limit: ptr(1), Where: &model.TripFilter{TripID: ent.Trip.TripID}})()
+				if err != nil {
+					return false, err
+				}
+				for _, trip := range trips {
+					stops, err := ldr.StopsByRouteIDs.Load(ctx, stopLoaderParam{RouteID: trip.RouteID.Int(), Limit: ptr(1), Where: &model.StopFilter{Bbox: bbox}})()
+					if err != nil {
+						return false, err
+					}
+					found += len(stops)
+				}
+			} else if ent.AgencyID != nil {
+				agencies, err := ldr.AgenciesByFeedVersionIDs.Load(ctx, agencyLoaderParam{FeedVersionID: fvid, Limit: ptr(1), Where: &model.AgencyFilter{AgencyID: ent.AgencyID, Bbox: bbox}})()
+				if err != nil {
+					return false, err
+				}
+				found += len(agencies)
+			}
+			if found > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// RT ENTITY SELECTOR
+
+type rtEntitySelectorResolver struct{ *Resolver }
+
+func (r *rtEntitySelectorResolver) Agency(ctx context.Context, obj *model.RTEntitySelector) (*model.Agency, error) {
+	if obj.AgencyID == nil {
+		return nil, nil
+	}
+	return firstInFeedVersions(obj.FeedVersionIDs, func(fvid int) ([]*model.Agency, error) {
+		return LoaderFor(ctx).AgenciesByFeedVersionIDs.Load(ctx, agencyLoaderParam{FeedVersionID: fvid, Limit: ptr(1), Where: &model.AgencyFilter{AgencyID: obj.AgencyID}})()
+	})
+}
+
+func (r *rtEntitySelectorResolver) Route(ctx context.Context, obj *model.RTEntitySelector) (*model.Route, error) {
+	routeID := obj.RouteID
+	if routeID == nil && obj.Trip != nil {
+		routeID = obj.Trip.RouteID
+	}
+	if routeID == nil {
+		return nil, nil
+	}
+	return firstInFeedVersions(obj.FeedVersionIDs, func(fvid int) ([]*model.Route, error) {
+		return LoaderFor(ctx).RoutesByFeedVersionIDs.Load(ctx, routeLoaderParam{FeedVersionID: fvid, Limit: ptr(1), Where: &model.RouteFilter{RouteID: routeID}})()
+	})
+}
+
+func (r *rtEntitySelectorResolver) Stop(ctx context.Context, obj *model.RTEntitySelector) (*model.Stop, error) {
+	if obj.StopID == nil {
+		return nil, nil
+	}
+	return firstInFeedVersions(obj.FeedVersionIDs, func(fvid int) ([]*model.Stop, error) {
+		return LoaderFor(ctx).StopsByFeedVersionIDs.Load(ctx, stopLoaderParam{FeedVersionID: fvid, Limit: ptr(1), Where: &model.StopFilter{StopID: obj.StopID}})()
+	})
+}
+
+func (r *rtEntitySelectorResolver) GtfsTrip(ctx context.Context, obj *model.RTEntitySelector) (*model.Trip, error) {
+	if obj.Trip == nil || obj.Trip.TripID == nil {
+		return nil, nil
+	}
+	return firstInFeedVersions(obj.FeedVersionIDs, func(fvid int) ([]*model.Trip, error) {
+		return LoaderFor(ctx).TripsByFeedVersionIDs.Load(ctx, tripLoaderParam{FeedVersionID: fvid, Limit: ptr(1), Where: &model.TripFilter{TripID: obj.Trip.TripID}})()
+	})
+}
+
+// firstInFeedVersions returns the first entity found, checking each feed version in order
+func firstInFeedVersions[T any](fvids []int, fn func(int) ([]*T, error)) (*T, error) {
+	for _, fvid := range fvids {
+		ents, err := fn(fvid)
+		if err != nil {
+			return nil, err
+		}
+		if len(ents) > 0 {
+			return ents[0], nil
+		}
+	}
+	return nil, nil
+}
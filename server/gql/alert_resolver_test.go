@@ -0,0 +1,154 @@
+package gql
+
+import (
+	"testing"
+
+	"github.com/interline-io/transitland-server/internal/testconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestAlertResolver(t *testing.T) {
+	rtfiles := []testconfig.RTJsonFile{
+		{Feed: "BA", Ftype: "realtime_alerts", Fname: "BA-alerts.json"},
+	}
+	q := `query($where:AlertFilter) {
+		alerts(where:$where) {
+			feed_onestop_id
+			cause
+			header_text { text }
+			informed_entity {
+				agency_id
+				route_id
+				stop_id
+				trip { trip_id }
+				agency { agency_id }
+				route { route_id }
+				stop { stop_id }
+				gtfs_trip { trip_id }
+			}
+		}
+	}`
+	allAlerts := []string{
+		"Test trip header", "Test trip header - active",
+		"Test agency header", "Test agency header - active",
+		"Test stop header", "Test stop header - active",
+		"Test route header", "Test route header - active",
+	}
+	tcs := []rtTestCase{
+		{
+			name:    "feed_onestop_ids",
+			query:   q,
+			vars:    hw{"where": hw{"feed_onestop_ids": []string{"BA"}}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t, allAlerts, astr(gjson.Get(jj, "alerts.#.header_text.0.text").Array()))
+				assert.ElementsMatch(t, []string{"BA"}, uniqueStr(astr(gjson.Get(jj, "alerts.#.feed_onestop_id").Array())))
+			},
+		},
+		{
+			name:    "feed_onestop_ids not visible",
+			query:   q,
+			vars:    hw{"where": hw{"feed_onestop_ids": []string{"unknown-feed"}}},
+			rtfiles: []testconfig.RTJsonFile{{Feed: "unknown-feed", Ftype: "realtime_alerts", Fname: "BA-alerts.json"}},
+			cb: func(t *testing.T, jj string) {
+				assert.Equal(t, 0, len(gjson.Get(jj, "alerts").Array()))
+			},
+		},
+		{
+			name:    "operator_onestop_ids",
+			query:   q,
+			vars:    hw{"where": hw{"operator_onestop_ids": []string{"o-9q9-bayarearapidtransit"}}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t, allAlerts, astr(gjson.Get(jj, "alerts.#.header_text.0.text").Array()))
+			},
+		},
+		{
+			name:    "operator_onestop_ids no alerts",
+			query:   q,
+			vars:    hw{"where": hw{"operator_onestop_ids": []string{"o-9q9-caltrain"}}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.Equal(t, 0, len(gjson.Get(jj, "alerts").Array()))
+			},
+		},
+		{
+			name:    "active",
+			query:   q,
+			vars:    hw{"where": hw{"feed_onestop_ids": []string{"BA"}, "active": true}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t,
+					[]string{"Test trip header - active", "Test agency header - active", "Test stop header - active", "Test route header - active"},
+					astr(gjson.Get(jj, "alerts.#.header_text.0.text").Array()),
+				)
+			},
+		},
+		{
+			name:    "cause",
+			query:   q,
+			vars:    hw{"where": hw{"feed_onestop_ids": []string{"BA"}, "cause": "UNKNOWN_CAUSE"}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.Equal(t, len(allAlerts), len(gjson.Get(jj, "alerts").Array()))
+			},
+		},
+		{
+			name:    "cause no match",
+			query:   q,
+			vars:    hw{"where": hw{"feed_onestop_ids": []string{"BA"}, "cause": "STRIKE"}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.Equal(t, 0, len(gjson.Get(jj, "alerts").Array()))
+			},
+		},
+		{
+			name:    "bbox",
+			query:   q,
+			vars:    hw{"where": hw{"feed_onestop_ids": []string{"BA"}, "bbox": hw{"min_lon": -122.2260, "min_lat": 37.7720, "max_lon": -122.2220, "max_lat": 37.7760}}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t, allAlerts, astr(gjson.Get(jj, "alerts.#.header_text.0.text").Array()))
+			},
+		},
+		{
+			name:    "bbox no match",
+			query:   q,
+			vars:    hw{"where": hw{"feed_onestop_ids": []string{"BA"}, "bbox": hw{"min_lon": -121.91, "min_lat": 37.32, "max_lon": -121.89, "max_lat": 37.34}}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.Equal(t, 0, len(gjson.Get(jj, "alerts").Array()))
+			},
+		},
+		{
+			name:    "informed entities",
+			query:   q,
+			vars:    hw{"where": hw{"feed_onestop_ids": []string{"BA"}}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				ents := gjson.Get(jj, "alerts.#.informed_entity").Array()
+				assert.Equal(t, 8, len(ents))
+				assert.ElementsMatch(t, []string{"BART"}, uniqueStr(astr(gjson.Get(jj, "alerts.#.informed_entity.#.agency.agency_id|@flatten").Array())))
+				assert.ElementsMatch(t, []string{"05"}, uniqueStr(astr(gjson.Get(jj, "alerts.#.informed_entity.#.route.route_id|@flatten").Array())))
+				assert.ElementsMatch(t, []string{"FTVL"}, uniqueStr(astr(gjson.Get(jj, "alerts.#.informed_entity.#.stop.stop_id|@flatten").Array())))
+				assert.ElementsMatch(t, []string{"1031527WKDY"}, uniqueStr(astr(gjson.Get(jj, "alerts.#.informed_entity.#.gtfs_trip.trip_id|@flatten").Array())))
+			},
+		},
+	}
+	for _, tc := range tcs {
+		testRt(t, tc)
+	}
+}
+
+func uniqueStr(v []string) []string {
+	seen := map[string]bool{}
+	var ret []string
+	for _, s := range v {
+		if !seen[s] {
+			seen[s] = true
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
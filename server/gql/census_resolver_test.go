@@ -263,6 +263,32 @@ func TestCensusResolver(t *testing.T) {
 				)
 			},
 		},
+		{
+			name:  "dataset intersection areas within multipolygon feature - tract",
+			query: `query($feature:MultiPolygon) { census_datasets(where:{name:"tiger2024"}) {name geographies(where:{layer: "tract", location:{within_multipolygon:$feature}}) { name geoid geometry_area intersection_geometry intersection_area }} }`,
+			vars: hw{"feature": hw{"type": "MultiPolygon", "coordinates": [][][][]float64{{{
+				{-122.27463277683867, 37.805635064682264},
+				{-122.28006473340696, 37.80461858815316},
+				{-122.27406099193678, 37.801456127261474},
+				{-122.2754189810789, 37.79671218203016},
+				{-122.27041586318674, 37.799648945955155},
+				{-122.26398328303992, 37.79863238703946},
+				{-122.26791430424078, 37.80247264731531},
+				{-122.26441212171653, 37.80693387544258},
+				{-122.269558185834, 37.806199767818995},
+				{-122.27313184147101, 37.81066077079923},
+				{-122.27463277683867, 37.805635064682264},
+			}}}}},
+			f: func(t *testing.T, jj string) {
+				testIntersectionArea(
+					t,
+					gjson.Get(jj, "census_datasets.0.geographies").Array(),
+					11,
+					4755614.60179,
+					829385.7985148486,
+				)
+			},
+		},
 		{
 			name:  "dataset intersection areas within feature - county",
 			query: `query($feature:Polygon) { census_datasets(where:{name:"tiger2024"}) {name geographies(where:{layer: "county", location:{within:$feature}}) { name geoid geometry_area intersection_geometry intersection_area }} }`,
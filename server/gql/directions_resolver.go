@@ -2,6 +2,7 @@ package gql
 
 import (
 	"context"
+	"time"
 
 	"github.com/interline-io/transitland-server/server/directions"
 	"github.com/interline-io/transitland-server/server/model"
@@ -13,3 +14,46 @@ type directionsResolver struct{ *Resolver }
 func (r *directionsResolver) Directions(ctx context.Context, where model.DirectionRequest) (*model.Directions, error) {
 	return directions.HandleRequest(ctx, "", where)
 }
+
+func (r *directionsResolver) TravelTimeMatrix(ctx context.Context, origins []*model.WaypointInput, destinations []*model.WaypointInput, mode *model.StepMode, departAt *time.Time) (*model.TravelTimeMatrix, error) {
+	req := directions.MatrixRequest{
+		Origins:      origins,
+		Destinations: destinations,
+		DepartAt:     departAt,
+	}
+	if mode != nil {
+		req.Mode = *mode
+	}
+	return directions.HandleMatrixRequest(ctx, "", req)
+}
+
+func (r *directionsResolver) Isochrones(ctx context.Context, origin model.WaypointInput, mode *model.StepMode, departAt *time.Time, cutoffs []int) (*model.Isochrones, error) {
+	req := directions.IsochroneRequest{
+		Origin:   &origin,
+		DepartAt: departAt,
+		Cutoffs:  cutoffs,
+	}
+	if mode != nil {
+		req.Mode = *mode
+	}
+	return directions.HandleIsochroneRequest(ctx, "", req)
+}
+
+type isochroneResolver struct{ *Resolver }
+
+// CensusGeographies returns geographies in a census dataset that intersect the isochrone
+func (r *isochroneResolver) CensusGeographies(ctx context.Context, obj *model.Isochrone, dataset string, layer *string, limit *int) ([]*model.CensusGeography, error) {
+	if !obj.Geometry.Valid {
+		return nil, nil
+	}
+	datasets, err := model.ForContext(ctx).Finder.FindCensusDatasets(ctx, nil, nil, nil, &model.CensusDatasetFilter{Name: &dataset})
+	if err != nil || len(datasets) == 0 {
+		return nil, err
+	}
+	geom := obj.Geometry
+	where := &model.CensusDatasetGeographyFilter{
+		Layer:    layer,
+		Location: &model.CensusDatasetGeographyLocationFilter{WithinMultipolygon: &geom},
+	}
+	return LoaderFor(ctx).CensusGeographiesByDatasetIDs.Load(ctx, censusDatasetGeographyLoaderParam{DatasetID: datasets[0].ID, Limit: limit, Where: where})()
+}
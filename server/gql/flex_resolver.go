@@ -0,0 +1,77 @@
+package gql
+
+import (
+	"context"
+	"time"
+
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+func (r *queryResolver) FlexService(ctx context.Context, limit *int, near model.PointRadius, at *time.Time) ([]*model.FlexService, error) {
+	cfg := model.ForContext(ctx)
+	ctx = addMetric(ctx, "flexService")
+	if err := checkGeo(cfg.MaxRadius, &near, nil); err != nil {
+		return nil, err
+	}
+	return cfg.Finder.FindFlexServices(ctx, checkLimit(limit), near, at)
+}
+
+// FLEX SERVICE
+
+type flexServiceResolver struct {
+	*Resolver
+}
+
+func (r *flexServiceResolver) Trip(ctx context.Context, obj *model.FlexService) (*model.Trip, error) {
+	return LoaderFor(ctx).TripsByIDs.Load(ctx, obj.TripID)()
+}
+
+func (r *flexServiceResolver) Location(ctx context.Context, obj *model.FlexService) (*model.Location, error) {
+	return loadLocation(ctx, obj.LocationID)
+}
+
+func (r *flexServiceResolver) LocationGroup(ctx context.Context, obj *model.FlexService) (*model.LocationGroup, error) {
+	return loadLocationGroup(ctx, obj.LocationGroupID)
+}
+
+func (r *flexServiceResolver) PickupBookingRule(ctx context.Context, obj *model.FlexService) (*model.BookingRule, error) {
+	return loadBookingRule(ctx, obj.PickupBookingRuleID)
+}
+
+func (r *flexServiceResolver) DropOffBookingRule(ctx context.Context, obj *model.FlexService) (*model.BookingRule, error) {
+	return loadBookingRule(ctx, obj.DropOffBookingRuleID)
+}
+
+// LOCATION GROUP
+
+type locationGroupResolver struct {
+	*Resolver
+}
+
+func (r *locationGroupResolver) Stops(ctx context.Context, obj *model.LocationGroup, limit *int) ([]*model.Stop, error) {
+	return LoaderFor(ctx).StopsByLocationGroupIDs.Load(ctx, stopLoaderParam{LocationGroupID: obj.ID, Limit: checkLimit(limit)})()
+}
+
+// helpers
+
+func loadLocation(ctx context.Context, id tt.Key) (*model.Location, error) {
+	if !id.Valid {
+		return nil, nil
+	}
+	return LoaderFor(ctx).LocationsByIDs.Load(ctx, id.Int())()
+}
+
+func loadLocationGroup(ctx context.Context, id tt.Key) (*model.LocationGroup, error) {
+	if !id.Valid {
+		return nil, nil
+	}
+	return LoaderFor(ctx).LocationGroupsByIDs.Load(ctx, id.Int())()
+}
+
+func loadBookingRule(ctx context.Context, id tt.Key) (*model.BookingRule, error) {
+	if !id.Valid {
+		return nil, nil
+	}
+	return LoaderFor(ctx).BookingRulesByIDs.Load(ctx, id.Int())()
+}
@@ -0,0 +1,140 @@
+package gql
+
+import (
+	"testing"
+
+	"github.com/interline-io/transitland-server/internal/testconfig"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// setupFlexTrip adds a HART SkyConnect trip with its own journey pattern, with a location group pickup at 8011/8012
+// and a location drop off around 8013. Other trips that share the pattern of trip 334572 are not changed.
+// The GTFS-Flex tables and stop_times columns are created by transitland-lib migrations; the test is skipped without them.
+func setupFlexTrip(t *testing.T, cfg model.Config) {
+	var hasFlex bool
+	if err := cfg.Finder.DBX().QueryRowx(`select to_regclass('gtfs_booking_rules') is not null and exists (select 1 from information_schema.columns where table_name = 'gtfs_stop_times' and column_name = 'location_group_id')`).Scan(&hasFlex); err != nil {
+		t.Fatal(err)
+	}
+	if !hasFlex {
+		t.Skip("GTFS-Flex tables not found; update transitland-lib migrations")
+	}
+	const fvid = `(select fs.feed_version_id from feed_states fs join current_feeds cf on cf.id = fs.feed_id where cf.onestop_id = 'HA')`
+	stmts := []string{
+		`insert into gtfs_booking_rules(feed_version_id,booking_rule_id,booking_type,prior_notice_duration_min,message,phone_number)
+		select ` + fvid + `, 'flex-test-rule', 1, 30, 'Call to book', '555-0100'`,
+
+		`insert into gtfs_locations(feed_version_id,location_id,stop_name,geometry)
+		select s.feed_version_id, 'flex-test-zone', 'SkyConnect Terminal Zone', ST_Buffer(s.geometry, 500)
+		from gtfs_stops s where s.feed_version_id = ` + fvid + ` and s.stop_id = '8013'`,
+
+		`insert into gtfs_location_groups(feed_version_id,location_group_id,location_group_name)
+		select ` + fvid + `, 'flex-test-group', 'SkyConnect Parking'`,
+
+		`insert into gtfs_location_group_stops(feed_version_id,location_group_id,stop_id)
+		select s.feed_version_id, lg.id, s.id
+		from gtfs_stops s
+		join gtfs_location_groups lg on lg.feed_version_id = s.feed_version_id and lg.location_group_id = 'flex-test-group'
+		where s.feed_version_id = ` + fvid + ` and s.stop_id in ('8011', '8012')`,
+
+		`insert into gtfs_trips(feed_version_id,trip_id,trip_headsign,trip_short_name,direction_id,block_id,wheelchair_accessible,bikes_allowed,route_id,shape_id,stop_pattern_id,service_id,journey_pattern_id,journey_pattern_offset)
+		select feed_version_id, 'flex-test-trip', trip_headsign, trip_short_name, direction_id, block_id, wheelchair_accessible, bikes_allowed, route_id, shape_id, stop_pattern_id, service_id, 'flex-test-trip', 0
+		from gtfs_trips where feed_version_id = ` + fvid + ` and trip_id = '334572'`,
+
+		`insert into gtfs_stop_times(feed_version_id,trip_id,stop_id,arrival_time,departure_time,stop_sequence,pickup_type,drop_off_type,location_id,location_group_id,start_pickup_drop_off_window,end_pickup_drop_off_window,pickup_booking_rule_id,drop_off_booking_rule_id)
+		select
+			sts.feed_version_id,
+			ft.id,
+			sts.stop_id,
+			sts.arrival_time + t.journey_pattern_offset,
+			sts.departure_time + t.journey_pattern_offset,
+			sts.stop_sequence,
+			sts.pickup_type,
+			sts.drop_off_type,
+			case when sts.stop_sequence = 3 then (select id from gtfs_locations where location_id = 'flex-test-zone') end,
+			case when sts.stop_sequence = 1 then (select id from gtfs_location_groups where location_group_id = 'flex-test-group') end,
+			case sts.stop_sequence when 1 then 0 when 3 then 300 end,
+			case sts.stop_sequence when 1 then 3600 when 3 then 3900 end,
+			case when sts.stop_sequence = 1 then (select id from gtfs_booking_rules where booking_rule_id = 'flex-test-rule') end,
+			case when sts.stop_sequence = 3 then (select id from gtfs_booking_rules where booking_rule_id = 'flex-test-rule') end
+		from gtfs_trips t
+		join gtfs_trips jp on jp.trip_id = t.journey_pattern_id and jp.feed_version_id = t.feed_version_id
+		join gtfs_stop_times sts on sts.trip_id = jp.id and sts.feed_version_id = jp.feed_version_id
+		join gtfs_trips ft on ft.feed_version_id = t.feed_version_id and ft.trip_id = 'flex-test-trip'
+		where t.feed_version_id = ` + fvid + ` and t.trip_id = '334572'`,
+	}
+	for _, stmt := range stmts {
+		if _, err := cfg.Finder.DBX().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFlexServiceResolver(t *testing.T) {
+	testcases := []testcase{
+		{
+			name:         "location near point",
+			query:        `query {flex_service(near:{lon:-82.533327,lat:27.979960,radius:100}) {stop_sequence location {location_id stop_name} location_group {location_group_id}}}`,
+			selector:     "flex_service.#.location.location_id",
+			selectExpect: []string{"flex-test-zone"},
+		},
+		{
+			name:         "location group near point",
+			query:        `query {flex_service(near:{lon:-82.535557,lat:27.963827,radius:100}) {stop_sequence location_group {location_group_id stops {stop_id}}}}`,
+			selector:     "flex_service.#.location_group.location_group_id",
+			selectExpect: []string{"flex-test-group"},
+		},
+		{
+			name:         "location group stops",
+			query:        `query {flex_service(near:{lon:-82.535557,lat:27.963827,radius:100}) {location_group {stops {stop_id}}}}`,
+			selector:     "flex_service.0.location_group.stops.#.stop_id",
+			selectExpect: []string{"8011", "8012"},
+		},
+		{
+			name:   "booking rules",
+			query:  `query {flex_service(near:{lon:-82.535557,lat:27.963827,radius:100}) {start_pickup_drop_off_window end_pickup_drop_off_window pickup_booking_rule {booking_rule_id booking_type phone_number} drop_off_booking_rule {booking_rule_id}}}`,
+			expect: `{"flex_service":[{"start_pickup_drop_off_window":"00:00:00","end_pickup_drop_off_window":"01:00:00","pickup_booking_rule":{"booking_rule_id":"flex-test-rule","booking_type":1,"phone_number":"555-0100"},"drop_off_booking_rule":null}]}`,
+		},
+		{
+			name:         "none near point",
+			query:        `query {flex_service(near:{lon:-122.407974,lat:37.784471,radius:100}) {stop_sequence}}`,
+			selector:     "flex_service.#.stop_sequence",
+			selectExpect: []string{},
+		},
+		{
+			name:         "at time in window",
+			query:        `query {flex_service(near:{lon:-82.535557,lat:27.963827,radius:100}, at:"2018-05-30T04:30:00Z") {stop_sequence service_date}}`,
+			selector:     "flex_service.#.stop_sequence",
+			selectExpect: []string{"1"},
+		},
+		{
+			name:         "at time outside window",
+			query:        `query {flex_service(near:{lon:-82.535557,lat:27.963827,radius:100}, at:"2018-05-30T06:30:00Z") {stop_sequence}}`,
+			selector:     "flex_service.#.stop_sequence",
+			selectExpect: []string{},
+		},
+		{
+			name:         "at time without service",
+			query:        `query {flex_service(near:{lon:-82.535557,lat:27.963827,radius:100}, at:"2018-06-02T04:30:00Z") {stop_sequence}}`,
+			selector:     "flex_service.#.stop_sequence",
+			selectExpect: []string{},
+		},
+		{
+			name:   "trip booking rule",
+			query:  `query {trips(where:{feed_onestop_id:"HA", trip_id:"flex-test-trip"}) {booking_rule {booking_rule_id prior_notice_duration_min message}}}`,
+			expect: `{"trips":[{"booking_rule":{"booking_rule_id":"flex-test-rule","prior_notice_duration_min":30,"message":"Call to book"}}]}`,
+		},
+		{
+			name:  "stop time windows",
+			query: `query {trips(where:{feed_onestop_id:"HA", trip_id:"flex-test-trip"}) {stop_times {stop_sequence stop {stop_id} start_pickup_drop_off_window end_pickup_drop_off_window location {location_id} location_group {location_group_id} pickup_booking_rule {booking_rule_id} drop_off_booking_rule {booking_rule_id}}}}`,
+			expect: `{"trips":[{"stop_times":[
+				{"stop_sequence":1,"stop":{"stop_id":"8011"},"start_pickup_drop_off_window":"00:00:00","end_pickup_drop_off_window":"01:00:00","location":null,"location_group":{"location_group_id":"flex-test-group"},"pickup_booking_rule":{"booking_rule_id":"flex-test-rule"},"drop_off_booking_rule":null},
+				{"stop_sequence":2,"stop":{"stop_id":"8012"},"start_pickup_drop_off_window":null,"end_pickup_drop_off_window":null,"location":null,"location_group":null,"pickup_booking_rule":null,"drop_off_booking_rule":null},
+				{"stop_sequence":3,"stop":{"stop_id":"8013"},"start_pickup_drop_off_window":"00:05:00","end_pickup_drop_off_window":"01:05:00","location":{"location_id":"flex-test-zone"},"location_group":null,"pickup_booking_rule":null,"drop_off_booking_rule":{"booking_rule_id":"flex-test-rule"}}
+			]}]}`,
+		},
+	}
+	testconfig.ConfigTxRollback(t, testconfig.Options{WhenUtc: DEFAULT_WHEN}, func(cfg model.Config) {
+		setupFlexTrip(t, cfg)
+		queryTestcases(t, newTestClientWithConfig(cfg), testcases)
+	})
+}
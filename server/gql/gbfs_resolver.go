@@ -2,14 +2,138 @@ package gql
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-lib/tt"
 
 	"github.com/interline-io/transitland-server/server/model"
 )
 
 func (r *queryResolver) Bikes(ctx context.Context, limit *int, where *model.GbfsBikeRequest) ([]*model.GbfsFreeBikeStatus, error) {
-	return model.ForContext(ctx).GbfsFinder.FindBikes(ctx, checkLimit(limit), where)
+	cfg := model.ForContext(ctx)
+	if where != nil {
+		if err := checkGeo(cfg.MaxRadius, where.Near, where.Bbox); err != nil {
+			return nil, err
+		}
+	}
+	return cfg.GbfsFinder.FindBikes(ctx, checkLimit(limit), where)
 }
 
 func (r *queryResolver) Docks(ctx context.Context, limit *int, where *model.GbfsDockRequest) ([]*model.GbfsStationInformation, error) {
-	return model.ForContext(ctx).GbfsFinder.FindDocks(ctx, checkLimit(limit), where)
+	cfg := model.ForContext(ctx)
+	if where != nil {
+		if err := checkGeo(cfg.MaxRadius, where.Near, where.Bbox); err != nil {
+			return nil, err
+		}
+	}
+	return cfg.GbfsFinder.FindDocks(ctx, checkLimit(limit), where)
+}
+
+const (
+	maxGeofencePathPoints = 1_000
+	maxGeofencePathLength = 100_000 // meters
+)
+
+func (r *queryResolver) GbfsGeofenceCheck(ctx context.Context, point *tt.Point, line *tt.LineString, systemID *string, vehicleTypeID *string, at *time.Time) ([]*model.GbfsGeofenceCheck, error) {
+	cfg := model.ForContext(ctx)
+	where := model.GbfsGeofenceCheckRequest{
+		SystemID:      systemID,
+		VehicleTypeID: vehicleTypeID,
+		At:            time.Now().In(time.UTC),
+	}
+	if cfg.Clock != nil {
+		where.At = cfg.Clock.Now()
+	}
+	if at != nil {
+		where.At = *at
+	}
+	switch {
+	case point != nil && line != nil:
+		return nil, errors.New("only one of point or line may be provided")
+	case point != nil && point.Valid:
+		where.Path = []tlxy.Point{point.ToPoint()}
+	case line != nil && line.Valid:
+		where.Path = line.ToPoints()
+	default:
+		return nil, errors.New("point or line is required")
+	}
+	if len(where.Path) > maxGeofencePathPoints {
+		return nil, errors.New("line has too many points")
+	}
+	if tlxy.LengthHaversine(where.Path) > maxGeofencePathLength {
+		return nil, errors.New("line too long")
+	}
+	ret, err := cfg.GbfsFinder.FindGeofenceChecks(ctx, &where)
+	if ret == nil {
+		ret = []*model.GbfsGeofenceCheck{}
+	}
+	return ret, err
+}
+
+// GBFS STATION INFORMATION
+
+type gbfsStationInformationResolver struct{ *Resolver }
+
+func (r *gbfsStationInformationResolver) AvailabilityHistory(ctx context.Context, obj *model.GbfsStationInformation, start time.Time, end time.Time, interval *int) ([]*model.GbfsStationAvailability, error) {
+	i := 3600
+	if interval != nil {
+		i = *interval
+	}
+	if i < 60 {
+		return nil, errors.New("interval must be at least 60 seconds")
+	}
+	if !end.After(start) {
+		return nil, errors.New("end must be after start")
+	}
+	if end.Sub(start).Seconds()/float64(i) > float64(MAXLIMIT) {
+		return nil, errors.New("too many intervals")
+	}
+	systemID, ok := gbfsSystemID(obj)
+	if !ok {
+		return []*model.GbfsStationAvailability{}, nil
+	}
+	return LoaderFor(ctx).GbfsStationAvailabilityByStationIDs.Load(ctx, gbfsStationAvailabilityLoaderParam{
+		StationID: obj.StationID.Val,
+		Limit:     ptr(MAXLIMIT),
+		Where: &model.GbfsStationAvailabilityFilter{
+			FeedOnestopID: obj.Feed.FeedOnestopID,
+			SystemID:      systemID,
+			Start:         start,
+			End:           end,
+			Interval:      i,
+		},
+	})()
+}
+
+func (r *gbfsStationInformationResolver) StationOccupancyProfile(ctx context.Context, obj *model.GbfsStationInformation, dow *int) ([]*model.GbfsStationOccupancy, error) {
+	if dow != nil && (*dow < 0 || *dow > 6) {
+		return nil, errors.New("dow must be between 0 and 6")
+	}
+	systemID, ok := gbfsSystemID(obj)
+	if !ok {
+		return []*model.GbfsStationOccupancy{}, nil
+	}
+	tz := obj.Feed.GbfsFeed.SystemInformation.Timezone.Val
+	if _, err := time.LoadLocation(tz); tz == "" || err != nil {
+		tz = "UTC"
+	}
+	return LoaderFor(ctx).GbfsStationOccupancyByStationIDs.Load(ctx, gbfsStationOccupancyLoaderParam{
+		StationID: obj.StationID.Val,
+		Limit:     ptr(MAXLIMIT),
+		Where: &model.GbfsStationOccupancyFilter{
+			FeedOnestopID: obj.Feed.FeedOnestopID,
+			SystemID:      systemID,
+			Timezone:      tz,
+			Dow:           dow,
+		},
+	})()
+}
+
+func gbfsSystemID(obj *model.GbfsStationInformation) (string, bool) {
+	if obj.StationInformation == nil || obj.Feed == nil || obj.Feed.GbfsFeed == nil || obj.Feed.GbfsFeed.SystemInformation == nil {
+		return "", false
+	}
+	return obj.Feed.GbfsFeed.SystemInformation.SystemID.Val, true
 }
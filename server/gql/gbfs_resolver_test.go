@@ -13,7 +13,7 @@ import (
 
 func setupGbfs(ctx context.Context, gbf model.GbfsFinder) error {
 	// Setup
-	sourceFeedId := "gbfs-test"
+	sourceFeedId := "test-gbfs"
 	ts := httptest.NewServer(&gbfs.TestGbfsServer{Language: "en", Path: testdata.Path("server/gbfs")})
 	defer ts.Close()
 	opts := gbfs.Options{}
@@ -64,7 +64,7 @@ func TestGbfsBikeResolver(t *testing.T) {
 				}
 			}`,
 			selector:     "bikes.#.bike_id",
-			selectExpect: []string{"0cbf9b08f8b71a6362e20c8173c071a6", "1682088b2335fa5365610e6d299fde2d", "1bc913bf913729a147458cd6b2f91773", "1d61a000cb330f6c260fc439d29b20ab", "21667e59d3c6bc814b6716d87621ddde"},
+			selectExpect: []string{"2e09a0ed99c8ad32cca516661618645e", "33b0705b1f5d3dede485d643824d2513", "60a300a143f18c0d2b2c50287e40cf07", "7cefbd63b55aadde86972228d6d381b2", "7a82eeedf048a6a07e5bc32b227a8fc1"},
 		},
 		{
 			name: "limit 1",
@@ -74,7 +74,163 @@ func TestGbfsBikeResolver(t *testing.T) {
 				}
 			}`,
 			selector:     "bikes.#.bike_id",
-			selectExpect: []string{"0cbf9b08f8b71a6362e20c8173c071a6"},
+			selectExpect: []string{"2e09a0ed99c8ad32cca516661618645e"},
+		},
+		{
+			name:        "bbox too large",
+			query:       `query($bbox:BoundingBox) {bikes(where:{bbox:$bbox}) {bike_id}}`,
+			vars:        hw{"bbox": hw{"min_lon": -137.88020156441956, "min_lat": 30.072648315782004, "max_lon": -109.00421121090919, "max_lat": 45.02437957865729}},
+			expectError: true,
+			f: func(t *testing.T, jj string) {
+			},
+		},
+		{
+			name:        "radius too large",
+			query:       `{bikes(where: {near:{lon: -122.396445, lat:37.793250, radius:1000000}}) {bike_id}}`,
+			expectError: true,
+			f: func(t *testing.T, jj string) {
+			},
+		},
+	}
+	c, cfg := newTestClient(t)
+	setupGbfs(context.Background(), cfg.GbfsFinder)
+	queryTestcases(t, c, testcases)
+}
+
+func TestGbfsStationResolver_Snapshots(t *testing.T) {
+	testcases := []testcase{
+		{
+			name: "availability history",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  availability_history(start: "2024-01-01T16:00:00Z", end: "2024-01-01T18:00:00Z") {
+					start
+					snapshots
+				  }
+				}
+			  }
+			  `,
+			selector:     "docks.0.availability_history.#.snapshots",
+			selectExpect: []string{"3", "1"},
+		},
+		{
+			name: "availability history values",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  availability_history(start: "2024-01-01T16:00:00Z", end: "2024-01-01T18:00:00Z") {
+					avg_bikes_available
+					min_bikes_available
+					max_bikes_available
+					full_ratio
+				  }
+				}
+			  }
+			  `,
+			sel: []testcaseSelector{
+				{selector: "docks.0.availability_history.#.avg_bikes_available", expect: []string{"4", "12"}},
+				{selector: "docks.0.availability_history.#.min_bikes_available", expect: []string{"0", "12"}},
+				{selector: "docks.0.availability_history.#.max_bikes_available", expect: []string{"8", "12"}},
+				{selector: "docks.0.availability_history.#.full_ratio", expect: []string{"0", "1"}},
+			},
+		},
+		{
+			name: "availability history interval",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  availability_history(start: "2024-01-01T16:00:00Z", end: "2024-01-01T18:00:00Z", interval: 1800) {
+					snapshots
+				  }
+				}
+			  }
+			  `,
+			selector:     "docks.0.availability_history.#.snapshots",
+			selectExpect: []string{"2", "1", "1"},
+		},
+		{
+			name: "availability history outside range",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  availability_history(start: "2024-02-01T16:00:00Z", end: "2024-02-01T18:00:00Z") {
+					snapshots
+				  }
+				}
+			  }
+			  `,
+			selector:     "docks.0.availability_history.#.snapshots",
+			selectExpect: []string{},
+		},
+		{
+			name: "availability history invalid interval",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  availability_history(start: "2024-01-01T16:00:00Z", end: "2024-01-01T18:00:00Z", interval: 10) {
+					snapshots
+				  }
+				}
+			  }
+			  `,
+			expectError: true,
+			f: func(t *testing.T, jj string) {
+			},
+		},
+		{
+			name: "availability history too many intervals",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  availability_history(start: "2020-01-01T00:00:00Z", end: "2024-01-01T00:00:00Z", interval: 60) {
+					snapshots
+				  }
+				}
+			  }
+			  `,
+			expectError: true,
+			f: func(t *testing.T, jj string) {
+			},
+		},
+		{
+			name: "occupancy profile",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  station_occupancy_profile(dow: 1) {
+					dow
+					hour
+					snapshots
+					empty_ratio
+				  }
+				}
+			  }
+			  `,
+			sel: []testcaseSelector{
+				{selector: "docks.0.station_occupancy_profile.#.hour", expect: []string{"8", "9"}},
+				{selector: "docks.0.station_occupancy_profile.#.snapshots", expect: []string{"3", "1"}},
+			},
+		},
+		{
+			name: "occupancy profile other day",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  station_occupancy_profile(dow: 2) {
+					hour
+				  }
+				}
+			  }
+			  `,
+			selector:     "docks.0.station_occupancy_profile.#.hour",
+			selectExpect: []string{},
+		},
+		{
+			name: "occupancy profile invalid dow",
+			query: `{
+				docks(limit: 1, where: {near: {lon: -121.908666, lat: 37.336289, radius: 1000}}) {
+				  station_occupancy_profile(dow: 7) {
+					hour
+				  }
+				}
+			  }
+			  `,
+			expectError: true,
+			f: func(t *testing.T, jj string) {
+			},
 		},
 	}
 	c, cfg := newTestClient(t)
@@ -192,7 +348,7 @@ func TestGbfsStationResolver(t *testing.T) {
 			  }
 			  `,
 			selector:     "docks.#.station_id",
-			selectExpect: []string{"27045384-791c-4519-8087-fce2f7c48a69", "28988488-fb74-4bbc-9e69-613698b2dd8c", "2c7560e6-62c6-4403-8b97-8016471948b5", "3ebc4f3f-2941-47cd-a173-83f01a91bf57", "a96032c0-9ff2-4fbe-8f03-6b3f9816947d"},
+			selectExpect: []string{"d75591d7-080d-46cb-8ada-0fbe6af676fc", "27045384-791c-4519-8087-fce2f7c48a69", "2c7560e6-62c6-4403-8b97-8016471948b5", "d28bb0a7-8dc5-49be-86c7-9a3395eed019", "3ebc4f3f-2941-47cd-a173-83f01a91bf57"},
 		},
 		{
 			name: "limit 1",
@@ -203,7 +359,15 @@ func TestGbfsStationResolver(t *testing.T) {
 			  }
 			  `,
 			selector:     "docks.#.station_id",
-			selectExpect: []string{"27045384-791c-4519-8087-fce2f7c48a69"},
+			selectExpect: []string{"d75591d7-080d-46cb-8ada-0fbe6af676fc"},
+		},
+		{
+			name:        "bbox too large",
+			query:       `query($bbox:BoundingBox) {docks(where:{bbox:$bbox}) {station_id}}`,
+			vars:        hw{"bbox": hw{"min_lon": -137.88020156441956, "min_lat": 30.072648315782004, "max_lon": -109.00421121090919, "max_lat": 45.02437957865729}},
+			expectError: true,
+			f: func(t *testing.T, jj string) {
+			},
 		},
 	}
 	c, cfg := newTestClient(t)
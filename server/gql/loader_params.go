@@ -12,6 +12,18 @@ type frequencyLoaderParam struct {
 	Limit  *int
 }
 
+type gbfsStationAvailabilityLoaderParam struct {
+	StationID string
+	Limit     *int
+	Where     *model.GbfsStationAvailabilityFilter
+}
+
+type gbfsStationOccupancyLoaderParam struct {
+	StationID string
+	Limit     *int
+	Where     *model.GbfsStationOccupancyFilter
+}
+
 type feedVersionFileInfoLoaderParam struct {
 	FeedVersionID int
 	Limit         *int
@@ -98,13 +110,14 @@ type tripLoaderParam struct {
 }
 
 type stopLoaderParam struct {
-	FeedVersionID int
-	ParentStopID  int
-	AgencyID      int
-	LevelID       int
-	Limit         *int
-	Where         *model.StopFilter
-	RouteID       int
+	FeedVersionID   int
+	ParentStopID    int
+	AgencyID        int
+	LevelID         int
+	Limit           *int
+	Where           *model.StopFilter
+	RouteID         int
+	LocationGroupID int
 }
 
 type levelLoaderParam struct {
@@ -28,6 +28,7 @@ type Loaders struct {
 	AgenciesByIDs                                                 *dataloader.Loader[int, *model.Agency]
 	AgenciesByOnestopIDs                                          *dataloader.Loader[agencyLoaderParam, []*model.Agency]
 	AgencyPlacesByAgencyIDs                                       *dataloader.Loader[agencyPlaceLoaderParam, []*model.AgencyPlace]
+	BookingRulesByIDs                                             *dataloader.Loader[int, *model.BookingRule]
 	CalendarDatesByServiceIDs                                     *dataloader.Loader[calendarDateLoaderParam, []*model.CalendarDate]
 	CalendarsByIDs                                                *dataloader.Loader[int, *model.Calendar]
 	CensusDatasetLayersByDatasetIDs                               *dataloader.Loader[int, []*model.CensusLayer]
@@ -54,9 +55,14 @@ type Loaders struct {
 	FeedVersionsByIDs                                             *dataloader.Loader[int, *model.FeedVersion]
 	FeedVersionServiceLevelsByFeedVersionIDs                      *dataloader.Loader[feedVersionServiceLevelLoaderParam, []*model.FeedVersionServiceLevel]
 	FeedVersionServiceWindowByFeedVersionIDs                      *dataloader.Loader[int, *model.FeedVersionServiceWindow]
+	FlexStopTimesByTripIDs                                        *dataloader.Loader[int, []*model.FlexService]
 	FrequenciesByTripIDs                                          *dataloader.Loader[frequencyLoaderParam, []*model.Frequency]
+	GbfsStationAvailabilityByStationIDs                           *dataloader.Loader[gbfsStationAvailabilityLoaderParam, []*model.GbfsStationAvailability]
+	GbfsStationOccupancyByStationIDs                              *dataloader.Loader[gbfsStationOccupancyLoaderParam, []*model.GbfsStationOccupancy]
 	LevelsByIDs                                                   *dataloader.Loader[int, *model.Level]
 	LevelsByParentStationIDs                                      *dataloader.Loader[levelLoaderParam, []*model.Level]
+	LocationGroupsByIDs                                           *dataloader.Loader[int, *model.LocationGroup]
+	LocationsByIDs                                                *dataloader.Loader[int, *model.Location]
 	OperatorsByAgencyIDs                                          *dataloader.Loader[int, *model.Operator]
 	OperatorsByCOIFs                                              *dataloader.Loader[int, *model.Operator]
 	OperatorsByFeedIDs                                            *dataloader.Loader[operatorLoaderParam, []*model.Operator]
@@ -84,6 +90,7 @@ type Loaders struct {
 	StopsByFeedVersionIDs                                         *dataloader.Loader[stopLoaderParam, []*model.Stop]
 	StopsByIDs                                                    *dataloader.Loader[int, *model.Stop]
 	StopsByLevelIDs                                               *dataloader.Loader[stopLoaderParam, []*model.Stop]
+	StopsByLocationGroupIDs                                       *dataloader.Loader[stopLoaderParam, []*model.Stop]
 	StopsByParentStopIDs                                          *dataloader.Loader[stopLoaderParam, []*model.Stop]
 	StopsByRouteIDs                                               *dataloader.Loader[stopLoaderParam, []*model.Stop]
 	StopTimesByStopIDs                                            *dataloader.Loader[stopTimeLoaderParam, []*model.StopTime]
@@ -126,6 +133,7 @@ func NewLoaders(dbf model.Finder, batchSize int, stopTimeBatchSize int) *Loaders
 				return p.AgencyID, p.Where, p.Limit
 			},
 		),
+		BookingRulesByIDs: withWaitAndCapacity(waitTime, batchSize, dbf.BookingRulesByIDs),
 		CalendarDatesByServiceIDs: withWaitAndCapacityGroup(waitTime, batchSize, dbf.CalendarDatesByServiceIDs,
 			func(p calendarDateLoaderParam) (int, *model.CalendarDateFilter, *int) {
 				return p.ServiceID, p.Where, p.Limit
@@ -228,12 +236,23 @@ func NewLoaders(dbf model.Finder, batchSize int, stopTimeBatchSize int) *Loaders
 		),
 
 		FeedVersionServiceWindowByFeedVersionIDs: withWaitAndCapacity(waitTime, maxBatch, dbf.FeedVersionServiceWindowByFeedVersionIDs),
+		FlexStopTimesByTripIDs:                   withWaitAndCapacity(waitTime, batchSize, dbf.FlexStopTimesByTripIDs),
 		FrequenciesByTripIDs: withWaitAndCapacityGroup(waitTime, batchSize,
 			paramGroupAdapter(dbf.FrequenciesByTripIDs),
 			func(p frequencyLoaderParam) (int, bool, *int) {
 				return p.TripID, false, p.Limit
 			},
 		),
+		GbfsStationAvailabilityByStationIDs: withWaitAndCapacityGroup(waitTime, batchSize, dbf.GbfsStationAvailabilityByStationIDs,
+			func(p gbfsStationAvailabilityLoaderParam) (string, *model.GbfsStationAvailabilityFilter, *int) {
+				return p.StationID, p.Where, p.Limit
+			},
+		),
+		GbfsStationOccupancyByStationIDs: withWaitAndCapacityGroup(waitTime, batchSize, dbf.GbfsStationOccupancyByStationIDs,
+			func(p gbfsStationOccupancyLoaderParam) (string, *model.GbfsStationOccupancyFilter, *int) {
+				return p.StationID, p.Where, p.Limit
+			},
+		),
 
 		LevelsByIDs: withWaitAndCapacity(waitTime, batchSize, dbf.LevelsByIDs),
 		LevelsByParentStationIDs: withWaitAndCapacityGroup(waitTime, batchSize,
@@ -243,6 +262,9 @@ func NewLoaders(dbf model.Finder, batchSize int, stopTimeBatchSize int) *Loaders
 			},
 		),
 
+		LocationGroupsByIDs: withWaitAndCapacity(waitTime, batchSize, dbf.LocationGroupsByIDs),
+		LocationsByIDs:      withWaitAndCapacity(waitTime, batchSize, dbf.LocationsByIDs),
+
 		OperatorsByAgencyIDs: withWaitAndCapacity(waitTime, batchSize, dbf.OperatorsByAgencyIDs),
 		OperatorsByCOIFs:     withWaitAndCapacity(waitTime, batchSize, dbf.OperatorsByCOIFs),
 		OperatorsByFeedIDs: withWaitAndCapacityGroup(waitTime, batchSize, dbf.OperatorsByFeedIDs,
@@ -343,6 +365,11 @@ func NewLoaders(dbf model.Finder, batchSize int, stopTimeBatchSize int) *Loaders
 				return p.LevelID, p.Where, p.Limit
 			},
 		),
+		StopsByLocationGroupIDs: withWaitAndCapacityGroup(waitTime, batchSize, dbf.StopsByLocationGroupIDs,
+			func(p stopLoaderParam) (int, *model.StopFilter, *int) {
+				return p.LocationGroupID, p.Where, p.Limit
+			},
+		),
 		StopsByParentStopIDs: withWaitAndCapacityGroup(waitTime, batchSize, dbf.StopsByParentStopIDs,
 			func(p stopLoaderParam) (int, *model.StopFilter, *int) {
 				return p.ParentStopID, p.Where, p.Limit
@@ -3,6 +3,7 @@ package gql
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/interline-io/transitland-lib/tt"
 	"github.com/interline-io/transitland-server/server/auth/authn"
@@ -78,10 +79,17 @@ func (r *queryResolver) Stops(ctx context.Context, limit *int, after *int, ids [
 	return cfg.Finder.FindStops(ctx, checkLimit(limit), checkCursor(after), ids, where)
 }
 
-func (r *queryResolver) Trips(ctx context.Context, limit *int, after *int, ids []int, where *model.TripFilter) ([]*model.Trip, error) {
+func (r *queryResolver) Trips(ctx context.Context, limit *int, after *int, ids []int, where *model.TripFilter, asOf *time.Time) ([]*model.Trip, error) {
 	cfg := model.ForContext(ctx)
 	ctx = addMetric(ctx, "trips")
-	return cfg.Finder.FindTrips(ctx, checkLimit(limit), checkCursor(after), ids, where)
+	trips, err := cfg.Finder.FindTrips(ctx, checkLimit(limit), checkCursor(after), ids, where)
+	if err != nil {
+		return nil, err
+	}
+	for _, trip := range trips {
+		trip.AsOf = asOf
+	}
+	return trips, nil
 }
 
 func (r *queryResolver) FeedVersions(ctx context.Context, limit *int, after *int, ids []int, where *model.FeedVersionFilter) ([]*model.FeedVersion, error) {
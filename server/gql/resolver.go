@@ -3,6 +3,7 @@ package gql
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/interline-io/transitland-lib/tlxy"
 	"github.com/interline-io/transitland-server/internal/generated/gqlout"
@@ -158,6 +159,11 @@ func (r *Resolver) Stop() gqlout.StopResolver { return &stopResolver{r} }
 // Trip .
 func (r *Resolver) Trip() gqlout.TripResolver { return &tripResolver{r} }
 
+// VehiclePosition .
+func (r *Resolver) VehiclePosition() gqlout.VehiclePositionResolver {
+	return &vehiclePositionResolver{r}
+}
+
 // StopTime .
 func (r *Resolver) StopTime() gqlout.StopTimeResolver { return &stopTimeResolver{r} }
 
@@ -173,6 +179,16 @@ func (r *Resolver) Level() gqlout.LevelResolver {
 	return &levelResolver{r}
 }
 
+// LocationGroup .
+func (r *Resolver) LocationGroup() gqlout.LocationGroupResolver {
+	return &locationGroupResolver{r}
+}
+
+// FlexService .
+func (r *Resolver) FlexService() gqlout.FlexServiceResolver {
+	return &flexServiceResolver{r}
+}
+
 // Calendar .
 func (r *Resolver) Calendar() gqlout.CalendarResolver {
 	return &calendarResolver{r}
@@ -196,6 +212,11 @@ func (r *Resolver) Pathway() gqlout.PathwayResolver {
 	return &pathwayResolver{r}
 }
 
+// GbfsStationInformation .
+func (r *Resolver) GbfsStationInformation() gqlout.GbfsStationInformationResolver {
+	return &gbfsStationInformationResolver{r}
+}
+
 // StopExternalReference .
 func (r *Resolver) StopExternalReference() gqlout.StopExternalReferenceResolver {
 	return &stopExternalReferenceResolver{r}
@@ -207,6 +228,22 @@ func (r *Resolver) Directions(ctx context.Context, where model.DirectionRequest)
 	return dr.Directions(ctx, where)
 }
 
+// TravelTimeMatrix .
+func (r *Resolver) TravelTimeMatrix(ctx context.Context, origins []*model.WaypointInput, destinations []*model.WaypointInput, mode *model.StepMode, departAt *time.Time) (*model.TravelTimeMatrix, error) {
+	dr := directionsResolver{r}
+	return dr.TravelTimeMatrix(ctx, origins, destinations, mode, departAt)
+}
+
+// Isochrones .
+func (r *Resolver) Isochrones(ctx context.Context, origin model.WaypointInput, mode *model.StepMode, departAt *time.Time, cutoffs []int) (*model.Isochrones, error) {
+	dr := directionsResolver{r}
+	return dr.Isochrones(ctx, origin, mode, departAt, cutoffs)
+}
+
+func (r *Resolver) Isochrone() gqlout.IsochroneResolver {
+	return &isochroneResolver{r}
+}
+
 func (r *Resolver) Place() gqlout.PlaceResolver {
 	return &placeResolver{r}
 }
@@ -230,3 +267,13 @@ func (r *Resolver) CensusSource() gqlout.CensusSourceResolver {
 func (r *Resolver) CensusLayer() gqlout.CensusLayerResolver {
 	return &censusLayerResolver{r}
 }
+
+// Subscription .
+func (r *Resolver) Subscription() gqlout.SubscriptionResolver {
+	return &subscriptionResolver{r}
+}
+
+// RTEntitySelector .
+func (r *Resolver) RTEntitySelector() gqlout.RTEntitySelectorResolver {
+	return &rtEntitySelectorResolver{r}
+}
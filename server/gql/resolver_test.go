@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/99designs/gqlgen/client"
+	"github.com/interline-io/transitland-lib/rt/pb"
 	"github.com/interline-io/transitland-server/internal/testconfig"
 	"github.com/interline-io/transitland-server/server/auth/authn"
 	"github.com/interline-io/transitland-server/server/auth/mw/usercheck"
@@ -14,6 +15,7 @@ import (
 	"github.com/interline-io/transitland-server/server/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/tidwall/gjson"
+	"google.golang.org/protobuf/proto"
 )
 
 const DEFAULT_WHEN = "2022-09-01T00:00:00Z"
@@ -70,12 +72,16 @@ func newTestClientWithOpts(t testing.TB, opts testconfig.Options) (*client.Clien
 		opts.WhenUtc = DEFAULT_WHEN
 	}
 	cfg := testconfig.Config(t, opts)
+	return newTestClientWithConfig(cfg), cfg
+}
+
+func newTestClientWithConfig(cfg model.Config) *client.Client {
 	srv, _ := NewServer()
 	graphqlServer := model.AddConfigAndPerms(cfg, srv)
 	srvMiddleware := usercheck.NewUserDefaultMiddleware(func() authn.User {
 		return authn.NewCtxUser("testuser", "", "").WithRoles("testrole")
 	})
-	return client.New(srvMiddleware(graphqlServer)), cfg
+	return client.New(srvMiddleware(graphqlServer))
 }
 
 func toJson(m map[string]interface{}) string {
@@ -347,34 +353,80 @@ func rtTestStopQueryVars() hw {
 	}
 }
 
+// rtReplayFiles returns BA.json, followed by a copy one hour later with a further 30 seconds of delay, for testing replay of archived RT data.
+func rtReplayFiles() []testconfig.RTJsonFile {
+	return []testconfig.RTJsonFile{
+		{Feed: "BA", Ftype: "realtime_trip_updates", Fname: "BA.json"},
+		{Feed: "BA", Ftype: "realtime_trip_updates", Fname: "BA.json", Transform: func(msg *pb.FeedMessage) {
+			msg.Header.Timestamp = proto.Uint64(msg.GetHeader().GetTimestamp() + 3600)
+			for _, ent := range msg.Entity {
+				for _, stu := range ent.GetTripUpdate().GetStopTimeUpdate() {
+					for _, ste := range []*pb.TripUpdate_StopTimeEvent{stu.Arrival, stu.Departure} {
+						if ste == nil {
+							continue
+						}
+						if ste.Delay != nil {
+							ste.Delay = proto.Int32(ste.GetDelay() + 30)
+						}
+						if ste.Time != nil {
+							ste.Time = proto.Int64(ste.GetTime() + 30)
+						}
+					}
+				}
+			}
+		}},
+	}
+}
+
 type rtTestCase struct {
-	name    string
-	query   string
-	vars    map[string]interface{}
-	rtfiles []testconfig.RTJsonFile
-	cb      func(t *testing.T, jj string)
-	whenUtc string
+	name          string
+	query         string
+	vars          map[string]interface{}
+	rtfiles       []testconfig.RTJsonFile
+	cb            func(t *testing.T, jj string)
+	whenUtc       string
+	rtArchive     bool
+	rtBlockDelays bool
+	// setupTx optionally modifies the database before the query; changes are rolled back afterwards
+	setupTx func(t *testing.T, cfg model.Config)
 }
 
 func testRt(t *testing.T, tc rtTestCase) {
 	t.Run(tc.name, func(t *testing.T) {
 		// Create a new RT Finder for each test...
-		c, _ := newTestClientWithOpts(t, testconfig.Options{
-			WhenUtc: tc.whenUtc,
-			RTJsons: tc.rtfiles,
-		})
-		var resp map[string]interface{}
-		opts := []client.Option{}
-		for k, v := range tc.vars {
-			opts = append(opts, client.Var(k, v))
+		opts := testconfig.Options{
+			WhenUtc:       tc.whenUtc,
+			RTJsons:       tc.rtfiles,
+			RTArchive:     tc.rtArchive,
+			RTBlockDelays: tc.rtBlockDelays,
 		}
-		if err := c.Post(tc.query, &resp, opts...); err != nil {
-			t.Error(err)
+		if tc.setupTx == nil {
+			c, _ := newTestClientWithOpts(t, opts)
+			queryRt(t, c, tc)
 			return
 		}
-		jj := toJson(resp)
-		if tc.cb != nil {
-			tc.cb(t, jj)
+		if opts.WhenUtc == "" {
+			opts.WhenUtc = DEFAULT_WHEN
 		}
+		testconfig.ConfigTxRollback(t, opts, func(cfg model.Config) {
+			tc.setupTx(t, cfg)
+			queryRt(t, newTestClientWithConfig(cfg), tc)
+		})
 	})
 }
+
+func queryRt(t *testing.T, c *client.Client, tc rtTestCase) {
+	var resp map[string]interface{}
+	opts := []client.Option{}
+	for k, v := range tc.vars {
+		opts = append(opts, client.Var(k, v))
+	}
+	if err := c.Post(tc.query, &resp, opts...); err != nil {
+		t.Error(err)
+		return
+	}
+	jj := toJson(resp)
+	if tc.cb != nil {
+		tc.cb(t, jj)
+	}
+}
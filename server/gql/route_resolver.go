@@ -2,6 +2,7 @@ package gql
 
 import (
 	"context"
+	"errors"
 
 	"github.com/interline-io/transitland-lib/tt"
 	"github.com/interline-io/transitland-server/server/model"
@@ -71,8 +72,34 @@ func (r *routeResolver) RouteStopBuffer(ctx context.Context, obj *model.Route, r
 	return nil, nil
 }
 
-func (r *routeResolver) Alerts(ctx context.Context, obj *model.Route, active *bool, limit *int) ([]*model.Alert, error) {
-	return model.ForContext(ctx).RTFinder.FindAlertsForRoute(ctx, obj, checkLimit(limit), active), nil
+func (r *routeResolver) OnTimePerformance(ctx context.Context, obj *model.Route, dateRange model.DateRange, threshold *int) (*model.RouteOnTimePerformance, error) {
+	t := 300
+	if threshold != nil {
+		t = *threshold
+	}
+	if t < 0 {
+		return nil, errors.New("threshold must be non-negative")
+	}
+	if !dateRange.StartDate.Valid || !dateRange.EndDate.Valid || dateRange.EndDate.Val.Before(dateRange.StartDate.Val) {
+		return nil, errors.New("invalid date range")
+	}
+	return model.ForContext(ctx).Finder.RouteOnTimePerformance(ctx, obj.ID, dateRange, t)
+}
+
+func (r *routeResolver) Alerts(ctx context.Context, obj *model.Route, active *bool, limit *int, includeStopAlerts *bool) ([]*model.Alert, error) {
+	var stops []*model.Stop
+	if includeStopAlerts != nil && *includeStopAlerts {
+		var err error
+		stops, err = LoaderFor(ctx).StopsByRouteIDs.Load(ctx, stopLoaderParam{RouteID: obj.ID, Limit: ptr(MAXLIMIT)})()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return model.ForContext(ctx).RTFinder.FindAlertsForRoute(ctx, obj, stops, checkLimit(limit), active), nil
+}
+
+func (r *routeResolver) VehiclePositions(ctx context.Context, obj *model.Route, limit *int) ([]*model.VehiclePosition, error) {
+	return model.ForContext(ctx).RTFinder.FindVehiclePositionsForRoute(ctx, obj, checkLimit(limit)), nil
 }
 
 func (r *routeResolver) Patterns(ctx context.Context, obj *model.Route) ([]*model.RouteStopPattern, error) {
@@ -76,3 +76,44 @@ func TestRouteRT_Alerts(t *testing.T) {
 	}
 
 }
+
+func TestRouteRT_AlertSelectors(t *testing.T) {
+	q := `query($include_stop_alerts:Boolean) {
+		routes(where:{feed_onestop_id:"BA", route_id:"03"}) {
+			alerts(include_stop_alerts:$include_stop_alerts) {
+				header_text { text }
+			}
+		}
+	}`
+	rtfiles := []testconfig.RTJsonFile{
+		{Feed: "BA", Ftype: "realtime_alerts", Fname: "BA-alerts-selectors.json"},
+	}
+	tcs := []rtTestCase{
+		{
+			name:    "route alerts",
+			query:   q,
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t,
+					[]string{"BART rail routes", "Route 03 direction 0"},
+					astr(gjson.Get(jj, "routes.0.alerts.#.header_text.0.text").Array()),
+				)
+			},
+		},
+		{
+			name:    "include stop alerts",
+			query:   q,
+			vars:    hw{"include_stop_alerts": true},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t,
+					[]string{"BART rail routes", "Route 03 direction 0", "Route 03 at FTVL", "FTVL"},
+					astr(gjson.Get(jj, "routes.0.alerts.#.header_text.0.text").Array()),
+				)
+			},
+		},
+	}
+	for _, tc := range tcs {
+		testRt(t, tc)
+	}
+}
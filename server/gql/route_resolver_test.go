@@ -88,6 +88,30 @@ func TestRouteResolver(t *testing.T) {
 			selector:     "routes.0.route_stop_buffer.stop_convexhull.type",
 			selectExpect: []string{"Polygon"},
 		},
+		{
+			name:   "on_time_performance",
+			query:  `query($route_id: String!) { routes(where:{feed_onestop_id:"BA", route_id:$route_id}) {on_time_performance(date_range:{start_date:"2023-03-01", end_date:"2023-03-31"}) {start_date end_date threshold observations early on_time late on_time_ratio average_delay}}}`,
+			vars:   vars,
+			expect: `{"routes":[{"on_time_performance":{"average_delay":55,"early":1,"end_date":"2023-03-31","late":1,"observations":4,"on_time":2,"on_time_ratio":0.5,"start_date":"2023-03-01","threshold":300}}]}`,
+		},
+		{
+			name:   "on_time_performance threshold",
+			query:  `query($route_id: String!) { routes(where:{feed_onestop_id:"BA", route_id:$route_id}) {on_time_performance(date_range:{start_date:"2023-03-01", end_date:"2023-03-31"}, threshold:60) {observations early on_time late}}}`,
+			vars:   vars,
+			expect: `{"routes":[{"on_time_performance":{"early":1,"late":2,"observations":4,"on_time":1}}]}`,
+		},
+		{
+			name:   "on_time_performance no observations",
+			query:  `query($route_id: String!) { routes(where:{feed_onestop_id:"BA", route_id:$route_id}) {on_time_performance(date_range:{start_date:"2020-01-01", end_date:"2020-01-31"}) {observations on_time_ratio average_delay}}}`,
+			vars:   vars,
+			expect: `{"routes":[{"on_time_performance":{"average_delay":null,"observations":0,"on_time_ratio":null}}]}`,
+		},
+		{
+			name:        "on_time_performance invalid date range",
+			query:       `query($route_id: String!) { routes(where:{feed_onestop_id:"BA", route_id:$route_id}) {on_time_performance(date_range:{start_date:"2023-03-31", end_date:"2023-03-01"}) {observations}}}`,
+			vars:        vars,
+			expectError: true,
+		},
 		{
 			// only check dow_category explicitly it's not a stable computation
 			name:         "headways",
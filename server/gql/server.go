@@ -2,10 +2,19 @@ package gql
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
 	"github.com/interline-io/transitland-server/internal/generated/gqlout"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
 // ServerOption configures the gqlgen server instance
@@ -25,7 +34,29 @@ func WithExtensions(exts ...graphql.HandlerExtension) ServerOption {
 func NewServer(opts ...ServerOption) (http.Handler, error) {
 	c := gqlout.Config{Resolvers: &Resolver{}}
 	// Setup server
-	srv := handler.NewDefaultServer(gqlout.NewExecutableSchema(c))
+	srv := handler.New(gqlout.NewExecutableSchema(c))
+	// Subscription transports; SSE must be checked before POST
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			// CORS does not apply to websocket upgrades
+			CheckOrigin: checkOrigin,
+		},
+	})
+	srv.AddTransport(transport.SSE{
+		KeepAlivePingInterval: 10 * time.Second,
+	})
+	// Query transports
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.Use(subscriptionErrors{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New[string](100),
+	})
 	// Apply functional options
 	for _, opt := range opts {
 		if opt != nil {
@@ -35,3 +66,34 @@ func NewServer(opts ...ServerOption) (http.Handler, error) {
 	graphqlServer := loaderMiddleware(srv)
 	return graphqlServer, nil
 }
+
+// checkOrigin allows websocket upgrades from the origins allowed for CORS requests.
+// Requests without an Origin header do not come from browsers and are allowed.
+// If no origins are configured, only same origin requests are allowed.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	allowed := model.ForContext(r.Context()).AllowedOrigins
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, pattern := range allowed {
+		if matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin matches an origin against a pattern with at most one wildcard, e.g. "https://*.example.com"
+func matchOrigin(pattern string, origin string) bool {
+	pattern = strings.ToLower(pattern)
+	origin = strings.ToLower(origin)
+	if prefix, suffix, ok := strings.Cut(pattern, "*"); ok {
+		return len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+	}
+	return pattern == origin
+}
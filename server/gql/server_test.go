@@ -0,0 +1,39 @@
+package gql
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tcs := []struct {
+		name    string
+		allowed []string
+		origin  string
+		expect  bool
+	}{
+		{"no origin header", []string{"https://example.com"}, "", true},
+		{"exact", []string{"https://example.com"}, "https://example.com", true},
+		{"exact case insensitive", []string{"https://example.com"}, "https://EXAMPLE.com", true},
+		{"not allowed", []string{"https://example.com"}, "https://evil.com", false},
+		{"wildcard subdomain", []string{"https://*.example.com"}, "https://app.example.com", true},
+		{"wildcard subdomain not matched", []string{"https://*.example.com"}, "https://example.com.evil.com", false},
+		{"wildcard scheme", []string{"https://*"}, "https://evil.com", true},
+		{"wildcard scheme not matched", []string{"https://*"}, "http://example.com", false},
+		{"same origin", nil, "http://localhost:8080", true},
+		{"cross origin", nil, "http://evil.com", false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:8080/query", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			req = req.WithContext(model.WithConfig(req.Context(), model.Config{AllowedOrigins: tc.allowed}))
+			assert.Equal(t, tc.expect, checkOrigin(req))
+		})
+	}
+}
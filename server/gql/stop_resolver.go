@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-lib/tt"
 	"github.com/interline-io/transitland-server/server/directions"
+	"github.com/interline-io/transitland-server/server/directions/pathways"
 	"github.com/interline-io/transitland-server/server/model"
 )
 
@@ -73,29 +75,34 @@ func (r *stopResolver) Observations(ctx context.Context, obj *model.Stop, limit
 	return LoaderFor(ctx).StopObservationsByStopIDs.Load(ctx, stopObservationLoaderParam{StopID: obj.ID, Where: where, Limit: checkLimit(limit)})()
 }
 
-func (r *stopResolver) Departures(ctx context.Context, obj *model.Stop, limit *int, where *model.StopTimeFilter) ([]*model.StopTime, error) {
+func (r *stopResolver) Departures(ctx context.Context, obj *model.Stop, limit *int, where *model.StopTimeFilter, asOf *time.Time) ([]*model.StopTime, error) {
 	if where == nil {
 		where = &model.StopTimeFilter{}
 	}
 	t := true
 	where.ExcludeLast = &t
-	return r.getStopTimes(ctx, obj, limit, where)
+	return r.getStopTimes(ctx, obj, limit, where, asOf)
 }
 
-func (r *stopResolver) Arrivals(ctx context.Context, obj *model.Stop, limit *int, where *model.StopTimeFilter) ([]*model.StopTime, error) {
+func (r *stopResolver) Arrivals(ctx context.Context, obj *model.Stop, limit *int, where *model.StopTimeFilter, asOf *time.Time) ([]*model.StopTime, error) {
 	if where == nil {
 		where = &model.StopTimeFilter{}
 	}
 	t := true
 	where.ExcludeFirst = &t
-	return r.getStopTimes(ctx, obj, limit, where)
+	return r.getStopTimes(ctx, obj, limit, where, asOf)
 }
 
-func (r *stopResolver) StopTimes(ctx context.Context, obj *model.Stop, limit *int, where *model.StopTimeFilter) ([]*model.StopTime, error) {
-	return r.getStopTimes(ctx, obj, limit, where)
+func (r *stopResolver) StopTimes(ctx context.Context, obj *model.Stop, limit *int, where *model.StopTimeFilter, asOf *time.Time) ([]*model.StopTime, error) {
+	return r.getStopTimes(ctx, obj, limit, where, asOf)
 }
 
-func (r *stopResolver) getStopTimes(ctx context.Context, obj *model.Stop, limit *int, where *model.StopTimeFilter) ([]*model.StopTime, error) {
+func (r *stopResolver) getStopTimes(ctx context.Context, obj *model.Stop, limit *int, where *model.StopTimeFilter, asOf *time.Time) ([]*model.StopTime, error) {
+	if asOf != nil {
+		// Relative times depend on the as-of time; use separate loaders to avoid batching with other requests
+		cfg := model.ForContext(ctx)
+		ctx = context.WithValue(withAsOf(ctx, asOf), loadersKey, NewLoaders(cfg.Finder, cfg.LoaderBatchSize, cfg.LoaderStopTimeBatchSize))
+	}
 	sts, err := (LoaderFor(ctx).StopTimesByStopIDs.Load(ctx, stopTimeLoaderParam{
 		StopID:        obj.ID,
 		FeedVersionID: obj.FeedVersionID,
@@ -109,15 +116,30 @@ func (r *stopResolver) getStopTimes(ctx context.Context, obj *model.Stop, limit
 	// Merge scheduled stop times with rt stop times
 	// TODO: handle StopTimeFilter in RT
 	// Handle scheduled trips; these can be matched on trip_id or (route_id,direction_id,...)
+	var rtSts []*model.StopTime
 	for _, st := range sts {
 		ft := model.Trip{}
+		ft.ID = st.TripID.Int()
 		ft.FeedVersionID = obj.FeedVersionID
 		tripId, _ := model.ForContext(ctx).RTFinder.GetGtfsTripID(ctx, st.TripID.Int())
 		ft.TripID.Set(tripId) // TODO!
+		// Trip modifications may remove or shift this stop time
+		if tm, ok := model.ForContext(ctx).RTFinder.FindTripModifications(ctx, &ft); ok && tripModificationsActive(tm, st.ServiceDate) {
+			mst, err := modifiedStopTime(ctx, &ft, st, tm)
+			if err != nil {
+				return nil, err
+			}
+			if mst == nil {
+				continue
+			}
+			st = mst
+		}
 		if ste, ok := model.ForContext(ctx).RTFinder.FindStopTimeUpdate(ctx, &ft, st); ok {
 			st.RTStopTimeUpdate = ste
 		}
+		rtSts = append(rtSts, st)
 	}
+	sts = rtSts
 	// Handle added trips; these must specify stop_id in StopTimeUpdates
 	for _, rtTrip := range model.ForContext(ctx).RTFinder.GetAddedTripsForStop(ctx, obj) {
 		for _, stu := range rtTrip.StopTimeUpdate {
@@ -135,6 +157,17 @@ func (r *stopResolver) getStopTimes(ctx context.Context, obj *model.Stop, limit
 			sts = append(sts, rtst)
 		}
 	}
+	// Handle modified trips that use this stop as a replacement stop
+	for _, tid := range model.ForContext(ctx).RTFinder.FindTripModificationsForStop(ctx, obj) {
+		rsts, err := replacementStopTimes(ctx, obj, tid, where)
+		if err != nil {
+			return nil, err
+		}
+		sts = append(sts, rsts...)
+	}
+	for _, st := range sts {
+		st.AsOf = asOf
+	}
 	// Sort by scheduled departure time.
 	// TODO: Sort by rt departure time? Requires full StopTime Resolver for timezones, processing, etc.
 	sort.Slice(sts, func(i, j int) bool {
@@ -147,8 +180,121 @@ func (r *stopResolver) getStopTimes(ctx context.Context, obj *model.Stop, limit
 	return sts, nil
 }
 
-func (r *stopResolver) Alerts(ctx context.Context, obj *model.Stop, active *bool, limit *int) ([]*model.Alert, error) {
-	rtAlerts := model.ForContext(ctx).RTFinder.FindAlertsForStop(ctx, obj, checkLimit(limit), active)
+// modifiedStopTime returns the stop time after applying trip modifications, or nil if the stop is no longer visited.
+func modifiedStopTime(ctx context.Context, trip *model.Trip, st *model.StopTime, tm *model.RTTripModifications) (*model.StopTime, error) {
+	tripSts, err := LoaderFor(ctx).StopTimesByTripIDs.Load(ctx, tripStopTimeLoaderParam{FeedVersionID: trip.FeedVersionID, TripID: trip.ID, Limit: ptr(MAXLIMIT)})()
+	if err != nil {
+		return nil, err
+	}
+	tripSts, err = applyTripModifications(ctx, tripSts, tm)
+	if err != nil {
+		return nil, err
+	}
+	for _, mst := range tripSts {
+		if mst.RTStop == nil && mst.StopSequence == st.StopSequence {
+			mst.ServiceDate = st.ServiceDate
+			mst.Date = st.Date
+			return mst, nil
+		}
+	}
+	return nil, nil
+}
+
+// replacementStopTimes returns the stop times at a replacement stop for a modified trip,
+// for each service date the modifications are active.
+func replacementStopTimes(ctx context.Context, obj *model.Stop, tripID string, where *model.StopTimeFilter) ([]*model.StopTime, error) {
+	trips, err := LoaderFor(ctx).TripsByFeedVersionIDs.Load(ctx, tripLoaderParam{FeedVersionID: obj.FeedVersionID, Limit: ptr(1), Where: &model.TripFilter{TripID: &tripID}})()
+	if err != nil || len(trips) == 0 {
+		return nil, err
+	}
+	trip := trips[0]
+	tm, ok := model.ForContext(ctx).RTFinder.FindTripModifications(ctx, trip)
+	if !ok {
+		return nil, nil
+	}
+	tripSts, err := LoaderFor(ctx).StopTimesByTripIDs.Load(ctx, tripStopTimeLoaderParam{FeedVersionID: trip.FeedVersionID, TripID: trip.ID, Limit: ptr(MAXLIMIT)})()
+	if err != nil {
+		return nil, err
+	}
+	tripSts, err = applyTripModifications(ctx, tripSts, tm)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*model.StopTime
+	for _, st := range tripSts {
+		if st.RTStop == nil || st.RTStop.StopID.Val != obj.StopID.Val {
+			continue
+		}
+		// Without a date filter, return the stop time without a service date, as for scheduled stop times
+		if where == nil || (where.ServiceDate == nil && where.Date == nil) {
+			ret = append(ret, st)
+			continue
+		}
+		for _, d := range tm.TripModifications.GetServiceDates() {
+			sd, err := time.Parse("20060102", d)
+			if err != nil {
+				continue
+			}
+			rst := *st
+			rst.ServiceDate = tt.NewDate(sd)
+			rst.Date = tt.NewDate(sd)
+			if rst.ArrivalTime.Val > 24*60*60 {
+				rst.Date = tt.NewDate(sd.AddDate(0, 0, 1))
+			}
+			if checkStopTimeFilter(&rst, where) {
+				ret = append(ret, &rst)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// checkStopTimeFilter checks the date and time window of a StopTimeFilter against a stop time not found in the database.
+func checkStopTimeFilter(st *model.StopTime, where *model.StopTimeFilter) bool {
+	if where.ServiceDate != nil && where.ServiceDate.Val.Format("20060102") != st.ServiceDate.Val.Format("20060102") {
+		return false
+	}
+	if where.Date != nil && where.Date.Val.Format("20060102") != st.Date.Val.Format("20060102") {
+		return false
+	}
+	// Compare times relative to the calendar date
+	offset := 0
+	if st.Date.Val.After(st.ServiceDate.Val) {
+		offset = 24 * 60 * 60
+	}
+	startTime, endTime := where.StartTime, where.EndTime
+	if where.Start != nil {
+		startTime = ptr(where.Start.Int())
+	}
+	if where.End != nil {
+		endTime = ptr(where.End.Int())
+	}
+	if startTime != nil && st.DepartureTime.Int()-offset < *startTime {
+		return false
+	}
+	if endTime != nil && st.ArrivalTime.Int()-offset > *endTime {
+		return false
+	}
+	return true
+}
+
+func (r *stopResolver) Alerts(ctx context.Context, obj *model.Stop, active *bool, limit *int, routeOnestopID *string) ([]*model.Alert, error) {
+	var route *model.Route
+	if routeOnestopID != nil {
+		routes, err := LoaderFor(ctx).RoutesByFeedVersionIDs.Load(ctx, routeLoaderParam{
+			FeedVersionID: obj.FeedVersionID,
+			Limit:         ptr(1),
+			Where:         &model.RouteFilter{OnestopID: routeOnestopID},
+		})()
+		if err != nil {
+			return nil, err
+		}
+		if len(routes) == 0 {
+			return nil, nil
+		}
+		route = routes[0]
+	}
+	rtAlerts := model.ForContext(ctx).RTFinder.FindAlertsForStop(ctx, obj, route, checkLimit(limit), active)
 	return rtAlerts, nil
 }
 
@@ -173,6 +319,39 @@ func (r *stopResolver) Directions(ctx context.Context, obj *model.Stop, from *mo
 	return directions.HandleRequest(ctx, "", p)
 }
 
+func (r *stopResolver) Isochrones(ctx context.Context, obj *model.Stop, mode *model.StepMode, departAt *time.Time, cutoffs []int) (*model.Isochrones, error) {
+	oc := obj.Coordinates()
+	p := directions.IsochroneRequest{
+		Origin: &model.WaypointInput{
+			Lon:  oc[0],
+			Lat:  oc[1],
+			Name: &obj.StopName.Val,
+		},
+		DepartAt: departAt,
+		Cutoffs:  cutoffs,
+	}
+	if mode != nil {
+		p.Mode = *mode
+	}
+	return directions.HandleIsochroneRequest(ctx, "", p)
+}
+
+func (r *stopResolver) PathwayDirections(ctx context.Context, obj *model.Stop, fromStopID string, toStopID string, wheelchair *bool, departAt *time.Time) (*model.Directions, error) {
+	cfg := model.ForContext(ctx)
+	h := pathways.NewRouter(cfg.Finder)
+	h.Clock = cfg.Clock
+	p := pathways.Request{
+		Station:    obj,
+		FromStopID: fromStopID,
+		ToStopID:   toStopID,
+		DepartAt:   departAt,
+	}
+	if wheelchair != nil {
+		p.Wheelchair = *wheelchair
+	}
+	return h.Request(ctx, p)
+}
+
 func (r *stopResolver) NearbyStops(ctx context.Context, obj *model.Stop, limit *int, radius *float64) ([]*model.Stop, error) {
 	cfg := model.ForContext(ctx)
 	c := obj.Coordinates()
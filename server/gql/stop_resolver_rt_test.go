@@ -5,6 +5,7 @@ import (
 
 	"github.com/interline-io/transitland-lib/tt"
 	"github.com/interline-io/transitland-server/internal/testconfig"
+	"github.com/interline-io/transitland-server/server/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/tidwall/gjson"
 )
@@ -519,3 +520,330 @@ func TestStopRT_Alerts(t *testing.T) {
 		testRt(t, tc)
 	}
 }
+
+func TestStopRT_AlertsForRoute(t *testing.T) {
+	q := `query($route_onestop_id:String) {
+		stops(where:{stop_id:"FTVL"}) {
+			alerts(route_onestop_id:$route_onestop_id) {
+				header_text { text }
+			}
+		}
+	}`
+	rtfiles := []testconfig.RTJsonFile{
+		{Feed: "BA", Ftype: "realtime_alerts", Fname: "BA-alerts-selectors.json"},
+	}
+	tcs := []rtTestCase{
+		{
+			name:    "any route",
+			query:   q,
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t,
+					[]string{"Route 03 at FTVL", "Route 01 at FTVL", "FTVL"},
+					astr(gjson.Get(jj, "stops.0.alerts.#.header_text.0.text").Array()),
+				)
+			},
+		},
+		{
+			name:    "route",
+			query:   q,
+			vars:    hw{"route_onestop_id": "r-9q9n-warmsprings~southfremont~richmond"},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t,
+					[]string{"Route 03 at FTVL", "FTVL"},
+					astr(gjson.Get(jj, "stops.0.alerts.#.header_text.0.text").Array()),
+				)
+			},
+		},
+	}
+	for _, tc := range tcs {
+		testRt(t, tc)
+	}
+}
+
+func TestStopRT_TripModifications(t *testing.T) {
+	const stopRtQuery = `query($stop_id:String!, $stf:StopTimeFilter!) {
+	stops(where: { stop_id: $stop_id }) {
+	  stop_id
+	  departures(where:$stf) {
+		stop_sequence
+		modified_by_detour
+		trip {
+			trip_id
+		}
+		departure {
+			scheduled
+		}
+	  }
+	}
+  }`
+	checkTrip := "1031527WKDY"
+	rtfiles := []testconfig.RTJsonFile{{Feed: "BA", Ftype: "realtime_trip_updates", Fname: "BA-trip-modifications.json"}}
+	tcs := []rtTestCase{
+		{
+			name:    "replaced stop",
+			query:   stopRtQuery,
+			vars:    hw{"stop_id": "COLS", "stf": hw{"service_date": "2018-05-30", "start": "16:05:00", "end": "16:07:00"}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				for _, st := range gjson.Get(jj, "stops.0.departures").Array() {
+					assert.NotEqual(t, checkTrip, st.Get("trip.trip_id").String(), "expected trip to be removed")
+				}
+			},
+		},
+		{
+			name:    "replacement stop",
+			query:   stopRtQuery,
+			vars:    hw{"stop_id": "OAKL", "stf": hw{"service_date": "2018-05-30", "start": "16:05:00", "end": "16:15:00"}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				a := gjson.Get(jj, "stops.0.departures").Array()
+				if assert.Equal(t, 1, len(a)) {
+					assert.Equal(t, checkTrip, a[0].Get("trip.trip_id").String())
+					assert.Equal(t, "16:10:00", a[0].Get("departure.scheduled").String())
+					assert.Equal(t, true, a[0].Get("modified_by_detour").Bool())
+				}
+			},
+		},
+		{
+			name:    "replacement stop on other date",
+			query:   stopRtQuery,
+			vars:    hw{"stop_id": "OAKL", "stf": hw{"service_date": "2018-05-31", "start": "16:05:00", "end": "16:15:00"}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.Equal(t, 0, len(gjson.Get(jj, "stops.0.departures").Array()))
+			},
+		},
+		{
+			name:    "shifted stop",
+			query:   stopRtQuery,
+			vars:    hw{"stop_id": "BAYF", "stf": hw{"service_date": "2018-05-30", "start": "16:13:00", "end": "16:15:00"}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				found := false
+				for _, st := range gjson.Get(jj, "stops.0.departures").Array() {
+					if st.Get("trip.trip_id").String() != checkTrip {
+						continue
+					}
+					found = true
+					assert.Equal(t, "16:16:00", st.Get("departure.scheduled").String())
+					assert.Equal(t, true, st.Get("modified_by_detour").Bool())
+				}
+				if !found {
+					t.Errorf("expected to find trip '%s'", checkTrip)
+				}
+			},
+		},
+	}
+	for _, tc := range tcs {
+		testRt(t, tc)
+	}
+}
+
+func TestStopRT_AsOf(t *testing.T) {
+	const stopRtQuery = `query($stop_id:String!, $stf:StopTimeFilter!, $as_of:Time) {
+	stops(where: { stop_id: $stop_id }) {
+	  stop_id
+	  departures(where:$stf, as_of:$as_of) {
+		trip {
+			trip_id
+			schedule_relationship
+		}
+		departure {
+			delay
+		}
+	  }
+	}
+  }`
+	rtfiles := rtReplayFiles()
+	tcs := []struct {
+		name        string
+		asOf        any
+		expectSr    string
+		expectDelay int
+	}{
+		{"current", nil, "SCHEDULED", 60},
+		{"replay first message", "2018-05-30T22:30:00Z", "SCHEDULED", 30},
+		{"replay second message", "2018-05-30T23:30:00Z", "SCHEDULED", 60},
+		{"replay before first message", "2018-05-30T22:00:00Z", "STATIC", 0},
+	}
+	for _, tc := range tcs {
+		vars := rtTestStopQueryVars()
+		vars["as_of"] = tc.asOf
+		testRt(t, rtTestCase{
+			name:      tc.name,
+			query:     stopRtQuery,
+			vars:      vars,
+			rtfiles:   rtfiles,
+			rtArchive: true,
+			cb: func(t *testing.T, jj string) {
+				a := gjson.Get(jj, "stops.0.departures").Array()
+				assert.Greater(t, len(a), 0)
+				for _, st := range a {
+					assert.Equal(t, tc.expectSr, st.Get("trip.schedule_relationship").String(), "trip.schedule_relationship")
+					assert.Equal(t, tc.expectDelay, int(st.Get("departure.delay").Int()), "departure.delay")
+				}
+			},
+		})
+	}
+}
+
+func TestStopRT_BlockDelays(t *testing.T) {
+	const stopRtQuery = `query($stop_id:String!, $stf:StopTimeFilter!) {
+	stops(where: { stop_id: $stop_id }) {
+	  stop_id
+	  departures(where:$stf) {
+		trip {
+			trip_id
+		}
+		departure {
+			delay
+			estimated_delay
+			estimate_source
+		}
+	  }
+	}
+  }`
+	// Trips 261, 370 and 287 share a block; only 261 has realtime data.
+	// 261 arrives 1200s late; 370 has an 840s layover; 287 has a further 1020s layover.
+	rtfiles := []testconfig.RTJsonFile{{Feed: "CT", Ftype: "realtime_trip_updates", Fname: "CT-block-delay.json"}}
+	// 261 arrives SF 17:02; 370 departs SF 17:16, arrives SJ 18:18; 287 departs SJ 18:35
+	setupBlock := func(t *testing.T, cfg model.Config) {
+		if _, err := cfg.Finder.DBX().Exec(`update gtfs_trips set block_id = 'test-block' where trip_id in ('261', '370', '287') and feed_version_id = (select fs.feed_version_id from feed_states fs join current_feeds cf on cf.id = fs.feed_id where cf.onestop_id = 'CT')`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkDeparture := func(t *testing.T, jj string, tripId string) gjson.Result {
+		a := gjson.Get(jj, "stops.0.departures").Array()
+		for _, st := range a {
+			if st.Get("trip.trip_id").String() == tripId {
+				return st.Get("departure")
+			}
+		}
+		t.Errorf("expected to find trip '%s'", tripId)
+		return gjson.Result{}
+	}
+	tcs := []rtTestCase{
+		{
+			name:          "realtime trip",
+			query:         stopRtQuery,
+			vars:          hw{"stop_id": "70271", "stf": hw{"service_date": "2018-05-30", "start": "15:31:00", "end": "15:33:00"}},
+			rtfiles:       rtfiles,
+			setupTx:       setupBlock,
+			rtBlockDelays: true,
+			cb: func(t *testing.T, jj string) {
+				dep := checkDeparture(t, jj, "261")
+				assert.Equal(t, int64(600), dep.Get("delay").Int())
+				assert.Equal(t, int64(600), dep.Get("estimated_delay").Int())
+				assert.Equal(t, "REALTIME", dep.Get("estimate_source").String())
+			},
+		},
+		{
+			name:          "next trip in block",
+			query:         stopRtQuery,
+			vars:          hw{"stop_id": "70012", "stf": hw{"service_date": "2018-05-30", "start": "17:15:00", "end": "17:17:00"}},
+			rtfiles:       rtfiles,
+			setupTx:       setupBlock,
+			rtBlockDelays: true,
+			cb: func(t *testing.T, jj string) {
+				dep := checkDeparture(t, jj, "370")
+				assert.Equal(t, gjson.Null, dep.Get("delay").Type)
+				assert.Equal(t, int64(360), dep.Get("estimated_delay").Int())
+				assert.Equal(t, "ESTIMATED_DELAY", dep.Get("estimate_source").String())
+			},
+		},
+		{
+			name:          "delay absorbed by layovers",
+			query:         stopRtQuery,
+			vars:          hw{"stop_id": "70261", "stf": hw{"service_date": "2018-05-30", "start": "18:34:00", "end": "18:36:00"}},
+			rtfiles:       rtfiles,
+			setupTx:       setupBlock,
+			rtBlockDelays: true,
+			cb: func(t *testing.T, jj string) {
+				dep := checkDeparture(t, jj, "287")
+				assert.Equal(t, gjson.Number, dep.Get("estimated_delay").Type)
+				assert.Equal(t, int64(0), dep.Get("estimated_delay").Int())
+				assert.Equal(t, "ESTIMATED_DELAY", dep.Get("estimate_source").String())
+			},
+		},
+		{
+			name:    "block delays not enabled",
+			query:   stopRtQuery,
+			vars:    hw{"stop_id": "70012", "stf": hw{"service_date": "2018-05-30", "start": "17:15:00", "end": "17:17:00"}},
+			rtfiles: rtfiles,
+			setupTx: setupBlock,
+			cb: func(t *testing.T, jj string) {
+				dep := checkDeparture(t, jj, "370")
+				assert.Equal(t, gjson.Null, dep.Get("estimated_delay").Type)
+				assert.Equal(t, gjson.Null, dep.Get("estimate_source").Type)
+			},
+		},
+	}
+	for _, tc := range tcs {
+		testRt(t, tc)
+	}
+}
+
+func TestStopRT_Frequencies(t *testing.T) {
+	const stopRtQuery = `query($stf:StopTimeFilter!) {
+	stops(where: { feed_version_sha1: "43e2278aa272879c79460582152b04e7487f0493", stop_id: "STAGECOACH" }) {
+	  stop_id
+	  departures(where:$stf) {
+		start_time
+		departure_time
+		schedule_relationship
+		trip {
+			trip_id
+			stop_times {
+				stop_sequence
+				start_time
+				departure_time
+				departure {
+					delay
+				}
+			}
+		}
+		departure {
+			delay
+		}
+	  }
+	}
+  }`
+	tc := rtTestCase{
+		name:    "frequency trip runs",
+		query:   stopRtQuery,
+		vars:    hw{"stf": hw{"service_date": "2007-01-02", "start": "06:00:00", "end": "07:00:00"}},
+		rtfiles: []testconfig.RTJsonFile{{Feed: "EX", Ftype: "realtime_trip_updates", Fname: "EX-frequencies.json"}},
+		cb: func(t *testing.T, jj string) {
+			var runs []gjson.Result
+			for _, st := range gjson.Get(jj, "stops.0.departures").Array() {
+				if st.Get("trip.trip_id").String() == "STBA" {
+					runs = append(runs, st)
+				}
+			}
+			if !assert.Equal(t, 3, len(runs)) {
+				return
+			}
+			expectStart := []string{"06:00:00", "06:30:00", "07:00:00"}
+			for i, run := range runs {
+				assert.Equal(t, expectStart[i], run.Get("start_time").String(), "start_time")
+				assert.Equal(t, expectStart[i], run.Get("departure_time").String(), "departure_time")
+			}
+			// RT is matched on trip_id and start_time
+			assert.Equal(t, "STATIC", runs[0].Get("schedule_relationship").String())
+			assert.Equal(t, gjson.Null, runs[0].Get("departure.delay").Type)
+			assert.Equal(t, int64(120), runs[1].Get("departure.delay").Int())
+			assert.Equal(t, int64(300), runs[2].Get("departure.delay").Int())
+			// Trip stop times are for the same trip run
+			sts := runs[1].Get("trip.stop_times").Array()
+			if assert.Equal(t, 2, len(sts)) {
+				assert.Equal(t, "06:30:00", sts[0].Get("departure_time").String())
+				assert.Equal(t, "06:50:00", sts[1].Get("departure_time").String())
+				assert.Equal(t, "06:30:00", sts[1].Get("start_time").String())
+				assert.Equal(t, int64(120), sts[0].Get("departure.delay").Int())
+			}
+		},
+	}
+	testRt(t, tc)
+}
@@ -17,6 +17,12 @@ type stopTimeResolver struct {
 }
 
 func (r *stopTimeResolver) Stop(ctx context.Context, obj *model.StopTime) (*model.Stop, error) {
+	if obj.RTStop != nil {
+		return obj.RTStop, nil
+	}
+	if isFlexLocation(obj) {
+		return nil, nil
+	}
 	return LoaderFor(ctx).StopsByIDs.Load(ctx, obj.StopID.Int())()
 }
 
@@ -39,19 +45,36 @@ func (r *stopTimeResolver) ScheduleRelationship(ctx context.Context, obj *model.
 }
 
 func (r *stopTimeResolver) Trip(ctx context.Context, obj *model.StopTime) (*model.Trip, error) {
+	ctx = withAsOf(ctx, obj.AsOf)
 	if obj.TripID.Val == "0" && obj.RTTripID != "" {
 		t := model.Trip{}
 		t.FeedVersionID = obj.FeedVersionID
 		t.TripID.Set(obj.RTTripID)
 		a, err := model.ForContext(ctx).RTFinder.MakeTrip(ctx, &t)
+		if a != nil {
+			a.AsOf = obj.AsOf
+		}
 		return a, err
 	}
-	return LoaderFor(ctx).TripsByIDs.Load(ctx, obj.TripID.Int())()
+	trip, err := LoaderFor(ctx).TripsByIDs.Load(ctx, obj.TripID.Int())()
+	if err != nil || trip == nil || (obj.AsOf == nil && !obj.StartTime.Valid && !obj.ServiceDate.Valid) {
+		return trip, err
+	}
+	// Trips may be shared with other requests through the loader
+	tripCopy := *trip
+	tripCopy.AsOf = obj.AsOf
+	tripCopy.StartTime = obj.StartTime
+	tripCopy.ServiceDate = obj.ServiceDate
+	return &tripCopy, nil
 }
 
 func (r *stopTimeResolver) Arrival(ctx context.Context, obj *model.StopTime) (*model.StopTimeEvent, error) {
+	// GTFS-Flex stop times without a stop have a pickup/drop off window instead
+	if isFlexLocation(obj) {
+		return &model.StopTimeEvent{}, nil
+	}
 	// Lookup timezone
-	loc, ok := model.ForContext(ctx).RTFinder.StopTimezone(ctx, obj.StopID.Int(), "")
+	loc, ok := model.ForContext(ctx).RTFinder.StopTimezone(ctx, obj.StopID.Int(), stopTimeTimezone(obj))
 	if loc == nil || !ok {
 		return nil, errors.New("timezone not available for stop")
 	}
@@ -67,12 +90,18 @@ func (r *stopTimeResolver) Arrival(ctx context.Context, obj *model.StopTime) (*m
 			ste = stu.Departure
 		}
 	}
-	return fromSte(ste, delay, obj.DepartureTime, obj.ServiceDate, loc), nil
+	a := fromSte(ste, delay, obj.DepartureTime, obj.ServiceDate, loc)
+	a.EstimateSource = estimateSource(a, obj.RTStopTimeUpdate)
+	return a, nil
 }
 
 func (r *stopTimeResolver) Departure(ctx context.Context, obj *model.StopTime) (*model.StopTimeEvent, error) {
+	// GTFS-Flex stop times without a stop have a pickup/drop off window instead
+	if isFlexLocation(obj) {
+		return &model.StopTimeEvent{}, nil
+	}
 	// Lookup timezone
-	loc, ok := model.ForContext(ctx).RTFinder.StopTimezone(ctx, obj.StopID.Int(), "")
+	loc, ok := model.ForContext(ctx).RTFinder.StopTimezone(ctx, obj.StopID.Int(), stopTimeTimezone(obj))
 	if loc == nil || !ok {
 		return nil, errors.New("timezone not available for stop")
 	}
@@ -88,7 +117,95 @@ func (r *stopTimeResolver) Departure(ctx context.Context, obj *model.StopTime) (
 			ste = stu.Arrival
 		}
 	}
-	return fromSte(ste, delay, obj.DepartureTime, obj.ServiceDate, loc), nil
+	a := fromSte(ste, delay, obj.DepartureTime, obj.ServiceDate, loc)
+	a.EstimateSource = estimateSource(a, obj.RTStopTimeUpdate)
+	return a, nil
+}
+
+func (r *stopTimeResolver) StartPickupDropOffWindow(ctx context.Context, obj *model.StopTime) (*tt.Seconds, error) {
+	fst, err := flexStopTime(ctx, obj)
+	if fst == nil || !fst.StartPickupDropOffWindow.Valid {
+		return nil, err
+	}
+	return &fst.StartPickupDropOffWindow, nil
+}
+
+func (r *stopTimeResolver) EndPickupDropOffWindow(ctx context.Context, obj *model.StopTime) (*tt.Seconds, error) {
+	fst, err := flexStopTime(ctx, obj)
+	if fst == nil || !fst.EndPickupDropOffWindow.Valid {
+		return nil, err
+	}
+	return &fst.EndPickupDropOffWindow, nil
+}
+
+func (r *stopTimeResolver) Location(ctx context.Context, obj *model.StopTime) (*model.Location, error) {
+	fst, err := flexStopTime(ctx, obj)
+	if fst == nil {
+		return nil, err
+	}
+	return loadLocation(ctx, fst.LocationID)
+}
+
+func (r *stopTimeResolver) LocationGroup(ctx context.Context, obj *model.StopTime) (*model.LocationGroup, error) {
+	fst, err := flexStopTime(ctx, obj)
+	if fst == nil {
+		return nil, err
+	}
+	return loadLocationGroup(ctx, fst.LocationGroupID)
+}
+
+func (r *stopTimeResolver) PickupBookingRule(ctx context.Context, obj *model.StopTime) (*model.BookingRule, error) {
+	fst, err := flexStopTime(ctx, obj)
+	if fst == nil {
+		return nil, err
+	}
+	return loadBookingRule(ctx, fst.PickupBookingRuleID)
+}
+
+func (r *stopTimeResolver) DropOffBookingRule(ctx context.Context, obj *model.StopTime) (*model.BookingRule, error) {
+	fst, err := flexStopTime(ctx, obj)
+	if fst == nil {
+		return nil, err
+	}
+	return loadBookingRule(ctx, fst.DropOffBookingRuleID)
+}
+
+// flexStopTime returns the GTFS-Flex attributes of a stop time, if any
+func flexStopTime(ctx context.Context, obj *model.StopTime) (*model.FlexService, error) {
+	fsts, err := LoaderFor(ctx).FlexStopTimesByTripIDs.Load(ctx, obj.TripID.Int())()
+	if err != nil {
+		return nil, err
+	}
+	for _, fst := range fsts {
+		if fst.StopSequence == obj.StopSequence.Int() {
+			return fst, nil
+		}
+	}
+	return nil, nil
+}
+
+// isFlexLocation reports whether a GTFS-Flex stop time serves a location or location group instead of a stop
+func isFlexLocation(obj *model.StopTime) bool {
+	return !obj.StopID.Valid
+}
+
+// stopTimeTimezone returns the timezone of a replacement stop, if known
+func stopTimeTimezone(obj *model.StopTime) string {
+	if obj.RTStop != nil {
+		return obj.RTStop.StopTimezone.Val
+	}
+	return ""
+}
+
+// estimateSource reports whether an estimate is from realtime data or propagated through a block
+func estimateSource(a *model.StopTimeEvent, rtStu *model.RTStopTimeUpdate) *model.StopTimeEventSource {
+	if a.EstimatedUtc == nil || rtStu == nil {
+		return nil
+	}
+	if rtStu.EstimatedDelay {
+		return ptr(model.StopTimeEventSourceEstimatedDelay)
+	}
+	return ptr(model.StopTimeEventSourceRealtime)
 }
 
 func fromSte(ste *pb.TripUpdate_StopTimeEvent, lastDelay *int32, sched tt.Seconds, serviceDate tt.Date, loc *time.Location) *model.StopTimeEvent {
@@ -0,0 +1,172 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// SUBSCRIPTIONS
+
+type subscriptionResolver struct{ *Resolver }
+
+// StopDepartures sends the next departures for the requested stops, and again after each realtime update.
+func (r *subscriptionResolver) StopDepartures(ctx context.Context, stopIds []int, next *int, limit *int) (<-chan []*model.StopTime, error) {
+	cfg := model.ForContext(ctx)
+	if len(stopIds) == 0 {
+		return nil, errors.New("must specify at least one stop_id")
+	}
+	stops, err := cfg.Finder.FindStops(ctx, nil, nil, stopIds, nil)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		next = ptr(3600)
+	}
+	var fvids []int
+	for _, stop := range stops {
+		fvids = append(fvids, stop.FeedVersionID)
+	}
+	sr := &stopResolver{r.Resolver}
+	return subscribeUpdates(ctx, cfg.RTFinder.SubscribeFeedVersions(ctx, fvids, "realtime_trip_updates"), func(ctx context.Context) ([]*model.StopTime, error) {
+		ret := []*model.StopTime{}
+		for _, stop := range stops {
+			sts, err := sr.Departures(ctx, stop, limit, &model.StopTimeFilter{Next: next}, nil)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, sts...)
+		}
+		return ret, nil
+	}), nil
+}
+
+// TripUpdates sends the requested trips, and again after each realtime update.
+func (r *subscriptionResolver) TripUpdates(ctx context.Context, tripIds []int) (<-chan []*model.Trip, error) {
+	cfg := model.ForContext(ctx)
+	if len(tripIds) == 0 {
+		return nil, errors.New("must specify at least one trip_id")
+	}
+	trips, err := cfg.Finder.FindTrips(ctx, nil, nil, tripIds, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(trips) == 0 {
+		return nil, errors.New("no trips found")
+	}
+	var fvids []int
+	for _, trip := range trips {
+		fvids = append(fvids, trip.FeedVersionID)
+	}
+	return subscribeUpdates(ctx, cfg.RTFinder.SubscribeFeedVersions(ctx, fvids, "realtime_trip_updates", "realtime_vehicle_positions"), func(ctx context.Context) ([]*model.Trip, error) {
+		return trips, nil
+	}), nil
+}
+
+// Alerts sends the currently known alerts, and again after each realtime update.
+func (r *subscriptionResolver) Alerts(ctx context.Context, limit *int, where *model.AlertFilter) (<-chan []*model.Alert, error) {
+	cfg := model.ForContext(ctx)
+	if where == nil {
+		where = &model.AlertFilter{}
+	}
+	feeds, err := alertFeeds(ctx, where)
+	if err != nil {
+		return nil, err
+	}
+	return subscribeUpdates(ctx, cfg.RTFinder.Subscribe(ctx, feeds, "realtime_alerts"), func(ctx context.Context) ([]*model.Alert, error) {
+		alerts := cfg.RTFinder.FindAlertsForFeeds(ctx, feeds, nil, where.Active)
+		return filterAlerts(ctx, alerts, limit, where)
+	}), nil
+}
+
+// subscribeUpdates sends an initial result and a new result after each signal on updates.
+// Each result is computed with fresh loaders, as loader results are cached for the life of the context.
+func subscribeUpdates[T any](ctx context.Context, updates <-chan struct{}, fn func(context.Context) ([]T, error)) <-chan []T {
+	cfg := model.ForContext(ctx)
+	out := make(chan []T, 1)
+	go func() {
+		defer close(out)
+		for {
+			loaderCtx := context.WithValue(ctx, loadersKey, NewLoaders(cfg.Finder, cfg.LoaderBatchSize, cfg.LoaderStopTimeBatchSize))
+			ents, err := fn(loaderCtx)
+			if err != nil {
+				setSubscriptionError(ctx, err)
+				return
+			}
+			select {
+			case out <- ents:
+			case <-ctx.Done():
+				return
+			}
+			if _, ok := <-updates; !ok {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// subscriptionError holds the error that ended a subscription, until it is sent to the client
+type subscriptionError struct {
+	lock sync.Mutex
+	err  error
+}
+
+type subscriptionErrorKey struct{}
+
+func setSubscriptionError(ctx context.Context, err error) {
+	if se, ok := ctx.Value(subscriptionErrorKey{}).(*subscriptionError); ok {
+		se.lock.Lock()
+		se.err = err
+		se.lock.Unlock()
+	}
+}
+
+// subscriptionErrors sends the error that ended a subscription to the client before the subscription is completed.
+// Errors added to the resolver context are not returned once the subscription has started.
+type subscriptionErrors struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.ResponseInterceptor
+} = subscriptionErrors{}
+
+func (subscriptionErrors) ExtensionName() string {
+	return "SubscriptionErrors"
+}
+
+func (subscriptionErrors) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (subscriptionErrors) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	if oc := graphql.GetOperationContext(ctx); oc.Operation == nil || oc.Operation.Operation != ast.Subscription {
+		return next(ctx)
+	}
+	return next(context.WithValue(ctx, subscriptionErrorKey{}, &subscriptionError{}))
+}
+
+func (subscriptionErrors) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	if resp != nil {
+		return resp
+	}
+	se, ok := ctx.Value(subscriptionErrorKey{}).(*subscriptionError)
+	if !ok {
+		return nil
+	}
+	se.lock.Lock()
+	err := se.err
+	se.err = nil
+	se.lock.Unlock()
+	if err == nil {
+		return nil
+	}
+	graphql.AddError(ctx, err)
+	return &graphql.Response{Errors: graphql.GetErrors(ctx)}
+}
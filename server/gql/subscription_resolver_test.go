@@ -0,0 +1,47 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/interline-io/transitland-server/server/finders/dbfinder"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestSubscriptionErrors(t *testing.T) {
+	ext := subscriptionErrors{}
+	ctx := model.WithConfig(context.Background(), model.Config{Finder: dbfinder.NewFinder(nil)})
+	ctx = graphql.WithOperationContext(ctx, &graphql.OperationContext{
+		Operation: &ast.OperationDefinition{Operation: ast.Subscription},
+	})
+	// Responses are requested with the context passed to the operation handler
+	var innerCtx context.Context
+	var responses graphql.ResponseHandler
+	ext.InterceptOperation(ctx, func(ctx context.Context) graphql.ResponseHandler {
+		innerCtx = ctx
+		out := subscribeUpdates(ctx, nil, func(context.Context) ([]int, error) {
+			return nil, errors.New("fetch failed")
+		})
+		responses = func(ctx context.Context) *graphql.Response {
+			if _, ok := <-out; !ok {
+				return nil
+			}
+			return &graphql.Response{}
+		}
+		return responses
+	})
+	next := func() *graphql.Response {
+		rctx := graphql.WithResponseContext(innerCtx, graphql.DefaultErrorPresenter, nil)
+		return ext.InterceptResponse(rctx, responses)
+	}
+	// The error is sent once, then the subscription is completed
+	resp := next()
+	if assert.NotNil(t, resp) && assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "fetch failed", resp.Errors[0].Message)
+	}
+	assert.Nil(t, next())
+}
@@ -2,8 +2,12 @@ package gql
 
 import (
 	"context"
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-lib/tt"
 	"github.com/interline-io/transitland-server/server/model"
 )
 
@@ -25,6 +29,13 @@ func (r *tripResolver) FeedVersion(ctx context.Context, obj *model.Trip) (*model
 }
 
 func (r *tripResolver) Shape(ctx context.Context, obj *model.Trip) (*model.Shape, error) {
+	ctx = withAsOf(ctx, obj.AsOf)
+	// Use the detour shape if provided by an active trip modification
+	if tm, ok, err := findActiveTripModifications(ctx, obj); err != nil {
+		return nil, err
+	} else if ok && tm.Shape != nil {
+		return tm.Shape, nil
+	}
 	if !obj.ShapeID.Valid {
 		return nil, nil
 	}
@@ -36,18 +47,58 @@ func (r *tripResolver) Calendar(ctx context.Context, obj *model.Trip) (*model.Ca
 }
 
 func (r *tripResolver) StopTimes(ctx context.Context, obj *model.Trip, limit *int, where *model.TripStopTimeFilter) ([]*model.StopTime, error) {
-	sts, err := LoaderFor(ctx).StopTimesByTripIDs.Load(ctx, tripStopTimeLoaderParam{
-		FeedVersionID: obj.FeedVersionID,
-		TripID:        obj.ID,
-		Limit:         checkLimit(limit),
-		Where:         where,
-	})()
+	ctx = withAsOf(ctx, obj.AsOf)
+	// Use the trip run for an expanded frequency-based trip, unless specified
+	if obj.StartTime.Valid && (where == nil || (where.StartTime == nil && where.ExpandFrequencies == nil)) {
+		w := model.TripStopTimeFilter{}
+		if where != nil {
+			w = *where
+		}
+		w.StartTime = &obj.StartTime
+		where = &w
+	}
+	var sts []*model.StopTime
+	tm, tmOk, err := findActiveTripModifications(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	if !tmOk {
+		sts, err = LoaderFor(ctx).StopTimesByTripIDs.Load(ctx, tripStopTimeLoaderParam{
+			FeedVersionID: obj.FeedVersionID,
+			TripID:        obj.ID,
+			Limit:         checkLimit(limit),
+			Where:         where,
+		})()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Modifications are applied to the full list of stops, then filtered by time and limit
+		sts, err = modifiedTripStopTimes(ctx, obj, tm, where, *checkLimit(limit))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if obj.AsOf != nil {
+		// Stop times may be shared with other requests through the loader
+		var asOfSts []*model.StopTime
+		for _, st := range sts {
+			stCopy := *st
+			stCopy.AsOf = obj.AsOf
+			asOfSts = append(asOfSts, &stCopy)
+		}
+		sts = asOfSts
+	}
 	for _, st := range sts {
+		// Replacement stops share a stop_sequence with the stop they replace; do not match these
+		if st.RTStop != nil {
+			continue
+		}
 		if ste, ok := model.ForContext(ctx).RTFinder.FindStopTimeUpdate(ctx, obj, st); ok {
 			st.RTStopTimeUpdate = ste
 		}
 	}
-	return sts, err
+	return sts, nil
 }
 
 func (r *tripResolver) Frequencies(ctx context.Context, obj *model.Trip, limit *int) ([]*model.Frequency, error) {
@@ -55,6 +106,7 @@ func (r *tripResolver) Frequencies(ctx context.Context, obj *model.Trip, limit *
 }
 
 func (r *tripResolver) ScheduleRelationship(ctx context.Context, obj *model.Trip) (*model.ScheduleRelationship, error) {
+	ctx = withAsOf(ctx, obj.AsOf)
 	if rtt := model.ForContext(ctx).RTFinder.FindTrip(ctx, obj); rtt != nil {
 		// If TripUpdate TripDescriptor has schedule relationship, use that
 		if rtt.Trip != nil && rtt.Trip.ScheduleRelationship != nil {
@@ -68,6 +120,7 @@ func (r *tripResolver) ScheduleRelationship(ctx context.Context, obj *model.Trip
 }
 
 func (r *tripResolver) Timestamp(ctx context.Context, obj *model.Trip) (*time.Time, error) {
+	ctx = withAsOf(ctx, obj.AsOf)
 	if rtt := model.ForContext(ctx).RTFinder.FindTrip(ctx, obj); rtt != nil {
 		t := time.Unix(int64(rtt.GetTimestamp()), 0).In(time.UTC)
 		return &t, nil
@@ -76,6 +129,239 @@ func (r *tripResolver) Timestamp(ctx context.Context, obj *model.Trip) (*time.Ti
 }
 
 func (r *tripResolver) Alerts(ctx context.Context, obj *model.Trip, active *bool, limit *int) ([]*model.Alert, error) {
+	ctx = withAsOf(ctx, obj.AsOf)
 	rtAlerts := model.ForContext(ctx).RTFinder.FindAlertsForTrip(ctx, obj, checkLimit(limit), active)
 	return rtAlerts, nil
 }
+
+func (r *tripResolver) VehiclePosition(ctx context.Context, obj *model.Trip) (*model.VehiclePosition, error) {
+	ctx = withAsOf(ctx, obj.AsOf)
+	return model.ForContext(ctx).RTFinder.FindVehiclePositionForTrip(ctx, obj), nil
+}
+
+func (r *tripResolver) BookingRule(ctx context.Context, obj *model.Trip) (*model.BookingRule, error) {
+	sts, err := LoaderFor(ctx).FlexStopTimesByTripIDs.Load(ctx, obj.ID)()
+	if err != nil {
+		return nil, err
+	}
+	// Prefer the first pickup booking rule, then the first drop off booking rule
+	for _, st := range sts {
+		if st.PickupBookingRuleID.Valid {
+			return loadBookingRule(ctx, st.PickupBookingRuleID)
+		}
+	}
+	for _, st := range sts {
+		if st.DropOffBookingRuleID.Valid {
+			return loadBookingRule(ctx, st.DropOffBookingRuleID)
+		}
+	}
+	return nil, nil
+}
+
+// withAsOf sets the time for replaying archived realtime data, if specified
+func withAsOf(ctx context.Context, asOf *time.Time) context.Context {
+	if asOf == nil {
+		return ctx
+	}
+	return model.WithAsOf(ctx, *asOf)
+}
+
+// TRIP MODIFICATIONS
+
+// applyTripModifications returns a copy of the stop times for a trip with GTFS-RT trip modifications applied.
+// Each modification removes the stops between the start and end stop selectors (inclusive),
+// adds the replacement stops, and shifts the following stops by the propagated modification delay.
+// Added and shifted stop times are flagged with ModifiedByDetour.
+func applyTripModifications(ctx context.Context, sts []*model.StopTime, tm *model.RTTripModifications) ([]*model.StopTime, error) {
+	var ret []*model.StopTime
+	for _, st := range sts {
+		stCopy := *st
+		ret = append(ret, &stCopy)
+	}
+	for _, mod := range tm.TripModifications.GetModifications() {
+		start := findStopSelector(ctx, ret, mod.StartStopSelector)
+		end := start
+		if mod.EndStopSelector != nil {
+			end = findStopSelector(ctx, ret, mod.EndStopSelector)
+		}
+		if start < 0 || end < start {
+			continue
+		}
+		// Replacement stop times are relative to the arrival at the stop before the modification,
+		// or the first stop of the trip if the modification begins at the first stop
+		ref := ret[start]
+		if start > 0 {
+			ref = ret[start-1]
+		}
+		var added []*model.StopTime
+		for _, rs := range mod.GetReplacementStops() {
+			stop, err := findReplacementStop(ctx, ref, rs.GetStopId(), tm)
+			if err != nil {
+				return nil, err
+			}
+			if stop == nil {
+				continue
+			}
+			t := tt.NewSeconds(ref.ArrivalTime.Int() + int(rs.GetTravelTimeToStop()))
+			rst := &model.StopTime{
+				ServiceDate:      ret[start].ServiceDate,
+				Date:             ret[start].Date,
+				RTStop:           stop,
+				ModifiedByDetour: true,
+			}
+			rst.FeedVersionID = ret[start].FeedVersionID
+			rst.TripID = ret[start].TripID
+			rst.StopID.Set(strconv.Itoa(stop.ID))
+			rst.StopSequence = ret[start].StopSequence
+			rst.StopHeadsign = ret[start].StopHeadsign
+			rst.ArrivalTime = t
+			rst.DepartureTime = t
+			added = append(added, rst)
+		}
+		after := ret[end+1:]
+		if delay := int(mod.GetPropagatedModificationDelay()); delay != 0 {
+			for _, st := range after {
+				st.ArrivalTime = tt.NewSeconds(st.ArrivalTime.Int() + delay)
+				st.DepartureTime = tt.NewSeconds(st.DepartureTime.Int() + delay)
+				st.ModifiedByDetour = true
+			}
+		}
+		var next []*model.StopTime
+		next = append(next, ret[:start]...)
+		next = append(next, added...)
+		next = append(next, after...)
+		ret = next
+	}
+	return ret, nil
+}
+
+// findStopSelector returns the index of the scheduled stop time matching a StopSelector, or -1.
+func findStopSelector(ctx context.Context, sts []*model.StopTime, sel *pb.StopSelector) int {
+	if sel == nil {
+		return -1
+	}
+	for i, st := range sts {
+		if st.RTStop != nil {
+			continue
+		}
+		if sel.StopSequence != nil {
+			if st.StopSequence.Int() == int(sel.GetStopSequence()) {
+				return i
+			}
+			continue
+		}
+		if sid, ok := model.ForContext(ctx).RTFinder.GetGtfsStopID(ctx, st.StopID.Int()); ok && sid == sel.GetStopId() {
+			return i
+		}
+	}
+	return -1
+}
+
+// findReplacementStop returns the stop from the static feed, or a stop defined in the realtime feed.
+func findReplacementStop(ctx context.Context, ref *model.StopTime, stopID string, tm *model.RTTripModifications) (*model.Stop, error) {
+	stops, err := LoaderFor(ctx).StopsByFeedVersionIDs.Load(ctx, stopLoaderParam{FeedVersionID: ref.FeedVersionID, Limit: ptr(1), Where: &model.StopFilter{StopID: &stopID}})()
+	if err != nil {
+		return nil, err
+	}
+	if len(stops) > 0 {
+		return stops[0], nil
+	}
+	rtStop, ok := tm.Stops[stopID]
+	if !ok {
+		return nil, nil
+	}
+	// Realtime stops use the timezone of the stop before the modification if not specified
+	stop := *rtStop
+	if !stop.StopTimezone.Valid {
+		if loc, ok := model.ForContext(ctx).RTFinder.StopTimezone(ctx, ref.StopID.Int(), ""); ok && loc != nil {
+			stop.StopTimezone.Set(loc.String())
+		}
+	}
+	return &stop, nil
+}
+
+// findActiveTripModifications returns the trip modifications for a trip, if they apply on its service date.
+// Trips that are not reached through a departure use the current date in the agency timezone.
+func findActiveTripModifications(ctx context.Context, obj *model.Trip) (*model.RTTripModifications, bool, error) {
+	tm, ok := model.ForContext(ctx).RTFinder.FindTripModifications(ctx, obj)
+	if !ok {
+		return nil, false, nil
+	}
+	serviceDate := obj.ServiceDate
+	if !serviceDate.Valid && len(tm.TripModifications.GetServiceDates()) > 0 {
+		d, err := tripLocalDate(ctx, obj)
+		if err != nil {
+			return nil, false, err
+		}
+		serviceDate = d
+	}
+	return tm, tripModificationsActive(tm, serviceDate), nil
+}
+
+// tripLocalDate returns the current date in the timezone of the agency operating a trip
+func tripLocalDate(ctx context.Context, obj *model.Trip) (tt.Date, error) {
+	now := time.Now()
+	if cfg := model.ForContext(ctx); cfg.Clock != nil {
+		now = cfg.Clock.Now()
+	}
+	route, err := LoaderFor(ctx).RoutesByIDs.Load(ctx, obj.RouteID.Int())()
+	if err != nil || route == nil {
+		return tt.Date{}, err
+	}
+	agency, err := LoaderFor(ctx).AgenciesByIDs.Load(ctx, route.AgencyID.Int())()
+	if err != nil || agency == nil {
+		return tt.Date{}, err
+	}
+	loc, err := time.LoadLocation(agency.AgencyTimezone.Val)
+	if err != nil {
+		return tt.Date{}, nil
+	}
+	local := now.In(loc)
+	return tt.NewDate(time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)), nil
+}
+
+// modifiedTripStopTimes loads all stop times for a trip, applies trip modifications,
+// and then filters the result by the requested time range and limit.
+func modifiedTripStopTimes(ctx context.Context, obj *model.Trip, tm *model.RTTripModifications, where *model.TripStopTimeFilter, limit int) ([]*model.StopTime, error) {
+	var loadWhere *model.TripStopTimeFilter
+	if where != nil {
+		loadWhere = &model.TripStopTimeFilter{StartTime: where.StartTime, ExpandFrequencies: where.ExpandFrequencies}
+	}
+	sts, err := LoaderFor(ctx).StopTimesByTripIDs.Load(ctx, tripStopTimeLoaderParam{
+		FeedVersionID: obj.FeedVersionID,
+		TripID:        obj.ID,
+		Limit:         ptr(MAXLIMIT),
+		Where:         loadWhere,
+	})()
+	if err != nil {
+		return nil, err
+	}
+	sts, err = applyTripModifications(ctx, sts, tm)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*model.StopTime
+	for _, st := range sts {
+		if where != nil && where.Start != nil && st.DepartureTime.Int() < where.Start.Int() {
+			continue
+		}
+		if where != nil && where.End != nil && st.ArrivalTime.Int() > where.End.Int() {
+			continue
+		}
+		if len(ret) >= limit {
+			break
+		}
+		ret = append(ret, st)
+	}
+	return ret, nil
+}
+
+// tripModificationsActive checks if the modifications apply on a service date.
+// If the service date is not known, the modifications are always applied.
+func tripModificationsActive(tm *model.RTTripModifications, serviceDate tt.Date) bool {
+	dates := tm.TripModifications.GetServiceDates()
+	if !serviceDate.Valid || len(dates) == 0 {
+		return true
+	}
+	return slices.Contains(dates, serviceDate.Val.Format("20060102"))
+}
@@ -133,3 +133,201 @@ func TestTripRT_StopTimes(t *testing.T) {
 		testRt(t, tc)
 	}
 }
+
+func TestTripRT_TripModifications(t *testing.T) {
+	const tripRtQuery = `query($trip_id:String!, $stf:TripStopTimeFilter, $limit:Int) {
+	trips(where: { trip_id: $trip_id }) {
+	  trip_id
+	  shape {
+		shape_id
+		geometry
+	  }
+	  stop_times(limit:$limit, where:$stf) {
+		stop_sequence
+		modified_by_detour
+		stop {
+			stop_id
+			stop_name
+		}
+		arrival {
+			scheduled
+			stop_timezone
+		}
+	  }
+	}
+  }`
+	checkTrip := "1031527WKDY"
+	rtfiles := []testconfig.RTJsonFile{{Feed: "BA", Ftype: "realtime_trip_updates", Fname: "BA-trip-modifications.json"}}
+	tcs := []rtTestCase{
+		{
+			name:    "trip modifications",
+			query:   tripRtQuery,
+			vars:    hw{"trip_id": checkTrip, "limit": 100},
+			rtfiles: rtfiles,
+			whenUtc: "2018-05-30T22:00:00Z",
+			cb: func(t *testing.T, jj string) {
+				trip := gjson.Get(jj, "trips.0")
+				assert.Equal(t, "detour-shape-1", trip.Get("shape.shape_id").String())
+				assert.Equal(t, 4, len(trip.Get("shape.geometry.coordinates").Array()))
+				a := trip.Get("stop_times").Array()
+				if !assert.Equal(t, 20, len(a)) {
+					return
+				}
+				expect := []struct {
+					stopID   string
+					arrival  string
+					modified bool
+				}{
+					{"FTVL", "16:02:00", false},
+					{"detour-stop-1", "16:06:00", true},
+					{"OAKL", "16:10:00", true},
+					{"BAYF", "16:16:00", true},
+				}
+				for i, e := range expect {
+					st := a[11+i]
+					assert.Equal(t, e.stopID, st.Get("stop.stop_id").String(), "stop.stop_id")
+					assert.Equal(t, e.arrival, st.Get("arrival.scheduled").String(), "arrival.scheduled")
+					assert.Equal(t, e.modified, st.Get("modified_by_detour").Bool(), "modified_by_detour")
+					assert.Equal(t, "America/Los_Angeles", st.Get("arrival.stop_timezone").String(), "arrival.stop_timezone")
+				}
+				assert.Equal(t, "Detour stop", a[12].Get("stop.stop_name").String())
+			},
+		},
+		{
+			name:    "trip modifications filtered after applying",
+			query:   tripRtQuery,
+			vars:    hw{"trip_id": checkTrip, "limit": 2, "stf": hw{"start": "16:05:00", "end": "16:20:00"}},
+			rtfiles: rtfiles,
+			whenUtc: "2018-05-30T22:00:00Z",
+			cb: func(t *testing.T, jj string) {
+				var stopIds []string
+				for _, st := range gjson.Get(jj, "trips.0.stop_times").Array() {
+					stopIds = append(stopIds, st.Get("stop.stop_id").String())
+				}
+				assert.Equal(t, []string{"detour-stop-1", "OAKL"}, stopIds)
+			},
+		},
+		{
+			name:    "trip modifications on other date",
+			query:   tripRtQuery,
+			vars:    hw{"trip_id": checkTrip, "limit": 100},
+			rtfiles: rtfiles,
+			whenUtc: "2018-05-31T22:00:00Z",
+			cb: func(t *testing.T, jj string) {
+				trip := gjson.Get(jj, "trips.0")
+				assert.NotEqual(t, "detour-shape-1", trip.Get("shape.shape_id").String())
+				for _, st := range trip.Get("stop_times").Array() {
+					assert.NotEqual(t, "detour-stop-1", st.Get("stop.stop_id").String())
+					assert.False(t, st.Get("modified_by_detour").Bool(), "modified_by_detour")
+				}
+			},
+		},
+	}
+	for _, tc := range tcs {
+		testRt(t, tc)
+	}
+}
+
+func TestTripRT_AsOf(t *testing.T) {
+	const tripRtQuery = `query($trip_id:String!, $as_of:Time) {
+	trips(where: { trip_id: $trip_id }, as_of: $as_of) {
+	  trip_id
+	  stop_times(limit:100) {
+		arrival {
+			delay
+		}
+	  }
+	}
+  }`
+	rtfiles := rtReplayFiles()
+	tcs := []struct {
+		name        string
+		asOf        any
+		expectDelay int
+	}{
+		{"current", nil, 60},
+		{"replay", "2018-05-30T22:30:00Z", 30},
+	}
+	for _, tc := range tcs {
+		testRt(t, rtTestCase{
+			name:      tc.name,
+			query:     tripRtQuery,
+			vars:      hw{"trip_id": "1031527WKDY", "as_of": tc.asOf},
+			rtfiles:   rtfiles,
+			rtArchive: true,
+			cb: func(t *testing.T, jj string) {
+				a := gjson.Get(jj, "trips.0.stop_times").Array()
+				assert.Equal(t, 20, len(a))
+				for _, st := range a {
+					assert.Equal(t, tc.expectDelay, int(st.Get("arrival.delay").Int()), "arrival.delay")
+				}
+			},
+		})
+	}
+}
+
+func TestTripRT_Frequencies(t *testing.T) {
+	const tripRtQuery = `query($stf:TripStopTimeFilter) {
+	trips(where: { feed_version_sha1: "43e2278aa272879c79460582152b04e7487f0493", trip_id: "STBA" }) {
+	  trip_id
+	  stop_times(limit:1000, where:$stf) {
+		stop_sequence
+		start_time
+		departure_time
+		departure {
+			delay
+		}
+	  }
+	}
+  }`
+	rtfiles := []testconfig.RTJsonFile{{Feed: "EX", Ftype: "realtime_trip_updates", Fname: "EX-frequencies.json"}}
+	tcs := []rtTestCase{
+		{
+			name:    "template trip",
+			query:   tripRtQuery,
+			vars:    hw{},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				sts := gjson.Get(jj, "trips.0.stop_times").Array()
+				if assert.Equal(t, 2, len(sts)) {
+					assert.Equal(t, "06:00:00", sts[0].Get("departure_time").String())
+					assert.Equal(t, gjson.Null, sts[0].Get("start_time").Type)
+				}
+			},
+		},
+		{
+			name:    "expand frequencies",
+			query:   tripRtQuery,
+			vars:    hw{"stf": hw{"expand_frequencies": true}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				// 6:00:00 -> 22:00:00, 1800 headway_secs
+				sts := gjson.Get(jj, "trips.0.stop_times").Array()
+				if assert.Equal(t, 33*2, len(sts)) {
+					assert.Equal(t, "06:00:00", sts[0].Get("start_time").String())
+					assert.Equal(t, "06:20:00", sts[1].Get("departure_time").String())
+					assert.Equal(t, "22:00:00", sts[64].Get("start_time").String())
+					assert.Equal(t, "22:20:00", sts[65].Get("departure_time").String())
+					assert.Equal(t, int64(120), sts[2].Get("departure.delay").Int())
+				}
+			},
+		},
+		{
+			name:    "trip run",
+			query:   tripRtQuery,
+			vars:    hw{"stf": hw{"start_time": "07:00:00"}},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				sts := gjson.Get(jj, "trips.0.stop_times").Array()
+				if assert.Equal(t, 2, len(sts)) {
+					assert.Equal(t, "07:00:00", sts[0].Get("departure_time").String())
+					assert.Equal(t, "07:20:00", sts[1].Get("departure_time").String())
+					assert.Equal(t, int64(300), sts[0].Get("departure.delay").Int())
+				}
+			},
+		},
+	}
+	for _, tc := range tcs {
+		testRt(t, tc)
+	}
+}
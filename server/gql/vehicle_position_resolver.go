@@ -0,0 +1,87 @@
+package gql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/interline-io/transitland-lib/tlxy"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// VEHICLE POSITIONS
+
+type vehiclePositionResolver struct{ *Resolver }
+
+func (r *vehiclePositionResolver) StopID(ctx context.Context, obj *model.VehiclePosition) (*model.Stop, error) {
+	if obj.RTStopID == "" {
+		return nil, nil
+	}
+	stops, err := LoaderFor(ctx).StopsByFeedVersionIDs.Load(ctx, stopLoaderParam{
+		FeedVersionID: obj.FeedVersionID,
+		Limit:         ptr(1),
+		Where:         &model.StopFilter{StopID: &obj.RTStopID},
+	})()
+	if err != nil {
+		return nil, err
+	}
+	if len(stops) > 0 {
+		return stops[0], nil
+	}
+	return nil, nil
+}
+
+func (r *queryResolver) Vehicles(ctx context.Context, limit *int, where *model.VehicleFilter) ([]*model.VehiclePosition, error) {
+	cfg := model.ForContext(ctx)
+	ctx = addMetric(ctx, "vehicles")
+	if where == nil || (where.Bbox == nil && where.Near == nil && len(where.RouteOnestopIds) == 0) {
+		return nil, errors.New("must specify at least one of bbox, near, or route_onestop_ids")
+	}
+	if err := checkGeo(cfg.MaxRadius, where.Near, where.Bbox); err != nil {
+		return nil, err
+	}
+	// Find candidate routes; vehicle positions are indexed by route
+	routes, err := cfg.Finder.FindRoutes(ctx, ptr(MAXLIMIT), nil, nil, &model.RouteFilter{
+		OnestopIds: where.RouteOnestopIds,
+		Bbox:       where.Bbox,
+		Near:       where.Near,
+	})
+	if err != nil {
+		return nil, err
+	}
+	lim := checkLimit(limit)
+	var ret []*model.VehiclePosition
+	for _, route := range routes {
+		for _, vp := range cfg.RTFinder.FindVehiclePositionsForRoute(ctx, route, nil) {
+			if !checkVehiclePosition(vp, where) {
+				continue
+			}
+			ret = append(ret, vp)
+			if len(ret) >= *lim {
+				return ret, nil
+			}
+		}
+	}
+	return ret, nil
+}
+
+// checkVehiclePosition checks the reported position is within the requested geographic area
+func checkVehiclePosition(vp *model.VehiclePosition, where *model.VehicleFilter) bool {
+	if where.Bbox == nil && where.Near == nil {
+		return true
+	}
+	if vp.Position == nil {
+		return false
+	}
+	pt := vp.Position.ToPoint()
+	if bbox := where.Bbox; bbox != nil {
+		if pt.Lon < bbox.MinLon || pt.Lon > bbox.MaxLon || pt.Lat < bbox.MinLat || pt.Lat > bbox.MaxLat {
+			return false
+		}
+	}
+	if near := where.Near; near != nil {
+		if tlxy.DistanceHaversine(tlxy.Point{Lon: near.Lon, Lat: near.Lat}, pt) > near.Radius {
+			return false
+		}
+	}
+	return true
+}
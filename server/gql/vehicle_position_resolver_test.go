@@ -0,0 +1,119 @@
+package gql
+
+import (
+	"testing"
+
+	"github.com/interline-io/transitland-server/internal/testconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestVehiclePositionRT(t *testing.T) {
+	rtfiles := []testconfig.RTJsonFile{
+		{Feed: "CT", Ftype: "realtime_vehicle_positions", Fname: "CT-vehicle-positions.json"},
+	}
+	tcs := []rtTestCase{
+		{
+			name: "trip vehicle position",
+			query: `query($trip_id:String!) {
+				trips(where: {feed_onestop_id: "CT", trip_id: $trip_id}) {
+					trip_id
+					vehicle_position {
+						vehicle { id label }
+						trip { trip_id route_id }
+						position
+						current_stop_sequence
+						current_status
+						stop_id { stop_id }
+					}
+				}
+			}`,
+			vars:    hw{"trip_id": "101"},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				vp := gjson.Get(jj, "trips.0.vehicle_position")
+				assert.Equal(t, "v101", vp.Get("vehicle.id").String())
+				assert.Equal(t, "Lo-130", vp.Get("trip.route_id").String())
+				assert.Equal(t, "70241", vp.Get("stop_id.stop_id").String())
+				assert.Equal(t, "IN_TRANSIT_TO", vp.Get("current_status").String())
+				assert.Equal(t, int64(2), vp.Get("current_stop_sequence").Int())
+			},
+		},
+		{
+			name: "trip without vehicle position",
+			query: `query($trip_id:String!) {
+				trips(where: {feed_onestop_id: "CT", trip_id: $trip_id}) {
+					trip_id
+					vehicle_position { vehicle { id } }
+				}
+			}`,
+			vars:    hw{"trip_id": "103"},
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.True(t, gjson.Get(jj, "trips.0.trip_id").Exists())
+				assert.False(t, gjson.Get(jj, "trips.0.vehicle_position.vehicle").Exists())
+			},
+		},
+		{
+			name: "route vehicle positions",
+			query: `query {
+				routes(where: {feed_onestop_id: "CT", route_id: "Bu-130"}) {
+					vehicle_positions { vehicle { id } timestamp }
+				}
+			}`,
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				vps := gjson.Get(jj, "routes.0.vehicle_positions").Array()
+				if assert.Equal(t, 1, len(vps)) {
+					assert.Equal(t, "v305", vps[0].Get("vehicle.id").String())
+					assert.Equal(t, "2018-05-30T16:59:55Z", vps[0].Get("timestamp").String())
+				}
+			},
+		},
+		{
+			name: "vehicles by route onestop id",
+			query: `query {
+				vehicles(where: {route_onestop_ids: ["r-9q9j-bullet"]}) { vehicle { id } }
+			}`,
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t, []string{"v305"}, astr(gjson.Get(jj, "vehicles.#.vehicle.id").Array()))
+			},
+		},
+		{
+			name: "vehicles by bbox",
+			query: `query {
+				vehicles(where: {bbox: {min_lon: -121.96, min_lat: 37.35, max_lon: -121.94, max_lat: 37.37}}) { vehicle { id } }
+			}`,
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t, []string{"v101"}, astr(gjson.Get(jj, "vehicles.#.vehicle.id").Array()))
+			},
+		},
+		{
+			name: "route vehicle positions include vehicles with only a trip_id",
+			query: `query {
+				routes(where: {feed_onestop_id: "CT", route_id: "Lo-130"}) {
+					vehicle_positions { vehicle { id } }
+				}
+			}`,
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t, []string{"v101", "v103"}, astr(gjson.Get(jj, "routes.0.vehicle_positions.#.vehicle.id").Array()))
+			},
+		},
+		{
+			name: "vehicles by bbox with only a trip_id",
+			query: `query {
+				vehicles(where: {bbox: {min_lon: -122.17, min_lat: 37.43, max_lon: -122.16, max_lat: 37.45}}) { vehicle { id } }
+			}`,
+			rtfiles: rtfiles,
+			cb: func(t *testing.T, jj string) {
+				assert.ElementsMatch(t, []string{"v103"}, astr(gjson.Get(jj, "vehicles.#.vehicle.id").Array()))
+			},
+		},
+	}
+	for _, tc := range tcs {
+		testRt(t, tc)
+	}
+}
@@ -0,0 +1,252 @@
+package gbfssnapshot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/tldb"
+	"github.com/interline-io/transitland-lib/tldb/postgres"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/internal/clock"
+	"github.com/interline-io/transitland-server/internal/gbfs"
+	"github.com/interline-io/transitland-server/server/jobs"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// JobType is the job kind for SnapshotWorker
+const JobType = "gbfs-snapshot"
+
+// Maximum number of station snapshots in a single insert
+const snapshotBatchSize = 1_000
+
+// NewJob returns a job that records availability snapshots for a GBFS topic
+func NewJob(topic string) jobs.Job {
+	return jobs.Job{
+		JobType: JobType,
+		JobArgs: jobs.JobArgs{"topic": topic},
+		Unique:  true,
+	}
+}
+
+// Scheduler queues snapshot jobs for updated GBFS topics, at most once per feed per interval.
+// Feeds with multiple languages are only recorded once.
+type Scheduler struct {
+	Clock    clock.Clock
+	interval time.Duration
+	times    map[string]time.Time
+	lock     sync.Mutex
+}
+
+// NewScheduler returns a Scheduler that records each feed at most once per interval
+func NewScheduler(interval time.Duration) *Scheduler {
+	return &Scheduler{
+		Clock:    &clock.Real{},
+		interval: interval,
+		times:    map[string]time.Time{},
+	}
+}
+
+// AddJob queues a snapshot job for a topic, if a job queue is configured and the feed is due.
+func (s *Scheduler) AddJob(ctx context.Context, topic string) {
+	jobQueue := model.ForContext(ctx).JobQueue
+	if jobQueue == nil || !s.due(topic) {
+		return
+	}
+	if err := jobQueue.AddJob(ctx, NewJob(topic)); err != nil {
+		log.For(ctx).Error().Err(err).Str("topic", topic).Msg("failed to add gbfs snapshot job")
+	}
+}
+
+// due checks if the feed for a topic has not been recorded within the interval
+func (s *Scheduler) due(topic string) bool {
+	feed, _, _ := strings.Cut(topic, ":")
+	now := s.Clock.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if last, ok := s.times[feed]; ok && now.Sub(last) < s.interval {
+		return false
+	}
+	s.times[feed] = now
+	return true
+}
+
+// SnapshotWorker records station and vehicle availability from the current GBFS data for a topic.
+type SnapshotWorker struct {
+	Topic string `json:"topic"`
+}
+
+func (w *SnapshotWorker) Kind() string {
+	return JobType
+}
+
+func (w *SnapshotWorker) Run(ctx context.Context) error {
+	cfg := model.ForContext(ctx)
+	if cfg.Finder == nil || cfg.GbfsFinder == nil {
+		return errors.New("gbfs-snapshot: finder not configured")
+	}
+	sf, ok := cfg.GbfsFinder.GetData(ctx, w.Topic)
+	if !ok {
+		return nil
+	}
+	feedOnestopID, _, _ := strings.Cut(w.Topic, ":")
+	feeds, err := cfg.Finder.FindFeeds(ctx, nil, nil, nil, &model.FeedFilter{OnestopID: &feedOnestopID})
+	if err != nil {
+		return err
+	}
+	if len(feeds) == 0 {
+		return nil
+	}
+	now := time.Now().In(time.UTC)
+	if cfg.Clock != nil {
+		now = cfg.Clock.Now()
+	}
+	stations, system := FindSnapshots(sf, now)
+	log.For(ctx).Trace().Str("topic", w.Topic).Int("stations", len(stations)).Msg("gbfs-snapshot: found snapshots")
+	if system == nil {
+		return nil
+	}
+	return postgres.NewPostgresAdapterFromDBX(cfg.Finder.DBX()).Tx(func(atx tldb.Adapter) error {
+		return saveSnapshots(atx, feeds[0].ID, stations, system)
+	})
+}
+
+// StationSnapshot is the availability of a single station
+type StationSnapshot struct {
+	SystemID          string
+	StationID         string
+	CapturedAt        time.Time
+	LastReported      tt.Time
+	Capacity          tt.Int
+	NumBikesAvailable tt.Int
+	NumBikesDisabled  tt.Int
+	NumDocksAvailable tt.Int
+	NumDocksDisabled  tt.Int
+	IsInstalled       tt.Bool
+	IsRenting         tt.Bool
+	IsReturning       tt.Bool
+}
+
+// SystemSnapshot is the number of vehicles reported by a system
+type SystemSnapshot struct {
+	SystemID            string
+	CapturedAt          time.Time
+	NumVehicles         int
+	NumVehiclesReserved int
+	NumVehiclesDisabled int
+}
+
+// FindSnapshots returns the station and vehicle availability for a feed.
+// Capacity is taken from station information; feeds without system information return no snapshots.
+func FindSnapshots(sf gbfs.GbfsFeed, now time.Time) ([]*StationSnapshot, *SystemSnapshot) {
+	if sf.SystemInformation == nil {
+		return nil, nil
+	}
+	systemID := sf.SystemInformation.SystemID.Val
+	capacity := map[string]tt.Int{}
+	for _, si := range sf.StationInformation {
+		if si != nil {
+			capacity[si.StationID.Val] = si.Capacity
+		}
+	}
+	var stations []*StationSnapshot
+	for _, ss := range sf.StationStatus {
+		if ss == nil || ss.StationID.Val == "" {
+			continue
+		}
+		snap := StationSnapshot{
+			SystemID:          systemID,
+			StationID:         ss.StationID.Val,
+			CapturedAt:        now,
+			Capacity:          capacity[ss.StationID.Val],
+			NumBikesAvailable: ss.NumBikesAvailable,
+			NumBikesDisabled:  ss.NumBikesDisabled,
+			NumDocksAvailable: ss.NumDocksAvailable,
+			NumDocksDisabled:  ss.NumDocksDisabled,
+			IsInstalled:       ss.IsInstalled,
+			IsRenting:         ss.IsRenting,
+			IsReturning:       ss.IsReturning,
+		}
+		if ss.LastReported.Val > 0 {
+			snap.LastReported = tt.NewTime(time.Unix(ss.LastReported.Val, 0).In(time.UTC))
+		}
+		stations = append(stations, &snap)
+	}
+	system := SystemSnapshot{
+		SystemID:   systemID,
+		CapturedAt: now,
+	}
+	for _, bike := range sf.Bikes {
+		if bike == nil {
+			continue
+		}
+		system.NumVehicles++
+		if bike.IsReserved.Val {
+			system.NumVehiclesReserved++
+		}
+		if bike.IsDisabled.Val {
+			system.NumVehiclesDisabled++
+		}
+	}
+	return stations, &system
+}
+
+func saveSnapshots(atx tldb.Adapter, feedID int, stations []*StationSnapshot, system *SystemSnapshot) error {
+	if _, err := atx.Sqrl().
+		Insert("ext_gbfs_system_snapshots").
+		SetMap(map[string]any{
+			"feed_id":               feedID,
+			"system_id":             system.SystemID,
+			"captured_at":           system.CapturedAt,
+			"num_vehicles":          system.NumVehicles,
+			"num_vehicles_reserved": system.NumVehiclesReserved,
+			"num_vehicles_disabled": system.NumVehiclesDisabled,
+		}).
+		Exec(); err != nil {
+		return err
+	}
+	// Stations are inserted in batches to stay within the query parameter limit
+	for i := 0; i < len(stations); i += snapshotBatchSize {
+		q := atx.Sqrl().
+			Insert("ext_gbfs_station_snapshots").
+			Columns(
+				"feed_id",
+				"system_id",
+				"station_id",
+				"captured_at",
+				"last_reported",
+				"capacity",
+				"num_bikes_available",
+				"num_bikes_disabled",
+				"num_docks_available",
+				"num_docks_disabled",
+				"is_installed",
+				"is_renting",
+				"is_returning",
+			)
+		for _, snap := range stations[i:min(i+snapshotBatchSize, len(stations))] {
+			q = q.Values(
+				feedID,
+				snap.SystemID,
+				snap.StationID,
+				snap.CapturedAt,
+				snap.LastReported,
+				snap.Capacity,
+				snap.NumBikesAvailable,
+				snap.NumBikesDisabled,
+				snap.NumDocksAvailable,
+				snap.NumDocksDisabled,
+				snap.IsInstalled,
+				snap.IsRenting,
+				snap.IsReturning,
+			)
+		}
+		if _, err := q.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
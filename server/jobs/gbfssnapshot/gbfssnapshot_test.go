@@ -0,0 +1,80 @@
+package gbfssnapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/internal/clock"
+	"github.com/interline-io/transitland-server/internal/gbfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindSnapshots(t *testing.T) {
+	now := time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC)
+	lastReported := time.Date(2024, 1, 1, 15, 59, 0, 0, time.UTC)
+	sf := gbfs.GbfsFeed{
+		SystemInformation: &gbfs.SystemInformation{SystemID: tt.NewString("test")},
+		StationInformation: []*gbfs.StationInformation{
+			{StationID: tt.NewString("a"), Capacity: tt.NewInt(12)},
+		},
+		StationStatus: []*gbfs.StationStatus{
+			{
+				StationID:         tt.NewString("a"),
+				NumBikesAvailable: tt.NewInt(4),
+				NumDocksAvailable: tt.NewInt(8),
+				IsRenting:         tt.NewBool(true),
+				LastReported:      tt.NewInt(int(lastReported.Unix())),
+			},
+			// no station information
+			{StationID: tt.NewString("b"), NumBikesAvailable: tt.NewInt(0)},
+			// no station_id
+			{NumBikesAvailable: tt.NewInt(1)},
+		},
+		Bikes: []*gbfs.FreeBikeStatus{
+			{BikeID: tt.NewString("1")},
+			{BikeID: tt.NewString("2"), IsReserved: tt.NewBool(true)},
+			{BikeID: tt.NewString("3"), IsDisabled: tt.NewBool(true)},
+		},
+	}
+	stations, system := FindSnapshots(sf, now)
+	if assert.Len(t, stations, 2) {
+		a := stations[0]
+		assert.Equal(t, "test", a.SystemID)
+		assert.Equal(t, "a", a.StationID)
+		assert.Equal(t, now, a.CapturedAt)
+		assert.Equal(t, lastReported, a.LastReported.Val)
+		assert.Equal(t, tt.NewInt(12), a.Capacity)
+		assert.Equal(t, tt.NewInt(4), a.NumBikesAvailable)
+		assert.Equal(t, tt.NewInt(8), a.NumDocksAvailable)
+		assert.Equal(t, tt.NewBool(true), a.IsRenting)
+		b := stations[1]
+		assert.Equal(t, "b", b.StationID)
+		assert.False(t, b.Capacity.Valid)
+		assert.False(t, b.LastReported.Valid)
+	}
+	if assert.NotNil(t, system) {
+		assert.Equal(t, "test", system.SystemID)
+		assert.Equal(t, 3, system.NumVehicles)
+		assert.Equal(t, 1, system.NumVehiclesReserved)
+		assert.Equal(t, 1, system.NumVehiclesDisabled)
+	}
+	t.Run("no system information", func(t *testing.T) {
+		stations, system := FindSnapshots(gbfs.GbfsFeed{StationStatus: sf.StationStatus}, now)
+		assert.Nil(t, stations)
+		assert.Nil(t, system)
+	})
+}
+
+func TestScheduler_Due(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cl := &clock.Mock{T: now}
+	s := NewScheduler(time.Minute)
+	s.Clock = cl
+	assert.True(t, s.due("test:en"))
+	assert.False(t, s.due("test:en"))
+	assert.False(t, s.due("test:fr"), "other languages share the feed interval")
+	assert.True(t, s.due("other:en"))
+	cl.T = now.Add(time.Minute)
+	assert.True(t, s.due("test:en"))
+}
@@ -0,0 +1,279 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-server/internal/clock"
+	"github.com/interline-io/transitland-server/server/jobs"
+	"github.com/interline-io/transitland-server/server/model"
+)
+
+// JobType is the job kind for PollWorker
+const JobType = "feed-poll"
+
+// QueueName is the queue used for polling jobs, so fetches do not delay other jobs
+const QueueName = "poll"
+
+// URL types that are polled
+var PollURLTypes = []string{
+	"realtime_vehicle_positions",
+	"realtime_trip_updates",
+	"realtime_alerts",
+	"gbfs_auto_discovery",
+}
+
+// Feed tag used to set a feed specific polling interval, in seconds
+const IntervalTag = "poll_interval"
+
+// NewJob returns a job that polls a feed URL
+func NewJob(feedID string, urlType string, url string) jobs.Job {
+	return jobs.Job{
+		Queue:   QueueName,
+		JobType: JobType,
+		JobArgs: jobs.JobArgs{"feed_id": feedID, "url_type": urlType, "url": url},
+		Unique:  true,
+	}
+}
+
+// FetchFunc fetches a feed URL and returns how long the response may be cached, if known
+type FetchFunc func(ctx context.Context, feedID string, urlType string, url string) (time.Duration, error)
+
+type Options struct {
+	RealtimeInterval time.Duration // Default interval for GTFS-RT URLs
+	GbfsInterval     time.Duration // Default interval for GBFS URLs
+	MaxBackoff       time.Duration // Longest delay after repeated errors
+	RefreshInterval  time.Duration // How often the list of feeds is reloaded
+	Fetch            FetchFunc
+}
+
+// Poller schedules periodic fetches of GTFS-RT and GBFS feeds.
+// Each URL is checked at its interval; a fetch is skipped until the URL is due again,
+// which is delayed by how long the fetch reports the response may be cached, and backs off exponentially after errors.
+type Poller struct {
+	Clock     clock.Clock
+	opts      Options
+	state     map[string]*pollState
+	scheduled map[string]*scheduledURL
+	lock      sync.Mutex
+}
+
+// scheduledURL is a URL with a periodic job, which is stopped by cancel
+type scheduledURL struct {
+	url      string
+	interval time.Duration
+	cancel   context.CancelFunc
+}
+
+type pollState struct {
+	interval time.Duration
+	next     time.Time
+	failures int
+}
+
+func NewPoller(opts Options) *Poller {
+	if opts.RealtimeInterval <= 0 {
+		opts.RealtimeInterval = 30 * time.Second
+	}
+	if opts.GbfsInterval <= 0 {
+		opts.GbfsInterval = 60 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Minute
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = 5 * time.Minute
+	}
+	return &Poller{
+		Clock:     &clock.Real{},
+		opts:      opts,
+		state:     map[string]*pollState{},
+		scheduled: map[string]*scheduledURL{},
+	}
+}
+
+// NewWorker returns a worker for this poller, for use with JobQueue.AddJobType
+func (p *Poller) NewWorker() jobs.JobWorker {
+	return &PollWorker{poller: p}
+}
+
+// Start schedules periodic jobs for each GTFS-RT and GBFS URL of the feeds in the database.
+// The list of feeds is reloaded every RefreshInterval until ctx is done: jobs are added for new URLs,
+// and stopped for URLs that were removed or changed.
+func (p *Poller) Start(ctx context.Context, finder model.Finder, queue jobs.JobQueue) error {
+	if err := p.refresh(ctx, finder, queue); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(p.opts.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.refresh(ctx, finder, queue); err != nil {
+				log.For(ctx).Error().Err(err).Msg("poller: failed to refresh feeds")
+			}
+		}
+	}
+}
+
+// refresh schedules jobs for the current feed URLs
+func (p *Poller) refresh(ctx context.Context, finder model.Finder, queue jobs.JobQueue) error {
+	feeds, err := finder.FindFeeds(ctx, nil, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	type feedURL struct {
+		feedID   string
+		urlType  string
+		url      string
+		interval time.Duration
+	}
+	current := map[string]feedURL{}
+	for _, feed := range feeds {
+		urls := map[string]string{
+			"realtime_vehicle_positions": feed.URLs.RealtimeVehiclePositions,
+			"realtime_trip_updates":      feed.URLs.RealtimeTripUpdates,
+			"realtime_alerts":            feed.URLs.RealtimeAlerts,
+			"gbfs_auto_discovery":        feed.URLs.GbfsAutoDiscovery,
+		}
+		for _, urlType := range PollURLTypes {
+			url := urls[urlType]
+			if url == "" {
+				continue
+			}
+			interval := p.defaultInterval(urlType)
+			if v, ok := feed.Tags.Get(IntervalTag); ok {
+				if n, err := strconv.Atoi(v); err == nil && n > 0 {
+					interval = time.Duration(n) * time.Second
+				}
+			}
+			current[stateKey(feed.FeedID, urlType)] = feedURL{feedID: feed.FeedID, urlType: urlType, url: url, interval: interval}
+		}
+	}
+	// Scheduled URLs are only used by refresh, which is not called concurrently
+	added, removed := 0, 0
+	for key, s := range p.scheduled {
+		if u, ok := current[key]; !ok || u.url != s.url || u.interval != s.interval {
+			s.cancel()
+			delete(p.scheduled, key)
+			removed++
+		}
+	}
+	for key, u := range current {
+		if _, ok := p.scheduled[key]; ok {
+			continue
+		}
+		p.setInterval(key, u.interval)
+		jobCtx, cancel := context.WithCancel(ctx)
+		jobFunc := func() jobs.Job { return NewJob(u.feedID, u.urlType, u.url) }
+		if err := queue.AddJob(ctx, jobFunc()); err != nil {
+			cancel()
+			return err
+		}
+		if err := queue.AddPeriodicJob(jobCtx, jobFunc, u.interval, ""); err != nil {
+			cancel()
+			return err
+		}
+		p.scheduled[key] = &scheduledURL{url: u.url, interval: u.interval, cancel: cancel}
+		added++
+	}
+	if added > 0 || removed > 0 {
+		log.For(ctx).Info().Int("count", len(p.scheduled)).Int("added", added).Int("removed", removed).Msg("poller: scheduled feed urls")
+	}
+	return nil
+}
+
+// due checks if a URL should be fetched
+func (p *Poller) due(key string, now time.Time) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s, ok := p.state[key]
+	return !ok || !now.Before(s.next)
+}
+
+// update sets the next fetch time after a fetch.
+// After success, the next fetch is after the interval or how long the response may be cached, whichever is longer.
+// After an error, the interval is doubled for each consecutive error, up to MaxBackoff.
+func (p *Poller) update(key string, urlType string, now time.Time, cacheFor time.Duration, fetchErr error) time.Time {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s, ok := p.state[key]
+	if !ok {
+		s = &pollState{interval: p.defaultInterval(urlType)}
+		p.state[key] = s
+	}
+	wait := s.interval
+	if fetchErr != nil {
+		s.failures++
+		for i := 0; i < s.failures && wait < p.opts.MaxBackoff; i++ {
+			wait *= 2
+		}
+		wait = min(wait, p.opts.MaxBackoff)
+	} else {
+		s.failures = 0
+		wait = max(wait, cacheFor)
+	}
+	// Allow some leeway so the fetch is not skipped by a tick that arrives slightly early
+	s.next = now.Add(wait - s.interval/10)
+	return s.next
+}
+
+func (p *Poller) setInterval(key string, interval time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if s, ok := p.state[key]; ok {
+		s.interval = interval
+	} else {
+		p.state[key] = &pollState{interval: interval}
+	}
+}
+
+func (p *Poller) defaultInterval(urlType string) time.Duration {
+	if urlType == "gbfs_auto_discovery" {
+		return p.opts.GbfsInterval
+	}
+	return p.opts.RealtimeInterval
+}
+
+func stateKey(feedID string, urlType string) string {
+	return fmt.Sprintf("%s:%s", feedID, urlType)
+}
+
+// PollWorker fetches a feed URL if it is due
+type PollWorker struct {
+	FeedID  string `json:"feed_id"`
+	URLType string `json:"url_type"`
+	URL     string `json:"url"`
+	poller  *Poller
+}
+
+func (w *PollWorker) Kind() string {
+	return JobType
+}
+
+func (w *PollWorker) Run(ctx context.Context) error {
+	p := w.poller
+	if p == nil || p.opts.Fetch == nil {
+		return errors.New("feed-poll: poller not configured")
+	}
+	key := stateKey(w.FeedID, w.URLType)
+	now := p.Clock.Now()
+	if !p.due(key, now) {
+		return nil
+	}
+	cacheFor, err := p.opts.Fetch(ctx, w.FeedID, w.URLType, w.URL)
+	next := p.update(key, w.URLType, now, cacheFor, err)
+	if err != nil {
+		log.For(ctx).Error().Err(err).Str("feed_id", w.FeedID).Str("url_type", w.URLType).Time("next", next).Msg("feed-poll: fetch failed")
+		return err
+	}
+	log.For(ctx).Trace().Str("feed_id", w.FeedID).Str("url_type", w.URLType).Time("next", next).Msg("feed-poll: fetched")
+	return nil
+}
@@ -0,0 +1,165 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-server/internal/clock"
+	"github.com/interline-io/transitland-server/server/jobs"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollWorker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var fetchErr error
+	var cacheFor time.Duration
+	fetchCount := 0
+	p := NewPoller(Options{
+		RealtimeInterval: 30 * time.Second,
+		GbfsInterval:     60 * time.Second,
+		MaxBackoff:       5 * time.Minute,
+		Fetch: func(ctx context.Context, feedID string, urlType string, url string) (time.Duration, error) {
+			fetchCount++
+			return cacheFor, fetchErr
+		},
+	})
+	mockClock := &clock.Mock{T: start}
+	p.Clock = mockClock
+	newWorker := func(urlType string) *PollWorker {
+		w := p.NewWorker().(*PollWorker)
+		w.FeedID = "test"
+		w.URLType = urlType
+		w.URL = "http://example.com"
+		return w
+	}
+	// Run at offset seconds from start and return if a fetch was made
+	run := func(w *PollWorker, offset int) bool {
+		mockClock.T = start.Add(time.Duration(offset) * time.Second)
+		prev := fetchCount
+		w.Run(context.Background())
+		return fetchCount > prev
+	}
+
+	t.Run("interval", func(t *testing.T) {
+		w := newWorker("realtime_trip_updates")
+		assert.True(t, run(w, 0))
+		assert.False(t, run(w, 10))
+		assert.True(t, run(w, 30))
+		assert.True(t, run(w, 60))
+	})
+	t.Run("gbfs ttl", func(t *testing.T) {
+		cacheFor = 300 * time.Second
+		defer func() { cacheFor = 0 }()
+		w := newWorker("gbfs_auto_discovery")
+		assert.True(t, run(w, 0))
+		assert.False(t, run(w, 60))
+		assert.False(t, run(w, 240))
+		assert.True(t, run(w, 300))
+	})
+	t.Run("backoff", func(t *testing.T) {
+		fetchErr = errors.New("fail")
+		w := newWorker("realtime_alerts")
+		assert.True(t, run(w, 0))
+		// 60s after the first error
+		assert.False(t, run(w, 30))
+		assert.True(t, run(w, 60))
+		// 120s after the second error
+		assert.False(t, run(w, 120))
+		assert.True(t, run(w, 180))
+		// success resets the interval
+		fetchErr = nil
+		assert.True(t, run(w, 480))
+		assert.True(t, run(w, 510))
+	})
+	t.Run("max backoff", func(t *testing.T) {
+		fetchErr = errors.New("fail")
+		defer func() { fetchErr = nil }()
+		w := newWorker("realtime_vehicle_positions")
+		offset := 0
+		for i := 0; i < 10; i++ {
+			assert.True(t, run(w, offset))
+			offset += 300
+		}
+	})
+}
+
+type testFeedFinder struct {
+	model.Finder
+	feeds []*model.Feed
+}
+
+func (f *testFeedFinder) FindFeeds(ctx context.Context, limit *int, after *model.Cursor, ids []int, where *model.FeedFilter) ([]*model.Feed, error) {
+	return f.feeds, nil
+}
+
+type testPeriodicJob struct {
+	job      jobs.Job
+	interval time.Duration
+	ctx      context.Context
+}
+
+type testJobQueue struct {
+	jobs.JobQueue
+	added    []jobs.Job
+	periodic []testPeriodicJob
+}
+
+func (q *testJobQueue) AddJob(ctx context.Context, job jobs.Job) error {
+	q.added = append(q.added, job)
+	return nil
+}
+
+func (q *testJobQueue) AddPeriodicJob(ctx context.Context, jobFunc func() jobs.Job, period time.Duration, cronTab string) error {
+	q.periodic = append(q.periodic, testPeriodicJob{job: jobFunc(), interval: period, ctx: ctx})
+	return nil
+}
+
+func TestPoller_Refresh(t *testing.T) {
+	ctx := context.Background()
+	newFeed := func(feedID string, url string) *model.Feed {
+		f := model.Feed{}
+		f.FeedID = feedID
+		f.URLs.RealtimeTripUpdates = url
+		return &f
+	}
+	finder := &testFeedFinder{feeds: []*model.Feed{newFeed("a", "http://example.com/a"), newFeed("b", "http://example.com/b")}}
+	queue := &testJobQueue{}
+	p := NewPoller(Options{RealtimeInterval: 30 * time.Second})
+	if err := p.refresh(ctx, finder, queue); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(queue.added))
+	if assert.Equal(t, 2, len(queue.periodic)) {
+		assert.Equal(t, QueueName, queue.periodic[0].job.Queue)
+		assert.Equal(t, 30*time.Second, queue.periodic[0].interval)
+	}
+	periodicJob := func(url string) testPeriodicJob {
+		for _, pj := range queue.periodic {
+			if pj.job.JobArgs["url"] == url {
+				return pj
+			}
+		}
+		t.Fatalf("no periodic job for url '%s'", url)
+		return testPeriodicJob{}
+	}
+
+	// Unchanged feeds are not scheduled again
+	if err := p.refresh(ctx, finder, queue); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(queue.periodic))
+
+	// Changed URLs are rescheduled, removed feeds are stopped, and new feeds are added
+	finder.feeds = []*model.Feed{newFeed("a", "http://example.com/a2"), newFeed("c", "http://example.com/c")}
+	if err := p.refresh(ctx, finder, queue); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, len(queue.periodic))
+	assert.Error(t, periodicJob("http://example.com/a").ctx.Err(), "changed url stopped")
+	assert.Error(t, periodicJob("http://example.com/b").ctx.Err(), "removed feed stopped")
+	assert.NoError(t, periodicJob("http://example.com/a2").ctx.Err())
+	assert.NoError(t, periodicJob("http://example.com/c").ctx.Err())
+}
@@ -0,0 +1,548 @@
+package stopobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-lib/tldb"
+	"github.com/interline-io/transitland-lib/tldb/postgres"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/server/dbutil"
+	"github.com/interline-io/transitland-server/server/jobs"
+	"github.com/interline-io/transitland-server/server/model"
+	sq "github.com/irees/squirrel"
+)
+
+// JobType is the job kind for StopObservationWorker
+const JobType = "stop-observations"
+
+// Source value used for observations derived from GTFS-RT TripUpdates
+const ObservationSource = "TripUpdate"
+
+// Maximum number of observations in a single statement
+const observationBatchSize = 1_000
+
+// NewJob returns a job that updates stop observations for a feed
+func NewJob(feedID string) jobs.Job {
+	return jobs.Job{
+		JobType: JobType,
+		JobArgs: jobs.JobArgs{"feed_id": feedID},
+		Unique:  true,
+	}
+}
+
+// AddJob queues a job to update stop observations for a feed, if a job queue is configured.
+func AddJob(ctx context.Context, feedID string) {
+	jobQueue := model.ForContext(ctx).JobQueue
+	if jobQueue == nil {
+		return
+	}
+	if err := jobQueue.AddJob(ctx, NewJob(feedID)); err != nil {
+		log.For(ctx).Error().Err(err).Str("feed_id", feedID).Msg("failed to add stop observations job")
+	}
+}
+
+// StopObservationWorker records observed arrivals and departures from the current GTFS-RT TripUpdates for a feed.
+type StopObservationWorker struct {
+	FeedID string `json:"feed_id"`
+}
+
+func (w *StopObservationWorker) Kind() string {
+	return JobType
+}
+
+func (w *StopObservationWorker) Run(ctx context.Context) error {
+	cfg := model.ForContext(ctx)
+	if cfg.Finder == nil || cfg.RTFinder == nil {
+		return errors.New("stop-observations: finder not configured")
+	}
+	msg, ok := cfg.RTFinder.GetMessage(ctx, w.FeedID, "realtime_trip_updates")
+	if !ok || msg == nil {
+		return nil
+	}
+	fvids, ok := cfg.RTFinder.GetRTFeedFeedVersions(ctx, w.FeedID)
+	if !ok || len(fvids) == 0 {
+		return nil
+	}
+	now := time.Now().In(time.UTC)
+	if ts := msg.GetHeader().GetTimestamp(); ts > 0 {
+		now = time.Unix(int64(ts), 0).In(time.UTC)
+	} else if cfg.Clock != nil {
+		now = cfg.Clock.Now()
+	}
+	// Collect TripUpdates for scheduled trips
+	var tus []*pb.TripUpdate
+	var tripIDs []string
+	for _, ent := range msg.Entity {
+		tu := ent.GetTripUpdate()
+		if tu == nil {
+			continue
+		}
+		td := tu.GetTrip()
+		switch td.GetScheduleRelationship() {
+		case pb.TripDescriptor_ADDED, pb.TripDescriptor_UNSCHEDULED, pb.TripDescriptor_CANCELED, pb.TripDescriptor_REPLACEMENT, pb.TripDescriptor_DUPLICATED, pb.TripDescriptor_DELETED:
+			continue
+		}
+		if td.GetTripId() == "" {
+			continue
+		}
+		tus = append(tus, tu)
+		tripIDs = append(tripIDs, td.GetTripId())
+	}
+	if len(tus) == 0 {
+		return nil
+	}
+	ots, err := observationTrips(ctx, fvids, tripIDs)
+	if err != nil {
+		return err
+	}
+	// Vehicle positions may be included with the TripUpdates or published separately
+	vps := map[string]*pb.VehiclePosition{}
+	addVehicles := func(m *pb.FeedMessage) {
+		for _, ent := range m.GetEntity() {
+			if vp := ent.GetVehicle(); vp != nil && vp.GetTrip().GetTripId() != "" {
+				vps[vp.GetTrip().GetTripId()] = vp
+			}
+		}
+	}
+	if vpMsg, ok := cfg.RTFinder.GetMessage(ctx, w.FeedID, "realtime_vehicle_positions"); ok && vpMsg != nil {
+		addVehicles(vpMsg)
+	}
+	addVehicles(msg)
+	var obs []*StopObservation
+	for _, tu := range tus {
+		ot := ots[tu.GetTrip().GetTripId()]
+		if ot == nil || len(ot.StopTimes) == 0 {
+			continue
+		}
+		loc, ok := cfg.RTFinder.StopTimezone(ctx, ot.StopTimes[0].StopID.Int(), "")
+		if !ok || loc == nil {
+			continue
+		}
+		obs = append(obs, FindObservations(tu, vps[tu.GetTrip().GetTripId()], ot, loc, now)...)
+	}
+	log.For(ctx).Trace().Str("feed_id", w.FeedID).Int("count", len(obs)).Msg("stop-observations: found observations")
+	if len(obs) == 0 {
+		return nil
+	}
+	return postgres.NewPostgresAdapterFromDBX(cfg.Finder.DBX()).Tx(func(atx tldb.Adapter) error {
+		return saveObservations(atx, obs)
+	})
+}
+
+// observationTrips looks up the static trips, routes, agencies, and stop times for a set of GTFS trip_ids,
+// keyed by trip_id. When a trip_id is present in more than one feed version, the first in fvids is used.
+func observationTrips(ctx context.Context, fvids []int, tripIDs []string) (map[string]*ObservationTrip, error) {
+	cfg := model.ForContext(ctx)
+
+	// Find static trips
+	var trips []struct {
+		ID            int
+		FeedVersionID int
+		TripID        string
+		RouteID       int
+		DirectionID   tt.Int
+	}
+	q := sq.StatementBuilder.
+		Select("id", "feed_version_id", "trip_id", "route_id", "direction_id").
+		From("gtfs_trips").
+		Where(sq.Eq{"feed_version_id": fvids, "trip_id": tripIDs})
+	if err := dbutil.Select(ctx, cfg.Finder.DBX(), q, &trips); err != nil {
+		return nil, err
+	}
+	fvOrder := map[int]int{}
+	for i := len(fvids) - 1; i >= 0; i-- {
+		fvOrder[fvids[i]] = i
+	}
+	ots := map[string]*ObservationTrip{}
+	tripKeys := map[string]model.FVPair{}
+	routeTrips := map[int][]*ObservationTrip{}
+	for _, trip := range trips {
+		if cur, ok := ots[trip.TripID]; ok && fvOrder[cur.FeedVersionID] <= fvOrder[trip.FeedVersionID] {
+			continue
+		}
+		ots[trip.TripID] = &ObservationTrip{
+			FeedVersionID: trip.FeedVersionID,
+			TripID:        trip.TripID,
+			DirectionID:   trip.DirectionID.Int(),
+			StopIDs:       map[int]string{},
+		}
+		tripKeys[trip.TripID] = model.FVPair{FeedVersionID: trip.FeedVersionID, EntityID: trip.ID}
+	}
+	if len(ots) == 0 {
+		return nil, nil
+	}
+	for _, trip := range trips {
+		if tripKeys[trip.TripID].EntityID == trip.ID {
+			routeTrips[trip.RouteID] = append(routeTrips[trip.RouteID], ots[trip.TripID])
+		}
+	}
+
+	// Get routes and agencies
+	var routeIDs []int
+	for routeID := range routeTrips {
+		routeIDs = append(routeIDs, routeID)
+	}
+	routes, errs := cfg.Finder.RoutesByIDs(ctx, routeIDs)
+	if err := firstError(errs); err != nil {
+		return nil, err
+	}
+	agencyRoutes := map[int][]*model.Route{}
+	for _, route := range routes {
+		if route == nil {
+			continue
+		}
+		for _, ot := range routeTrips[route.ID] {
+			ot.RouteID = route.RouteID.Val
+		}
+		agencyRoutes[route.AgencyID.Int()] = append(agencyRoutes[route.AgencyID.Int()], route)
+	}
+	var agencyIDs []int
+	for agencyID := range agencyRoutes {
+		agencyIDs = append(agencyIDs, agencyID)
+	}
+	if len(agencyIDs) > 0 {
+		agencies, errs := cfg.Finder.AgenciesByIDs(ctx, agencyIDs)
+		if err := firstError(errs); err != nil {
+			return nil, err
+		}
+		for _, agency := range agencies {
+			if agency == nil {
+				continue
+			}
+			for _, route := range agencyRoutes[agency.ID] {
+				for _, ot := range routeTrips[route.ID] {
+					ot.AgencyID = agency.AgencyID.Val
+				}
+			}
+		}
+	}
+
+	// Get stop times
+	var keys []model.FVPair
+	var keyTripIDs []string
+	for tripID, key := range tripKeys {
+		keys = append(keys, key)
+		keyTripIDs = append(keyTripIDs, tripID)
+	}
+	stGroups, err := cfg.Finder.StopTimesByTripIDs(ctx, nil, nil, keys)
+	if err != nil {
+		return nil, err
+	}
+	for i, sts := range stGroups {
+		ot := ots[keyTripIDs[i]]
+		ot.StopTimes = sts
+		for _, st := range sts {
+			if sid, ok := cfg.RTFinder.GetGtfsStopID(ctx, st.StopID.Int()); ok {
+				ot.StopIDs[st.StopID.Int()] = sid
+			}
+		}
+	}
+	return ots, nil
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ObservationTrip provides the static trip data used to match a TripUpdate
+type ObservationTrip struct {
+	FeedVersionID int
+	TripID        string
+	RouteID       string
+	AgencyID      string
+	DirectionID   int
+	StopTimes     []*model.StopTime // ordered by stop_sequence
+	StopIDs       map[int]string    // stop integer ID to GTFS stop_id
+}
+
+// StopObservation is a single observed arrival or departure
+type StopObservation struct {
+	FeedVersionID          int
+	TripID                 string
+	RouteID                string
+	AgencyID               string
+	DirectionID            int
+	TripStartDate          tt.Date
+	TripStartTime          tt.Seconds
+	ScheduleRelationship   string
+	VehicleID              string
+	StopSequence           int
+	FromStopID             string
+	ToStopID               string
+	ScheduledArrivalTime   tt.Seconds
+	ScheduledDepartureTime tt.Seconds
+	ObservedArrivalTime    tt.Seconds
+	ObservedDepartureTime  tt.Seconds
+	ObservedArrivalDelay   tt.Int
+	DwellTimeSecs          tt.Int
+	ScheduledDwellTimeSecs tt.Int
+}
+
+// FindObservations returns observations for each stop in the TripUpdate that the vehicle has passed as of now.
+// A stop has been passed when a later StopTimeUpdate has an event time before now, or when it is before
+// the current stop of the VehiclePosition; the vehicle has arrived at, but not yet departed, its current
+// stop if it is STOPPED_AT. Observed times are taken from explicit event times or applied as delays to
+// the scheduled times, and are expressed in seconds since noon minus 12 hours of the trip start date in
+// the given timezone. The VehiclePosition may be nil.
+func FindObservations(tu *pb.TripUpdate, vp *pb.VehiclePosition, ot *ObservationTrip, loc *time.Location, now time.Time) []*StopObservation {
+	td := tu.GetTrip()
+	serviceDate := now.In(loc)
+	if sd := td.GetStartDate(); sd != "" {
+		d, err := time.ParseInLocation("20060102", sd, loc)
+		if err != nil {
+			return nil
+		}
+		serviceDate = d
+	}
+	// GTFS times are relative to noon minus 12 hours, which is not midnight on days with a DST change
+	midnight := time.Date(serviceDate.Year(), serviceDate.Month(), serviceDate.Day(), 12, 0, 0, 0, loc).Add(-12 * time.Hour)
+	isPast := func(t tt.Seconds) bool {
+		return t.Valid && !midnight.Add(time.Duration(t.Val)*time.Second).After(now)
+	}
+	var startTime tt.Seconds
+	if s := td.GetStartTime(); s != "" {
+		startTime, _ = tt.NewSecondsFromString(s)
+	}
+
+	// Match StopTimeUpdates to stop times
+	type stopEvent struct {
+		idx int
+		arr tt.Seconds
+		dep tt.Seconds
+	}
+	var events []stopEvent
+	for _, stu := range tu.StopTimeUpdate {
+		if stu.GetScheduleRelationship() != pb.TripUpdate_StopTimeUpdate_SCHEDULED {
+			continue
+		}
+		idx := matchStopTime(stu.StopSequence, stu.GetStopId(), ot)
+		if idx < 0 {
+			continue
+		}
+		st := ot.StopTimes[idx]
+		ev := stopEvent{
+			idx: idx,
+			arr: observedTime(stu.Arrival, st.ArrivalTime, midnight),
+			dep: observedTime(stu.Departure, st.DepartureTime, midnight),
+		}
+		if !ev.arr.Valid {
+			ev.arr = ev.dep
+		}
+		if !ev.dep.Valid {
+			ev.dep = ev.arr
+		}
+		events = append(events, ev)
+	}
+
+	// Stops before passedBefore have been departed
+	passedBefore := -1
+	arrivedAt := -1
+	if vp != nil && (vp.CurrentStopSequence != nil || vp.GetStopId() != "") {
+		if idx := matchStopTime(vp.CurrentStopSequence, vp.GetStopId(), ot); idx >= 0 {
+			passedBefore = idx
+			if vp.GetCurrentStatus() == pb.VehiclePosition_STOPPED_AT {
+				arrivedAt = idx
+			}
+		}
+	}
+	for _, ev := range events {
+		if ev.idx > passedBefore && isPast(ev.arr) {
+			passedBefore = ev.idx
+		}
+	}
+
+	var ret []*StopObservation
+	for _, ev := range events {
+		st := ot.StopTimes[ev.idx]
+		arr, dep := ev.arr, ev.dep
+		// Not yet arrived
+		if (ev.idx >= passedBefore && ev.idx != arrivedAt) || !isPast(arr) {
+			continue
+		}
+		// Not yet departed
+		if ev.idx >= passedBefore || !isPast(dep) {
+			dep = tt.Seconds{}
+		}
+		ob := StopObservation{
+			FeedVersionID:          ot.FeedVersionID,
+			TripID:                 ot.TripID,
+			RouteID:                ot.RouteID,
+			AgencyID:               ot.AgencyID,
+			DirectionID:            ot.DirectionID,
+			TripStartDate:          tt.NewDate(serviceDate),
+			TripStartTime:          startTime,
+			ScheduleRelationship:   td.GetScheduleRelationship().String(),
+			VehicleID:              tu.GetVehicle().GetId(),
+			StopSequence:           st.StopSequence.Int(),
+			ToStopID:               ot.StopIDs[st.StopID.Int()],
+			ScheduledArrivalTime:   st.ArrivalTime,
+			ScheduledDepartureTime: st.DepartureTime,
+			ObservedArrivalTime:    arr,
+			ObservedDepartureTime:  dep,
+		}
+		if ev.idx > 0 {
+			ob.FromStopID = ot.StopIDs[ot.StopTimes[ev.idx-1].StopID.Int()]
+		}
+		if st.ArrivalTime.Valid {
+			ob.ObservedArrivalDelay = tt.NewInt(int(arr.Val - st.ArrivalTime.Val))
+		}
+		if dep.Valid {
+			ob.DwellTimeSecs = tt.NewInt(int(dep.Val - arr.Val))
+		}
+		if st.ArrivalTime.Valid && st.DepartureTime.Valid {
+			ob.ScheduledDwellTimeSecs = tt.NewInt(int(st.DepartureTime.Val - st.ArrivalTime.Val))
+		}
+		ret = append(ret, &ob)
+	}
+	return ret
+}
+
+// matchStopTime returns the index of the static stop time for a stop_sequence and stop_id,
+// matching on stop_sequence if present, otherwise stop_id if the stop is visited only once.
+func matchStopTime(stopSequence *uint32, stopID string, ot *ObservationTrip) int {
+	if stopSequence != nil {
+		for i, st := range ot.StopTimes {
+			if st.StopSequence.Int() == int(*stopSequence) {
+				return i
+			}
+		}
+		return -1
+	}
+	found := -1
+	for i, st := range ot.StopTimes {
+		if ot.StopIDs[st.StopID.Int()] != stopID {
+			continue
+		}
+		if found >= 0 {
+			return -1
+		}
+		found = i
+	}
+	return found
+}
+
+// observedTime returns the event time in seconds since the service day reference time, using an explicit time or a delay from the scheduled time
+func observedTime(ste *pb.TripUpdate_StopTimeEvent, sched tt.Seconds, midnight time.Time) tt.Seconds {
+	if ste == nil {
+		return tt.Seconds{}
+	}
+	if ste.Time != nil {
+		return tt.NewSeconds(int(time.Unix(ste.GetTime(), 0).Sub(midnight).Seconds()))
+	}
+	if ste.Delay != nil && sched.Valid {
+		return tt.NewSeconds(int(sched.Val) + int(ste.GetDelay()))
+	}
+	return tt.Seconds{}
+}
+
+// saveObservations replaces any existing observations for the same trip, date, and stop.
+// Each batch is written in a single statement that deletes the replaced rows and inserts the new rows.
+func saveObservations(atx tldb.Adapter, obs []*StopObservation) error {
+	// Keep the last observation for each key
+	type obsKey struct {
+		FeedVersionID int
+		TripID        string
+		TripStartDate string
+		StopSequence  int
+	}
+	keyIdx := map[obsKey]int{}
+	var uniq []*StopObservation
+	for _, ob := range obs {
+		k := obsKey{ob.FeedVersionID, ob.TripID, ob.TripStartDate.String(), ob.StopSequence}
+		if i, ok := keyIdx[k]; ok {
+			uniq[i] = ob
+			continue
+		}
+		keyIdx[k] = len(uniq)
+		uniq = append(uniq, ob)
+	}
+	now := time.Now().In(time.UTC)
+	for i := 0; i < len(uniq); i += observationBatchSize {
+		batch := uniq[i:min(i+observationBatchSize, len(uniq))]
+		var keyValues []string
+		var keyArgs []any
+		for _, ob := range batch {
+			keyValues = append(keyValues, "(?::bigint, ?::text, ?::date, ?::int)")
+			keyArgs = append(keyArgs, ob.FeedVersionID, ob.TripID, ob.TripStartDate, ob.StopSequence)
+		}
+		keyArgs = append(keyArgs, ObservationSource)
+		q := atx.Sqrl().
+			Insert("ext_performance_stop_observations").
+			Prefix(
+				`WITH replaced AS (
+				DELETE FROM ext_performance_stop_observations o
+				USING (VALUES `+strings.Join(keyValues, ", ")+`) AS k(feed_version_id, trip_id, trip_start_date, stop_sequence)
+				WHERE o.feed_version_id = k.feed_version_id
+				AND o.trip_id = k.trip_id
+				AND o.trip_start_date = k.trip_start_date
+				AND o.stop_sequence = k.stop_sequence
+				AND o.source = ?
+			)`,
+				keyArgs...,
+			).
+			Columns(
+				"created_at",
+				"updated_at",
+				"feed_version_id",
+				"source",
+				"trip_id",
+				"route_id",
+				"agency_id",
+				"direction_id",
+				"trip_start_date",
+				"trip_start_time",
+				"schedule_relationship",
+				"vehicle_id",
+				"stop_sequence",
+				"from_stop_id",
+				"to_stop_id",
+				"scheduled_arrival_time",
+				"scheduled_departure_time",
+				"observed_arrival_time",
+				"observed_departure_time",
+				"observed_arrival_delay",
+				"dwell_time_secs",
+				"scheduled_dwell_time_secs",
+			)
+		for _, ob := range batch {
+			q = q.Values(
+				now,
+				now,
+				ob.FeedVersionID,
+				ObservationSource,
+				ob.TripID,
+				ob.RouteID,
+				ob.AgencyID,
+				ob.DirectionID,
+				ob.TripStartDate,
+				ob.TripStartTime,
+				ob.ScheduleRelationship,
+				ob.VehicleID,
+				ob.StopSequence,
+				ob.FromStopID,
+				ob.ToStopID,
+				ob.ScheduledArrivalTime,
+				ob.ScheduledDepartureTime,
+				ob.ObservedArrivalTime,
+				ob.ObservedDepartureTime,
+				ob.ObservedArrivalDelay,
+				ob.DwellTimeSecs,
+				ob.ScheduledDwellTimeSecs,
+			)
+		}
+		if _, err := q.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
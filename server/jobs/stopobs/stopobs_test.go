@@ -0,0 +1,190 @@
+package stopobs
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-lib/gtfs"
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-lib/tt"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFindObservations(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newStopTime := func(seq int, stopID int, arr string, dep string) *model.StopTime {
+		st := &model.StopTime{}
+		st.StopSequence = tt.NewInt(seq)
+		st.StopID = tt.NewString(strconv.Itoa(stopID))
+		st.ArrivalTime, _ = tt.NewSecondsFromString(arr)
+		st.DepartureTime, _ = tt.NewSecondsFromString(dep)
+		return st
+	}
+	ot := &ObservationTrip{
+		FeedVersionID: 1,
+		TripID:        "1031527WKDY",
+		RouteID:       "05",
+		AgencyID:      "BART",
+		DirectionID:   0,
+		StopTimes: []*model.StopTime{
+			newStopTime(12, 1, "16:02:00", "16:02:00"),
+			newStopTime(13, 2, "16:06:00", "16:06:30"),
+			newStopTime(14, 3, "16:10:00", "16:10:00"),
+			newStopTime(15, 4, "16:14:00", "16:14:00"),
+		},
+		StopIDs: map[int]string{1: "FTVL", 2: "COLS", 3: "SANL", 4: "BAYF"},
+	}
+	serviceTime := func(h, m, s int) *int64 {
+		return proto.Int64(time.Date(2018, 5, 30, h, m, s, 0, loc).Unix())
+	}
+	tu := &pb.TripUpdate{
+		Trip: &pb.TripDescriptor{
+			TripId:    proto.String("1031527WKDY"),
+			StartDate: proto.String("20180530"),
+		},
+		Vehicle: &pb.VehicleDescriptor{Id: proto.String("bus1")},
+		StopTimeUpdate: []*pb.TripUpdate_StopTimeUpdate{
+			// arrived and departed using delay
+			{StopSequence: proto.Uint32(12), Arrival: &pb.TripUpdate_StopTimeEvent{Delay: proto.Int32(60)}},
+			// arrived using explicit time, matched by stop_id, not yet departed
+			{StopId: proto.String("COLS"), Arrival: &pb.TripUpdate_StopTimeEvent{Time: serviceTime(16, 7, 0)}, Departure: &pb.TripUpdate_StopTimeEvent{Time: serviceTime(16, 9, 0)}},
+			// skipped
+			{StopSequence: proto.Uint32(14), ScheduleRelationship: pb.TripUpdate_StopTimeUpdate_SKIPPED.Enum()},
+			// not yet arrived
+			{StopSequence: proto.Uint32(15), Arrival: &pb.TripUpdate_StopTimeEvent{Delay: proto.Int32(60)}},
+		},
+	}
+	newVehicle := func(seq uint32, status pb.VehiclePosition_VehicleStopStatus) *pb.VehiclePosition {
+		return &pb.VehiclePosition{
+			Trip:                &pb.TripDescriptor{TripId: proto.String("1031527WKDY")},
+			CurrentStopSequence: proto.Uint32(seq),
+			CurrentStatus:       status.Enum(),
+		}
+	}
+	now := time.Date(2018, 5, 30, 16, 8, 0, 0, loc)
+	obs := FindObservations(tu, newVehicle(13, pb.VehiclePosition_STOPPED_AT), ot, loc, now)
+	if !assert.Equal(t, 2, len(obs)) {
+		return
+	}
+
+	a := obs[0]
+	assert.Equal(t, "FTVL", a.ToStopID)
+	assert.Equal(t, "", a.FromStopID)
+	assert.Equal(t, 12, a.StopSequence)
+	assert.Equal(t, "2018-05-30", a.TripStartDate.String())
+	assert.Equal(t, "16:03:00", a.ObservedArrivalTime.String())
+	assert.Equal(t, "16:03:00", a.ObservedDepartureTime.String())
+	assert.Equal(t, 60, a.ObservedArrivalDelay.Int())
+	assert.Equal(t, "05", a.RouteID)
+	assert.Equal(t, "BART", a.AgencyID)
+	assert.Equal(t, "bus1", a.VehicleID)
+
+	b := obs[1]
+	assert.Equal(t, "COLS", b.ToStopID)
+	assert.Equal(t, "FTVL", b.FromStopID)
+	assert.Equal(t, 13, b.StopSequence)
+	assert.Equal(t, "16:07:00", b.ObservedArrivalTime.String())
+	assert.False(t, b.ObservedDepartureTime.Valid)
+	assert.Equal(t, 60, b.ObservedArrivalDelay.Int())
+	assert.Equal(t, 30, b.ScheduledDwellTimeSecs.Int())
+	assert.False(t, b.DwellTimeSecs.Valid)
+
+	t.Run("passed by later stop time update", func(t *testing.T) {
+		// COLS is in the past but the vehicle is not known to have reached it
+		obs := FindObservations(tu, nil, ot, loc, now)
+		if assert.Equal(t, 1, len(obs)) {
+			assert.Equal(t, "FTVL", obs[0].ToStopID)
+			assert.Equal(t, "16:03:00", obs[0].ObservedDepartureTime.String())
+		}
+	})
+	t.Run("in transit to current stop", func(t *testing.T) {
+		obs := FindObservations(tu, newVehicle(13, pb.VehiclePosition_IN_TRANSIT_TO), ot, loc, now)
+		if assert.Equal(t, 1, len(obs)) {
+			assert.Equal(t, "FTVL", obs[0].ToStopID)
+		}
+	})
+	t.Run("departed", func(t *testing.T) {
+		obs := FindObservations(tu, newVehicle(14, pb.VehiclePosition_IN_TRANSIT_TO), ot, loc, time.Date(2018, 5, 30, 16, 10, 0, 0, loc))
+		if assert.Equal(t, 2, len(obs)) {
+			assert.Equal(t, "16:09:00", obs[1].ObservedDepartureTime.String())
+			assert.Equal(t, 120, obs[1].DwellTimeSecs.Int())
+		}
+	})
+	t.Run("departure time not yet passed", func(t *testing.T) {
+		obs := FindObservations(tu, newVehicle(14, pb.VehiclePosition_IN_TRANSIT_TO), ot, loc, now)
+		if assert.Equal(t, 2, len(obs)) {
+			assert.False(t, obs[1].ObservedDepartureTime.Valid)
+		}
+	})
+	t.Run("start date from current time", func(t *testing.T) {
+		tu2 := proto.Clone(tu).(*pb.TripUpdate)
+		tu2.Trip.StartDate = nil
+		obs := FindObservations(tu2, newVehicle(13, pb.VehiclePosition_STOPPED_AT), ot, loc, now)
+		if assert.Equal(t, 2, len(obs)) {
+			assert.Equal(t, "2018-05-30", obs[0].TripStartDate.String())
+		}
+	})
+	t.Run("ambiguous stop_id", func(t *testing.T) {
+		ot2 := *ot
+		ot2.StopIDs = map[int]string{1: "FTVL", 2: "COLS", 3: "SANL", 4: "COLS"}
+		obs := FindObservations(tu, newVehicle(13, pb.VehiclePosition_STOPPED_AT), &ot2, loc, now)
+		if assert.Equal(t, 1, len(obs)) {
+			assert.Equal(t, "FTVL", obs[0].ToStopID)
+		}
+	})
+}
+
+func TestFindObservations_NoSchedule(t *testing.T) {
+	// Stop times without scheduled times can only be observed with explicit times
+	loc := time.UTC
+	st := &model.StopTime{StopTime: gtfs.StopTime{StopSequence: tt.NewInt(1), StopID: tt.NewString("1")}}
+	ot := &ObservationTrip{StopTimes: []*model.StopTime{st}, StopIDs: map[int]string{1: "A"}}
+	now := time.Date(2018, 5, 30, 12, 0, 0, 0, loc)
+	tu := &pb.TripUpdate{
+		Trip: &pb.TripDescriptor{TripId: proto.String("test")},
+		StopTimeUpdate: []*pb.TripUpdate_StopTimeUpdate{
+			{StopSequence: proto.Uint32(1), Arrival: &pb.TripUpdate_StopTimeEvent{Delay: proto.Int32(60)}},
+		},
+	}
+	vp := &pb.VehiclePosition{CurrentStopSequence: proto.Uint32(1), CurrentStatus: pb.VehiclePosition_STOPPED_AT.Enum()}
+	assert.Equal(t, 0, len(FindObservations(tu, vp, ot, loc, now)))
+	tu.StopTimeUpdate[0].Arrival = &pb.TripUpdate_StopTimeEvent{Time: proto.Int64(now.Add(-time.Minute).Unix())}
+	obs := FindObservations(tu, vp, ot, loc, now)
+	if assert.Equal(t, 1, len(obs)) {
+		assert.Equal(t, "11:59:00", obs[0].ObservedArrivalTime.String())
+		assert.False(t, obs[0].ObservedArrivalDelay.Valid)
+	}
+}
+
+func TestFindObservations_DST(t *testing.T) {
+	// Times on the day clocks change are relative to noon minus 12 hours, not midnight
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &model.StopTime{}
+	st.StopSequence = tt.NewInt(1)
+	st.StopID = tt.NewString("1")
+	st.ArrivalTime, _ = tt.NewSecondsFromString("08:00:00")
+	st.DepartureTime = st.ArrivalTime
+	ot := &ObservationTrip{StopTimes: []*model.StopTime{st}, StopIDs: map[int]string{1: "A"}}
+	tu := &pb.TripUpdate{
+		Trip: &pb.TripDescriptor{TripId: proto.String("test"), StartDate: proto.String("20180311")},
+		StopTimeUpdate: []*pb.TripUpdate_StopTimeUpdate{
+			{StopSequence: proto.Uint32(1), Arrival: &pb.TripUpdate_StopTimeEvent{Time: proto.Int64(time.Date(2018, 3, 11, 8, 0, 0, 0, loc).Unix())}},
+		},
+	}
+	vp := &pb.VehiclePosition{CurrentStopSequence: proto.Uint32(1), CurrentStatus: pb.VehiclePosition_STOPPED_AT.Enum()}
+	obs := FindObservations(tu, vp, ot, loc, time.Date(2018, 3, 11, 9, 0, 0, 0, loc))
+	if assert.Equal(t, 1, len(obs)) {
+		assert.Equal(t, "2018-03-11", obs[0].TripStartDate.String())
+		assert.Equal(t, "08:00:00", obs[0].ObservedArrivalTime.String())
+		assert.Equal(t, 0, obs[0].ObservedArrivalDelay.Int())
+	}
+}
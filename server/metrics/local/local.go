@@ -20,6 +20,10 @@ func (m *LocalMetric) NewApiMetric(handlerName string) metrics.ApiMetric {
 	return &LocalMetric{}
 }
 
+func (m *LocalMetric) NewCacheMetric(cacheName string) metrics.CacheMetric {
+	return &LocalMetric{}
+}
+
 func (m *LocalMetric) MetricsHandler() http.Handler {
 	return nil
 }
@@ -32,3 +36,9 @@ func (m *LocalMetric) AddCompletedJob(queueName string, jobType string, success
 
 func (m *LocalMetric) AddResponse(method string, responseCode int, requestSize int64, responseSize int64, responseTime float64) {
 }
+
+func (m *LocalMetric) AddCacheHit() {
+}
+
+func (m *LocalMetric) AddCacheMiss() {
+}
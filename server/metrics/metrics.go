@@ -11,12 +11,30 @@ type JobMetric interface {
 	AddCompletedJob(string, string, bool)
 }
 
+type CacheMetric interface {
+	AddCacheHit()
+	AddCacheMiss()
+}
+
 type MetricProvider interface {
 	NewApiMetric(handlerName string) ApiMetric
 	NewJobMetric(queue string) JobMetric
 	MetricsHandler() http.Handler
 }
 
+// CacheMetricProvider is optionally implemented by a MetricProvider that supports cache metrics
+type CacheMetricProvider interface {
+	NewCacheMetric(cacheName string) CacheMetric
+}
+
+// NewCacheMetric returns a cache metric from the provider, or nil if the provider does not support cache metrics
+func NewCacheMetric(p MetricProvider, cacheName string) CacheMetric {
+	if cp, ok := p.(CacheMetricProvider); ok {
+		return cp.NewCacheMetric(cacheName)
+	}
+	return nil
+}
+
 type Config struct {
 	EnableMetrics   bool
 	MetricsProvider string
@@ -3,6 +3,7 @@ package model
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/interline-io/transitland-lib/dmfr"
 	"github.com/interline-io/transitland-server/internal/clock"
@@ -26,6 +27,7 @@ type Config struct {
 	LoaderBatchSize         int
 	LoaderStopTimeBatchSize int
 	MaxRadius               float64
+	AllowedOrigins          []string
 }
 
 var finderCtxKey = &contextKey{"finderConfig"}
@@ -47,6 +49,22 @@ func WithConfig(ctx context.Context, cfg Config) context.Context {
 	return r
 }
 
+var asOfCtxKey = &contextKey{"asOf"}
+
+// WithAsOf sets a time for replaying archived realtime data.
+// The config Clock is also set to this time, so relative queries such as next departures use the as-of time.
+func WithAsOf(ctx context.Context, t time.Time) context.Context {
+	cfg := ForContext(ctx)
+	cfg.Clock = &clock.Mock{T: t}
+	return context.WithValue(WithConfig(ctx, cfg), asOfCtxKey, t)
+}
+
+// AsOfForContext returns the as-of time for replaying archived realtime data, if set.
+func AsOfForContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(asOfCtxKey).(time.Time)
+	return t, ok
+}
+
 func AddConfig(cfg Config) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,7 +41,9 @@ type EntityFinder interface {
 	FindPlaces(context.Context, *int, *Cursor, []int, *PlaceAggregationLevel, *PlaceFilter) ([]*Place, error)
 	FindCensusDatasets(context.Context, *int, *Cursor, []int, *CensusDatasetFilter) ([]*CensusDataset, error)
 	RouteStopBuffer(context.Context, *int, *float64, int) ([]*RouteStopBuffer, error)
+	RouteOnTimePerformance(context.Context, int, DateRange, int) (*RouteOnTimePerformance, error)
 	FindFeedVersionServiceWindow(context.Context, int) (*ServiceWindow, error)
+	FindFlexServices(context.Context, *int, PointRadius, *time.Time) ([]*FlexService, error)
 	DBX() tldb.Ext // escape hatch, for now
 }
 
@@ -50,6 +52,7 @@ type EntityLoader interface {
 	AgenciesByIDs(context.Context, []int) ([]*Agency, []error)
 	AgenciesByOnestopIDs(context.Context, *int, *AgencyFilter, []string) ([][]*Agency, error)
 	AgencyPlacesByAgencyIDs(context.Context, *int, *AgencyPlaceFilter, []int) ([][]*AgencyPlace, error)
+	BookingRulesByIDs(context.Context, []int) ([]*BookingRule, []error)
 	CalendarDatesByServiceIDs(context.Context, *int, *CalendarDateFilter, []int) ([][]*CalendarDate, error)
 	CalendarsByIDs(context.Context, []int) ([]*Calendar, []error)
 	CensusDatasetLayersByDatasetIDs(context.Context, []int) ([][]*CensusLayer, []error)
@@ -76,9 +79,14 @@ type EntityLoader interface {
 	FeedVersionsByIDs(context.Context, []int) ([]*FeedVersion, []error)
 	FeedVersionServiceLevelsByFeedVersionIDs(context.Context, *int, *FeedVersionServiceLevelFilter, []int) ([][]*FeedVersionServiceLevel, error)
 	FeedVersionServiceWindowByFeedVersionIDs(context.Context, []int) ([]*FeedVersionServiceWindow, []error)
+	FlexStopTimesByTripIDs(context.Context, []int) ([][]*FlexService, []error)
 	FrequenciesByTripIDs(context.Context, *int, []int) ([][]*Frequency, error)
+	GbfsStationAvailabilityByStationIDs(context.Context, *int, *GbfsStationAvailabilityFilter, []string) ([][]*GbfsStationAvailability, error)
+	GbfsStationOccupancyByStationIDs(context.Context, *int, *GbfsStationOccupancyFilter, []string) ([][]*GbfsStationOccupancy, error)
 	LevelsByIDs(context.Context, []int) ([]*Level, []error)
 	LevelsByParentStationIDs(context.Context, *int, []int) ([][]*Level, error)
+	LocationGroupsByIDs(context.Context, []int) ([]*LocationGroup, []error)
+	LocationsByIDs(context.Context, []int) ([]*Location, []error)
 	OperatorsByAgencyIDs(context.Context, []int) ([]*Operator, []error)
 	OperatorsByCOIFs(context.Context, []int) ([]*Operator, []error)
 	OperatorsByFeedIDs(context.Context, *int, *OperatorFilter, []int) ([][]*Operator, error)
@@ -106,11 +114,13 @@ type EntityLoader interface {
 	StopsByFeedVersionIDs(context.Context, *int, *StopFilter, []int) ([][]*Stop, error)
 	StopsByIDs(context.Context, []int) ([]*Stop, []error)
 	StopsByLevelIDs(context.Context, *int, *StopFilter, []int) ([][]*Stop, error)
+	StopsByLocationGroupIDs(context.Context, *int, *StopFilter, []int) ([][]*Stop, error)
 	StopsByParentStopIDs(context.Context, *int, *StopFilter, []int) ([][]*Stop, error)
 	StopsByRouteIDs(context.Context, *int, *StopFilter, []int) ([][]*Stop, error)
 	StopTimesByStopIDs(context.Context, *int, *StopTimeFilter, []FVPair) ([][]*StopTime, error)
 	StopTimesByTripIDs(context.Context, *int, *TripStopTimeFilter, []FVPair) ([][]*StopTime, error)
 	TargetStopsByStopIDs(context.Context, []int) ([]*Stop, []error)
+	TransfersByFeedVersionIDs(context.Context, *int, []int) ([][]*Transfer, error)
 	TripsByFeedVersionIDs(context.Context, *int, *TripFilter, []int) ([][]*Trip, error)
 	TripsByIDs(context.Context, []int) ([]*Trip, []error)
 	TripsByRouteIDs(context.Context, *int, *TripFilter, []FVPair) ([][]*Trip, error)
@@ -137,22 +147,34 @@ type RTFinder interface {
 	FindTrip(context.Context, *Trip) *pb.TripUpdate
 	MakeTrip(context.Context, *Trip) (*Trip, error)
 	FindAlertsForTrip(context.Context, *Trip, *int, *bool) []*Alert
-	FindAlertsForStop(context.Context, *Stop, *int, *bool) []*Alert
-	FindAlertsForRoute(context.Context, *Route, *int, *bool) []*Alert
+	FindAlertsForStop(context.Context, *Stop, *Route, *int, *bool) []*Alert
+	FindAlertsForRoute(context.Context, *Route, []*Stop, *int, *bool) []*Alert
 	FindAlertsForAgency(context.Context, *Agency, *int, *bool) []*Alert
+	FindAlertsForFeeds(context.Context, []string, *int, *bool) []*Alert
+	FindVehiclePositionForTrip(context.Context, *Trip) *VehiclePosition
+	FindVehiclePositionsForRoute(context.Context, *Route, *int) []*VehiclePosition
 	GetAddedTripsForStop(context.Context, *Stop) []*pb.TripUpdate
+	FindTripModifications(context.Context, *Trip) (*RTTripModifications, bool)
+	FindTripModificationsForStop(context.Context, *Stop) []string
 	FindStopTimeUpdate(context.Context, *Trip, *StopTime) (*RTStopTimeUpdate, bool)
+	// subscriptions
+	Subscribe(context.Context, []string, ...string) <-chan struct{}
+	SubscribeFeedVersions(context.Context, []int, ...string) <-chan struct{}
 	// lookup cache methods
 	StopTimezone(context.Context, int, string) (*time.Location, bool)
 	GetGtfsTripID(context.Context, int) (string, bool)
+	GetGtfsStopID(context.Context, int) (string, bool)
+	GetRTFeedFeedVersions(context.Context, string) ([]int, bool)
 	GetMessage(context.Context, string, string) (*pb.FeedMessage, bool)
 }
 
 // GbfsFinder manages and looks up GBFS data
 type GbfsFinder interface {
 	AddData(context.Context, string, gbfs.GbfsFeed) error
+	GetData(context.Context, string) (gbfs.GbfsFeed, bool)
 	FindBikes(context.Context, *int, *GbfsBikeRequest) ([]*GbfsFreeBikeStatus, error)
 	FindDocks(context.Context, *int, *GbfsDockRequest) ([]*GbfsStationInformation, error)
+	FindGeofenceChecks(context.Context, *GbfsGeofenceCheckRequest) ([]*GbfsGeofenceCheck, error)
 }
 
 type Checker interface {
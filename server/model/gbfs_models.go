@@ -1,6 +1,9 @@
 package model
 
 import (
+	"time"
+
+	"github.com/interline-io/transitland-lib/tlxy"
 	"github.com/interline-io/transitland-server/internal/gbfs"
 )
 
@@ -13,6 +16,7 @@ type GbfsBrandAsset struct {
 }
 
 type GbfsFeed struct {
+	FeedOnestopID string // internal: feed the GBFS data was fetched from
 	*gbfs.GbfsFeed
 }
 
@@ -148,6 +152,56 @@ func (g *GbfsFreeBikeStatus) RentalUris() *GbfsRentalUris {
 	return &GbfsRentalUris{RentalURIs: g.RentalURIs}
 }
 
+type GbfsGeofenceCheck struct {
+	Feed          *GbfsFeed
+	VehicleTypeID string
+	gbfs.GeofenceCheck
+}
+
+func (g *GbfsGeofenceCheck) SystemInformation() *GbfsSystemInformation {
+	if g.Feed == nil {
+		return nil
+	}
+	return g.Feed.SystemInformation()
+}
+
+func (g *GbfsGeofenceCheck) VehicleType() *GbfsVehicleType {
+	if g.Feed == nil || g.VehicleTypeID == "" {
+		return nil
+	}
+	for _, s := range g.Feed.VehicleTypes {
+		if s != nil && s.VehicleTypeID.Val == g.VehicleTypeID {
+			return &GbfsVehicleType{VehicleType: s, Feed: g.Feed}
+		}
+	}
+	return nil
+}
+
+// GbfsGeofenceCheckRequest is a path to check against geofencing zones
+type GbfsGeofenceCheckRequest struct {
+	Path          []tlxy.Point
+	SystemID      *string
+	VehicleTypeID *string
+	At            time.Time
+}
+
+// GbfsStationAvailabilityFilter selects station availability snapshots for a GBFS system
+type GbfsStationAvailabilityFilter struct {
+	FeedOnestopID string
+	SystemID      string
+	Start         time.Time
+	End           time.Time
+	Interval      int
+}
+
+// GbfsStationOccupancyFilter selects station availability snapshots for a GBFS system, grouped in a timezone
+type GbfsStationOccupancyFilter struct {
+	FeedOnestopID string
+	SystemID      string
+	Timezone      string
+	Dow           *int
+}
+
 type GbfsGeofenceFeature struct {
 	*gbfs.GeofenceFeature
 }
@@ -230,6 +284,13 @@ func (g *GbfsStationInformation) Region() *GbfsSystemRegion {
 	return nil
 }
 
+func (g *GbfsStationInformation) RentalUris() *GbfsRentalUris {
+	if g.StationInformation == nil || g.StationInformation.RentalURIs == nil {
+		return nil
+	}
+	return &GbfsRentalUris{RentalURIs: g.StationInformation.RentalURIs}
+}
+
 func (g *GbfsStationInformation) Status() *GbfsStationStatus {
 	if g.Feed == nil {
 		return nil
@@ -96,31 +96,72 @@ type Route struct {
 }
 
 type Trip struct {
-	RTTripID string // internal: for ADDED trips
+	RTTripID    string     // internal: for ADDED trips
+	AsOf        *time.Time // internal: replay archived RT data
+	StartTime   tt.Seconds `db:"-"` // internal: start time of an expanded frequency-based trip run
+	ServiceDate tt.Date    `db:"-"` // internal: service date of a trip reached through a departure
 	gtfs.Trip
 }
 
 type RTStopTimeUpdate struct {
 	LastDelay      *int32
+	EstimatedDelay bool // LastDelay is propagated from an earlier trip in the same block
 	StopTimeUpdate *pb.TripUpdate_StopTimeUpdate
 	TripUpdate     *pb.TripUpdate
 }
 
+// VehiclePosition is a GTFS-RT VehiclePosition associated with a feed version
+type VehiclePosition struct {
+	Vehicle             *RTVehicleDescriptor
+	Trip                *RTTripDescriptor
+	Position            *tt.Point
+	CurrentStopSequence *int
+	CurrentStatus       *string
+	Timestamp           *time.Time
+	CongestionLevel     *string
+	FeedVersionID       int    // internal
+	RTStopID            string // internal
+}
+
+// RTEntitySelector is a GTFS-RT alert informed entity
+type RTEntitySelector struct {
+	AgencyID       *string
+	RouteID        *string
+	RouteType      *int
+	DirectionID    *int
+	StopID         *string
+	Trip           *RTTripDescriptor
+	FeedVersionIDs []int // internal: used to find matching static entities
+}
+
+// RTTripModifications holds the GTFS-RT TripModifications for a trip,
+// along with the replacement shape and any stops defined in the realtime feed.
+type RTTripModifications struct {
+	TripModifications *pb.TripModifications
+	Shape             *Shape
+	Stops             map[string]*Stop
+}
+
 type StopTime struct {
 	ServiceDate      tt.Date
 	Date             tt.Date
 	RTTripID         string            // internal: for ADDED trips
 	RTStopTimeUpdate *RTStopTimeUpdate // internal
+	RTStop           *Stop             // internal: replacement stop from a GTFS-RT trip modification
+	AsOf             *time.Time        // internal: replay archived RT data
+	ModifiedByDetour bool
+	StartTime        tt.Seconds // start time of an expanded frequency-based trip run
 	gtfs.StopTime
 }
 
 type Stop struct {
-	FeedOnestopID   string
-	FeedVersionSHA1 string
-	OnestopID       *string
-	SearchRank      *string
-	WithinFeatures  tt.Strings
-	WithRouteID     tt.Int
+	FeedOnestopID       string
+	FeedVersionSHA1     string
+	OnestopID           *string
+	SearchRank          *string
+	WithinFeatures      tt.Strings
+	WithRouteID         tt.Int
+	WithLocationGroupID tt.Int
 	gtfs.Stop
 }
 
@@ -146,10 +187,73 @@ type FeedInfo struct {
 	gtfs.FeedInfo
 }
 
+type Transfer struct {
+	gtfs.Transfer
+}
+
 type Pathway struct {
 	gtfs.Pathway
 }
 
+// Location is a GTFS-Flex locations.geojson zone
+type Location struct {
+	LocationID tt.String
+	StopName   tt.String
+	StopDesc   tt.String
+	ZoneID     tt.String
+	StopURL    tt.String
+	Geometry   tt.Geometry
+	tt.BaseEntity
+}
+
+// LocationGroup is a GTFS-Flex location_groups.txt record
+type LocationGroup struct {
+	LocationGroupID   tt.String
+	LocationGroupName tt.String
+	tt.BaseEntity
+}
+
+// BookingRule is a GTFS-Flex booking_rules.txt record
+type BookingRule struct {
+	BookingRuleID          tt.String
+	BookingType            tt.Int
+	PriorNoticeDurationMin tt.Int
+	PriorNoticeDurationMax tt.Int
+	PriorNoticeLastDay     tt.Int
+	PriorNoticeLastTime    tt.Seconds
+	PriorNoticeStartDay    tt.Int
+	PriorNoticeStartTime   tt.Seconds
+	Message                tt.String
+	PickupMessage          tt.String
+	DropOffMessage         tt.String
+	PhoneNumber            tt.String
+	InfoURL                tt.String
+	BookingURL             tt.String
+	tt.BaseEntity
+}
+
+// FlexService is the GTFS-Flex attributes of a stop time; returned by flex_service for stop times whose location or location group covers a point
+type FlexService struct {
+	FeedVersionID            int
+	TripID                   int
+	StopSequence             int
+	LocationID               tt.Key
+	LocationGroupID          tt.Key
+	StartPickupDropOffWindow tt.Seconds
+	EndPickupDropOffWindow   tt.Seconds
+	PickupType               tt.Int
+	DropOffType              tt.Int
+	PickupBookingRuleID      tt.Key
+	DropOffBookingRuleID     tt.Key
+	ServiceDate              tt.Date
+}
+
+// Isochrone is the area reachable from an origin within a travel time cutoff
+type Isochrone struct {
+	Cutoff   int // minutes
+	Geometry tt.MultiPolygon
+}
+
 type FeedVersionFileInfo struct {
 	dmfr.FeedVersionFileInfo
 }
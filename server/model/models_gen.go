@@ -89,6 +89,28 @@ type Alert struct {
 	URL []*RTTranslation `json:"url,omitempty"`
 	// GTFS-RT Alert severity level
 	SeverityLevel *string `json:"severity_level,omitempty"`
+	// OnestopID of the GTFS-RT feed that provided this alert
+	FeedOnestopID *string `json:"feed_onestop_id,omitempty"`
+	// GTFS-RT Alert informed entities. See https://gtfs.org/realtime/reference/#message-entityselector
+	InformedEntity []*RTEntitySelector `json:"informed_entity,omitempty"`
+}
+
+// Search options for GTFS-RT alerts
+type AlertFilter struct {
+	// Search for alerts from feeds with these OnestopIDs
+	FeedOnestopIds []string `json:"feed_onestop_ids,omitempty"`
+	// Search for alerts from feeds associated with operators with these OnestopIDs
+	OperatorOnestopIds []string `json:"operator_onestop_ids,omitempty"`
+	// Search for alerts with this cause, e.g. CONSTRUCTION
+	Cause *string `json:"cause,omitempty"`
+	// Search for alerts with this effect, e.g. DETOUR
+	Effect *string `json:"effect,omitempty"`
+	// Search for alerts with this severity level, e.g. SEVERE
+	SeverityLevel *string `json:"severity_level,omitempty"`
+	// Search for alerts that are currently active
+	Active *bool `json:"active,omitempty"`
+	// Search for alerts with informed entities within this bounding box
+	Bbox *BoundingBox `json:"bbox,omitempty"`
 }
 
 // Search for entities within a specified bounding box
@@ -154,6 +176,8 @@ type CensusDatasetGeographyLocationFilter struct {
 	Bbox *BoundingBox `json:"bbox,omitempty"`
 	// Search within this geographic polygon
 	Within *tt.Polygon `json:"within,omitempty"`
+	// Search within this geographic multipolygon
+	WithinMultipolygon *tt.MultiPolygon `json:"within_multipolygon,omitempty"`
 	// Search within specified radius of a point
 	Near *PointRadius `json:"near,omitempty"`
 	// Focus search on this point; results will be sorted by distance
@@ -307,11 +331,27 @@ type CensusValue struct {
 	TableID     int    `json:"-"`
 }
 
+// Inclusive range of dates
+type DateRange struct {
+	// First date in range
+	StartDate tt.Date `json:"start_date"`
+	// Last date in range
+	EndDate tt.Date `json:"end_date"`
+}
+
 type DirectionRequest struct {
-	To       *WaypointInput `json:"to"`
-	From     *WaypointInput `json:"from"`
-	Mode     StepMode       `json:"mode"`
-	DepartAt *time.Time     `json:"depart_at,omitempty"`
+	To                    *WaypointInput `json:"to"`
+	From                  *WaypointInput `json:"from"`
+	Mode                  StepMode       `json:"mode"`
+	DepartAt              *time.Time     `json:"depart_at,omitempty"`
+	ArriveBy              *bool          `json:"arrive_by,omitempty"`
+	NumItineraries        *int           `json:"num_itineraries,omitempty"`
+	MaxWalkDistance       *float64       `json:"max_walk_distance,omitempty"`
+	Wheelchair            *bool          `json:"wheelchair,omitempty"`
+	AllowedRouteTypes     []int          `json:"allowed_route_types,omitempty"`
+	BannedRouteOnestopIds []string       `json:"banned_route_onestop_ids,omitempty"`
+	WalkSpeed             *float64       `json:"walk_speed,omitempty"`
+	MaxTransfers          *int           `json:"max_transfers,omitempty"`
 }
 
 type Directions struct {
@@ -497,21 +537,70 @@ type FocusPoint struct {
 }
 
 type GbfsBikeRequest struct {
-	Near *PointRadius `json:"near,omitempty"`
+	Near                  *PointRadius `json:"near,omitempty"`
+	Bbox                  *BoundingBox `json:"bbox,omitempty"`
+	SystemID              *string      `json:"system_id,omitempty"`
+	FeedOnestopID         *string      `json:"feed_onestop_id,omitempty"`
+	FormFactor            *string      `json:"form_factor,omitempty"`
+	PropulsionType        *string      `json:"propulsion_type,omitempty"`
+	MinCurrentRangeMeters *float64     `json:"min_current_range_meters,omitempty"`
 }
 
 type GbfsDockRequest struct {
-	Near *PointRadius `json:"near,omitempty"`
+	Near              *PointRadius `json:"near,omitempty"`
+	Bbox              *BoundingBox `json:"bbox,omitempty"`
+	SystemID          *string      `json:"system_id,omitempty"`
+	FeedOnestopID     *string      `json:"feed_onestop_id,omitempty"`
+	MinBikesAvailable *int         `json:"min_bikes_available,omitempty"`
+	MinDocksAvailable *int         `json:"min_docks_available,omitempty"`
+}
+
+type GbfsStationAvailability struct {
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	Snapshots         int       `json:"snapshots"`
+	AvgBikesAvailable *float64  `json:"avg_bikes_available,omitempty"`
+	MinBikesAvailable *int      `json:"min_bikes_available,omitempty"`
+	MaxBikesAvailable *int      `json:"max_bikes_available,omitempty"`
+	AvgDocksAvailable *float64  `json:"avg_docks_available,omitempty"`
+	MinDocksAvailable *int      `json:"min_docks_available,omitempty"`
+	MaxDocksAvailable *int      `json:"max_docks_available,omitempty"`
+	// Fraction of snapshots with no bikes available
+	EmptyRatio *float64 `json:"empty_ratio,omitempty"`
+	// Fraction of snapshots with no docks available
+	FullRatio *float64 `json:"full_ratio,omitempty"`
+}
+
+type GbfsStationOccupancy struct {
+	Dow               int      `json:"dow"`
+	Hour              int      `json:"hour"`
+	Snapshots         int      `json:"snapshots"`
+	AvgBikesAvailable *float64 `json:"avg_bikes_available,omitempty"`
+	AvgDocksAvailable *float64 `json:"avg_docks_available,omitempty"`
+	// Fraction of snapshots with no bikes available
+	EmptyRatio *float64 `json:"empty_ratio,omitempty"`
+	// Fraction of snapshots with no docks available
+	FullRatio *float64 `json:"full_ratio,omitempty"`
+}
+
+type Isochrones struct {
+	Success    bool         `json:"success"`
+	Exception  *string      `json:"exception,omitempty"`
+	DataSource *string      `json:"data_source,omitempty"`
+	Origin     *Waypoint    `json:"origin,omitempty"`
+	Isochrones []*Isochrone `json:"isochrones,omitempty"`
 }
 
 type Itinerary struct {
-	Duration  *Duration `json:"duration"`
-	Distance  *Distance `json:"distance"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	From      *Waypoint `json:"from"`
-	To        *Waypoint `json:"to"`
-	Legs      []*Leg    `json:"legs,omitempty"`
+	Duration         *Duration `json:"duration"`
+	Distance         *Distance `json:"distance"`
+	StartTime        time.Time `json:"start_time"`
+	EndTime          time.Time `json:"end_time"`
+	From             *Waypoint `json:"from"`
+	To               *Waypoint `json:"to"`
+	Legs             []*Leg    `json:"legs,omitempty"`
+	RealtimeFeasible *bool     `json:"realtime_feasible,omitempty"`
+	RealtimeWarnings []string  `json:"realtime_warnings,omitempty"`
 }
 
 type Leg struct {
@@ -526,6 +615,34 @@ type Leg struct {
 	Stops     []*WaypointDeparture `json:"stops,omitempty"`
 	Geometry  tt.LineString        `json:"geometry"`
 	Trip      *LegTrip             `json:"trip,omitempty"`
+	Realtime  *LegRealtime         `json:"realtime,omitempty"`
+	Rental    *LegRental           `json:"rental,omitempty"`
+}
+
+type LegRealtime struct {
+	ScheduleRelationship ScheduleRelationship `json:"schedule_relationship"`
+	EstimatedDeparture   *time.Time           `json:"estimated_departure,omitempty"`
+	EstimatedArrival     *time.Time           `json:"estimated_arrival,omitempty"`
+	DepartureDelay       *int                 `json:"departure_delay,omitempty"`
+	ArrivalDelay         *int                 `json:"arrival_delay,omitempty"`
+	Alerts               []*Alert             `json:"alerts,omitempty"`
+}
+
+type LegRental struct {
+	SystemID      string            `json:"system_id"`
+	SystemName    *string           `json:"system_name,omitempty"`
+	VehicleID     *string           `json:"vehicle_id,omitempty"`
+	VehicleTypeID *string           `json:"vehicle_type_id,omitempty"`
+	FromStation   *LegRentalStation `json:"from_station,omitempty"`
+	ToStation     *LegRentalStation `json:"to_station,omitempty"`
+	RentalUris    *GbfsRentalUris   `json:"rental_uris,omitempty"`
+}
+
+type LegRentalStation struct {
+	StationID   string  `json:"station_id"`
+	StationName *string `json:"station_name,omitempty"`
+	Lon         float64 `json:"lon"`
+	Lat         float64 `json:"lat"`
 }
 
 type LegRoute struct {
@@ -842,6 +959,28 @@ type RouteHeadway struct {
 	SelectedStopID   int           `json:"-"`
 }
 
+// On-time performance summary for a route, based on observed arrivals
+type RouteOnTimePerformance struct {
+	// First trip start date included in the summary
+	StartDate tt.Date `json:"start_date"`
+	// Last trip start date included in the summary
+	EndDate tt.Date `json:"end_date"`
+	// Number of seconds early or late that an arrival is considered on time
+	Threshold int `json:"threshold"`
+	// Number of observed arrivals with a scheduled arrival time
+	Observations int `json:"observations"`
+	// Number of arrivals more than threshold seconds early
+	Early int `json:"early"`
+	// Number of arrivals within threshold seconds of the scheduled time
+	OnTime int `json:"on_time"`
+	// Number of arrivals more than threshold seconds late
+	Late int `json:"late"`
+	// Fraction of observed arrivals that were on time
+	OnTimeRatio *float64 `json:"on_time_ratio,omitempty"`
+	// Average arrival delay, in seconds
+	AverageDelay *float64 `json:"average_delay,omitempty"`
+}
+
 // RouteStops describe associations between stops, routes, and agencies.
 type RouteStop struct {
 	// Internal integer ID
@@ -1156,6 +1295,8 @@ type StopTimeEvent struct {
 	Delay *int `json:"delay,omitempty"`
 	// Estimation uncertainty. This value is set when there is a directly matching GTFS-RT StopTimeUpdate for this stop and passed through as-is. See https://gtfs.org/realtime/reference/#message-stoptimeevent
 	Uncertainty *int `json:"uncertainty,omitempty"`
+	// Source of the estimated time, or null if there is no estimate
+	EstimateSource *StopTimeEventSource `json:"estimate_source,omitempty"`
 }
 
 // Search options for stop times, optionally on a given date
@@ -1188,6 +1329,19 @@ type StopTimeFilter struct {
 	ExcludeLast *bool `json:"exclude_last,omitempty"`
 }
 
+type Subscription struct {
+}
+
+type TravelTimeMatrix struct {
+	Success      bool          `json:"success"`
+	Exception    *string       `json:"exception,omitempty"`
+	DataSource   *string       `json:"data_source,omitempty"`
+	Origins      []*Waypoint   `json:"origins"`
+	Destinations []*Waypoint   `json:"destinations"`
+	Durations    [][]*Duration `json:"durations"`
+	Distances    [][]*Distance `json:"distances"`
+}
+
 // Search options for trips
 type TripFilter struct {
 	// Search for trips scheduled on the specified GTFS calendar service date
@@ -1218,6 +1372,10 @@ type TripStopTimeFilter struct {
 	Start *tt.Seconds `json:"start,omitempty"`
 	// Search for stop times with arrival times before the specified time, in local time HH:MM:SS
 	End *tt.Seconds `json:"end,omitempty"`
+	// For frequency-based trips, return stop times for the trip run starting at this time, in local time HH:MM:SS
+	StartTime *tt.Seconds `json:"start_time,omitempty"`
+	// For frequency-based trips, return stop times for every trip run in frequencies.txt, ordered by run start time
+	ExpandFrequencies *bool `json:"expand_frequencies,omitempty"`
 }
 
 // Source URL and JSON representation of GTFS-RT data used for validation
@@ -1347,22 +1505,14 @@ type ValidationReportFilter struct {
 	IncludesStatic *bool `json:"includes_static,omitempty"`
 }
 
-// [Vehicle Position](https://gtfs.org/reference/realtime/v2/#message-vehicleposition) message provided by a source GTFS Realtime feed.
-type VehiclePosition struct {
-	// GTFS-RT VehiclePosition vehicle. See https://gtfs.org/realtime/reference/#message-vehicledescriptor
-	Vehicle *RTVehicleDescriptor `json:"vehicle,omitempty"`
-	// GTFS-RT VehiclePosition current vehicle position
-	Position *tt.Point `json:"position,omitempty"`
-	// GTFS-RT VehiclePosition current stop sequence in trip
-	CurrentStopSequence *int `json:"current_stop_sequence,omitempty"`
-	// GTFS-RT VehiclePosition current stop in trip
-	StopID *Stop `json:"stop_id,omitempty"`
-	// GTFS-RT VehiclePosition current status string
-	CurrentStatus *string `json:"current_status,omitempty"`
-	// GTFS-RT VehiclePosition timestamp
-	Timestamp *time.Time `json:"timestamp,omitempty"`
-	// GTFS-RT VehiclePosition congestion level estimate
-	CongestionLevel *string `json:"congestion_level,omitempty"`
+// Search options for vehicle positions
+type VehicleFilter struct {
+	// Search for vehicles within this bounding box
+	Bbox *BoundingBox `json:"bbox,omitempty"`
+	// Search for vehicles within specified radius of a point
+	Near *PointRadius `json:"near,omitempty"`
+	// Search for vehicles on routes with these OnestopIDs
+	RouteOnestopIds []string `json:"route_onestop_ids,omitempty"`
 }
 
 type Waypoint struct {
@@ -2026,11 +2176,12 @@ func (e ScheduleRelationship) MarshalJSON() ([]byte, error) {
 type StepMode string
 
 const (
-	StepModeWalk    StepMode = "WALK"
-	StepModeAuto    StepMode = "AUTO"
-	StepModeBicycle StepMode = "BICYCLE"
-	StepModeTransit StepMode = "TRANSIT"
-	StepModeLine    StepMode = "LINE"
+	StepModeWalk      StepMode = "WALK"
+	StepModeAuto      StepMode = "AUTO"
+	StepModeBicycle   StepMode = "BICYCLE"
+	StepModeTransit   StepMode = "TRANSIT"
+	StepModeLine      StepMode = "LINE"
+	StepModeBikeshare StepMode = "BIKESHARE"
 )
 
 var AllStepMode = []StepMode{
@@ -2039,11 +2190,12 @@ var AllStepMode = []StepMode{
 	StepModeBicycle,
 	StepModeTransit,
 	StepModeLine,
+	StepModeBikeshare,
 }
 
 func (e StepMode) IsValid() bool {
 	switch e {
-	case StepModeWalk, StepModeAuto, StepModeBicycle, StepModeTransit, StepModeLine:
+	case StepModeWalk, StepModeAuto, StepModeBicycle, StepModeTransit, StepModeLine, StepModeBikeshare:
 		return true
 	}
 	return false
@@ -2083,3 +2235,61 @@ func (e StepMode) MarshalJSON() ([]byte, error) {
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
+
+// Source of a StopTimeEvent estimate
+type StopTimeEventSource string
+
+const (
+	// Estimate from a GTFS-RT TripUpdate for this trip
+	StopTimeEventSourceRealtime StopTimeEventSource = "REALTIME"
+	// Estimate propagated from the delay of an earlier trip in the same block, allowing for layover time. This is not a GTFS-RT prediction.
+	StopTimeEventSourceEstimatedDelay StopTimeEventSource = "ESTIMATED_DELAY"
+)
+
+var AllStopTimeEventSource = []StopTimeEventSource{
+	StopTimeEventSourceRealtime,
+	StopTimeEventSourceEstimatedDelay,
+}
+
+func (e StopTimeEventSource) IsValid() bool {
+	switch e {
+	case StopTimeEventSourceRealtime, StopTimeEventSourceEstimatedDelay:
+		return true
+	}
+	return false
+}
+
+func (e StopTimeEventSource) String() string {
+	return string(e)
+}
+
+func (e *StopTimeEventSource) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = StopTimeEventSource(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid StopTimeEventSource", str)
+	}
+	return nil
+}
+
+func (e StopTimeEventSource) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *StopTimeEventSource) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e StopTimeEventSource) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -43,8 +43,12 @@ func WithPerms(ctx context.Context, checker Checker) context.Context {
 		panic(err)
 	}
 	//	log.For(ctx).Trace().Msgf("WithPerms: %#v", pf)
-	r := context.WithValue(ctx, pfCtxKey, pf)
-	return r
+	return WithPermFilter(ctx, pf)
+}
+
+// WithPermFilter sets the permission filter for a context
+func WithPermFilter(ctx context.Context, pf *PermFilter) context.Context {
+	return context.WithValue(ctx, pfCtxKey, pf)
 }
 
 func AddPerms(checker Checker) func(http.Handler) http.Handler {
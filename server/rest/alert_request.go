@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"context"
+	_ "embed"
+
+	oa "github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed alert_request.gql
+var alertQuery string
+
+// AlertRequest holds options for an Alert request
+type AlertRequest struct {
+	FeedOnestopID     string    `json:"feed_onestop_id"`
+	OperatorOnestopID string    `json:"operator_onestop_id"`
+	Cause             string    `json:"cause"`
+	Effect            string    `json:"effect"`
+	SeverityLevel     string    `json:"severity_level"`
+	Active            bool      `json:"active,string"`
+	Bbox              *restBbox `json:"bbox"`
+	WithCursor
+}
+
+func (r AlertRequest) RequestInfo() RequestInfo {
+	return RequestInfo{
+		Path: "/alerts",
+		Get: RequestOperation{
+			Query: alertQuery,
+			Operation: &oa.Operation{
+				Summary: `Search for current GTFS Realtime alerts`,
+				Extensions: map[string]any{
+					"x-alternates": []RequestAltPath{
+						{"GET", "/alerts.{format}", "Request alerts in specified format"},
+					},
+				},
+				Parameters: oa.Parameters{
+					&pref{Value: &param{
+						Name:        "feed_onestop_id",
+						In:          "query",
+						Description: `Search for alerts from GTFS Realtime feeds with these Onestop IDs, as a comma separated string`,
+						Schema:      newSRVal("string", "", nil),
+						Extensions:  newExt("", "feed_onestop_id=f-sf~bay~area~rg~rt", ""),
+					}},
+					&pref{Value: &param{
+						Name:        "operator_onestop_id",
+						In:          "query",
+						Description: `Search for alerts from GTFS Realtime feeds associated with operators with these Onestop IDs, as a comma separated string`,
+						Schema:      newSRVal("string", "", nil),
+						Extensions:  newExt("", "operator_onestop_id=o-9q9-bayarearapidtransit", ""),
+					}},
+					&pref{Value: &param{
+						Name:        "cause",
+						In:          "query",
+						Description: `Search for alerts with this cause`,
+						Schema:      newSRVal("string", "", nil),
+						Extensions:  newExt("", "cause=CONSTRUCTION", ""),
+					}},
+					&pref{Value: &param{
+						Name:        "effect",
+						In:          "query",
+						Description: `Search for alerts with this effect`,
+						Schema:      newSRVal("string", "", nil),
+						Extensions:  newExt("", "effect=DETOUR", ""),
+					}},
+					&pref{Value: &param{
+						Name:        "severity_level",
+						In:          "query",
+						Description: `Search for alerts with this severity level`,
+						Schema:      newSRVal("string", "", nil),
+						Extensions:  newExt("", "severity_level=SEVERE", ""),
+					}},
+					&pref{Value: &param{
+						Name:        "active",
+						In:          "query",
+						Description: `Only include alerts that are currently active`,
+						Schema:      newSRVal("string", "", []any{"true", "false"}),
+						Extensions:  newExt("", "active=true", ""),
+					}},
+					newPRefExt("limitParam", "", "limit=1", ""),
+					newPRefExt("bboxParam", "", "bbox=-122.269,37.807,-122.267,37.808", ""),
+				},
+			},
+		},
+	}
+}
+
+// ResponseKey returns the GraphQL response entity key.
+func (r AlertRequest) ResponseKey() string { return "alerts" }
+
+// IncludeNext returns false; alerts are not paginated.
+func (r AlertRequest) IncludeNext() bool { return false }
+
+// Query returns a GraphQL query string and variables.
+func (r AlertRequest) Query(ctx context.Context) (string, map[string]interface{}) {
+	where := hw{}
+	if r.FeedOnestopID != "" {
+		where["feed_onestop_ids"] = commaSplit(r.FeedOnestopID)
+	}
+	if r.OperatorOnestopID != "" {
+		where["operator_onestop_ids"] = commaSplit(r.OperatorOnestopID)
+	}
+	if r.Cause != "" {
+		where["cause"] = r.Cause
+	}
+	if r.Effect != "" {
+		where["effect"] = r.Effect
+	}
+	if r.SeverityLevel != "" {
+		where["severity_level"] = r.SeverityLevel
+	}
+	if r.Active {
+		where["active"] = true
+	}
+	if r.Bbox != nil {
+		where["bbox"] = r.Bbox.AsJson()
+	}
+	return alertQuery, hw{
+		"limit": r.CheckLimit(),
+		"where": where,
+	}
+}
@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/interline-io/transitland-server/internal/testconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestAlertRequest(t *testing.T) {
+	allAlerts := []string{
+		"Test trip header", "Test trip header - active",
+		"Test agency header", "Test agency header - active",
+		"Test stop header", "Test stop header - active",
+		"Test route header", "Test route header - active",
+	}
+	testcases := []testCase{
+		{
+			name:         "feed_onestop_id",
+			h:            AlertRequest{FeedOnestopID: "BA"},
+			selector:     "alerts.#.header_text.0.text",
+			expectSelect: allAlerts,
+		},
+		{
+			name:         "operator_onestop_id",
+			h:            AlertRequest{OperatorOnestopID: "o-9q9-bayarearapidtransit"},
+			selector:     "alerts.#.header_text.0.text",
+			expectSelect: allAlerts,
+		},
+		{
+			name:         "cause",
+			h:            AlertRequest{FeedOnestopID: "BA", Cause: "UNKNOWN_CAUSE"},
+			selector:     "alerts.#.header_text.0.text",
+			expectSelect: allAlerts,
+		},
+		{
+			name:         "cause no match",
+			h:            AlertRequest{FeedOnestopID: "BA", Cause: "STRIKE"},
+			selector:     "alerts.#.header_text.0.text",
+			expectLength: 0,
+		},
+		{
+			name:         "limit",
+			h:            AlertRequest{FeedOnestopID: "BA", WithCursor: WithCursor{Limit: 2}},
+			selector:     "alerts.#.header_text.0.text",
+			expectLength: 2,
+		},
+		{
+			name:         "informed entity stop",
+			h:            AlertRequest{FeedOnestopID: "BA"},
+			selector:     "alerts.#.informed_entity.#.stop.stop_id|@flatten",
+			expectSelect: []string{"FTVL", "FTVL"},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			checkTestCase(t, tc)
+		})
+	}
+}
+
+func TestAlertRequest_Format(t *testing.T) {
+	_, restSrv, _ := testHandlersWithOptions(t, testconfig.Options{
+		RTJsons: testconfig.DefaultRTJson(),
+	})
+	req, _ := http.NewRequest("GET", "/alerts.json?feed_onestop_id=BA", nil)
+	rr := httptest.NewRecorder()
+	restSrv.ServeHTTP(rr, req)
+	assert.Equal(t, 200, rr.Result().StatusCode, "status code")
+	assert.Equal(t, 8, len(gjson.Get(rr.Body.String(), "alerts").Array()))
+}
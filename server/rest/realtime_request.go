@@ -0,0 +1,411 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	oa "github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+	"github.com/interline-io/log"
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-server/internal/util"
+	"github.com/interline-io/transitland-server/server/model"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// RealtimeRequest builds a single GTFS Realtime message from all matching feeds.
+// Currently this exists only for OpenAPI documentation; requests are handled by realtimeHandler.
+type RealtimeRequest struct {
+	FeedOnestopID     string    `json:"feed_onestop_id"`
+	OperatorOnestopID string    `json:"operator_onestop_id"`
+	RouteOnestopID    string    `json:"route_onestop_id"`
+	Bbox              *restBbox `json:"bbox"`
+}
+
+func (r RealtimeRequest) RequestInfo() RequestInfo {
+	return RequestInfo{
+		Path:        "/realtime/{rt_type}.{format}",
+		Description: `Combine the latest GTFS Realtime messages from all matching feeds into a single message. Feeds that do not allow redistribution are excluded; returns 401 if a requested feed does not allow redistribution. Entity IDs are prefixed with the source feed Onestop ID to keep them unique.`,
+		Get: RequestOperation{
+			Operation: &oa.Operation{
+				Summary: "Combined GTFS Realtime feed",
+				Parameters: oa.Parameters{
+					&pref{Value: &param{
+						Name:        "rt_type",
+						In:          "path",
+						Required:    true,
+						Description: `GTFS Realtime message type`,
+						Schema:      newSRVal("string", "", []any{"alerts", "trip_updates", "vehicle_positions"}),
+					}},
+					&pref{Value: &param{
+						Name:        "format",
+						In:          "path",
+						Required:    true,
+						Description: `Output format (JSON or Protocol Buffers)`,
+						Schema:      newSRVal("string", "", []any{"json", "pb"}),
+					}},
+					&pref{Value: &param{
+						Name:        "feed_onestop_id",
+						In:          "query",
+						Description: `Include GTFS Realtime feeds with these Onestop IDs, as a comma separated string`,
+						Schema:      newSRVal("string", "", nil),
+						Extensions:  newExt("", "feed_onestop_id=f-sf~bay~area~rg~rt", ""),
+					}},
+					&pref{Value: &param{
+						Name:        "operator_onestop_id",
+						In:          "query",
+						Description: `Include GTFS Realtime feeds associated with operators with these Onestop IDs, as a comma separated string`,
+						Schema:      newSRVal("string", "", nil),
+						Extensions:  newExt("", "operator_onestop_id=o-9q9-bayarearapidtransit", ""),
+					}},
+					&pref{Value: &param{
+						Name:        "route_onestop_id",
+						In:          "query",
+						Description: `Include entities that reference routes with these Onestop IDs, or their trips, as a comma separated string`,
+						Schema:      newSRVal("string", "", nil),
+						Extensions:  newExt("", "route_onestop_id=r-9q9j-l1", ""),
+					}},
+					newPRefExt("bboxParam", "Include entities that reference stops, or vehicles positioned, within this bounding box", "bbox=-122.269,37.807,-122.267,37.808", ""),
+				},
+				Responses: oa.NewResponses(
+					oa.WithStatus(200, &oa.ResponseRef{
+						Value: &oa.Response{
+							Description: toPtr("Success"),
+							Content: oa.Content{
+								"application/json": &oa.MediaType{
+									Schema: newSRVal("object", "", nil),
+								},
+								"application/octet-stream": &oa.MediaType{
+									Schema: newSRVal("string", "binary", nil),
+								},
+							},
+						},
+					}),
+					oa.WithStatus(400, &oa.ResponseRef{
+						Value: &oa.Response{
+							Description: toPtr("Bad request - invalid parameters"),
+						},
+					}),
+					oa.WithStatus(401, &oa.ResponseRef{
+						Value: &oa.Response{
+							Description: toPtr("Not authorized - feed redistribution not allowed"),
+						},
+					}),
+				),
+			},
+		},
+	}
+}
+
+// Query returns a GraphQL query string and variables.
+func (r RealtimeRequest) Query(ctx context.Context) (string, map[string]interface{}) {
+	return "", nil
+}
+
+var realtimeUrlTypes = map[string]model.FeedSourceURLTypes{
+	"trip_updates":      model.FeedSourceURLTypesRealtimeTripUpdates,
+	"vehicle_positions": model.FeedSourceURLTypesRealtimeVehiclePositions,
+	"alerts":            model.FeedSourceURLTypesRealtimeAlerts,
+}
+
+// realtimeHandler merges the current messages from all matching GTFS Realtime feeds
+func realtimeHandler(graphqlHandler http.Handler, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	urlType, ok := realtimeUrlTypes[chi.URLParam(r, "rt_type")]
+	if !ok {
+		util.WriteJsonError(w, "invalid rt_type", http.StatusBadRequest)
+		return
+	}
+	format := chi.URLParam(r, "format")
+	if format != "json" && format != "pb" {
+		util.WriteJsonError(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+	req := RealtimeRequest{
+		FeedOnestopID:     r.URL.Query().Get("feed_onestop_id"),
+		OperatorOnestopID: r.URL.Query().Get("operator_onestop_id"),
+		RouteOnestopID:    r.URL.Query().Get("route_onestop_id"),
+	}
+	if v := r.URL.Query().Get("bbox"); v != "" {
+		req.Bbox = &restBbox{}
+		if err := req.Bbox.UnmarshalText([]byte(v)); err != nil {
+			util.WriteJsonError(w, "invalid bbox", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Find feeds
+	feeds, notAllowed, err := realtimeFeeds(ctx, req, urlType)
+	if err != nil {
+		log.For(ctx).Error().Err(err).Msg("realtime: failed to find feeds")
+		util.WriteJsonError(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	if notAllowed {
+		util.WriteJsonError(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Build filter
+	filter, err := newRealtimeFilter(ctx, req, feeds)
+	if err != nil {
+		log.For(ctx).Error().Err(err).Msg("realtime: failed to prepare filter")
+		util.WriteJsonError(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Merge messages
+	rtf := model.ForContext(ctx).RTFinder
+	msg := newRealtimeMessage()
+	for _, feed := range feeds {
+		rtMsg, ok := rtf.GetMessage(ctx, feed, string(urlType))
+		if !ok || rtMsg == nil {
+			continue
+		}
+		if ts := rtMsg.GetHeader().GetTimestamp(); ts > msg.Header.GetTimestamp() {
+			msg.Header.Timestamp = proto.Uint64(ts)
+		}
+		for _, ent := range rtMsg.Entity {
+			if !filter.Match(feed, ent) {
+				continue
+			}
+			ent = proto.Clone(ent).(*pb.FeedEntity)
+			ent.Id = proto.String(fmt.Sprintf("%s:%s", feed, ent.GetId()))
+			msg.Entity = append(msg.Entity, ent)
+		}
+	}
+
+	var data []byte
+	var marshalErr error
+	switch format {
+	case "json":
+		data, marshalErr = protojson.Marshal(msg)
+		w.Header().Add("Content-Type", "application/json")
+	default:
+		data, marshalErr = proto.Marshal(msg)
+		w.Header().Add("Content-Type", "application/octet-stream")
+	}
+	if marshalErr != nil {
+		util.WriteJsonError(w, "error processing result", http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+func newRealtimeMessage() *pb.FeedMessage {
+	incr := pb.FeedHeader_FULL_DATASET
+	return &pb.FeedMessage{
+		Header: &pb.FeedHeader{
+			GtfsRealtimeVersion: proto.String("2.0"),
+			Incrementality:      &incr,
+			Timestamp:           proto.Uint64(0),
+		},
+		Entity: []*pb.FeedEntity{},
+	}
+}
+
+// realtimeFeeds returns the sorted Onestop IDs of feeds that provide this url type and match the request.
+// Feeds that do not allow redistribution are skipped; notAllowed is true if any of these were requested by Onestop ID.
+func realtimeFeeds(ctx context.Context, req RealtimeRequest, urlType model.FeedSourceURLTypes) ([]string, bool, error) {
+	cfg := model.ForContext(ctx)
+	ents, err := cfg.Finder.FindFeeds(ctx, nil, nil, nil, &model.FeedFilter{SourceURL: &model.FeedSourceURL{Type: &urlType}})
+	if err != nil {
+		return nil, false, err
+	}
+	requested := map[string]bool{}
+	for _, osid := range commaSplit(req.FeedOnestopID) {
+		requested[osid] = true
+	}
+	operatorFeeds := map[string]bool{}
+	if osids := commaSplit(req.OperatorOnestopID); len(osids) > 0 {
+		groups, err := cfg.Finder.FeedsByOperatorOnestopIDs(ctx, nil, nil, osids)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, group := range groups {
+			for _, ent := range group {
+				operatorFeeds[ent.FeedID] = true
+			}
+		}
+	}
+	var feeds []string
+	notAllowed := false
+	for _, ent := range ents {
+		if len(requested) > 0 && !requested[ent.FeedID] {
+			continue
+		}
+		if req.OperatorOnestopID != "" && !operatorFeeds[ent.FeedID] {
+			continue
+		}
+		if ent.License.RedistributionAllowed == "no" {
+			if requested[ent.FeedID] {
+				notAllowed = true
+			}
+			continue
+		}
+		feeds = append(feeds, ent.FeedID)
+	}
+	sort.Strings(feeds)
+	return feeds, notAllowed, nil
+}
+
+// realtimeFilter matches realtime entities against static routes, trips and stops.
+// Static entities are associated with a realtime feed through its active feed versions.
+type realtimeFilter struct {
+	bbox   *model.BoundingBox
+	routes map[string]map[string]bool // feed: route_id
+	trips  map[string]map[string]bool // feed: trip_id
+	stops  map[string]map[string]bool // feed: stop_id
+}
+
+func newRealtimeFilter(ctx context.Context, req RealtimeRequest, feeds []string) (*realtimeFilter, error) {
+	cfg := model.ForContext(ctx)
+	filter := &realtimeFilter{}
+	if req.RouteOnestopID == "" && req.Bbox == nil {
+		return filter, nil
+	}
+
+	// Get the feed versions for each realtime feed
+	feedFvids := map[string][]int{}
+	fvidSet := map[int]bool{}
+	var fvids []int
+	for _, feed := range feeds {
+		a, _ := cfg.RTFinder.GetRTFeedFeedVersions(ctx, feed)
+		feedFvids[feed] = a
+		for _, fvid := range a {
+			if !fvidSet[fvid] {
+				fvidSet[fvid] = true
+				fvids = append(fvids, fvid)
+			}
+		}
+	}
+
+	// Routes and their trips
+	if osids := commaSplit(req.RouteOnestopID); len(osids) > 0 {
+		routes, err := cfg.Finder.FindRoutes(ctx, nil, nil, nil, &model.RouteFilter{OnestopIds: osids})
+		if err != nil {
+			return nil, err
+		}
+		fvRoutes := map[int][]string{}
+		var keys []model.FVPair
+		for _, route := range routes {
+			fvRoutes[route.FeedVersionID] = append(fvRoutes[route.FeedVersionID], route.RouteID.Val)
+			keys = append(keys, model.FVPair{FeedVersionID: route.FeedVersionID, EntityID: route.ID})
+		}
+		fvTrips := map[int][]string{}
+		if len(keys) > 0 {
+			groups, err := cfg.Finder.TripsByRouteIDs(ctx, nil, nil, keys)
+			if err != nil {
+				return nil, err
+			}
+			for _, group := range groups {
+				for _, trip := range group {
+					fvTrips[trip.FeedVersionID] = append(fvTrips[trip.FeedVersionID], trip.TripID.Val)
+				}
+			}
+		}
+		filter.routes = groupByFeed(feedFvids, fvRoutes)
+		filter.trips = groupByFeed(feedFvids, fvTrips)
+	}
+
+	// Stops within bbox
+	if req.Bbox != nil {
+		filter.bbox = &req.Bbox.BoundingBox
+		fvStops := map[int][]string{}
+		if len(fvids) > 0 {
+			groups, err := cfg.Finder.StopsByFeedVersionIDs(ctx, nil, &model.StopFilter{Bbox: filter.bbox}, fvids)
+			if err != nil {
+				return nil, err
+			}
+			for _, group := range groups {
+				for _, stop := range group {
+					fvStops[stop.FeedVersionID] = append(fvStops[stop.FeedVersionID], stop.StopID.Val)
+				}
+			}
+		}
+		filter.stops = groupByFeed(feedFvids, fvStops)
+	}
+	return filter, nil
+}
+
+func groupByFeed(feedFvids map[string][]int, fvValues map[int][]string) map[string]map[string]bool {
+	ret := map[string]map[string]bool{}
+	for feed, fvids := range feedFvids {
+		ret[feed] = map[string]bool{}
+		for _, fvid := range fvids {
+			for _, v := range fvValues[fvid] {
+				ret[feed][v] = true
+			}
+		}
+	}
+	return ret
+}
+
+// Match checks if an entity passes the route and geographic filters
+func (f *realtimeFilter) Match(feed string, ent *pb.FeedEntity) bool {
+	if f.routes != nil && !f.matchRoute(feed, ent) {
+		return false
+	}
+	if f.bbox != nil && !f.matchBbox(feed, ent) {
+		return false
+	}
+	return true
+}
+
+func (f *realtimeFilter) matchRoute(feed string, ent *pb.FeedEntity) bool {
+	checkTrip := func(td *pb.TripDescriptor) bool {
+		if td == nil {
+			return false
+		}
+		if td.RouteId != nil && f.routes[feed][td.GetRouteId()] {
+			return true
+		}
+		return td.TripId != nil && f.trips[feed][td.GetTripId()]
+	}
+	if tu := ent.TripUpdate; tu != nil {
+		return checkTrip(tu.Trip)
+	}
+	if vp := ent.Vehicle; vp != nil {
+		return checkTrip(vp.Trip)
+	}
+	if alert := ent.Alert; alert != nil {
+		for _, sel := range alert.InformedEntity {
+			if sel.RouteId != nil && f.routes[feed][sel.GetRouteId()] {
+				return true
+			}
+			if checkTrip(sel.Trip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *realtimeFilter) matchBbox(feed string, ent *pb.FeedEntity) bool {
+	if tu := ent.TripUpdate; tu != nil {
+		for _, stu := range tu.StopTimeUpdate {
+			if stu.StopId != nil && f.stops[feed][stu.GetStopId()] {
+				return true
+			}
+		}
+		return false
+	}
+	if vp := ent.Vehicle; vp != nil {
+		if pos := vp.Position; pos != nil {
+			lon, lat := float64(pos.GetLongitude()), float64(pos.GetLatitude())
+			return lon >= f.bbox.MinLon && lon <= f.bbox.MaxLon && lat >= f.bbox.MinLat && lat <= f.bbox.MaxLat
+		}
+		return vp.StopId != nil && f.stops[feed][vp.GetStopId()]
+	}
+	if alert := ent.Alert; alert != nil {
+		for _, sel := range alert.InformedEntity {
+			if sel.StopId != nil && f.stops[feed][sel.GetStopId()] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/interline-io/transitland-lib/rt/pb"
+	"github.com/interline-io/transitland-server/internal/testconfig"
+	"github.com/interline-io/transitland-server/server/auth/mw/usercheck"
+	"github.com/interline-io/transitland-server/server/model"
+	"github.com/interline-io/transitland-server/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRealtimeRequest(t *testing.T) {
+	_, restSrv, _ := testHandlersWithOptions(t, testconfig.Options{
+		Storage: testdata.Path("server", "tmp"),
+		RTJsons: []testconfig.RTJsonFile{
+			{Feed: "BA~rt", Ftype: "realtime_trip_updates", Fname: "BA.json"},
+			{Feed: "CT~rt", Ftype: "realtime_trip_updates", Fname: "CT.json"},
+			{Feed: "CT~rt", Ftype: "realtime_vehicle_positions", Fname: "CT-vehicle-positions.json"},
+		},
+	})
+	getMsg := func(t *testing.T, url string, expectCode int) *pb.FeedMessage {
+		req, _ := http.NewRequest("GET", url, nil)
+		rr := httptest.NewRecorder()
+		usercheck.AdminDefaultMiddleware("test")(restSrv).ServeHTTP(rr, req)
+		if !assert.Equal(t, expectCode, rr.Result().StatusCode, "status code") || expectCode != 200 {
+			return nil
+		}
+		msg := pb.FeedMessage{}
+		if err := proto.Unmarshal(rr.Body.Bytes(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		return &msg
+	}
+	entityIds := func(msg *pb.FeedMessage) []string {
+		var ret []string
+		for _, ent := range msg.Entity {
+			ret = append(ret, ent.GetId())
+		}
+		return ret
+	}
+	t.Run("all feeds", func(t *testing.T) {
+		msg := getMsg(t, "/realtime/trip_updates.pb", 200)
+		if msg == nil {
+			return
+		}
+		assert.Equal(t, 48+9, len(msg.Entity))
+		assert.Contains(t, entityIds(msg), "BA~rt:1011630WKDY")
+		assert.Contains(t, entityIds(msg), "CT~rt:261")
+		assert.Equal(t, uint64(1527719550), msg.GetHeader().GetTimestamp())
+	})
+	t.Run("feed_onestop_id", func(t *testing.T) {
+		msg := getMsg(t, "/realtime/trip_updates.pb?feed_onestop_id=CT~rt", 200)
+		if msg == nil {
+			return
+		}
+		assert.Equal(t, 9, len(msg.Entity))
+	})
+	t.Run("operator_onestop_id", func(t *testing.T) {
+		msg := getMsg(t, "/realtime/trip_updates.pb?operator_onestop_id=o-9q9-caltrain", 200)
+		if msg == nil {
+			return
+		}
+		assert.Equal(t, 9, len(msg.Entity))
+	})
+	t.Run("route_onestop_id", func(t *testing.T) {
+		msg := getMsg(t, "/realtime/trip_updates.pb?route_onestop_id=r-9q9j-bullet", 200)
+		if msg == nil {
+			return
+		}
+		assert.ElementsMatch(t, []string{"CT~rt:365", "CT~rt:371", "CT~rt:375"}, entityIds(msg))
+	})
+	t.Run("bbox", func(t *testing.T) {
+		msg := getMsg(t, "/realtime/trip_updates.pb?bbox=-121.89,37.30,-121.87,37.32", 200)
+		if msg == nil {
+			return
+		}
+		assert.ElementsMatch(t, []string{"CT~rt:261", "CT~rt:269"}, entityIds(msg))
+	})
+	t.Run("vehicle_positions bbox", func(t *testing.T) {
+		msg := getMsg(t, "/realtime/vehicle_positions.pb?bbox=-121.91,37.32,-121.90,37.33", 200)
+		if msg == nil {
+			return
+		}
+		assert.ElementsMatch(t, []string{"CT~rt:v305"}, entityIds(msg))
+	})
+	t.Run("json", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/realtime/trip_updates.json?feed_onestop_id=CT~rt", nil)
+		rr := httptest.NewRecorder()
+		usercheck.AdminDefaultMiddleware("test")(restSrv).ServeHTTP(rr, req)
+		assert.Equal(t, 200, rr.Result().StatusCode, "status code")
+		assert.Equal(t, "application/json", rr.Header().Get("content-type"), "content-type")
+	})
+	t.Run("no data", func(t *testing.T) {
+		msg := getMsg(t, "/realtime/alerts.pb", 200)
+		if msg == nil {
+			return
+		}
+		assert.Equal(t, 0, len(msg.Entity))
+	})
+	t.Run("invalid rt_type", func(t *testing.T) {
+		getMsg(t, "/realtime/asd.pb", 400)
+	})
+	t.Run("invalid format", func(t *testing.T) {
+		getMsg(t, "/realtime/trip_updates.geojson", 400)
+	})
+	t.Run("invalid bbox", func(t *testing.T) {
+		getMsg(t, "/realtime/trip_updates.pb?bbox=1,2,3", 400)
+	})
+}
+
+func TestRealtimeFilter(t *testing.T) {
+	tripUpdate := func(tripID string, routeID string, stopIDs ...string) *pb.FeedEntity {
+		tu := &pb.TripUpdate{Trip: &pb.TripDescriptor{TripId: proto.String(tripID)}}
+		if routeID != "" {
+			tu.Trip.RouteId = proto.String(routeID)
+		}
+		for _, stopID := range stopIDs {
+			tu.StopTimeUpdate = append(tu.StopTimeUpdate, &pb.TripUpdate_StopTimeUpdate{StopId: proto.String(stopID)})
+		}
+		return &pb.FeedEntity{Id: proto.String(tripID), TripUpdate: tu}
+	}
+	filter := &realtimeFilter{
+		bbox:   &model.BoundingBox{MinLon: -122.0, MinLat: 37.0, MaxLon: -121.0, MaxLat: 38.0},
+		routes: map[string]map[string]bool{"a": {"r1": true}},
+		trips:  map[string]map[string]bool{"a": {"t1": true, "t2": true}},
+		stops:  map[string]map[string]bool{"a": {"s1": true}},
+	}
+	tcs := []struct {
+		name   string
+		feed   string
+		ent    *pb.FeedEntity
+		expect bool
+	}{
+		{"trip update route_id", "a", tripUpdate("x", "r1", "s1"), true},
+		{"trip update trip_id", "a", tripUpdate("t1", "", "s1"), true},
+		{"trip update wrong route", "a", tripUpdate("x", "r2", "s1"), false},
+		{"trip update outside bbox", "a", tripUpdate("t2", "", "s2"), false},
+		{"trip update other feed", "b", tripUpdate("t1", "r1", "s1"), false},
+		{
+			"vehicle position inside bbox", "a",
+			&pb.FeedEntity{Vehicle: &pb.VehiclePosition{Trip: &pb.TripDescriptor{TripId: proto.String("t1")}, Position: &pb.Position{Longitude: proto.Float32(-121.5), Latitude: proto.Float32(37.5)}}},
+			true,
+		},
+		{
+			"vehicle position outside bbox", "a",
+			&pb.FeedEntity{Vehicle: &pb.VehiclePosition{Trip: &pb.TripDescriptor{TripId: proto.String("t1")}, Position: &pb.Position{Longitude: proto.Float32(-120.5), Latitude: proto.Float32(37.5)}}},
+			false,
+		},
+		{
+			"alert route and stop", "a",
+			&pb.FeedEntity{Alert: &pb.Alert{InformedEntity: []*pb.EntitySelector{{RouteId: proto.String("r1")}, {StopId: proto.String("s1")}}}},
+			true,
+		},
+		{
+			"alert route only", "a",
+			&pb.FeedEntity{Alert: &pb.Alert{InformedEntity: []*pb.EntitySelector{{RouteId: proto.String("r1")}}}},
+			false,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, filter.Match(tc.feed, tc.ent))
+		})
+	}
+	t.Run("no filters", func(t *testing.T) {
+		assert.True(t, (&realtimeFilter{}).Match("a", tripUpdate("x", "")))
+	})
+}
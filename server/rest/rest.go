@@ -43,6 +43,7 @@ func NewServer(graphqlHandler http.Handler) (http.Handler, error) {
 	stopHandler := makeHandler(graphqlHandler, "stops", func() apiHandler { return &StopRequest{} })
 	stopDepartureHandler := makeHandler(graphqlHandler, "stopDepartures", func() apiHandler { return &StopDepartureRequest{} })
 	operatorHandler := makeHandler(graphqlHandler, "operators", func() apiHandler { return &OperatorRequest{} })
+	alertHandler := makeHandler(graphqlHandler, "alerts", func() apiHandler { return &AlertRequest{} })
 
 	// Redirect root to OpenAPI documentation
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -75,6 +76,7 @@ func NewServer(graphqlHandler http.Handler) (http.Handler, error) {
 	r.Handle("/feeds/{feed_key}/download_latest_feed_version", usercheck.RoleRequired("tl_download_fv_current")(makeHandlerFunc(graphqlHandler, "feedVersionDownloadLatest", feedVersionDownloadLatestHandler)))
 
 	r.Handle("/feeds/{feed_key}/download_latest_rt/{rt_type}.{format}", makeHandlerFunc(graphqlHandler, "feedDownloadRtHelper", feedDownloadRtHelper))
+	r.Handle("/realtime/{rt_type}.{format}", makeHandlerFunc(graphqlHandler, "realtime", realtimeHandler))
 
 	r.HandleFunc("/feed_versions.{format}", feedVersionHandler)
 	r.HandleFunc("/feed_versions", feedVersionHandler)
@@ -112,6 +114,9 @@ func NewServer(graphqlHandler http.Handler) (http.Handler, error) {
 	r.HandleFunc("/operators/{operator_key}.{format}", operatorHandler)
 	r.HandleFunc("/operators/{operator_key}", operatorHandler)
 
+	r.HandleFunc("/alerts.{format}", alertHandler)
+	r.HandleFunc("/alerts", alertHandler)
+
 	// OnestopID generic handler
 	r.Handle("/onestop_id/{onestop_id}", &OnestopIdEntityRedirectRequest{})
 
@@ -27,6 +27,7 @@ var RestHandlersList = []RestHandlers{
 	&TripRequest{},          // /routes/{route_key}/trips
 	&StopRequest{},          // /stops
 	&StopDepartureRequest{}, // /stops/{stop_key}/departures
+	&AlertRequest{},         // /alerts
 
 	// Individual resource endpoints (for direct lookups)
 	&FeedKeyRequest{},        // /feeds/{feed_key}
@@ -41,6 +42,7 @@ var RestHandlersList = []RestHandlers{
 	&FeedDownloadLatestFeedVersionRequest{}, // /feeds/{feed_key}/download_latest_feed_version
 	&FeedVersionDownloadRequest{},           // /feed_versions/{feed_version_key}/download
 	&FeedDownloadRtRequest{},                // /feeds/{feed_key}/download_latest_rt/{rt_type}.{format}
+	&RealtimeRequest{},                      // /realtime/{rt_type}.{format}
 	&OnestopIdEntityRedirectRequest{},       // /onestop_id/{onestop_id} - redirect to entity by Onestop ID
 }
 
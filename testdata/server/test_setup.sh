@@ -37,6 +37,9 @@ tlserver import --dburl="$TL_TEST_SERVER_DATABASE_URL" --storage="$TL_TEST_STORA
 # sync again
 tlserver sync --dburl="$TL_TEST_SERVER_DATABASE_URL" testdata/server/server-test.dmfr.json
 
+# server tables
+psql $TL_TEST_SERVER_DATABASE_URL -f schema/postgres/gbfs_snapshots.pgsql
+
 # supplemental data
 psql $TL_TEST_SERVER_DATABASE_URL -f testdata/server/test_supplement.pgsql
 